@@ -0,0 +1,503 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path"
+	"sync"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+const defaultAllChangesConcurrency = 4
+
+// AllChangesOptions configures Client.AllChanges. The zero value follows
+// every database, starting from the beginning of each database's changes
+// feed, and keeping checkpoints in memory.
+type AllChangesOptions struct {
+	// Pattern restricts the databases followed to those whose names match
+	// the shell pattern, as understood by path.Match. For example "user-*".
+	Pattern string
+	// Concurrency is the maximum number of databases whose changes are read
+	// at once. The default is 4.
+	Concurrency int
+	// BatchSize is the maximum number of changes read from a database per
+	// request. The default is 100.
+	BatchSize int
+	// Checkpoints returns the CheckpointStore for the named database. If nil,
+	// checkpoints are kept in memory, for the life of the AllChanges feed. To
+	// persist checkpoints in each database, use:
+	//
+	//	func(dbName string) kivik.CheckpointStore {
+	//		return kivik.LocalCheckpointStore(client.DB(dbName), "my-aggregator")
+	//	}
+	Checkpoints func(dbName string) CheckpointStore
+	// ChangesOptions are passed to DB.Changes for each database, and may be
+	// used to include documents, or to filter the feed. The feed, since and
+	// limit options are set by AllChanges.
+	ChangesOptions Options
+	// UpdatesOptions are passed to Client.DBUpdates.
+	UpdatesOptions Options
+}
+
+// AllChanges is an iterator over the document changes in all databases on a
+// server, as returned by Client.AllChanges.
+//
+// A database's checkpoint only advances once the changes read from it have
+// been consumed, which is to say, once Next has been called again after the
+// last of them. Delivery is therefore at-least-once.
+type AllChanges struct {
+	client  *Client
+	opts    AllChangesOptions
+	updates *DBUpdates
+	ctx     context.Context
+	cancel  context.CancelFunc
+	out     chan *dbChange
+	queue   chan string
+	workers sync.WaitGroup
+	done    chan struct{}
+
+	mu     sync.Mutex
+	dbs    map[string]*dbFollowState
+	stores map[string]CheckpointStore
+	closed bool
+	err    error
+	cur    *dbChange
+}
+
+// dbFollowState tracks the scheduling of a single database.
+type dbFollowState struct {
+	queued  bool // waiting for a worker
+	running bool // being read by a worker
+	dirty   bool // updated while running, so must be read again
+	reset   bool // created or deleted, so the checkpoint must be ignored
+}
+
+type dbChange struct {
+	dbName string
+	driver.Change
+	ack func()
+}
+
+// AllChanges returns a single feed of the document changes in every
+// database on the server, or in every database matching opts.Pattern.
+//
+// Databases which already exist are read from their checkpoints first. After
+// that, AllChanges follows Client.DBUpdates, and reads the changes of each
+// database as it is reported created or updated. A database which is
+// re-created after being deleted is read from the beginning.
+//
+// At most opts.Concurrency databases are read at once, and reading stops
+// while changes are waiting to be consumed, so a slow consumer does not cause
+// changes to accumulate in memory.
+//
+// The feed ends when the DBUpdates feed ends, or when an error occurs. A
+// database which is deleted while being read is skipped, but any other error
+// ends the feed, and is returned by Err.
+func (c *Client) AllChanges(ctx context.Context, opts AllChangesOptions) (*AllChanges, error) {
+	if opts.Pattern != "" {
+		if _, err := path.Match(opts.Pattern, ""); err != nil {
+			return nil, &Error{HTTPStatus: http.StatusBadRequest, Message: "kivik: invalid database pattern", Err: err}
+		}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	updates, err := c.DBUpdates(ctx, Options{"feed": "continuous", "since": "now"}, opts.UpdatesOptions)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// The list of databases is read after subscribing to updates, so that no
+	// database created in between is missed.
+	dbNames, err := c.AllDBs(ctx)
+	if err != nil {
+		_ = updates.Close()
+		cancel()
+		return nil, err
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultAllChangesConcurrency
+	}
+	a := &AllChanges{
+		client:  c,
+		opts:    opts,
+		updates: updates,
+		ctx:     ctx,
+		cancel:  cancel,
+		out:     make(chan *dbChange),
+		queue:   make(chan string),
+		done:    make(chan struct{}),
+		dbs:     make(map[string]*dbFollowState),
+		stores:  make(map[string]CheckpointStore),
+	}
+	a.workers.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go a.worker()
+	}
+	go a.run(dbNames)
+	return a, nil
+}
+
+func (a *AllChanges) match(dbName string) bool {
+	if a.opts.Pattern == "" {
+		return true
+	}
+	ok, _ := path.Match(a.opts.Pattern, dbName)
+	return ok
+}
+
+// run schedules databases for reading, first from the initial list, and then
+// as they are reported by the DBUpdates feed.
+func (a *AllChanges) run(dbNames []string) {
+	defer func() {
+		close(a.queue)
+		a.workers.Wait()
+		close(a.out)
+		close(a.done)
+	}()
+	for _, dbName := range dbNames {
+		if a.match(dbName) && !a.schedule(dbName, false) {
+			return
+		}
+	}
+	for a.updates.Next() {
+		dbName := a.updates.DBName()
+		if !a.match(dbName) {
+			continue
+		}
+		switch a.updates.Type() {
+		case "deleted":
+			a.mu.Lock()
+			a.state(dbName).reset = true
+			a.mu.Unlock()
+		case "created":
+			if !a.schedule(dbName, true) {
+				return
+			}
+		default:
+			if !a.schedule(dbName, false) {
+				return
+			}
+		}
+	}
+	a.setErr(a.updates.Err())
+}
+
+// state returns the scheduling state of dbName. The caller must hold a.mu.
+func (a *AllChanges) state(dbName string) *dbFollowState {
+	st, ok := a.dbs[dbName]
+	if !ok {
+		st = &dbFollowState{}
+		a.dbs[dbName] = st
+	}
+	return st
+}
+
+// schedule queues dbName to be read, unless it is already queued or being
+// read. It returns false if the feed has been stopped.
+func (a *AllChanges) schedule(dbName string, reset bool) bool {
+	a.mu.Lock()
+	st := a.state(dbName)
+	if reset {
+		st.reset = true
+	}
+	if st.running {
+		st.dirty = true
+	}
+	if st.queued || st.running {
+		a.mu.Unlock()
+		return true
+	}
+	st.queued = true
+	a.mu.Unlock()
+	select {
+	case a.queue <- dbName:
+		return true
+	case <-a.ctx.Done():
+		return false
+	}
+}
+
+func (a *AllChanges) worker() {
+	defer a.workers.Done()
+	for dbName := range a.queue {
+		a.mu.Lock()
+		st := a.state(dbName)
+		st.queued, st.running = false, true
+		for {
+			reset := st.reset
+			st.reset, st.dirty = false, false
+			a.mu.Unlock()
+			err := a.follow(dbName, reset)
+			if StatusCode(err) == http.StatusNotFound {
+				err = nil
+			}
+			if err != nil {
+				a.setErr(err)
+				return
+			}
+			a.mu.Lock()
+			if !st.dirty {
+				break
+			}
+		}
+		st.running = false
+		a.mu.Unlock()
+	}
+}
+
+func (a *AllChanges) store(dbName string) CheckpointStore {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if store, ok := a.stores[dbName]; ok {
+		return store
+	}
+	var store CheckpointStore
+	if a.opts.Checkpoints != nil {
+		store = a.opts.Checkpoints(dbName)
+	} else {
+		store = &memoryCheckpointStore{}
+	}
+	a.stores[dbName] = store
+	return store
+}
+
+// follow reads the changes of dbName from its checkpoint to the end of its
+// feed, and delivers them. Each batch is checkpointed once all of its changes
+// have been consumed.
+func (a *AllChanges) follow(dbName string, reset bool) error {
+	db := a.client.DB(dbName)
+	if err := db.Err(); err != nil {
+		return err
+	}
+	store := a.store(dbName)
+	var since string
+	if !reset {
+		var err error
+		if since, err = store.LoadCheckpoint(a.ctx); err != nil {
+			return err
+		}
+	}
+	batchSize := a.opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultConsumerBatchSize
+	}
+	for {
+		opts := Options{"feed": "normal", "limit": batchSize}
+		if since != "" {
+			opts["since"] = since
+		}
+		changes, err := db.Changes(a.ctx, a.opts.ChangesOptions, opts)
+		if err != nil {
+			return err
+		}
+		acks := newAckCounter()
+		var count int
+		last := since
+		for changes.Next() {
+			change := &dbChange{dbName: dbName, Change: *changes.curVal.(*driver.Change), ack: acks.ack}
+			acks.add()
+			select {
+			case a.out <- change:
+			case <-a.ctx.Done():
+				_ = changes.Close()
+				return a.ctx.Err()
+			}
+			count++
+			last = change.Seq
+		}
+		if err := changes.Err(); err != nil {
+			return err
+		}
+		if seq := changes.LastSeq(); seq != "" {
+			last = seq
+		}
+		pending := changes.Pending()
+		select {
+		case <-acks.wait():
+		case <-a.ctx.Done():
+			return a.ctx.Err()
+		}
+		if last != since {
+			if err := store.SaveCheckpoint(a.ctx, last); err != nil {
+				return err
+			}
+			since = last
+		}
+		if count < batchSize && pending <= 0 {
+			return nil
+		}
+	}
+}
+
+// ackCounter counts the changes of a batch which have been delivered, but not
+// yet acknowledged.
+type ackCounter struct {
+	mu      sync.Mutex
+	pending int
+	waiting bool
+	done    chan struct{}
+}
+
+func newAckCounter() *ackCounter {
+	return &ackCounter{done: make(chan struct{})}
+}
+
+func (c *ackCounter) add() {
+	c.mu.Lock()
+	c.pending++
+	c.mu.Unlock()
+}
+
+func (c *ackCounter) ack() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending--
+	if c.waiting && c.pending == 0 {
+		close(c.done)
+	}
+}
+
+// wait returns a channel which is closed once every change has been
+// acknowledged. add must not be called after wait.
+func (c *ackCounter) wait() <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.waiting {
+		c.waiting = true
+		if c.pending == 0 {
+			close(c.done)
+		}
+	}
+	return c.done
+}
+
+// setErr records the first error to stop the feed, and stops it.
+func (a *AllChanges) setErr(err error) {
+	if err == nil {
+		return
+	}
+	a.mu.Lock()
+	if !a.closed && a.err == nil {
+		a.err = err
+	}
+	a.mu.Unlock()
+	a.cancel()
+}
+
+// Next prepares the next change for reading. It returns false when the feed
+// has ended, either due to an error, or because it was closed. Err should be
+// consulted to distinguish between the two. Calling Next acknowledges the
+// previous change.
+func (a *AllChanges) Next() bool {
+	a.mu.Lock()
+	if a.cur != nil {
+		a.cur.ack()
+		a.cur = nil
+	}
+	a.mu.Unlock()
+	change, ok := <-a.out
+	if !ok {
+		return false
+	}
+	a.mu.Lock()
+	a.cur = change
+	a.mu.Unlock()
+	return true
+}
+
+// Err returns the error, if any, which ended the feed.
+func (a *AllChanges) Err() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.err
+}
+
+// Close stops the feed, and waits for all databases to stop being read. The
+// current change, if any, is not acknowledged.
+func (a *AllChanges) Close() error {
+	a.mu.Lock()
+	a.closed = true
+	a.mu.Unlock()
+	a.cancel()
+	err := a.updates.Close()
+	<-a.done
+	return err
+}
+
+func (a *AllChanges) current() *dbChange {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.cur == nil {
+		return &dbChange{}
+	}
+	return a.cur
+}
+
+// DBName returns the name of the database of the current change.
+func (a *AllChanges) DBName() string {
+	return a.current().dbName
+}
+
+// ID returns the document ID of the current change.
+func (a *AllChanges) ID() string {
+	return a.current().ID
+}
+
+// Seq returns the update sequence of the current change, within its
+// database.
+func (a *AllChanges) Seq() string {
+	return a.current().Seq
+}
+
+// Deleted returns true if the current change relates to a deleted document.
+func (a *AllChanges) Deleted() bool {
+	return a.current().Deleted
+}
+
+// Changes returns the list of changed revisions of the current change.
+func (a *AllChanges) Changes() []string {
+	return a.current().Changes
+}
+
+// ScanDoc unmarshals the document of the current change into dest. It is only
+// valid when ChangesOptions include documents.
+func (a *AllChanges) ScanDoc(dest interface{}) error {
+	cur := a.current()
+	if cur.dbName == "" {
+		return &Error{HTTPStatus: http.StatusBadRequest, Message: "kivik: no current change"}
+	}
+	return json.Unmarshal(cur.Doc, dest)
+}
+
+// memoryCheckpointStore keeps a checkpoint in memory.
+type memoryCheckpointStore struct {
+	mu  sync.Mutex
+	seq string
+}
+
+func (s *memoryCheckpointStore) LoadCheckpoint(context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.seq, nil
+}
+
+func (s *memoryCheckpointStore) SaveCheckpoint(_ context.Context, seq string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq = seq
+	return nil
+}
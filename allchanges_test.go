@@ -0,0 +1,269 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+// allChangesFixture emulates a server with several databases, each with a
+// list of document IDs as its changes history, and a DBUpdates feed which
+// returns the updates sent on the updates channel.
+type allChangesFixture struct {
+	mu      sync.Mutex
+	dbs     map[string][]string
+	fail    map[string]error
+	touched map[string]bool
+	updates chan driver.DBUpdate
+	client  *Client
+}
+
+func newAllChangesFixture(dbs map[string][]string) *allChangesFixture {
+	f := &allChangesFixture{
+		dbs:     dbs,
+		fail:    map[string]error{},
+		touched: map[string]bool{},
+		updates: make(chan driver.DBUpdate),
+	}
+	f.client = &Client{driverClient: &mock.DBUpdater{
+		Client: &mock.Client{
+			AllDBsFunc: func(context.Context, map[string]interface{}) ([]string, error) {
+				f.mu.Lock()
+				defer f.mu.Unlock()
+				names := make([]string, 0, len(f.dbs))
+				for name := range f.dbs {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				return names, nil
+			},
+			DBFunc: func(dbName string, _ map[string]interface{}) (driver.DB, error) {
+				return &mock.DB{ChangesFunc: func(_ context.Context, opts map[string]interface{}) (driver.Changes, error) {
+					return f.changes(dbName, opts)
+				}}, nil
+			},
+		},
+		DBUpdatesFunc: func(ctx context.Context) (driver.DBUpdates, error) {
+			return &mock.DBUpdates{
+				NextFunc: func(u *driver.DBUpdate) error {
+					select {
+					case update, ok := <-f.updates:
+						if !ok {
+							return io.EOF
+						}
+						*u = update
+						return nil
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				},
+				CloseFunc: func() error { return nil },
+			}, nil
+		},
+	}}
+	return f
+}
+
+// changes returns the changes of dbName after the since option, where the
+// sequence of each change is its position in the history.
+func (f *allChangesFixture) changes(dbName string, opts map[string]interface{}) (driver.Changes, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.touched[dbName] = true
+	if err := f.fail[dbName]; err != nil {
+		return nil, err
+	}
+	history, ok := f.dbs[dbName]
+	if !ok {
+		return nil, &Error{HTTPStatus: http.StatusNotFound, Message: "missing"}
+	}
+	since, _ := opts["since"].(string)
+	start, _ := strconv.Atoi(since)
+	limit := opts["limit"].(int)
+	end := start + limit
+	if end > len(history) {
+		end = len(history)
+	}
+	changes := make([]driver.Change, 0, end-start)
+	for i := start; i < end; i++ {
+		changes = append(changes, driver.Change{ID: history[i], Seq: strconv.Itoa(i + 1)})
+	}
+	feed := changesFeed(strconv.Itoa(end), changes...)
+	feed.PendingFunc = func() int64 { return int64(len(history) - end) }
+	return feed, nil
+}
+
+func (f *allChangesFixture) set(dbName string, history ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if history == nil {
+		delete(f.dbs, dbName)
+		return
+	}
+	f.dbs[dbName] = history
+}
+
+func collect(t *testing.T, feed *AllChanges, each func(dbName, id string)) []string {
+	t.Helper()
+	var result []string
+	for feed.Next() {
+		result = append(result, feed.DBName()+"/"+feed.ID())
+		if each != nil {
+			each(feed.DBName(), feed.ID())
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func TestAllChanges(t *testing.T) {
+	t.Run("existing and updated databases", func(t *testing.T) {
+		f := newAllChangesFixture(map[string][]string{
+			"user-a": {"a1", "a2", "a3"},
+			"user-b": {"b1"},
+			"other":  {"o1"},
+		})
+		feed, err := f.client.AllChanges(context.Background(), AllChangesOptions{Pattern: "user-*", BatchSize: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			f.set("user-a", "a1", "a2", "a3", "a4")
+			f.updates <- driver.DBUpdate{DBName: "user-a", Type: "updated"}
+			f.set("user-c", "c1")
+			f.updates <- driver.DBUpdate{DBName: "user-c", Type: "created"}
+			f.set("other", "o1", "o2")
+			f.updates <- driver.DBUpdate{DBName: "other", Type: "updated"}
+			close(f.updates)
+		}()
+		result := collect(t, feed, nil)
+		if err := feed.Err(); err != nil {
+			t.Fatal(err)
+		}
+		expected := []string{"user-a/a1", "user-a/a2", "user-a/a3", "user-a/a4", "user-b/b1", "user-c/c1"}
+		if d := testy.DiffInterface(expected, result); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("recreated database", func(t *testing.T) {
+		f := newAllChangesFixture(map[string][]string{"db": {"x"}})
+		checkpoints := map[string]*memoryCheckpointStore{}
+		var mu sync.Mutex
+		feed, err := f.client.AllChanges(context.Background(), AllChangesOptions{
+			Checkpoints: func(dbName string) CheckpointStore {
+				mu.Lock()
+				defer mu.Unlock()
+				store := &memoryCheckpointStore{}
+				checkpoints[dbName] = store
+				return store
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := collect(t, feed, func(_, id string) {
+			if id != "x" {
+				close(f.updates)
+				return
+			}
+			go func() {
+				f.set("db")
+				f.updates <- driver.DBUpdate{DBName: "db", Type: "deleted"}
+				f.set("db", "y")
+				f.updates <- driver.DBUpdate{DBName: "db", Type: "created"}
+			}()
+		})
+		if d := testy.DiffInterface([]string{"db/x", "db/y"}, result); d != nil {
+			t.Error(d)
+		}
+		if seq, _ := checkpoints["db"].LoadCheckpoint(context.Background()); seq != "1" {
+			t.Errorf("Unexpected checkpoint: %s", seq)
+		}
+	})
+	t.Run("error", func(t *testing.T) {
+		f := newAllChangesFixture(map[string][]string{"a": {"a1"}, "b": {"b1"}})
+		f.fail["b"] = &Error{HTTPStatus: http.StatusInternalServerError, Message: "boom"}
+		feed, err := f.client.AllChanges(context.Background(), AllChangesOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = collect(t, feed, nil)
+		testy.StatusError(t, "boom", http.StatusInternalServerError, feed.Err())
+	})
+	t.Run("backpressure", func(t *testing.T) {
+		f := newAllChangesFixture(map[string][]string{
+			"a": {"a1"}, "b": {"b1"}, "c": {"c1"}, "d": {"d1"}, "e": {"e1"},
+		})
+		feed, err := f.client.AllChanges(context.Background(), AllChangesOptions{Concurrency: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+		f.mu.Lock()
+		touched := len(f.touched)
+		f.mu.Unlock()
+		if touched > 2 {
+			t.Errorf("Expected at most 2 databases to be read without a consumer, got %d", touched)
+		}
+		if err := feed.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := feed.Err(); err != nil {
+			t.Errorf("Unexpected error after Close: %s", err)
+		}
+	})
+	t.Run("close with unacknowledged change", func(t *testing.T) {
+		before := runtime.NumGoroutine()
+		f := newAllChangesFixture(map[string][]string{"a": {"a1"}, "b": {"b1"}})
+		feed, err := f.client.AllChanges(context.Background(), AllChangesOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !feed.Next() {
+			t.Fatal(feed.Err())
+		}
+		if err := feed.Close(); err != nil {
+			t.Fatal(err)
+		}
+		deadline := time.Now().Add(time.Second)
+		for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if n := runtime.NumGoroutine() - before; n > 0 {
+			t.Errorf("%d goroutines leaked after Close", n)
+		}
+	})
+	t.Run("invalid pattern", func(t *testing.T) {
+		f := newAllChangesFixture(nil)
+		_, err := f.client.AllChanges(context.Background(), AllChangesOptions{Pattern: "["})
+		testy.StatusError(t, "kivik: invalid database pattern: syntax error in pattern", http.StatusBadRequest, err)
+	})
+	t.Run("no DBUpdates support", func(t *testing.T) {
+		client := &Client{driverClient: &mock.Client{}}
+		_, err := client.AllChanges(context.Background(), AllChangesOptions{})
+		testy.StatusError(t, "kivik: driver does not implement DBUpdater", http.StatusNotImplemented, err)
+	})
+}
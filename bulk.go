@@ -111,10 +111,12 @@ func (db *DB) BulkDocs(ctx context.Context, docs []interface{}, options ...Optio
 	opts := mergeOptions(options...)
 	if bulkDocer, ok := db.driverDB.(driver.BulkDocer); ok {
 		bulki, err := bulkDocer.BulkDocs(ctx, docsi, opts)
-		if err != nil {
-			return nil, err
+		if !isNotImplemented(err) {
+			if err != nil {
+				return nil, err
+			}
+			return newBulkResults(ctx, bulki), nil
 		}
-		return newBulkResults(ctx, bulki), nil
 	}
 	var results []driver.BulkResult
 	for _, doc := range docsi {
@@ -312,7 +312,7 @@ func TestBulkDocs(t *testing.T) { // nolint: gocyclo
 			db := &DB{driverDB: test.dbDriver}
 			result, err := db.BulkDocs(context.Background(), test.docs, test.options)
 			testy.StatusError(t, test.err, test.status, err)
-			result.cancel = nil // Determinism
+			result.ctx, result.stop = nil, nil // Determinism
 			if d := testy.DiffInterface(test.expected, result); d != nil {
 				t.Error(d)
 			}
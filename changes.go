@@ -15,6 +15,8 @@ package kivik
 import (
 	"context"
 	"encoding/json"
+	"net/http"
+	"strconv"
 
 	"github.com/go-kivik/kivik/v4/driver"
 )
@@ -23,12 +25,15 @@ import (
 type Changes struct {
 	*iter
 	changesi driver.Changes
+	// meta caches the metadata decoded from the current document.
+	meta *changeMeta
 }
 
 // Next prepares the next result value for reading. It returns true on success
 // or false if there are no more results, due to an error or the changes feed
 // having been closed. Err should be consulted to determine any error.
 func (c *Changes) Next() bool {
+	c.meta = nil
 	return c.iter.Next()
 }
 
@@ -86,18 +91,173 @@ func (c *Changes) ScanDoc(dest interface{}) error {
 	return json.Unmarshal(c.curVal.(*driver.Change).Doc, dest)
 }
 
+// Revisions is the revision history of a document, as found in its _revisions
+// field.
+type Revisions struct {
+	// Start is the generation number of the newest revision.
+	Start int64 `json:"start"`
+	// IDs are the revision hashes, newest first.
+	IDs []string `json:"ids"`
+}
+
+// Revs returns the full revision IDs, newest first.
+func (r *Revisions) Revs() []string {
+	if r == nil {
+		return nil
+	}
+	revs := make([]string, len(r.IDs))
+	for i, id := range r.IDs {
+		revs[i] = strconv.FormatInt(r.Start-int64(i), 10) + "-" + id
+	}
+	return revs
+}
+
+// changeMeta is the subset of document fields read by the metadata accessors
+// of Changes, so that they need not decode the full document.
+type changeMeta struct {
+	Rev              string     `json:"_rev"`
+	Conflicts        []string   `json:"_conflicts"`
+	DeletedConflicts []string   `json:"_deleted_conflicts"`
+	Revisions        *Revisions `json:"_revisions"`
+}
+
+// docMeta returns the metadata of the current document, decoding it at most
+// once per result.
+func (c *Changes) docMeta() *changeMeta {
+	if c.meta == nil {
+		c.meta = &changeMeta{}
+		if doc := c.curVal.(*driver.Change).Doc; len(doc) > 0 {
+			_ = json.Unmarshal(doc, c.meta)
+		}
+	}
+	return c.meta
+}
+
+// WinningRev returns the winning revision of the changed document. If the
+// driver does not report it, it is read from the included document, or, if
+// the result lists a single changed revision, that revision is returned.
+func (c *Changes) WinningRev() string {
+	ch := c.curVal.(*driver.Change)
+	if ch.WinningRev != "" {
+		return ch.WinningRev
+	}
+	if rev := c.docMeta().Rev; rev != "" {
+		return rev
+	}
+	if len(ch.Changes) == 1 {
+		return ch.Changes[0]
+	}
+	return ""
+}
+
+// Conflicts returns the conflicting revisions of the changed document. It is
+// only populated when the conflicts option is set.
+func (c *Changes) Conflicts() []string {
+	if conflicts := c.curVal.(*driver.Change).Conflicts; conflicts != nil {
+		return conflicts
+	}
+	return c.docMeta().Conflicts
+}
+
+// DeletedConflicts returns the deleted conflicting revisions of the changed
+// document, if reported by the driver or included in the document.
+func (c *Changes) DeletedConflicts() []string {
+	if conflicts := c.curVal.(*driver.Change).DeletedConflicts; conflicts != nil {
+		return conflicts
+	}
+	return c.docMeta().DeletedConflicts
+}
+
+// Revisions returns the revision history of the changed document, if reported
+// by the driver or included in the document. It returns nil otherwise.
+func (c *Changes) Revisions() *Revisions {
+	if revs := c.curVal.(*driver.Change).Revisions; revs != nil {
+		return &Revisions{Start: revs.Start, IDs: revs.IDs}
+	}
+	return c.docMeta().Revisions
+}
+
+// RawRow returns the raw result row, as returned by the backend, useful if you
+// need additional backend-specific information. Not all drivers support this,
+// in which case it returns nil.
+func (c *Changes) RawRow() json.RawMessage {
+	return c.curVal.(*driver.Change).RawRow
+}
+
+// Change is a single result of a changes feed, detached from the iterator
+// which read it.
+type Change struct {
+	// ID is the ID of the changed document.
+	ID string
+	// Seq is the update sequence of the change.
+	Seq string
+	// Deleted is true if the change relates to a deleted document.
+	Deleted bool
+	// Changes is the list of changed revisions.
+	Changes []string
+	// Doc is the raw JSON document, when documents are included in the feed.
+	Doc json.RawMessage
+	// WinningRev is the winning revision of the document. See
+	// Changes.WinningRev.
+	WinningRev string
+	// Conflicts is the list of conflicting revisions, when requested.
+	Conflicts []string
+	// DeletedConflicts is the list of deleted conflicting revisions, when
+	// available.
+	DeletedConflicts []string
+	// Revisions is the revision history of the document, when available.
+	Revisions *Revisions
+	// RawRow is the raw result row, if supported by the driver.
+	RawRow json.RawMessage
+}
+
+// ScanDoc unmarshals the document of the change into dest. It is only valid
+// for results that include documents.
+func (c *Change) ScanDoc(dest interface{}) error {
+	return json.Unmarshal(c.Doc, dest)
+}
+
+// change returns a copy of the current result.
+func (c *Changes) change() Change {
+	ch := c.curVal.(*driver.Change)
+	return Change{
+		ID:               ch.ID,
+		Seq:              ch.Seq,
+		Deleted:          ch.Deleted,
+		Changes:          ch.Changes,
+		Doc:              ch.Doc,
+		WinningRev:       c.WinningRev(),
+		Conflicts:        c.Conflicts(),
+		DeletedConflicts: c.DeletedConflicts(),
+		Revisions:        c.Revisions(),
+		RawRow:           ch.RawRow,
+	}
+}
+
 // Changes returns an iterator over the real-time changes feed. The feed remains
 // open until explicitly closed, or an error is encountered.
 // See http://couchdb.readthedocs.io/en/latest/api/database/changes.html#get--db-_changes
+//
+// Options may include ChangesOptions, by way of its Options method. When the
+// driver does not support a requested _doc_ids, _selector or _design filter,
+// the filter is emulated on the client.
 func (db *DB) Changes(ctx context.Context, options ...Options) (*Changes, error) {
-	changesi, err := db.driverDB.Changes(ctx, mergeOptions(options...))
+	opts, err := changesOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	changesi, err := db.driverDB.Changes(ctx, opts)
+	if StatusCode(err) == http.StatusNotImplemented && opts["filter"] != nil {
+		changesi, err = db.fallbackChanges(ctx, opts, err)
+	}
 	if err != nil {
 		return nil, err
 	}
 	return newChanges(ctx, changesi), nil
 }
 
-// Seq returns the Seq of the current result.
+// Seq returns the Seq of the current result. Use ParseSeq to inspect or
+// compare sequences.
 func (c *Changes) Seq() string {
 	return c.curVal.(*driver.Change).Seq
 }
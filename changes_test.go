@@ -14,6 +14,7 @@ package kivik
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -103,7 +104,7 @@ func TestChangesIteratorNew(t *testing.T) {
 		},
 		changesi: &mock.Changes{},
 	}
-	ch.cancel = nil // determinism
+	ch.ctx, ch.stop = nil, nil // determinism
 	if d := testy.DiffInterface(expected, ch); d != nil {
 		t.Error(d)
 	}
@@ -179,6 +180,108 @@ func TestChangesGetters(t *testing.T) {
 	})
 }
 
+func TestChangesMetadata(t *testing.T) {
+	type tst struct {
+		change           driver.Change
+		winningRev       string
+		conflicts        []string
+		deletedConflicts []string
+		revisions        *Revisions
+		rawRow           json.RawMessage
+	}
+	tests := testy.NewTable()
+	tests.Add("no metadata", tst{
+		change: driver.Change{Changes: []string{"1-a", "1-b"}},
+	})
+	tests.Add("single changed rev", tst{
+		change:     driver.Change{Changes: []string{"1-a"}},
+		winningRev: "1-a",
+	})
+	tests.Add("from driver", tst{
+		change: driver.Change{
+			Changes:          []string{"2-a", "2-b"},
+			WinningRev:       "2-b",
+			Conflicts:        []string{"2-a"},
+			DeletedConflicts: []string{"2-c"},
+			Revisions:        &driver.Revisions{Start: 2, IDs: []string{"b", "x"}},
+			RawRow:           json.RawMessage(`{"id":"foo","extra":true}`),
+			Doc:              json.RawMessage(`{"_rev":"1-z","_conflicts":["1-y"]}`),
+		},
+		winningRev:       "2-b",
+		conflicts:        []string{"2-a"},
+		deletedConflicts: []string{"2-c"},
+		revisions:        &Revisions{Start: 2, IDs: []string{"b", "x"}},
+		rawRow:           json.RawMessage(`{"id":"foo","extra":true}`),
+	})
+	tests.Add("from doc", tst{
+		change: driver.Change{
+			Changes: []string{"2-a", "2-b"},
+			Doc: json.RawMessage(`{"_id":"foo","_rev":"2-b","_conflicts":["2-a"],"_deleted_conflicts":["2-c"],` +
+				`"_revisions":{"start":2,"ids":["b","x"]},"value":{"nested":[1,2,3]}}`),
+		},
+		winningRev:       "2-b",
+		conflicts:        []string{"2-a"},
+		deletedConflicts: []string{"2-c"},
+		revisions:        &Revisions{Start: 2, IDs: []string{"b", "x"}},
+	})
+	tests.Add("invalid doc", tst{
+		change: driver.Change{Changes: []string{"1-a", "1-b"}, Doc: json.RawMessage(`invalid`)},
+	})
+
+	tests.Run(t, func(t *testing.T, tt tst) {
+		changes := newChanges(context.Background(), changesFeed("", tt.change))
+		if !changes.Next() {
+			t.Fatal(changes.Err())
+		}
+		if rev := changes.WinningRev(); rev != tt.winningRev {
+			t.Errorf("Unexpected winning rev: %s", rev)
+		}
+		if d := testy.DiffInterface(tt.conflicts, changes.Conflicts()); d != nil {
+			t.Errorf("Conflicts:\n%s", d)
+		}
+		if d := testy.DiffInterface(tt.deletedConflicts, changes.DeletedConflicts()); d != nil {
+			t.Errorf("DeletedConflicts:\n%s", d)
+		}
+		if d := testy.DiffInterface(tt.revisions, changes.Revisions()); d != nil {
+			t.Errorf("Revisions:\n%s", d)
+		}
+		if d := testy.DiffInterface(tt.rawRow, changes.RawRow()); d != nil {
+			t.Errorf("RawRow:\n%s", d)
+		}
+		change := changes.change()
+		if change.WinningRev != tt.winningRev {
+			t.Errorf("Unexpected detached winning rev: %s", change.WinningRev)
+		}
+		if d := testy.DiffInterface(tt.conflicts, change.Conflicts); d != nil {
+			t.Errorf("Detached conflicts:\n%s", d)
+		}
+	})
+
+	t.Run("metadata is reset by Next", func(t *testing.T) {
+		changes := newChanges(context.Background(), changesFeed("",
+			driver.Change{Doc: json.RawMessage(`{"_rev":"1-a","_conflicts":["1-b"]}`)},
+			driver.Change{Doc: json.RawMessage(`{"_rev":"1-c"}`)},
+		))
+		var conflicts [][]string
+		for changes.Next() {
+			conflicts = append(conflicts, changes.Conflicts())
+		}
+		if d := testy.DiffInterface([][]string{{"1-b"}, nil}, conflicts); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
+func TestRevisionsRevs(t *testing.T) {
+	revs := (&Revisions{Start: 3, IDs: []string{"c", "b", "a"}}).Revs()
+	if d := testy.DiffInterface([]string{"3-c", "2-b", "1-a"}, revs); d != nil {
+		t.Error(d)
+	}
+	if revs := (*Revisions)(nil).Revs(); revs != nil {
+		t.Errorf("Unexpected revs: %v", revs)
+	}
+}
+
 func TestChangesScanDoc(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -272,7 +375,7 @@ func TestChanges(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			result, err := test.db.Changes(context.Background(), test.opts)
 			testy.StatusError(t, test.err, test.status, err)
-			result.cancel = nil // Determinism
+			result.ctx, result.stop = nil, nil // Determinism
 			if d := testy.DiffInterface(test.expected, result); d != nil {
 				t.Error(d)
 			}
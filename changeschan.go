@@ -0,0 +1,189 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ChangesChannel reads a changes feed in a goroutine, and delivers the
+// changes on a channel, singly with C, or in batches with NextBatch.
+type ChangesChannel struct {
+	changes *Changes
+	cancel  context.CancelFunc
+	ch      chan Change
+	done    chan struct{}
+
+	mu       sync.Mutex
+	closed   bool
+	err      error
+	closeErr error
+	lastSeq  string
+	pending  int64
+}
+
+// ChangesChannel opens the changes feed with the given options, as
+// DB.Changes does, and starts reading it in a goroutine. Up to buffer changes
+// are read ahead of the consumer.
+//
+// The feed is closed when it ends, when ctx is cancelled, or when Close is
+// called. In each case the channel returned by C is closed once the feed has
+// been closed, after which Err reports any error which ended the feed.
+func (db *DB) ChangesChannel(ctx context.Context, buffer int, options ...Options) (*ChangesChannel, error) {
+	if buffer < 0 {
+		buffer = 0
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	changes, err := db.Changes(ctx, options...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	c := &ChangesChannel{
+		changes: changes,
+		cancel:  cancel,
+		ch:      make(chan Change, buffer),
+		done:    make(chan struct{}),
+	}
+	go c.run(ctx)
+	return c, nil
+}
+
+func (c *ChangesChannel) run(ctx context.Context) {
+	var err error
+loop:
+	for c.changes.Next() {
+		select {
+		case c.ch <- c.changes.change():
+		case <-ctx.Done():
+			err = ctx.Err()
+			break loop
+		}
+	}
+	_ = c.changes.Close()
+	closeErr := c.changes.feedCloseErr()
+	if err == nil {
+		err = c.changes.Err()
+	}
+	if err == nil {
+		err = closeErr
+	}
+	c.mu.Lock()
+	if !c.closed || !errors.Is(err, context.Canceled) {
+		// The cancellation caused by Close does not count.
+		c.err = err
+	}
+	c.closeErr = closeErr
+	c.lastSeq = c.changes.LastSeq()
+	c.pending = c.changes.Pending()
+	c.mu.Unlock()
+	close(c.ch)
+	close(c.done)
+	c.cancel()
+}
+
+// C returns the channel on which changes are delivered. It is closed when the
+// feed ends.
+func (c *ChangesChannel) C() <-chan Change {
+	return c.ch
+}
+
+// NextBatch returns the next batch of up to max changes. It blocks until at
+// least one change is available, and then waits up to maxWait for the batch
+// to fill, before returning whatever has been received. A maxWait of zero
+// returns only the changes already read. A max less than 1 is treated as 1.
+// NextBatch returns an empty batch only once the feed has ended, after which
+// Err should be consulted.
+func (c *ChangesChannel) NextBatch(max int, maxWait time.Duration) []Change {
+	if max < 1 {
+		max = 1
+	}
+	first, ok := <-c.ch
+	if !ok {
+		return nil
+	}
+	batch := []Change{first}
+	var timeout <-chan time.Time
+	if maxWait > 0 {
+		timer := time.NewTimer(maxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	for len(batch) < max {
+		if timeout == nil {
+			select {
+			case change, ok := <-c.ch:
+				if !ok {
+					return batch
+				}
+				batch = append(batch, change)
+				continue
+			default:
+				return batch
+			}
+		}
+		select {
+		case change, ok := <-c.ch:
+			if !ok {
+				return batch
+			}
+			batch = append(batch, change)
+		case <-timeout:
+			return batch
+		}
+	}
+	return batch
+}
+
+// Err returns the error, if any, which ended the feed. As with the Changes
+// iterator, it may be called after an explicit or implicit Close, and is not
+// affected by Close.
+func (c *ChangesChannel) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// LastSeq returns the last update sequence reported by the feed. It is only
+// set once the feed has ended.
+func (c *ChangesChannel) LastSeq() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastSeq
+}
+
+// Pending returns the number of changes remaining, as reported by the feed.
+// It is only set once the feed has ended.
+func (c *ChangesChannel) Pending() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pending
+}
+
+// Close stops reading the feed, and closes it, waiting until the underlying
+// Changes iterator has been closed. It returns the error, if any, from closing
+// the underlying feed. Changes already buffered remain available from C.
+// Close is idempotent.
+func (c *ChangesChannel) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+	c.cancel()
+	<-c.done
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closeErr
+}
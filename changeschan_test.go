@@ -0,0 +1,207 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+// liveChangesFeed returns a mock changes feed which delivers the changes sent
+// on ch, and blocks until ctx is done once they are exhausted. Closing ch
+// ends the feed.
+func liveChangesFeed(ctx context.Context, ch <-chan driver.Change) *mock.Changes {
+	return &mock.Changes{
+		NextFunc: func(c *driver.Change) error {
+			select {
+			case change, ok := <-ch:
+				if !ok {
+					return io.EOF
+				}
+				*c = change
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+		CloseFunc:   func() error { return nil },
+		LastSeqFunc: func() string { return "9" },
+		PendingFunc: func() int64 { return 0 },
+	}
+}
+
+func changesChannelDB(feed func(ctx context.Context) (driver.Changes, error)) *DB {
+	return &DB{driverDB: &mock.DB{
+		ChangesFunc: func(ctx context.Context, _ map[string]interface{}) (driver.Changes, error) {
+			return feed(ctx)
+		},
+	}}
+}
+
+func batchIDs(batch []Change) []string {
+	ids := make([]string, len(batch))
+	for i, c := range batch {
+		ids[i] = c.ID
+	}
+	return ids
+}
+
+func TestChangesChannel(t *testing.T) {
+	t.Run("batches", func(t *testing.T) {
+		db := changesChannelDB(func(context.Context) (driver.Changes, error) {
+			return changesFeed("5",
+				driver.Change{ID: "a", Seq: "1"},
+				driver.Change{ID: "b", Seq: "2"},
+				driver.Change{ID: "c", Seq: "3", Doc: []byte(`{"foo":"bar"}`)},
+				driver.Change{ID: "d", Seq: "4"},
+				driver.Change{ID: "e", Seq: "5", Deleted: true},
+			), nil
+		})
+		c, err := db.ChangesChannel(context.Background(), 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var batches [][]string
+		var doc map[string]string
+		for {
+			batch := c.NextBatch(2, time.Second)
+			if len(batch) == 0 {
+				break
+			}
+			for _, ch := range batch {
+				if ch.ID == "c" {
+					if err := ch.ScanDoc(&doc); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			batches = append(batches, batchIDs(batch))
+		}
+		if err := c.Err(); err != nil {
+			t.Fatal(err)
+		}
+		expected := [][]string{{"a", "b"}, {"c", "d"}, {"e"}}
+		if d := testy.DiffInterface(expected, batches); d != nil {
+			t.Error(d)
+		}
+		if doc["foo"] != "bar" {
+			t.Errorf("Unexpected doc: %v", doc)
+		}
+		if seq := c.LastSeq(); seq != "5" {
+			t.Errorf("Unexpected last seq: %s", seq)
+		}
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("flush interval", func(t *testing.T) {
+		ch := make(chan driver.Change, 1)
+		db := changesChannelDB(func(ctx context.Context) (driver.Changes, error) {
+			return liveChangesFeed(ctx, ch), nil
+		})
+		c, err := db.ChangesChannel(context.Background(), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ch <- driver.Change{ID: "a"}
+		batch := c.NextBatch(500, 10*time.Millisecond)
+		if d := testy.DiffInterface([]string{"a"}, batchIDs(batch)); d != nil {
+			t.Error(d)
+		}
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if batch := c.NextBatch(500, time.Second); batch != nil {
+			t.Errorf("Unexpected batch after Close: %v", batch)
+		}
+		if err := c.Err(); err != nil {
+			t.Errorf("Unexpected error after Close: %s", err)
+		}
+		if err := c.Close(); err != nil {
+			t.Errorf("Second Close failed: %s", err)
+		}
+	})
+	t.Run("context cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		db := changesChannelDB(func(ctx context.Context) (driver.Changes, error) {
+			return liveChangesFeed(ctx, nil), nil
+		})
+		c, err := db.ChangesChannel(ctx, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cancel()
+		for range c.C() {
+			t.Error("Unexpected change")
+		}
+		if err := c.Err(); !errors.Is(err, context.Canceled) {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+	t.Run("feed error", func(t *testing.T) {
+		db := changesChannelDB(func(context.Context) (driver.Changes, error) {
+			feed := changesFeed("")
+			feed.NextFunc = func(*driver.Change) error { return &Error{HTTPStatus: http.StatusBadGateway, Message: "broken"} }
+			return feed, nil
+		})
+		c, err := db.ChangesChannel(context.Background(), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if batch := c.NextBatch(10, 0); batch != nil {
+			t.Errorf("Unexpected batch: %v", batch)
+		}
+		testy.StatusError(t, "broken", http.StatusBadGateway, c.Err())
+	})
+	t.Run("close error", func(t *testing.T) {
+		db := changesChannelDB(func(ctx context.Context) (driver.Changes, error) {
+			feed := liveChangesFeed(ctx, nil)
+			feed.CloseFunc = func() error { return &Error{HTTPStatus: http.StatusBadGateway, Message: "close failed"} }
+			return feed, nil
+		})
+		c, err := db.ChangesChannel(context.Background(), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		testy.StatusError(t, "close failed", http.StatusBadGateway, c.Close())
+	})
+	t.Run("zero max", func(t *testing.T) {
+		db := changesChannelDB(func(context.Context) (driver.Changes, error) {
+			return changesFeed("2", driver.Change{ID: "a"}, driver.Change{ID: "b"}), nil
+		})
+		c, err := db.ChangesChannel(context.Background(), 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close() // nolint: errcheck
+		if d := testy.DiffInterface([]string{"a"}, batchIDs(c.NextBatch(0, time.Second))); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("open error", func(t *testing.T) {
+		db := changesChannelDB(func(context.Context) (driver.Changes, error) {
+			return nil, &Error{HTTPStatus: http.StatusNotFound, Message: "missing"}
+		})
+		_, err := db.ChangesChannel(context.Background(), 0)
+		testy.StatusError(t, "missing", http.StatusNotFound, err)
+	})
+}
@@ -0,0 +1,183 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/mango"
+)
+
+// changesFilterOptions are the options which describe a changes filter, and
+// are removed from the request when the filter is emulated.
+var changesFilterOptions = []string{"filter", "doc_ids", "selector", "view", "limit"}
+
+// fallbackChanges emulates the built-in filter requested in opts, by reading
+// the unfiltered changes feed and filtering it on the client. cause is the
+// error returned by the driver for the filtered request, which is returned
+// if the filter cannot be emulated.
+func (db *DB) fallbackChanges(ctx context.Context, opts Options, cause error) (driver.Changes, error) {
+	filter, _ := opts["filter"].(string)
+	var match func(*driver.Change) bool
+	var needDocs bool
+	switch filter {
+	case "_doc_ids":
+		ids, err := changesDocIDs(opts["doc_ids"])
+		if err != nil {
+			return nil, err
+		}
+		match = func(c *driver.Change) bool { return ids[c.ID] }
+	case "_selector":
+		if opts["selector"] == nil {
+			return nil, missingArg("selector")
+		}
+		sel, err := mango.New(opts["selector"])
+		if err != nil {
+			return nil, &Error{HTTPStatus: http.StatusBadRequest, Message: "kivik: invalid selector", Err: err}
+		}
+		needDocs = true
+		match = func(c *driver.Change) bool {
+			var doc interface{}
+			if err := json.Unmarshal(c.Doc, &doc); err != nil {
+				return false
+			}
+			return sel.Match(doc)
+		}
+	case "_design":
+		match = func(c *driver.Change) bool { return strings.HasPrefix(c.ID, "_design/") }
+	default:
+		// Views and filter functions are JavaScript, and cannot be evaluated
+		// on the client.
+		return nil, cause
+	}
+	limit, err := changesLimit(opts["limit"])
+	if err != nil {
+		return nil, err
+	}
+	unfiltered := make(map[string]interface{}, len(opts))
+	for k, v := range opts {
+		unfiltered[k] = v
+	}
+	for _, k := range changesFilterOptions {
+		delete(unfiltered, k)
+	}
+	includeDocs, _ := opts["include_docs"].(bool)
+	if needDocs {
+		unfiltered["include_docs"] = true
+	}
+	changesi, err := db.driverDB.Changes(ctx, unfiltered)
+	if err != nil {
+		return nil, err
+	}
+	return &filteredChanges{
+		Changes:   changesi,
+		match:     match,
+		stripDocs: needDocs && !includeDocs,
+		remaining: limit,
+	}, nil
+}
+
+func changesDocIDs(v interface{}) (map[string]bool, error) {
+	if v == nil {
+		return nil, missingArg("doc_ids")
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, &Error{HTTPStatus: http.StatusBadRequest, Err: err}
+	}
+	var docIDs []string
+	if err := json.Unmarshal(raw, &docIDs); err != nil {
+		return nil, &Error{HTTPStatus: http.StatusBadRequest, Message: "kivik: doc_ids must be a list of strings"}
+	}
+	ids := make(map[string]bool, len(docIDs))
+	for _, id := range docIDs {
+		ids[id] = true
+	}
+	return ids, nil
+}
+
+// changesLimit parses the limit option. It returns -1 for no limit.
+func changesLimit(v interface{}) (int64, error) {
+	var limit int64
+	switch t := v.(type) {
+	case nil:
+		return -1, nil
+	case int:
+		limit = int64(t)
+	case int64:
+		limit = t
+	case float64:
+		limit = int64(t)
+	case string:
+		n, err := strconv.ParseInt(t, 10, 64)
+		if err != nil {
+			return 0, badChangesOption("invalid limit " + t)
+		}
+		limit = n
+	default:
+		return 0, badChangesOption("invalid limit")
+	}
+	if limit < 0 {
+		return 0, badChangesOption("limit must not be negative")
+	}
+	return limit, nil
+}
+
+// filteredChanges applies an emulated filter to a changes feed.
+type filteredChanges struct {
+	driver.Changes
+	match     func(*driver.Change) bool
+	stripDocs bool
+	remaining int64 // -1 for no limit
+	lastSeq   string
+}
+
+var _ driver.Changes = &filteredChanges{}
+
+func (c *filteredChanges) Next(change *driver.Change) error {
+	if c.remaining == 0 {
+		return io.EOF
+	}
+	for {
+		if err := c.Changes.Next(change); err != nil {
+			return err
+		}
+		if !c.match(change) {
+			continue
+		}
+		if c.stripDocs {
+			change.Doc = nil
+		}
+		if c.remaining > 0 {
+			c.remaining--
+			c.lastSeq = change.Seq
+		}
+		return nil
+	}
+}
+
+// LastSeq returns the sequence of the last change returned, if the feed was
+// ended by the limit, as the underlying feed's last sequence may be beyond
+// changes which were never read.
+func (c *filteredChanges) LastSeq() string {
+	if c.remaining == 0 {
+		return c.lastSeq
+	}
+	return c.Changes.LastSeq()
+}
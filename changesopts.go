@@ -0,0 +1,242 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// changesOptionsKey is the option key used by ChangesOptions.Options. It is
+// never passed on to the driver.
+const changesOptionsKey = "kivik:changes_options"
+
+// Feed modes for ChangesOptions.Feed.
+const (
+	FeedNormal     = "normal"
+	FeedLongpoll   = "longpoll"
+	FeedContinuous = "continuous"
+)
+
+// ChangesOptions is a typed alternative to the free-form options accepted by
+// DB.Changes. Each field corresponds to the query parameter noted in its
+// comment. Pass the result of the Options method:
+//
+//	changes, err := db.Changes(ctx, kivik.ChangesOptions{
+//		Feed:   kivik.FeedContinuous,
+//		DocIDs: []string{"foo", "bar"},
+//	}.Options())
+//
+// The options are validated before they are passed to the driver. Free-form
+// options passed alongside take precedence over the typed ones.
+//
+// DocIDs, Selector, View and Filter are mutually exclusive. When the driver
+// cannot apply the _doc_ids, _selector or _design filter itself, kivik reads
+// the unfiltered feed, and applies the filter on the client.
+//
+// See https://docs.couchdb.org/en/stable/api/database/changes.html
+type ChangesOptions struct {
+	// Feed is the feed mode, one of FeedNormal, FeedLongpoll or
+	// FeedContinuous (feed).
+	Feed string
+	// Since is the update sequence from which to start (since).
+	Since string
+	// Limit is the maximum number of changes to return (limit).
+	Limit int
+	// Descending returns changes in reverse order (descending).
+	Descending bool
+	// IncludeDocs includes the document in each result (include_docs).
+	IncludeDocs bool
+	// Conflicts includes the conflicting revisions of each document
+	// (conflicts). Requires IncludeDocs.
+	Conflicts bool
+	// Attachments includes the content of attachments (attachments).
+	// Requires IncludeDocs.
+	Attachments bool
+	// Style is "main_only", or "all_docs" to return all leaf revisions
+	// (style).
+	Style string
+	// Heartbeat is the interval at which an empty line is sent to keep the
+	// connection alive (heartbeat). It is sent in whole milliseconds.
+	Heartbeat time.Duration
+	// Timeout is the time to wait for a change before ending a longpoll or
+	// continuous feed (timeout). It is sent in whole milliseconds.
+	Timeout time.Duration
+	// DocIDs limits the feed to the named documents, with the _doc_ids
+	// filter.
+	DocIDs []string
+	// Selector is a Mango selector which documents must match, with the
+	// _selector filter. It may be any value which marshals to a JSON object.
+	Selector interface{}
+	// View limits the feed to documents emitted by a view, in the form
+	// "ddoc/view", with the _view filter.
+	View string
+	// Filter is the name of a filter function, in the form "ddoc/filter", or
+	// "_design" to limit the feed to design documents (filter).
+	Filter string
+	// QueryParams are passed to the filter function or view as additional
+	// query parameters. Requires Filter or View.
+	QueryParams map[string]interface{}
+}
+
+// Options returns o as an option which may be passed to DB.Changes.
+func (o ChangesOptions) Options() Options {
+	return Options{changesOptionsKey: o}
+}
+
+func badChangesOption(msg string) error {
+	return &Error{HTTPStatus: http.StatusBadRequest, Message: "kivik: " + msg}
+}
+
+// changesParams are the query parameters which QueryParams may not override.
+var changesParams = map[string]bool{
+	"feed": true, "since": true, "limit": true, "descending": true,
+	"include_docs": true, "conflicts": true, "attachments": true,
+	"att_encoding_info": true, "style": true, "heartbeat": true,
+	"timeout": true, "filter": true, "doc_ids": true, "selector": true,
+	"view": true, "last-event-id": true, "seq_interval": true,
+}
+
+// Validate returns an error if o contains incompatible or invalid settings.
+func (o ChangesOptions) Validate() error {
+	selective := 0
+	for _, set := range []bool{len(o.DocIDs) > 0, o.Selector != nil, o.View != "", o.Filter != ""} {
+		if set {
+			selective++
+		}
+	}
+	if selective > 1 {
+		return badChangesOption("doc_ids, selector, view and filter are mutually exclusive")
+	}
+	if strings.HasPrefix(o.Filter, "_") && o.Filter != "_design" {
+		return badChangesOption("use DocIDs, Selector or View instead of filter " + o.Filter)
+	}
+	if len(o.QueryParams) > 0 && o.Filter == "" && o.View == "" {
+		return badChangesOption("query params require filter or view")
+	}
+	for key := range o.QueryParams {
+		if changesParams[key] {
+			return badChangesOption(fmt.Sprintf("query param %q conflicts with a changes option", key))
+		}
+	}
+	switch o.Feed {
+	case "", FeedNormal, FeedLongpoll, FeedContinuous:
+	default:
+		return badChangesOption("invalid feed " + o.Feed)
+	}
+	switch o.Style {
+	case "", "main_only", "all_docs":
+	default:
+		return badChangesOption("invalid style " + o.Style)
+	}
+	if (o.Conflicts || o.Attachments) && !o.IncludeDocs {
+		return badChangesOption("conflicts and attachments require include_docs")
+	}
+	if o.Limit < 0 {
+		return badChangesOption("limit must not be negative")
+	}
+	if o.Heartbeat < 0 {
+		return badChangesOption("heartbeat must not be negative")
+	}
+	if o.Timeout < 0 {
+		return badChangesOption("timeout must not be negative")
+	}
+	return nil
+}
+
+// options returns o as free-form options, in the form described by
+// driver.DB.Changes.
+func (o ChangesOptions) options() (Options, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	opts := Options{}
+	if o.Feed != "" {
+		opts["feed"] = o.Feed
+	}
+	if o.Since != "" {
+		opts["since"] = o.Since
+	}
+	if o.Limit > 0 {
+		opts["limit"] = o.Limit
+	}
+	if o.Descending {
+		opts["descending"] = true
+	}
+	if o.IncludeDocs {
+		opts["include_docs"] = true
+	}
+	if o.Conflicts {
+		opts["conflicts"] = true
+	}
+	if o.Attachments {
+		opts["attachments"] = true
+	}
+	if o.Style != "" {
+		opts["style"] = o.Style
+	}
+	if o.Heartbeat > 0 {
+		opts["heartbeat"] = int(o.Heartbeat / time.Millisecond)
+	}
+	if o.Timeout > 0 {
+		opts["timeout"] = int(o.Timeout / time.Millisecond)
+	}
+	switch {
+	case len(o.DocIDs) > 0:
+		opts["filter"] = "_doc_ids"
+		opts["doc_ids"] = o.DocIDs
+	case o.Selector != nil:
+		selector, err := json.Marshal(o.Selector)
+		if err != nil {
+			return nil, &Error{HTTPStatus: http.StatusBadRequest, Message: "kivik: invalid selector", Err: err}
+		}
+		opts["filter"] = "_selector"
+		opts["selector"] = json.RawMessage(selector)
+	case o.View != "":
+		opts["filter"] = "_view"
+		opts["view"] = o.View
+	case o.Filter != "":
+		opts["filter"] = o.Filter
+	}
+	for k, v := range o.QueryParams {
+		opts[k] = v
+	}
+	return opts, nil
+}
+
+// changesOptions merges options, replacing any ChangesOptions with the
+// equivalent free-form options.
+func changesOptions(options []Options) (Options, error) {
+	opts := mergeOptions(options...)
+	co, ok := opts[changesOptionsKey].(ChangesOptions)
+	if !ok {
+		return opts, nil
+	}
+	delete(opts, changesOptionsKey)
+	typed, err := co.options()
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range typed {
+		if _, ok := opts[k]; !ok {
+			opts[k] = v
+		}
+	}
+	if len(opts) == 0 {
+		return nil, nil
+	}
+	return opts, nil
+}
@@ -0,0 +1,240 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestChangesOptions(t *testing.T) {
+	type tst struct {
+		options  []Options
+		expected Options
+		status   int
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("no typed options", tst{
+		options:  []Options{{"feed": "longpoll"}},
+		expected: Options{"feed": "longpoll"},
+	})
+	tests.Add("all simple options", tst{
+		options: []Options{ChangesOptions{
+			Feed:        FeedContinuous,
+			Since:       "now",
+			Limit:       10,
+			Descending:  true,
+			IncludeDocs: true,
+			Conflicts:   true,
+			Attachments: true,
+			Style:       "all_docs",
+			Heartbeat:   5 * time.Second,
+			Timeout:     time.Minute,
+		}.Options()},
+		expected: Options{
+			"feed":         "continuous",
+			"since":        "now",
+			"limit":        10,
+			"descending":   true,
+			"include_docs": true,
+			"conflicts":    true,
+			"attachments":  true,
+			"style":        "all_docs",
+			"heartbeat":    5000,
+			"timeout":      60000,
+		},
+	})
+	tests.Add("doc ids", tst{
+		options:  []Options{ChangesOptions{DocIDs: []string{"a", "b"}}.Options()},
+		expected: Options{"filter": "_doc_ids", "doc_ids": []string{"a", "b"}},
+	})
+	tests.Add("selector", tst{
+		options:  []Options{ChangesOptions{Selector: map[string]interface{}{"type": "x"}}.Options()},
+		expected: Options{"filter": "_selector", "selector": json.RawMessage(`{"type":"x"}`)},
+	})
+	tests.Add("view", tst{
+		options:  []Options{ChangesOptions{View: "ddoc/view", QueryParams: map[string]interface{}{"key": "x"}}.Options()},
+		expected: Options{"filter": "_view", "view": "ddoc/view", "key": "x"},
+	})
+	tests.Add("filter function", tst{
+		options:  []Options{ChangesOptions{Filter: "ddoc/filter", QueryParams: map[string]interface{}{"type": "x"}}.Options()},
+		expected: Options{"filter": "ddoc/filter", "type": "x"},
+	})
+	tests.Add("free-form options take precedence", tst{
+		options:  []Options{ChangesOptions{Since: "1", Limit: 5}.Options(), {"since": "2"}},
+		expected: Options{"since": "2", "limit": 5},
+	})
+	tests.Add("mutually exclusive", tst{
+		options: []Options{ChangesOptions{DocIDs: []string{"a"}, View: "ddoc/view"}.Options()},
+		status:  http.StatusBadRequest,
+		err:     "kivik: doc_ids, selector, view and filter are mutually exclusive",
+	})
+	tests.Add("built-in filter", tst{
+		options: []Options{ChangesOptions{Filter: "_doc_ids"}.Options()},
+		status:  http.StatusBadRequest,
+		err:     "kivik: use DocIDs, Selector or View instead of filter _doc_ids",
+	})
+	tests.Add("query params without filter", tst{
+		options: []Options{ChangesOptions{QueryParams: map[string]interface{}{"a": 1}}.Options()},
+		status:  http.StatusBadRequest,
+		err:     "kivik: query params require filter or view",
+	})
+	tests.Add("conflicting query param", tst{
+		options: []Options{ChangesOptions{Filter: "_design", QueryParams: map[string]interface{}{"since": 1}}.Options()},
+		status:  http.StatusBadRequest,
+		err:     `kivik: query param "since" conflicts with a changes option`,
+	})
+	tests.Add("invalid feed", tst{
+		options: []Options{ChangesOptions{Feed: "eventsource"}.Options()},
+		status:  http.StatusBadRequest,
+		err:     "kivik: invalid feed eventsource",
+	})
+	tests.Add("invalid style", tst{
+		options: []Options{ChangesOptions{Style: "some"}.Options()},
+		status:  http.StatusBadRequest,
+		err:     "kivik: invalid style some",
+	})
+	tests.Add("conflicts without docs", tst{
+		options: []Options{ChangesOptions{Conflicts: true}.Options()},
+		status:  http.StatusBadRequest,
+		err:     "kivik: conflicts and attachments require include_docs",
+	})
+	tests.Add("negative timeout", tst{
+		options: []Options{ChangesOptions{Timeout: -time.Second}.Options()},
+		status:  http.StatusBadRequest,
+		err:     "kivik: timeout must not be negative",
+	})
+	tests.Add("invalid selector", tst{
+		options: []Options{ChangesOptions{Selector: func() {}}.Options()},
+		status:  http.StatusBadRequest,
+		err:     "kivik: invalid selector: json: unsupported type: func()",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tst) {
+		opts, err := changesOptions(tt.options)
+		testy.StatusError(t, tt.err, tt.status, err)
+		if d := testy.DiffInterface(tt.expected, opts); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
+func TestChangesFilterFallback(t *testing.T) {
+	type tst struct {
+		options     Options
+		ids         []string
+		docs        bool
+		lastSeq     string
+		driverOpts  map[string]interface{}
+		status      int
+		err         string
+		driverError error
+	}
+	notImplemented := &Error{HTTPStatus: http.StatusNotImplemented, Message: "filter not supported"}
+	feed := []driver.Change{
+		{ID: "a", Seq: "1", Doc: json.RawMessage(`{"_id":"a","type":"x"}`)},
+		{ID: "_design/foo", Seq: "2", Doc: json.RawMessage(`{"_id":"_design/foo"}`)},
+		{ID: "b", Seq: "3", Doc: json.RawMessage(`{"_id":"b","type":"y"}`)},
+		{ID: "c", Seq: "4", Doc: json.RawMessage(`{"_id":"c","type":"x"}`)},
+	}
+	tests := testy.NewTable()
+	tests.Add("doc ids", tst{
+		options:    ChangesOptions{DocIDs: []string{"c", "a"}, Since: "0"}.Options(),
+		ids:        []string{"a", "c"},
+		docs:       true,
+		lastSeq:    "4",
+		driverOpts: map[string]interface{}{"since": "0"},
+	})
+	tests.Add("selector without docs", tst{
+		options:    ChangesOptions{Selector: map[string]interface{}{"type": "x"}, Limit: 1}.Options(),
+		ids:        []string{"a"},
+		lastSeq:    "1",
+		driverOpts: map[string]interface{}{"include_docs": true},
+	})
+	tests.Add("selector with docs", tst{
+		options:    ChangesOptions{Selector: map[string]interface{}{"type": "x"}, IncludeDocs: true}.Options(),
+		ids:        []string{"a", "c"},
+		docs:       true,
+		lastSeq:    "4",
+		driverOpts: map[string]interface{}{"include_docs": true},
+	})
+	tests.Add("design", tst{
+		options:    Options{"filter": "_design", "limit": "5"},
+		ids:        []string{"_design/foo"},
+		docs:       true,
+		lastSeq:    "4",
+		driverOpts: map[string]interface{}{},
+	})
+	tests.Add("view", tst{
+		options: ChangesOptions{View: "ddoc/view"}.Options(),
+		status:  http.StatusNotImplemented,
+		err:     "filter not supported",
+	})
+	tests.Add("missing doc ids", tst{
+		options: Options{"filter": "_doc_ids"},
+		status:  http.StatusBadRequest,
+		err:     "kivik: doc_ids required",
+	})
+	tests.Add("other driver error", tst{
+		options:     ChangesOptions{DocIDs: []string{"a"}}.Options(),
+		driverError: &Error{HTTPStatus: http.StatusBadGateway, Message: "broken"},
+		status:      http.StatusBadGateway,
+		err:         "broken",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tst) {
+		var driverOpts map[string]interface{}
+		db := &DB{driverDB: &mock.DB{
+			ChangesFunc: func(_ context.Context, opts map[string]interface{}) (driver.Changes, error) {
+				if tt.driverError != nil {
+					return nil, tt.driverError
+				}
+				if opts["filter"] != nil {
+					return nil, notImplemented
+				}
+				driverOpts = opts
+				return changesFeed("4", feed...), nil
+			},
+		}}
+		changes, err := db.Changes(context.Background(), tt.options)
+		testy.StatusError(t, tt.err, tt.status, err)
+		var ids []string
+		for changes.Next() {
+			ids = append(ids, changes.ID())
+			if hasDoc := changes.curVal.(*driver.Change).Doc != nil; hasDoc != tt.docs {
+				t.Errorf("%s: unexpected doc presence: %t", changes.ID(), hasDoc)
+			}
+		}
+		if err := changes.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface(tt.ids, ids); d != nil {
+			t.Error(d)
+		}
+		if d := testy.DiffInterface(tt.driverOpts, driverOpts); d != nil {
+			t.Errorf("Unexpected driver options:\n%s", d)
+		}
+		if seq := changes.LastSeq(); seq != tt.lastSeq {
+			t.Errorf("Unexpected last seq: %s", seq)
+		}
+	})
+}
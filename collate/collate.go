@@ -0,0 +1,289 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package collate implements CouchDB's view collation order, and helpers for
+// building view key ranges.
+//
+// Values of different types sort in the following order:
+//
+//	null < false < true < numbers < strings < arrays < objects
+//
+// Numbers are compared numerically. Strings are compared with an
+// approximation of the ICU root collation used by CouchDB: punctuation and
+// whitespace sort before digits, which sort before letters; letters compare
+// case- and accent-insensitively first, then unaccented before accented, then
+// lowercase before uppercase. Arrays are compared element by element, and
+// objects member by member. See
+// https://docs.couchdb.org/en/stable/ddocs/views/collation.html
+//
+// The string collation covers Latin scripts, including accented letters in
+// the Latin-1 Supplement and Latin Extended-A blocks. Other scripts sort after
+// Latin, by code point.
+package collate
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+)
+
+var errTrailingData = errors.New("collate: invalid JSON: unexpected data after value")
+
+// Type ranks, in collation order.
+const (
+	rankNull = iota
+	rankBool
+	rankNumber
+	rankString
+	rankArray
+	rankObject
+)
+
+// member is an object member in an object decoded by CompareJSON, which
+// preserves member order.
+type member struct {
+	key   string
+	value interface{}
+}
+
+type object []member
+
+// Compare compares two values, returning -1 if a sorts before b, 1 if a
+// sorts after b, and 0 if they are equal. Values are normally decoded JSON:
+// nil, bool, float64, json.Number, string, []interface{} and
+// map[string]interface{}. Go integer types are also accepted. Any other
+// value, including json.RawMessage, is converted by round-tripping through
+// encoding/json.
+//
+// Go maps have no member order, so map members are compared in collation
+// order of their keys. Use CompareJSON to respect the member order of JSON
+// objects.
+func Compare(a, b interface{}) int {
+	return compare(normalize(a), normalize(b))
+}
+
+// CompareJSON compares two JSON values, as Compare does, but preserves the
+// member order of objects.
+func CompareJSON(a, b []byte) (int, error) {
+	av, err := decode(a)
+	if err != nil {
+		return 0, err
+	}
+	bv, err := decode(b)
+	if err != nil {
+		return 0, err
+	}
+	return compare(av, bv), nil
+}
+
+// decode decodes JSON, preserving object member order.
+func decode(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	v, err := decodeValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errTrailingData
+	}
+	return v, nil
+}
+
+func decodeValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('['):
+		list := []interface{}{}
+		for dec.More() {
+			v, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		_, err := dec.Token()
+		return list, err
+	case json.Delim('{'):
+		obj := object{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj = append(obj, member{key: key.(string), value: v})
+		}
+		_, err := dec.Token()
+		return obj, err
+	}
+	return tok, nil
+}
+
+// normalize converts v to a type understood by compare.
+func normalize(v interface{}) interface{} {
+	switch t := v.(type) {
+	case nil, bool, float64, json.Number, string, []interface{}, map[string]interface{}, object:
+		return v
+	case int:
+		return float64(t)
+	case int8:
+		return float64(t)
+	case int16:
+		return float64(t)
+	case int32:
+		return float64(t)
+	case int64:
+		return float64(t)
+	case uint:
+		return float64(t)
+	case uint8:
+		return float64(t)
+	case uint16:
+		return float64(t)
+	case uint32:
+		return float64(t)
+	case uint64:
+		return float64(t)
+	case float32:
+		return float64(t)
+	case json.RawMessage:
+		if d, err := decode(t); err == nil {
+			return d
+		}
+		return nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	d, _ := decode(raw)
+	return d
+}
+
+func rank(v interface{}) int {
+	switch v.(type) {
+	case nil:
+		return rankNull
+	case bool:
+		return rankBool
+	case float64, json.Number:
+		return rankNumber
+	case string:
+		return rankString
+	case []interface{}:
+		return rankArray
+	case map[string]interface{}, object:
+		return rankObject
+	}
+	return rankNull
+}
+
+func compare(a, b interface{}) int {
+	ra, rb := rank(a), rank(b)
+	if ra != rb {
+		return cmpInt(ra, rb)
+	}
+	switch ra {
+	case rankBool:
+		ab, bb := a.(bool), b.(bool)
+		switch {
+		case ab == bb:
+			return 0
+		case !ab:
+			return -1
+		}
+		return 1
+	case rankNumber:
+		af, bf := number(a), number(b)
+		switch {
+		case af < bf:
+			return -1
+		case af > bf:
+			return 1
+		}
+		return 0
+	case rankString:
+		return CompareString(a.(string), b.(string))
+	case rankArray:
+		al, bl := a.([]interface{}), b.([]interface{})
+		for i := 0; i < len(al) && i < len(bl); i++ {
+			if c := compare(normalize(al[i]), normalize(bl[i])); c != 0 {
+				return c
+			}
+		}
+		return cmpInt(len(al), len(bl))
+	case rankObject:
+		ao, bo := members(a), members(b)
+		for i := 0; i < len(ao) && i < len(bo); i++ {
+			if c := CompareString(ao[i].key, bo[i].key); c != 0 {
+				return c
+			}
+			if c := compare(normalize(ao[i].value), normalize(bo[i].value)); c != 0 {
+				return c
+			}
+		}
+		return cmpInt(len(ao), len(bo))
+	}
+	return 0
+}
+
+// members returns the members of an object. Map members are sorted by key.
+func members(v interface{}) object {
+	switch t := v.(type) {
+	case object:
+		return t
+	case map[string]interface{}:
+		obj := make(object, 0, len(t))
+		for key, value := range t {
+			obj = append(obj, member{key: key, value: value})
+		}
+		sortMembers(obj)
+		return obj
+	}
+	return nil
+}
+
+func sortMembers(obj object) {
+	sort.Slice(obj, func(i, j int) bool {
+		return CompareString(obj[i].key, obj[j].key) < 0
+	})
+}
+
+func number(v interface{}) float64 {
+	switch t := v.(type) {
+	case float64:
+		return t
+	case json.Number:
+		f, _ := t.Float64()
+		return f
+	}
+	return 0
+}
+
+func cmpInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,111 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package collate
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// checkOrder checks that each value sorts strictly before the next.
+func checkOrder(t *testing.T, values []interface{}, labels []string) {
+	t.Helper()
+	for i := range values {
+		for j := range values {
+			want := cmpInt(i, j)
+			if got := Compare(values[i], values[j]); got != want {
+				t.Errorf("Compare(%s, %s) = %d, want %d", labels[i], labels[j], got, want)
+			}
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	// From the CouchDB collation specification, with member order
+	// normalized, since Go maps are unordered.
+	ordered := []string{
+		`null`, `false`, `true`,
+		`1`, `2`, `3.0`, `4`,
+		`"a"`, `"A"`, `"aa"`, `"b"`, `"B"`, `"ba"`, `"bb"`,
+		`["a"]`, `["b"]`, `["b","c"]`, `["b","c","a"]`, `["b","d"]`, `["b","d","e"]`,
+		`{"a":1}`, `{"a":2}`, `{"b":1}`, `{"b":2}`,
+	}
+	values := make([]interface{}, len(ordered))
+	for i, raw := range ordered {
+		if err := json.Unmarshal([]byte(raw), &values[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkOrder(t, values, ordered)
+}
+
+func TestCompareString(t *testing.T) {
+	ordered := []string{
+		" ", "_", "-", ",", ";", ":", "!", "?", ".", "'", `"`, "(", ")",
+		"[", "]", "{", "}", "@", "*", "/", `\`, "&", "#", "%", "`", "^",
+		"+", "<", "=", ">", "|", "~", "$",
+		"0", "1", "10", "9",
+		"a", "A", "á", "Á", "ae", "æ", "Æ", "af",
+		"e", "é", "É", "ef",
+		"s", "ss", "ß", "st",
+		"z", "Z",
+		"α", "Ω",
+	}
+	values := make([]interface{}, len(ordered))
+	for i, s := range ordered {
+		values[i] = s
+	}
+	checkOrder(t, values, ordered)
+}
+
+func TestCompareGoTypes(t *testing.T) {
+	if c := Compare(int64(2), json.Number("2.0")); c != 0 {
+		t.Errorf("int64 and json.Number should compare equal, got %d", c)
+	}
+	if c := Compare([]string{"a", "b"}, []interface{}{"a", "b"}); c != 0 {
+		t.Errorf("[]string and []interface{} should compare equal, got %d", c)
+	}
+	if c := Compare(json.RawMessage(`[1,{}]`), []interface{}{1, map[string]interface{}{}}); c != 0 {
+		t.Errorf("json.RawMessage should be decoded, got %d", c)
+	}
+}
+
+func TestCompareJSON(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+		err  string
+	}{
+		{a: `{"b":2,"a":1}`, b: `{"b":2}`, want: 1},
+		{a: `{"a":1,"b":2}`, b: `{"b":2,"a":1}`, want: -1},
+		{a: `"a"`, b: `"A"`, want: -1},
+		{a: `[1,2]`, b: `[1,2]`, want: 0},
+		{a: `[1`, b: `1`, err: "*"}, // The message varies by Go version
+		{a: `1 2`, b: `1`, err: "collate: invalid JSON: unexpected data after value"},
+	}
+	for _, tt := range tests {
+		got, err := CompareJSON([]byte(tt.a), []byte(tt.b))
+		if tt.err != "" {
+			if err == nil || (tt.err != "*" && err.Error() != tt.err) {
+				t.Errorf("CompareJSON(%s, %s): unexpected error: %v", tt.a, tt.b, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("CompareJSON(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package collate
+
+// StringSuffix is a high Unicode character (U+FFF0), which sorts after any
+// character likely to appear in a key. It is the same as kivik.EndKeySuffix.
+const StringSuffix = string(rune(0xfff0))
+
+// Range is a range of view keys.
+type Range struct {
+	// StartKey is the first key in the range. A nil StartKey leaves the
+	// range open at the start.
+	StartKey interface{}
+	// EndKey is the last key in the range. A nil EndKey leaves the range
+	// open at the end.
+	EndKey interface{}
+	// ExclusiveEnd excludes EndKey itself from the range.
+	ExclusiveEnd bool
+	// Descending indicates that StartKey sorts after EndKey, and that rows
+	// are returned in descending order.
+	Descending bool
+}
+
+// Prefix returns the range of string keys beginning with prefix.
+func Prefix(prefix string) Range {
+	return Range{StartKey: prefix, EndKey: prefix + StringSuffix}
+}
+
+// CompoundPrefix returns the range of array keys whose first elements equal
+// prefix. For example, CompoundPrefix("a", "b") spans ["a", "b"] to
+// ["a", "b", {}].
+func CompoundPrefix(prefix ...interface{}) Range {
+	start := make([]interface{}, len(prefix))
+	copy(start, prefix)
+	end := make([]interface{}, len(prefix), len(prefix)+1)
+	copy(end, prefix)
+	return Range{StartKey: start, EndKey: append(end, map[string]interface{}{})}
+}
+
+// Reverse returns the same range, iterated in the opposite direction, by
+// swapping the start and end keys. CouchDB cannot exclude a start key, so
+// when ExclusiveEnd is set, the excluded key changes from the old end key to
+// the new one.
+func (r Range) Reverse() Range {
+	return Range{
+		StartKey:     r.EndKey,
+		EndKey:       r.StartKey,
+		ExclusiveEnd: r.ExclusiveEnd,
+		Descending:   !r.Descending,
+	}
+}
+
+// Contains returns true if key falls within the range.
+func (r Range) Contains(key interface{}) bool {
+	low, high := r.StartKey, r.EndKey
+	lowExclusive, highExclusive := false, r.ExclusiveEnd
+	if r.Descending {
+		low, high = high, low
+		lowExclusive, highExclusive = highExclusive, lowExclusive
+	}
+	if low != nil {
+		if c := Compare(key, low); c < 0 || (c == 0 && lowExclusive) {
+			return false
+		}
+	}
+	if high != nil {
+		if c := Compare(key, high); c > 0 || (c == 0 && highExclusive) {
+			return false
+		}
+	}
+	return true
+}
+
+// Options returns the range as view query options, suitable for passing to
+// DB.Query or DB.AllDocs as kivik.Options.
+func (r Range) Options() map[string]interface{} {
+	opts := map[string]interface{}{}
+	if r.StartKey != nil {
+		opts["startkey"] = r.StartKey
+	}
+	if r.EndKey != nil {
+		opts["endkey"] = r.EndKey
+	}
+	if r.ExclusiveEnd {
+		opts["inclusive_end"] = false
+	}
+	if r.Descending {
+		opts["descending"] = true
+	}
+	return opts
+}
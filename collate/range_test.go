@@ -0,0 +1,99 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package collate
+
+import (
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+)
+
+func TestRange(t *testing.T) {
+	type tt struct {
+		r       Range
+		in, out []interface{}
+		options map[string]interface{}
+	}
+	tests := testy.NewTable()
+	tests.Add("Prefix", tt{
+		r:   Prefix("foo"),
+		in:  []interface{}{"foo", "foobar", "FOO", "foo~"},
+		out: []interface{}{"fo", "fop", []interface{}{"foo"}, nil},
+		options: map[string]interface{}{
+			"startkey": "foo",
+			"endkey":   "foo" + StringSuffix,
+		},
+	})
+	tests.Add("CompoundPrefix", tt{
+		r:   CompoundPrefix("a", 1),
+		in:  []interface{}{[]interface{}{"a", 1}, []interface{}{"a", 1, "x"}, []interface{}{"a", 1, []interface{}{"x"}}},
+		out: []interface{}{[]interface{}{"a"}, []interface{}{"a", 2}, []interface{}{"b", 1}, "a"},
+		options: map[string]interface{}{
+			"startkey": []interface{}{"a", 1},
+			"endkey":   []interface{}{"a", 1, map[string]interface{}{}},
+		},
+	})
+	tests.Add("Reverse", tt{
+		r:   Prefix("foo").Reverse(),
+		in:  []interface{}{"foo", "foobar"},
+		out: []interface{}{"fo", "fop"},
+		options: map[string]interface{}{
+			"startkey":   "foo" + StringSuffix,
+			"endkey":     "foo",
+			"descending": true,
+		},
+	})
+	tests.Add("exclusive end", tt{
+		r:   Range{StartKey: 1, EndKey: 3, ExclusiveEnd: true},
+		in:  []interface{}{1, 2, 2.5},
+		out: []interface{}{0, 3, "1"},
+		options: map[string]interface{}{
+			"startkey":      1,
+			"endkey":        3,
+			"inclusive_end": false,
+		},
+	})
+	tests.Add("exclusive end, descending", tt{
+		r:   Range{StartKey: 3, EndKey: 1, ExclusiveEnd: true, Descending: true},
+		in:  []interface{}{3, 2},
+		out: []interface{}{1, 4},
+		options: map[string]interface{}{
+			"startkey":      3,
+			"endkey":        1,
+			"inclusive_end": false,
+			"descending":    true,
+		},
+	})
+	tests.Add("open start", tt{
+		r:       Range{EndKey: "m"},
+		in:      []interface{}{nil, false, 10, "a", "m"},
+		out:     []interface{}{"n", []interface{}{}},
+		options: map[string]interface{}{"endkey": "m"},
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		for _, key := range tt.in {
+			if !tt.r.Contains(key) {
+				t.Errorf("Expected range to contain %v", key)
+			}
+		}
+		for _, key := range tt.out {
+			if tt.r.Contains(key) {
+				t.Errorf("Expected range not to contain %v", key)
+			}
+		}
+		if d := testy.DiffInterface(tt.options, tt.r.Options()); d != nil {
+			t.Error(d)
+		}
+	})
+}
@@ -0,0 +1,151 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package collate
+
+import (
+	"strings"
+	"unicode"
+)
+
+// punctuation lists ASCII whitespace, punctuation and symbols, in collation
+// order. They sort before all other characters.
+const punctuation = "\t\n\v\f\r _-,;:!?.'\"()[]{}@*/\\&#%`^+<=>|~$"
+
+// latinBase maps U+00C0 through U+017F to their unaccented base letters. A
+// period indicates a character with no base letter, or one handled by
+// latinExpansions.
+const latinBase = "" +
+	"AAAAAA.CEEEEIIIIDNOOOOO.OUUUUY..aaaaaa.ceeeeiiiidnooooo.ouuuuy.y" +
+	"AaAaAaCcCcCcCcDdDdEeEeEeEeEeGgGgGgGgHhHhIiIiIiIiIi..JjKk.LlLlLlL" +
+	"lLlNnNnNnn..OoOoOo..RrRrRrSsSsSsSsTtTtTtUuUuUuUuUuUuWwYyYZzZzZzs"
+
+// latinExpansions maps ligatures to the letters they sort as.
+var latinExpansions = map[rune]string{
+	'Æ': "AE",
+	'æ': "ae",
+	'ß': "ss",
+	'Ĳ': "IJ",
+	'ĳ': "ij",
+	'Œ': "OE",
+	'œ': "oe",
+}
+
+// Primary weight ranges, in collation order.
+const (
+	weightSymbol = 0x100
+	weightDigit  = 0x200000
+	weightLatin  = 0x300000
+	weightOther  = 0x400000
+	weightHigh   = 0x600000
+)
+
+// sortKey holds the weights of a string at each collation level.
+type sortKey struct {
+	primary   []int
+	secondary []int
+	tertiary  []int
+}
+
+func (k *sortKey) add(primary, secondary int, upper bool) {
+	tertiary := 0
+	if upper {
+		tertiary = 1
+	}
+	k.primary = append(k.primary, primary)
+	k.secondary = append(k.secondary, secondary)
+	k.tertiary = append(k.tertiary, tertiary)
+}
+
+func newSortKey(s string) *sortKey {
+	k := &sortKey{}
+	for _, r := range s {
+		if r < 0x80 {
+			if i := strings.IndexRune(punctuation, r); i >= 0 {
+				k.add(1+i, 0, false)
+				continue
+			}
+		}
+		switch {
+		case r >= '0' && r <= '9':
+			k.add(weightDigit+int(r-'0'), 0, false)
+		case r >= 'a' && r <= 'z':
+			k.add(weightLatin+int(r-'a'), 0, false)
+		case r >= 'A' && r <= 'Z':
+			k.add(weightLatin+int(r-'A'), 0, true)
+		case r >= 0xC0 && r <= 0x17F:
+			upper := unicode.IsUpper(r)
+			accent := int(unicode.ToLower(r))
+			if exp, ok := latinExpansions[r]; ok {
+				for _, b := range strings.ToLower(exp) {
+					k.add(weightLatin+int(b-'a'), accent, upper)
+				}
+				continue
+			}
+			if b := latinBase[r-0xC0]; b != '.' {
+				k.add(weightLatin+int(unicode.ToLower(rune(b))-'a'), accent, upper)
+				continue
+			}
+			k.addOther(r)
+		default:
+			k.addOther(r)
+		}
+	}
+	return k
+}
+
+// addOther adds a character outside of the Latin alphabet, digits and ASCII
+// punctuation. Letters sort after Latin letters, and other characters after
+// ASCII punctuation, by code point. As in ICU, unassigned characters, such as
+// StringSuffix, sort after everything else.
+func (k *sortKey) addOther(r rune) {
+	switch {
+	case unicode.IsLetter(r):
+		k.add(weightOther+int(unicode.ToLower(r)), 0, unicode.IsUpper(r))
+	case unicode.IsControl(r):
+		// Control characters are ignored.
+	case !unicode.IsGraphic(r):
+		k.add(weightHigh+int(r), 0, false)
+	default:
+		k.add(weightSymbol+int(r), 0, false)
+	}
+}
+
+// CompareString compares two strings in CouchDB's collation order, returning
+// -1, 0 or 1.
+func CompareString(a, b string) int {
+	if a == b {
+		return 0
+	}
+	ka, kb := newSortKey(a), newSortKey(b)
+	if c := compareWeights(ka.primary, kb.primary); c != 0 {
+		return c
+	}
+	if c := compareWeights(ka.secondary, kb.secondary); c != 0 {
+		return c
+	}
+	if c := compareWeights(ka.tertiary, kb.tertiary); c != 0 {
+		return c
+	}
+	// Strings which differ only in ignored characters are ordered by code
+	// point, so that unequal strings never compare equal.
+	return strings.Compare(a, b)
+}
+
+func compareWeights(a, b []int) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return cmpInt(a[i], b[i])
+		}
+	}
+	return cmpInt(len(a), len(b))
+}
@@ -0,0 +1,381 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/go-kivik/kivik/v4/collate"
+)
+
+// LeafRevision is a single leaf revision of a conflicted document.
+type LeafRevision struct {
+	// Rev is the revision ID of the leaf.
+	Rev string
+	// Doc is the raw JSON document at this revision.
+	Doc json.RawMessage
+}
+
+// ScanDoc unmarshals the document of the leaf into dest.
+func (l *LeafRevision) ScanDoc(dest interface{}) error {
+	return json.Unmarshal(l.Doc, dest)
+}
+
+// MergeFunc merges the leaf revisions of a conflicted document. leaves are
+// ordered winning revision first. It returns the merged document, which may
+// be any value which marshals to a JSON object, or nil to leave the document
+// unresolved.
+//
+// The _id, _rev and _attachments fields of the merged document are ignored:
+// the merged document is written as a new revision of the winning leaf, with
+// the winning leaf's attachments.
+type MergeFunc func(ctx context.Context, docID string, leaves []LeafRevision) (interface{}, error)
+
+// LastWriterWins returns a MergeFunc which keeps the leaf with the greatest
+// value of field, compared in CouchDB collation order, so that numbers and
+// RFC 3339 timestamps compare as expected. Nested fields may be named with
+// dots, as in "meta.updated". Leaves without the field sort first. Ties are
+// won by the earlier leaf.
+func LastWriterWins(field string) MergeFunc {
+	path := strings.Split(field, ".")
+	return func(_ context.Context, _ string, leaves []LeafRevision) (interface{}, error) {
+		var best json.RawMessage
+		var bestValue interface{}
+		for _, leaf := range leaves {
+			var doc interface{}
+			if err := json.Unmarshal(leaf.Doc, &doc); err != nil {
+				return nil, &Error{HTTPStatus: http.StatusBadGateway, Err: err}
+			}
+			value, ok := fieldValue(doc, path)
+			if best == nil || ok && (bestValue == nil || collate.Compare(value, bestValue) > 0) {
+				best, bestValue = leaf.Doc, value
+			}
+		}
+		return best, nil
+	}
+}
+
+// fieldValue returns the value of the field at path in doc.
+func fieldValue(doc interface{}, path []string) (interface{}, bool) {
+	for _, name := range path {
+		obj, ok := doc.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if doc, ok = obj[name]; !ok {
+			return nil, false
+		}
+	}
+	return doc, true
+}
+
+// DeepMerge is a MergeFunc which merges the documents of all leaves member
+// by member. Objects are merged recursively. Where leaves hold different
+// values of any other type, including arrays, the value of the earlier leaf
+// is kept. Members missing from some leaves are kept, so a member deleted on
+// one side of a conflict is restored.
+func DeepMerge(_ context.Context, _ string, leaves []LeafRevision) (interface{}, error) {
+	var merged interface{}
+	for _, leaf := range leaves {
+		var doc interface{}
+		if err := json.Unmarshal(leaf.Doc, &doc); err != nil {
+			return nil, &Error{HTTPStatus: http.StatusBadGateway, Err: err}
+		}
+		if merged == nil {
+			merged = doc
+			continue
+		}
+		merged = deepMerge(merged, doc)
+	}
+	return merged, nil
+}
+
+// deepMerge merges src into dst, which takes precedence.
+func deepMerge(dst, src interface{}) interface{} {
+	dstObj, ok := dst.(map[string]interface{})
+	if !ok {
+		return dst
+	}
+	srcObj, ok := src.(map[string]interface{})
+	if !ok {
+		return dst
+	}
+	for k, v := range srcObj {
+		if existing, ok := dstObj[k]; ok {
+			dstObj[k] = deepMerge(existing, v)
+			continue
+		}
+		dstObj[k] = v
+	}
+	return dstObj
+}
+
+// ConflictResolution describes a resolved document.
+type ConflictResolution struct {
+	// DocID is the ID of the resolved document.
+	DocID string
+	// Rev is the revision of the merged document.
+	Rev string
+	// Deleted lists the losing leaf revisions which were deleted.
+	Deleted []string
+	// Errors maps each revision which could not be updated, whether the
+	// winning leaf or a losing one, to the error reported for it.
+	Errors map[string]error
+}
+
+// ConflictResolver finds conflicted documents in a database, and resolves
+// them with a MergeFunc. For each document, the merged document is written
+// as a new revision of the winning leaf, and the losing leaves are deleted,
+// in a single BulkDocs call. As BulkDocs is not atomic, the result of each
+// write is reported in the ConflictResolution.
+//
+// Exported fields may be changed after NewConflictResolver returns, but
+// before the resolver is used.
+type ConflictResolver struct {
+	// Merge is the function used to merge the leaves of each conflicted
+	// document. LastWriterWins and DeepMerge are provided for common cases.
+	Merge MergeFunc
+	// View names a view, in the form "ddoc/view", whose rows identify
+	// conflicted documents by ID, such as one with the map function:
+	//
+	//	function(doc) { if (doc._conflicts) { emit(doc._id); } }
+	//
+	// If View is empty, the changes feed is scanned with conflicts=true,
+	// which reads every document in the database.
+	View string
+
+	db *DB
+}
+
+// NewConflictResolver returns a ConflictResolver which resolves conflicts in
+// db with merge.
+func NewConflictResolver(db *DB, merge MergeFunc) *ConflictResolver {
+	return &ConflictResolver{
+		Merge: merge,
+		db:    db,
+	}
+}
+
+// Conflicted returns the IDs of the conflicted documents in the database.
+func (r *ConflictResolver) Conflicted(ctx context.Context) ([]string, error) {
+	if r.View != "" {
+		return r.conflictedView(ctx)
+	}
+	changes, err := r.db.Changes(ctx, Options{
+		"style":        "all_docs",
+		"conflicts":    true,
+		"include_docs": true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer changes.Close() // nolint: errcheck
+	var ids []string
+	for changes.Next() {
+		if len(changes.Conflicts()) > 0 {
+			ids = append(ids, changes.ID())
+		}
+	}
+	return ids, changes.Err()
+}
+
+func (r *ConflictResolver) conflictedView(ctx context.Context) ([]string, error) {
+	i := strings.LastIndex(r.View, "/")
+	if i <= 0 || i == len(r.View)-1 {
+		return nil, &Error{HTTPStatus: http.StatusBadRequest, Message: "kivik: view must be in the form ddoc/view"}
+	}
+	rows, err := r.db.Query(ctx, r.View[:i], r.View[i+1:])
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() // nolint: errcheck
+	seen := map[string]bool{}
+	var ids []string
+	for rows.Next() {
+		if id := rows.ID(); !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, rows.Err()
+}
+
+// ResolveAll resolves every conflicted document in the database, and returns
+// the resolutions made, including partial ones. Documents which are deleted
+// or updated concurrently are skipped, to be found again on a later run. Any
+// other error ends the run, and is returned along with the resolutions made
+// so far.
+func (r *ConflictResolver) ResolveAll(ctx context.Context) ([]ConflictResolution, error) {
+	ids, err := r.Conflicted(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var resolutions []ConflictResolution
+	for _, id := range ids {
+		res, err := r.Resolve(ctx, id)
+		if res != nil {
+			resolutions = append(resolutions, *res)
+		}
+		switch StatusCode(err) {
+		case 0, http.StatusNotFound, http.StatusConflict:
+		default:
+			return resolutions, err
+		}
+	}
+	return resolutions, nil
+}
+
+// Resolve resolves the conflicts of a single document. It returns nil if the
+// document has no conflicts, or Merge declined to resolve it.
+//
+// If no write succeeds, Resolve returns only the error for the winning leaf.
+// Otherwise it returns the resolution, whose Rev and Deleted fields reflect
+// the writes which succeeded, and whose Errors field holds the others. If the
+// merged document was written, but some losing leaves could not be deleted,
+// the error is the first deletion error, and the remaining leaves stay in
+// conflict with the merged revision. If losing leaves were deleted, but the
+// merged document could not be written, their content may be lost, and
+// Resolve returns a status 500 error wrapping the error for the winning leaf.
+func (r *ConflictResolver) Resolve(ctx context.Context, docID string) (*ConflictResolution, error) {
+	if r.Merge == nil {
+		return nil, missingArg("merge function")
+	}
+	var winner json.RawMessage
+	if err := r.db.Get(ctx, docID, Options{"conflicts": true}).ScanDoc(&winner); err != nil {
+		return nil, err
+	}
+	var meta changeMeta
+	if err := json.Unmarshal(winner, &meta); err != nil {
+		return nil, &Error{HTTPStatus: http.StatusBadGateway, Err: err}
+	}
+	if len(meta.Conflicts) == 0 {
+		return nil, nil
+	}
+	leaves, err := r.leaves(ctx, docID, LeafRevision{Rev: meta.Rev, Doc: winner}, meta.Conflicts)
+	if err != nil {
+		return nil, err
+	}
+	merged, err := r.Merge(ctx, docID, leaves)
+	if err != nil || merged == nil {
+		return nil, err
+	}
+	doc, err := mergedDoc(merged, docID, meta.Rev, winner)
+	if err != nil {
+		return nil, err
+	}
+	docs := []interface{}{doc}
+	for _, rev := range meta.Conflicts {
+		docs = append(docs, map[string]interface{}{
+			"_id":      docID,
+			"_rev":     rev,
+			"_deleted": true,
+		})
+	}
+	results, err := r.db.BulkDocs(ctx, docs)
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close() // nolint: errcheck
+	res := &ConflictResolution{DocID: docID}
+	var winnerErr, deleteErr error
+	for i := 0; results.Next(); i++ {
+		rev := meta.Rev
+		if i > 0 {
+			rev = meta.Conflicts[i-1]
+		}
+		if err := results.UpdateErr(); err != nil {
+			if res.Errors == nil {
+				res.Errors = make(map[string]error)
+			}
+			res.Errors[rev] = err
+			switch {
+			case i == 0:
+				winnerErr = err
+			case deleteErr == nil:
+				deleteErr = err
+			}
+			continue
+		}
+		if i == 0 {
+			res.Rev = results.Rev()
+			continue
+		}
+		res.Deleted = append(res.Deleted, rev)
+	}
+	if err := results.Err(); err != nil {
+		return nil, err
+	}
+	switch {
+	case winnerErr != nil && len(res.Deleted) == 0:
+		return nil, winnerErr
+	case winnerErr != nil:
+		return res, &Error{HTTPStatus: http.StatusInternalServerError, Message: "kivik: losing leaves deleted, but merged document not written", Err: winnerErr}
+	}
+	return res, deleteErr
+}
+
+// leaves fetches the conflicting revisions of a document, and returns them
+// after the winning leaf.
+func (r *ConflictResolver) leaves(ctx context.Context, docID string, winner LeafRevision, revs []string) ([]LeafRevision, error) {
+	openRevs, err := r.db.GetRevs(ctx, docID, revs)
+	if err != nil {
+		return nil, err
+	}
+	defer openRevs.Close() // nolint: errcheck
+	leaves := make([]LeafRevision, 0, len(revs)+1)
+	leaves = append(leaves, winner)
+	for openRevs.Next() {
+		if openRevs.Missing() {
+			return nil, &Error{HTTPStatus: http.StatusConflict, Message: "kivik: leaf revision " + openRevs.Rev() + " no longer exists"}
+		}
+		leaf := LeafRevision{Rev: openRevs.Rev()}
+		if err := openRevs.ScanDoc(&leaf.Doc); err != nil {
+			return nil, err
+		}
+		leaves = append(leaves, leaf)
+	}
+	return leaves, openRevs.Err()
+}
+
+// mergedDoc prepares merged to be written as a new revision of the winning
+// leaf, rev, by replacing its special fields with those of winner.
+func mergedDoc(merged interface{}, docID, rev string, winner json.RawMessage) (map[string]interface{}, error) {
+	raw, err := json.Marshal(merged)
+	if err != nil {
+		return nil, &Error{HTTPStatus: http.StatusBadRequest, Message: "kivik: invalid merged document", Err: err}
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil || doc == nil {
+		return nil, &Error{HTTPStatus: http.StatusBadRequest, Message: "kivik: merged document must be a JSON object"}
+	}
+	var special struct {
+		Attachments json.RawMessage `json:"_attachments"`
+	}
+	if err := json.Unmarshal(winner, &special); err != nil {
+		return nil, &Error{HTTPStatus: http.StatusBadGateway, Err: err}
+	}
+	for k := range doc {
+		if strings.HasPrefix(k, "_") {
+			delete(doc, k)
+		}
+	}
+	doc["_id"] = docID
+	doc["_rev"] = rev
+	if special.Attachments != nil {
+		doc["_attachments"] = special.Attachments
+	}
+	return doc, nil
+}
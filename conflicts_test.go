@@ -0,0 +1,349 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func leafRevisions(docs ...string) []LeafRevision {
+	leaves := make([]LeafRevision, len(docs))
+	for i, doc := range docs {
+		leaves[i] = LeafRevision{Doc: json.RawMessage(doc)}
+	}
+	return leaves
+}
+
+func TestLastWriterWins(t *testing.T) {
+	type tst struct {
+		field    string
+		leaves   []LeafRevision
+		expected string
+	}
+	tests := testy.NewTable()
+	tests.Add("timestamps", tst{
+		field: "updated",
+		leaves: leafRevisions(
+			`{"v":1,"updated":"2024-01-02T09:00:00Z"}`,
+			`{"v":2,"updated":"2024-01-02T10:00:00Z"}`,
+			`{"v":3,"updated":"2024-01-01T23:00:00Z"}`,
+		),
+		expected: `{"v":2,"updated":"2024-01-02T10:00:00Z"}`,
+	})
+	tests.Add("numbers", tst{
+		field:    "updated",
+		leaves:   leafRevisions(`{"v":1,"updated":9}`, `{"v":2,"updated":10}`),
+		expected: `{"v":2,"updated":10}`,
+	})
+	tests.Add("missing field", tst{
+		field:    "updated",
+		leaves:   leafRevisions(`{"v":1}`, `{"v":2,"updated":1}`, `{"v":3}`),
+		expected: `{"v":2,"updated":1}`,
+	})
+	tests.Add("tie", tst{
+		field:    "updated",
+		leaves:   leafRevisions(`{"v":1,"updated":1}`, `{"v":2,"updated":1}`),
+		expected: `{"v":1,"updated":1}`,
+	})
+	tests.Add("nested", tst{
+		field:    "meta.updated",
+		leaves:   leafRevisions(`{"v":1,"meta":{"updated":2}}`, `{"v":2,"meta":{"updated":1}}`),
+		expected: `{"v":1,"meta":{"updated":2}}`,
+	})
+
+	tests.Run(t, func(t *testing.T, tt tst) {
+		result, err := LastWriterWins(tt.field)(context.Background(), "foo", tt.leaves)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffAsJSON([]byte(tt.expected), result); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
+func TestDeepMerge(t *testing.T) {
+	result, err := DeepMerge(context.Background(), "foo", leafRevisions(
+		`{"name":"a","tags":["x"],"address":{"city":"Paris"}}`,
+		`{"name":"b","tags":["y"],"address":{"city":"Lyon","zip":"69000"},"phone":"123"}`,
+		`{"email":"c@example.com"}`,
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"name":"a","tags":["x"],"address":{"city":"Paris","zip":"69000"},"phone":"123","email":"c@example.com"}`
+	if d := testy.DiffAsJSON([]byte(expected), result); d != nil {
+		t.Error(d)
+	}
+}
+
+// conflictedDB returns a DB whose driver serves the leaf revisions in leaves,
+// the first being the winner, and records the documents passed to BulkDocs.
+// BulkDocs reports rowErrs for the documents at the corresponding positions.
+func conflictedDB(leaves map[string]string, conflicts []string, rowErrs map[int]error, bulkDocs *[]interface{}) *DB {
+	return &DB{driverDB: &mock.BulkDocer{
+		DB: &mock.DB{
+			GetFunc: func(_ context.Context, _ string, opts map[string]interface{}) (*driver.Document, error) {
+				if rev, ok := opts["rev"].(string); ok {
+					doc, ok := leaves[rev]
+					if !ok {
+						return nil, &Error{HTTPStatus: http.StatusNotFound, Message: "missing"}
+					}
+					return &driver.Document{Rev: rev, Body: body(doc)}, nil
+				}
+				var doc map[string]interface{}
+				_ = json.Unmarshal([]byte(leaves["winner"]), &doc)
+				if len(conflicts) > 0 {
+					doc["_conflicts"] = conflicts
+				}
+				raw, _ := json.Marshal(doc)
+				return &driver.Document{Body: body(string(raw))}, nil
+			},
+		},
+		BulkDocsFunc: func(_ context.Context, docs []interface{}, _ map[string]interface{}) (driver.BulkResults, error) {
+			*bulkDocs = docs
+			results := make([]driver.BulkResult, len(docs))
+			for i := range docs {
+				results[i] = driver.BulkResult{ID: "foo", Rev: "4-merged", Error: rowErrs[i]}
+			}
+			return &mock.BulkResults{
+				NextFunc: func(r *driver.BulkResult) error {
+					if len(results) == 0 {
+						return io.EOF
+					}
+					*r = results[0]
+					results = results[1:]
+					return nil
+				},
+				CloseFunc: func() error { return nil },
+			}, nil
+		},
+	}}
+}
+
+func TestConflictResolverResolve(t *testing.T) {
+	type tst struct {
+		conflicts []string
+		merge     MergeFunc
+		rowErrs   map[int]error
+		expected  *ConflictResolution
+		bulkDocs  string
+		status    int
+		err       string
+	}
+	leaves := map[string]string{
+		"winner": `{"_id":"foo","_rev":"3-c","v":3,"_attachments":{"a.txt":{"stub":true}}}`,
+		"3-b":    `{"_id":"foo","_rev":"3-b","v":2,"w":1}`,
+		"2-a":    `{"_id":"foo","_rev":"2-a","v":1}`,
+	}
+	tests := testy.NewTable()
+	tests.Add("no conflicts", tst{
+		merge: DeepMerge,
+	})
+	tests.Add("resolved", tst{
+		conflicts: []string{"3-b", "2-a"},
+		merge:     DeepMerge,
+		expected:  &ConflictResolution{DocID: "foo", Rev: "4-merged", Deleted: []string{"3-b", "2-a"}},
+		bulkDocs: `[
+			{"_id":"foo","_rev":"3-c","v":3,"w":1,"_attachments":{"a.txt":{"stub":true}}},
+			{"_id":"foo","_rev":"3-b","_deleted":true},
+			{"_id":"foo","_rev":"2-a","_deleted":true}
+		]`,
+	})
+	tests.Add("merge sees all leaves", tst{
+		conflicts: []string{"3-b", "2-a"},
+		merge: func(_ context.Context, docID string, leaves []LeafRevision) (interface{}, error) {
+			revs := make([]string, len(leaves))
+			for i, leaf := range leaves {
+				revs[i] = leaf.Rev
+			}
+			return map[string]interface{}{"docID": docID, "revs": revs, "_deleted": true}, nil
+		},
+		expected: &ConflictResolution{DocID: "foo", Rev: "4-merged", Deleted: []string{"3-b", "2-a"}},
+		bulkDocs: `[
+			{"_id":"foo","_rev":"3-c","docID":"foo","revs":["3-c","3-b","2-a"],"_attachments":{"a.txt":{"stub":true}}},
+			{"_id":"foo","_rev":"3-b","_deleted":true},
+			{"_id":"foo","_rev":"2-a","_deleted":true}
+		]`,
+	})
+	tests.Add("merge declined", tst{
+		conflicts: []string{"3-b"},
+		merge:     func(context.Context, string, []LeafRevision) (interface{}, error) { return nil, nil },
+	})
+	tests.Add("merge error", tst{
+		conflicts: []string{"3-b"},
+		merge: func(context.Context, string, []LeafRevision) (interface{}, error) {
+			return nil, &Error{HTTPStatus: http.StatusTeapot, Message: "no merge"}
+		},
+		status: http.StatusTeapot,
+		err:    "no merge",
+	})
+	tests.Add("merged document not an object", tst{
+		conflicts: []string{"3-b"},
+		merge:     func(context.Context, string, []LeafRevision) (interface{}, error) { return []int{1}, nil },
+		status:    http.StatusBadRequest,
+		err:       "kivik: merged document must be a JSON object",
+	})
+	tests.Add("no merge func", tst{
+		conflicts: []string{"3-b"},
+		status:    http.StatusBadRequest,
+		err:       "kivik: merge function required",
+	})
+	tests.Add("leaf removed", tst{
+		conflicts: []string{"3-b", "9-z"},
+		merge:     DeepMerge,
+		status:    http.StatusConflict,
+		err:       "kivik: leaf revision 9-z no longer exists",
+	})
+	conflict := &Error{HTTPStatus: http.StatusConflict, Message: "conflict"}
+	tests.Add("update conflict", tst{
+		conflicts: []string{"3-b"},
+		merge:     DeepMerge,
+		rowErrs:   map[int]error{0: conflict, 1: conflict},
+		status:    http.StatusConflict,
+		err:       "conflict",
+	})
+	tests.Add("deletion conflict", tst{
+		conflicts: []string{"3-b", "2-a"},
+		merge:     DeepMerge,
+		rowErrs:   map[int]error{2: conflict},
+		expected: &ConflictResolution{
+			DocID:   "foo",
+			Rev:     "4-merged",
+			Deleted: []string{"3-b"},
+			Errors:  map[string]error{"2-a": conflict},
+		},
+		bulkDocs: `[
+			{"_id":"foo","_rev":"3-c","v":3,"w":1,"_attachments":{"a.txt":{"stub":true}}},
+			{"_id":"foo","_rev":"3-b","_deleted":true},
+			{"_id":"foo","_rev":"2-a","_deleted":true}
+		]`,
+		status: http.StatusConflict,
+		err:    "conflict",
+	})
+	tests.Add("merged document not written", tst{
+		conflicts: []string{"3-b", "2-a"},
+		merge:     DeepMerge,
+		rowErrs:   map[int]error{0: conflict},
+		expected: &ConflictResolution{
+			DocID:   "foo",
+			Deleted: []string{"3-b", "2-a"},
+			Errors:  map[string]error{"3-c": conflict},
+		},
+		status: http.StatusInternalServerError,
+		err:    "kivik: losing leaves deleted, but merged document not written: conflict",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tst) {
+		var bulkDocs []interface{}
+		r := NewConflictResolver(conflictedDB(leaves, tt.conflicts, tt.rowErrs, &bulkDocs), tt.merge)
+		result, err := r.Resolve(context.Background(), "foo")
+		if tt.bulkDocs != "" {
+			if d := testy.DiffAsJSON([]byte(tt.bulkDocs), bulkDocs); d != nil {
+				t.Errorf("Unexpected bulk docs:\n%s", d)
+			}
+		}
+		if d := testy.DiffInterface(tt.expected, result); d != nil {
+			t.Error(d)
+		}
+		testy.StatusError(t, tt.err, tt.status, err)
+	})
+}
+
+func TestConflictResolverResolveAll(t *testing.T) {
+	leaves := map[string]string{
+		"winner": `{"_id":"foo","_rev":"3-c","v":3}`,
+		"3-b":    `{"_id":"foo","_rev":"3-b","v":2}`,
+		"2-a":    `{"_id":"foo","_rev":"2-a","v":1}`,
+	}
+	var bulkDocs []interface{}
+	conflict := &Error{HTTPStatus: http.StatusConflict, Message: "conflict"}
+	db := conflictedDB(leaves, []string{"3-b", "2-a"}, map[int]error{2: conflict}, &bulkDocs)
+	db.driverDB.(*mock.BulkDocer).DB.ChangesFunc = func(context.Context, map[string]interface{}) (driver.Changes, error) {
+		return changesFeed("1", driver.Change{ID: "foo", Conflicts: []string{"3-b", "2-a"}}), nil
+	}
+	resolutions, err := NewConflictResolver(db, DeepMerge).ResolveAll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ConflictResolution{{
+		DocID:   "foo",
+		Rev:     "4-merged",
+		Deleted: []string{"3-b"},
+		Errors:  map[string]error{"2-a": conflict},
+	}}
+	if d := testy.DiffInterface(expected, resolutions); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestConflictResolverConflicted(t *testing.T) {
+	t.Run("changes", func(t *testing.T) {
+		var changesOpts map[string]interface{}
+		db := &DB{driverDB: &mock.DB{
+			ChangesFunc: func(_ context.Context, opts map[string]interface{}) (driver.Changes, error) {
+				changesOpts = opts
+				return changesFeed("3",
+					driver.Change{ID: "a", Doc: json.RawMessage(`{"_id":"a","_conflicts":["1-x"]}`)},
+					driver.Change{ID: "b", Doc: json.RawMessage(`{"_id":"b"}`)},
+					driver.Change{ID: "c", Conflicts: []string{"2-y"}},
+				), nil
+			},
+		}}
+		ids, err := NewConflictResolver(db, DeepMerge).Conflicted(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface([]string{"a", "c"}, ids); d != nil {
+			t.Error(d)
+		}
+		expectedOpts := map[string]interface{}{"style": "all_docs", "conflicts": true, "include_docs": true}
+		if d := testy.DiffInterface(expectedOpts, changesOpts); d != nil {
+			t.Errorf("Unexpected options:\n%s", d)
+		}
+	})
+	t.Run("view", func(t *testing.T) {
+		db := &DB{driverDB: &mock.DB{
+			QueryFunc: func(_ context.Context, ddoc, view string, _ map[string]interface{}) (driver.Rows, error) {
+				if ddoc != "conflicts" || view != "all" {
+					t.Errorf("Unexpected view: %s/%s", ddoc, view)
+				}
+				return rowsFeed(driver.Row{ID: "a"}, driver.Row{ID: "a"}, driver.Row{ID: "b"}), nil
+			},
+		}}
+		r := NewConflictResolver(db, DeepMerge)
+		r.View = "_design/conflicts/all"
+		ids, err := r.Conflicted(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface([]string{"a", "b"}, ids); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("invalid view", func(t *testing.T) {
+		r := NewConflictResolver(&DB{}, DeepMerge)
+		r.View = "conflicts"
+		_, err := r.Conflicted(context.Background())
+		testy.StatusError(t, "kivik: view must be in the form ddoc/view", http.StatusBadRequest, err)
+	})
+}
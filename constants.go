@@ -36,4 +36,7 @@ const UserPrefix = "org.couchdb.user:"
 //        "startkey": "foo",
 //        "endkey":   "foo" + kivik.EndKeySuffix,
 //    })
+//
+// The collate package provides helpers to build such ranges, including for
+// compound keys.
 const EndKeySuffix = string(rune(0xfff0))
@@ -0,0 +1,362 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultConsumerBatchSize  = 100
+	defaultConsumerMinBackoff = 100 * time.Millisecond
+	defaultConsumerMaxBackoff = time.Minute
+)
+
+// ChangesHandler processes a single change. c is positioned on the change
+// being handled; the handler may read it with ID, Seq, Changes, Deleted and
+// ScanDoc, but must not call Next or Close.
+type ChangesHandler func(ctx context.Context, c *Changes) error
+
+// CheckpointStore persists the position of a ChangesConsumer in a changes
+// feed.
+type CheckpointStore interface {
+	// LoadCheckpoint returns the last saved update sequence, or an empty
+	// string if none has been saved.
+	LoadCheckpoint(ctx context.Context) (string, error)
+	// SaveCheckpoint records seq as the last processed update sequence.
+	SaveCheckpoint(ctx context.Context, seq string) error
+}
+
+// LocalCheckpointStore returns a CheckpointStore which keeps the update
+// sequence in the _local document named docID in db. The _local/ prefix is
+// added if absent.
+func LocalCheckpointStore(db *DB, docID string) CheckpointStore {
+	if !strings.HasPrefix(docID, localDocPrefix) {
+		docID = localDocPrefix + docID
+	}
+	return &localCheckpointStore{db: db, docID: docID}
+}
+
+type localCheckpointStore struct {
+	db    *DB
+	docID string
+
+	mu  sync.Mutex
+	rev string
+}
+
+type localCheckpoint struct {
+	ID  string        `json:"_id"`
+	Rev string        `json:"_rev,omitempty"`
+	Seq checkpointSeq `json:"seq"`
+}
+
+func (s *localCheckpointStore) LoadCheckpoint(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	doc, err := s.load(ctx)
+	if err != nil || doc == nil {
+		return "", err
+	}
+	return string(doc.Seq), nil
+}
+
+// load reads the checkpoint document, and records its revision. It returns
+// nil if the document does not exist. The caller must hold s.mu.
+func (s *localCheckpointStore) load(ctx context.Context) (*localCheckpoint, error) {
+	doc := &localCheckpoint{}
+	err := s.db.Get(ctx, s.docID).ScanDoc(doc)
+	if StatusCode(err) == http.StatusNotFound {
+		s.rev = ""
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.rev = doc.Rev
+	return doc, nil
+}
+
+// SaveCheckpoint writes seq to the checkpoint document. If the document was
+// changed by another writer, it is re-read and the write is retried once.
+func (s *localCheckpointStore) SaveCheckpoint(ctx context.Context, seq string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.save(ctx, seq)
+	if StatusCode(err) != http.StatusConflict {
+		return err
+	}
+	if _, err := s.load(ctx); err != nil {
+		return err
+	}
+	return s.save(ctx, seq)
+}
+
+func (s *localCheckpointStore) save(ctx context.Context, seq string) error {
+	rev, err := s.db.Put(ctx, s.docID, &localCheckpoint{
+		ID:  s.docID,
+		Rev: s.rev,
+		Seq: checkpointSeq(seq),
+	})
+	if err != nil {
+		return err
+	}
+	s.rev = rev
+	return nil
+}
+
+// ChangesConsumerStats is a snapshot of the progress of a ChangesConsumer.
+type ChangesConsumerStats struct {
+	// Seq is the update sequence of the last change processed.
+	Seq string
+	// CheckpointSeq is the last update sequence saved to the CheckpointStore.
+	CheckpointSeq string
+	// Processed is the number of changes successfully handled since Run was
+	// called.
+	Processed int64
+	// Pending is the number of changes remaining in the feed, as reported by
+	// the server at the end of the most recent batch. It is a measure of how
+	// far the consumer lags behind the database.
+	Pending int64
+	// Retries is the number of times the feed has been reconnected after an
+	// error.
+	Retries int64
+	// LastError is the most recent error that caused a reconnect. It is reset
+	// to nil by the next successful batch.
+	LastError error
+}
+
+// ChangesConsumer reads a database's changes feed and passes each change to
+// a handler, resuming from a durable checkpoint after restarts and
+// reconnecting after transient failures.
+//
+// Delivery is at-least-once: the checkpoint only ever advances past changes
+// for which the handler has returned nil, but changes handled after the last
+// saved checkpoint are delivered again if the consumer stops before the next
+// save. Handlers should therefore be idempotent.
+//
+// The exported fields may be set to tune the consumer before Run is called,
+// and must not be changed afterwards.
+type ChangesConsumer struct {
+	// Store holds the consumer's checkpoint. If nil, it defaults to
+	// LocalCheckpointStore(db, id).
+	Store CheckpointStore
+	// Options are passed to DB.Changes on each request, and may be used to
+	// include documents, or to filter the feed. The feed, since and limit
+	// options are set by the consumer.
+	Options Options
+	// BatchSize is the maximum number of changes read per request. The
+	// default is 100.
+	BatchSize int
+	// CheckpointInterval is the minimum time between checkpoint saves. When
+	// zero, a checkpoint is saved after every batch that made progress.
+	CheckpointInterval time.Duration
+	// MinBackoff and MaxBackoff bound the delay before reconnecting after a
+	// failure. The delay starts at MinBackoff, and doubles after each
+	// consecutive failure, up to MaxBackoff. The defaults are 100ms and one
+	// minute.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	db      *DB
+	id      string
+	handler ChangesHandler
+
+	mu    sync.RWMutex
+	stats ChangesConsumerStats
+}
+
+// NewChangesConsumer returns a consumer of db's changes feed, which calls
+// handler for each change. id identifies the consumer, and names its default
+// checkpoint document. Call Run to start consuming.
+func NewChangesConsumer(db *DB, id string, handler ChangesHandler) *ChangesConsumer {
+	return &ChangesConsumer{
+		db:      db,
+		id:      id,
+		handler: handler,
+	}
+}
+
+// Stats returns a snapshot of the consumer's progress. It is safe to call
+// concurrently with Run.
+func (c *ChangesConsumer) Stats() ChangesConsumerStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.stats
+}
+
+// Run consumes the changes feed, starting from the stored checkpoint, until
+// ctx is cancelled, the handler returns an error, or a non-transient error
+// occurs. Errors with a 5xx or 429 status, and errors without a status, are
+// considered transient, and cause a reconnect after a backoff delay.
+//
+// When the handler returns an error, the checkpoint is saved up to the
+// preceding change, and the handler's error is returned. The failed change is
+// delivered again on the next call to Run.
+func (c *ChangesConsumer) Run(ctx context.Context) error {
+	if c.handler == nil {
+		return missingArg("handler")
+	}
+	store := c.Store
+	if store == nil {
+		if c.id == "" {
+			return missingArg("consumer id")
+		}
+		store = LocalCheckpointStore(c.db, c.id)
+	}
+	seq, err := store.LoadCheckpoint(ctx)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.stats = ChangesConsumerStats{Seq: seq, CheckpointSeq: seq}
+	c.mu.Unlock()
+
+	saved, lastSave := seq, time.Now()
+	save := func() error {
+		if seq == saved {
+			return nil
+		}
+		if err := store.SaveCheckpoint(ctx, seq); err != nil {
+			return err
+		}
+		saved, lastSave = seq, time.Now()
+		c.mu.Lock()
+		c.stats.CheckpointSeq = seq
+		c.mu.Unlock()
+		return nil
+	}
+
+	backoff := c.minBackoff()
+	for {
+		var handlerErr, feedErr error
+		seq, handlerErr, feedErr = c.poll(ctx, seq)
+		if handlerErr != nil {
+			if err := save(); err != nil {
+				return err
+			}
+			return handlerErr
+		}
+		if feedErr == nil && time.Since(lastSave) >= c.CheckpointInterval {
+			feedErr = save()
+		}
+		if feedErr == nil {
+			backoff = c.minBackoff()
+			c.mu.Lock()
+			c.stats.LastError = nil
+			c.mu.Unlock()
+			continue
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !transientError(feedErr) {
+			_ = save()
+			return feedErr
+		}
+		c.mu.Lock()
+		c.stats.Retries++
+		c.stats.LastError = feedErr
+		c.mu.Unlock()
+		if err := sleep(ctx, backoff); err != nil {
+			return err
+		}
+		if backoff *= 2; backoff > c.maxBackoff() {
+			backoff = c.maxBackoff()
+		}
+	}
+}
+
+// poll reads a single batch of changes following seq, and passes them to the
+// handler. It returns the sequence from which to continue, which accounts
+// for every change successfully handled.
+func (c *ChangesConsumer) poll(ctx context.Context, seq string) (next string, handlerErr, feedErr error) {
+	opts := Options{
+		"feed":  "longpoll",
+		"limit": c.batchSize(),
+	}
+	if seq != "" {
+		opts["since"] = seq
+	}
+	changes, err := c.db.Changes(ctx, c.Options, opts)
+	if err != nil {
+		return seq, nil, err
+	}
+	defer changes.Close() // nolint: errcheck
+	for changes.Next() {
+		if err := c.handler(ctx, changes); err != nil {
+			return seq, err, nil
+		}
+		seq = changes.Seq()
+		c.mu.Lock()
+		c.stats.Seq = seq
+		c.stats.Processed++
+		c.mu.Unlock()
+	}
+	if err := changes.Err(); err != nil {
+		return seq, nil, err
+	}
+	// The last sequence may be beyond the last change read, when the feed is
+	// filtered.
+	if last := changes.LastSeq(); last != "" {
+		seq = last
+	}
+	c.mu.Lock()
+	c.stats.Seq = seq
+	c.stats.Pending = changes.Pending()
+	c.mu.Unlock()
+	return seq, nil, nil
+}
+
+func (c *ChangesConsumer) batchSize() int {
+	if c.BatchSize > 0 {
+		return c.BatchSize
+	}
+	return defaultConsumerBatchSize
+}
+
+func (c *ChangesConsumer) minBackoff() time.Duration {
+	if c.MinBackoff > 0 {
+		return c.MinBackoff
+	}
+	return defaultConsumerMinBackoff
+}
+
+func (c *ChangesConsumer) maxBackoff() time.Duration {
+	if c.MaxBackoff > 0 {
+		return c.MaxBackoff
+	}
+	return defaultConsumerMaxBackoff
+}
+
+// transientError returns true if err may succeed on retry.
+func transientError(err error) bool {
+	status := StatusCode(err)
+	return status >= http.StatusInternalServerError || status == http.StatusTooManyRequests
+}
+
+// sleep waits for d, or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
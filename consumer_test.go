@@ -0,0 +1,184 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+// consumerFixture serves a scripted sequence of responses to a
+// ChangesConsumer. The context is cancelled once the script is exhausted.
+type consumerFixture struct {
+	local   *localDocStore
+	since   []string
+	handled []string
+	script  []func() (driver.Changes, error)
+	cancel  func()
+	db      *DB
+}
+
+func newConsumerFixture(script ...func() (driver.Changes, error)) *consumerFixture {
+	f := &consumerFixture{local: &localDocStore{}, script: script}
+	f.db = &DB{name: "db", driverDB: &mock.DB{
+		GetFunc: f.local.get,
+		PutFunc: f.local.put,
+		ChangesFunc: func(ctx context.Context, opts map[string]interface{}) (driver.Changes, error) {
+			since, _ := opts["since"].(string)
+			f.since = append(f.since, since)
+			if len(f.script) == 0 {
+				f.cancel()
+				return nil, ctx.Err()
+			}
+			next := f.script[0]
+			f.script = f.script[1:]
+			return next()
+		},
+	}}
+	return f
+}
+
+func (f *consumerFixture) run(c *ChangesConsumer) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	f.cancel = cancel
+	return c.Run(ctx)
+}
+
+func (f *consumerFixture) handler(_ context.Context, c *Changes) error {
+	f.handled = append(f.handled, c.ID())
+	return nil
+}
+
+func batch(lastSeq string, changes ...driver.Change) func() (driver.Changes, error) {
+	return func() (driver.Changes, error) {
+		return changesFeed(lastSeq, changes...), nil
+	}
+}
+
+func failure(status int) func() (driver.Changes, error) {
+	return func() (driver.Changes, error) {
+		return nil, &Error{HTTPStatus: status, Message: http.StatusText(status)}
+	}
+}
+
+func TestChangesConsumer(t *testing.T) {
+	t.Run("resume from checkpoint", func(t *testing.T) {
+		f := newConsumerFixture(
+			batch("5", driver.Change{ID: "a", Seq: "4"}, driver.Change{ID: "b", Seq: "5"}),
+			batch("7", driver.Change{ID: "c", Seq: "6"}),
+		)
+		f.local.docs = map[string]json.RawMessage{"_local/consumer": json.RawMessage(`{"_id":"_local/consumer","_rev":"0-1","seq":3}`)}
+		c := NewChangesConsumer(f.db, "consumer", f.handler)
+		err := f.run(c)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if d := testy.DiffInterface([]string{"3", "5", "7"}, f.since); d != nil {
+			t.Errorf("Unexpected since values:\n%s", d)
+		}
+		if d := testy.DiffInterface([]string{"a", "b", "c"}, f.handled); d != nil {
+			t.Errorf("Unexpected changes handled:\n%s", d)
+		}
+		if d := testy.DiffAsJSON(json.RawMessage(`{"_id":"_local/consumer","_rev":"0-1","seq":"7"}`), f.local.docs["_local/consumer"]); d != nil {
+			t.Errorf("Unexpected checkpoint:\n%s", d)
+		}
+		stats := c.Stats()
+		if stats.Seq != "7" || stats.CheckpointSeq != "7" || stats.Processed != 3 {
+			t.Errorf("Unexpected stats: %+v", stats)
+		}
+	})
+	t.Run("reconnect after transient errors", func(t *testing.T) {
+		f := newConsumerFixture(
+			batch("2", driver.Change{ID: "a", Seq: "1"}, driver.Change{ID: "b", Seq: "2"}),
+			failure(http.StatusServiceUnavailable),
+			failure(http.StatusTooManyRequests),
+			batch("3", driver.Change{ID: "c", Seq: "3"}),
+		)
+		c := NewChangesConsumer(f.db, "consumer", f.handler)
+		c.MinBackoff = time.Millisecond
+		err := f.run(c)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if d := testy.DiffInterface([]string{"", "2", "2", "2", "3"}, f.since); d != nil {
+			t.Errorf("Unexpected since values:\n%s", d)
+		}
+		stats := c.Stats()
+		if stats.Retries != 2 || stats.LastError != nil {
+			t.Errorf("Unexpected stats: %+v", stats)
+		}
+	})
+	t.Run("fatal error", func(t *testing.T) {
+		f := newConsumerFixture(failure(http.StatusUnauthorized))
+		err := f.run(NewChangesConsumer(f.db, "consumer", f.handler))
+		testy.StatusError(t, "Unauthorized", http.StatusUnauthorized, err)
+	})
+	t.Run("handler error", func(t *testing.T) {
+		f := newConsumerFixture(
+			batch("3", driver.Change{ID: "a", Seq: "1"}, driver.Change{ID: "b", Seq: "2"}, driver.Change{ID: "c", Seq: "3"}),
+		)
+		c := NewChangesConsumer(f.db, "consumer", func(_ context.Context, c *Changes) error {
+			if c.ID() == "c" {
+				return errors.New("handler failed")
+			}
+			return nil
+		})
+		err := f.run(c)
+		if d := testy.DiffAsJSON(json.RawMessage(`{"_id":"_local/consumer","seq":"2"}`), f.local.docs["_local/consumer"]); d != nil {
+			t.Errorf("Unexpected checkpoint:\n%s", d)
+		}
+		testy.Error(t, "handler failed", err)
+	})
+	t.Run("pending", func(t *testing.T) {
+		f := newConsumerFixture(
+			func() (driver.Changes, error) {
+				feed := changesFeed("2", driver.Change{ID: "a", Seq: "1"}, driver.Change{ID: "b", Seq: "2"})
+				feed.PendingFunc = func() int64 { return 42 }
+				return feed, nil
+			},
+		)
+		c := NewChangesConsumer(f.db, "consumer", f.handler)
+		c.BatchSize = 2
+		_ = f.run(c)
+		if pending := c.Stats().Pending; pending != 42 {
+			t.Errorf("Unexpected pending count: %d", pending)
+		}
+	})
+	t.Run("custom store", func(t *testing.T) {
+		f := newConsumerFixture(batch("9", driver.Change{ID: "a", Seq: "9"}))
+		store := &memoryCheckpointStore{seq: "8"}
+		c := NewChangesConsumer(f.db, "", f.handler)
+		c.Store = store
+		_ = f.run(c)
+		if store.seq != "9" {
+			t.Errorf("Unexpected stored seq: %s", store.seq)
+		}
+		if len(f.local.docs) != 0 {
+			t.Errorf("Unexpected local docs: %v", f.local.docs)
+		}
+	})
+	t.Run("missing id", func(t *testing.T) {
+		err := NewChangesConsumer(&DB{}, "", func(context.Context, *Changes) error { return nil }).Run(context.Background())
+		testy.StatusError(t, "kivik: consumer id required", http.StatusBadRequest, err)
+	})
+}
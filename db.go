@@ -177,7 +177,10 @@ func (db *DB) GetMeta(ctx context.Context, docID string, options ...Options) (si
 	}
 	opts := mergeOptions(options...)
 	if r, ok := db.driverDB.(driver.MetaGetter); ok {
-		return r.GetMeta(ctx, docID, opts)
+		size, rev, err = r.GetMeta(ctx, docID, opts)
+		if !isNotImplemented(err) {
+			return size, rev, err
+		}
 	}
 	row := db.Get(ctx, docID, opts)
 	if row.Err != nil {
@@ -362,6 +365,9 @@ func (db *DB) Stats(ctx context.Context) (*DBStats, error) {
 }
 
 func driverStats2kivikStats(i *driver.DBStats) *DBStats {
+	if i == nil {
+		return nil
+	}
 	var cluster *ClusterConfig
 	if i.Cluster != nil {
 		c := ClusterConfig(*i.Cluster)
@@ -468,7 +474,10 @@ func (db *DB) Copy(ctx context.Context, targetID, sourceID string, options ...Op
 	}
 	opts := mergeOptions(options...)
 	if copier, ok := db.driverDB.(driver.Copier); ok {
-		return copier.Copy(ctx, targetID, sourceID, opts)
+		targetRev, err = copier.Copy(ctx, targetID, sourceID, opts)
+		if !isNotImplemented(err) {
+			return targetRev, err
+		}
 	}
 	var doc map[string]interface{}
 	if err = db.Get(ctx, sourceID, opts).ScanDoc(&doc); err != nil {
@@ -544,12 +553,16 @@ func (db *DB) GetAttachmentMeta(ctx context.Context, docID, filename string, opt
 	var att *Attachment
 	if metaer, ok := db.driverDB.(driver.AttachmentMetaGetter); ok {
 		a, err := metaer.GetAttachmentMeta(ctx, docID, filename, mergeOptions(options...))
-		if err != nil {
+		switch {
+		case isNotImplemented(err):
+		case err != nil:
 			return nil, err
+		default:
+			att = new(Attachment)
+			*att = Attachment(*a)
 		}
-		att = new(Attachment)
-		*att = Attachment(*a)
-	} else {
+	}
+	if att == nil {
 		var err error
 		att, err = db.GetAttachment(ctx, docID, filename, options...)
 		if err != nil {
@@ -654,7 +667,9 @@ func (db *DB) Close(ctx context.Context) error {
 		return db.err
 	}
 	if closer, ok := db.driverDB.(driver.DBCloser); ok {
-		return closer.Close(ctx)
+		if err := closer.Close(ctx); !isNotImplemented(err) {
+			return err
+		}
 	}
 	return nil
 }
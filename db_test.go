@@ -96,7 +96,7 @@ func TestAllDocs(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			result, err := test.db.AllDocs(context.Background(), test.options)
 			testy.StatusError(t, test.err, test.status, err)
-			result.cancel = nil // Determinism
+			result.ctx, result.stop = nil, nil // Determinism
 			if d := testy.DiffInterface(test.expected, result); d != nil {
 				t.Error(d)
 			}
@@ -159,7 +159,7 @@ func TestDesignDocs(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			result, err := test.db.DesignDocs(context.Background(), test.options)
 			testy.StatusError(t, test.err, test.status, err)
-			result.cancel = nil // Determinism
+			result.ctx, result.stop = nil, nil // Determinism
 			if d := testy.DiffInterface(test.expected, result); d != nil {
 				t.Error(d)
 			}
@@ -222,7 +222,7 @@ func TestLocalDocs(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			result, err := test.db.LocalDocs(context.Background(), test.options)
 			testy.StatusError(t, test.err, test.status, err)
-			result.cancel = nil // Determinism
+			result.ctx, result.stop = nil, nil // Determinism
 			if d := testy.DiffInterface(test.expected, result); d != nil {
 				t.Error(d)
 			}
@@ -290,7 +290,7 @@ func TestQuery(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			result, err := test.db.Query(context.Background(), test.ddoc, test.view, test.options)
 			testy.StatusError(t, test.err, test.status, err)
-			result.cancel = nil // Determinism
+			result.ctx, result.stop = nil, nil // Determinism
 			if d := testy.DiffInterface(test.expected, result); d != nil {
 				t.Error(d)
 			}
@@ -1980,7 +1980,7 @@ func TestBulkGet(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			result, err := test.db.BulkGet(context.Background(), test.docs, test.options)
 			testy.StatusError(t, test.err, test.status, err)
-			result.cancel = nil // Determinism
+			result.ctx, result.stop = nil, nil // Determinism
 			if d := testy.DiffInterface(test.expected, result); d != nil {
 				t.Error(d)
 			}
@@ -2062,7 +2062,7 @@ func TestRevsDiff(t *testing.T) {
 	tests.Run(t, func(t *testing.T, tt tt) {
 		rows, err := tt.db.RevsDiff(context.Background(), tt.revMap)
 		testy.StatusError(t, tt.err, tt.status, err)
-		rows.cancel = nil // Determinism
+		rows.ctx, rows.stop = nil, nil // Determinism
 		if d := testy.DiffInterface(tt.expected, rows); d != nil {
 			t.Error(d)
 		}
@@ -0,0 +1,132 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// Option keys used by DBsStatsBatchSize and DBsStatsConcurrency.
+const (
+	dbsStatsBatchSizeKey   = "kivik:dbs_stats_batch_size"
+	dbsStatsConcurrencyKey = "kivik:dbs_stats_concurrency"
+)
+
+const (
+	// defaultDBsStatsBatchSize matches the default value of CouchDB's
+	// max_db_number_for_dbs_info_req setting.
+	defaultDBsStatsBatchSize   = 100
+	defaultDBsStatsConcurrency = 8
+)
+
+// DBsStatsBatchSize returns an option for Client.DBsStats, which limits the
+// number of databases requested from the driver at once. Longer lists are
+// split into batches of at most n databases. The default is 100, which matches
+// CouchDB's default limit for POST /_dbs_info.
+func DBsStatsBatchSize(n int) Options {
+	return Options{dbsStatsBatchSizeKey: n}
+}
+
+// DBsStatsConcurrency returns an option for Client.DBsStats, which limits the
+// number of simultaneous DB.Stats calls made when the driver or server does
+// not support fetching statistics for multiple databases at once. The default
+// is 8.
+func DBsStatsConcurrency(n int) Options {
+	return Options{dbsStatsConcurrencyKey: n}
+}
+
+// intOption returns the positive integer value of opts[key], or def.
+func intOption(opts Options, key string, def int) int {
+	if n, ok := opts[key].(int); ok && n > 0 {
+		return n
+	}
+	return def
+}
+
+func (c *Client) nativeDBsStats(ctx context.Context, dbnames []string, batchSize int) ([]*DBStats, error) {
+	statser, ok := c.driverClient.(driver.DBsStatser)
+	if !ok {
+		return nil, &Error{HTTPStatus: http.StatusNotImplemented, Message: "kivik: not supported by driver"}
+	}
+	dbstats := make([]*DBStats, 0, len(dbnames))
+	for start := 0; start < len(dbnames); start += batchSize {
+		end := start + batchSize
+		if end > len(dbnames) {
+			end = len(dbnames)
+		}
+		stats, err := statser.DBsStats(ctx, dbnames[start:end])
+		if err != nil {
+			return nil, err
+		}
+		for _, stat := range stats {
+			dbstats = append(dbstats, driverStats2kivikStats(stat))
+		}
+	}
+	return dbstats, nil
+}
+
+// fallbackDBsStats calls DB.Stats for each database, using at most concurrency
+// goroutines. Databases which do not exist result in a nil entry. Any other
+// error aborts the remaining requests, and is returned.
+func (c *Client) fallbackDBsStats(ctx context.Context, dbnames []string, concurrency int) ([]*DBStats, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if concurrency > len(dbnames) {
+		concurrency = len(dbnames)
+	}
+	dbstats := make([]*DBStats, len(dbnames))
+	indexes := make(chan int)
+	errs := make(chan error, concurrency)
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for w := 0; w < concurrency; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				stat, err := c.DB(dbnames[i]).Stats(ctx)
+				if err != nil {
+					if StatusCode(err) == http.StatusNotFound {
+						continue
+					}
+					errs <- err
+					cancel()
+					return
+				}
+				dbstats[i] = stat
+			}
+		}()
+	}
+feed:
+	for i := range dbnames {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indexes)
+	wg.Wait()
+	select {
+	case err := <-errs:
+		return nil, err
+	default:
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return dbstats, nil
+}
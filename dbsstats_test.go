@@ -0,0 +1,177 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func dbNames(n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		names[i] = fmt.Sprintf("db%03d", i)
+	}
+	return names
+}
+
+func TestDBsStatsBatches(t *testing.T) {
+	type tst struct {
+		options  []Options
+		dbnames  []string
+		batches  []int
+		status   int
+		err      string
+		expected int
+	}
+	tests := testy.NewTable()
+	tests.Add("default batch size", tst{
+		dbnames:  dbNames(250),
+		batches:  []int{100, 100, 50},
+		expected: 250,
+	})
+	tests.Add("custom batch size", tst{
+		options:  []Options{DBsStatsBatchSize(2)},
+		dbnames:  dbNames(5),
+		batches:  []int{2, 2, 1},
+		expected: 5,
+	})
+	tests.Add("invalid batch size", tst{
+		options:  []Options{DBsStatsBatchSize(0)},
+		dbnames:  dbNames(5),
+		batches:  []int{5},
+		expected: 5,
+	})
+	tests.Add("no databases", tst{
+		dbnames:  []string{},
+		expected: 0,
+	})
+	tests.Add("error in second batch", tst{
+		options: []Options{DBsStatsBatchSize(2)},
+		dbnames: dbNames(5),
+		batches: []int{2, 2},
+		status:  http.StatusBadGateway,
+		err:     "batch failed",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tst) {
+		var batches []int
+		client := &Client{
+			driverClient: &mock.DBsStatser{
+				DBsStatsFunc: func(_ context.Context, names []string) ([]*driver.DBStats, error) {
+					batches = append(batches, len(names))
+					if tt.err != "" && len(batches) == 2 {
+						return nil, &Error{HTTPStatus: tt.status, Message: tt.err}
+					}
+					stats := make([]*driver.DBStats, len(names))
+					for i, name := range names {
+						stats[i] = &driver.DBStats{Name: name}
+					}
+					return stats, nil
+				},
+			},
+		}
+		stats, err := client.DBsStats(context.Background(), tt.dbnames, tt.options...)
+		if d := testy.DiffInterface(tt.batches, batches); d != nil {
+			t.Errorf("Unexpected batches:\n%s", d)
+		}
+		testy.StatusError(t, tt.err, tt.status, err)
+		if len(stats) != tt.expected {
+			t.Fatalf("Expected %d results, got %d", tt.expected, len(stats))
+		}
+		for i, stat := range stats {
+			if stat.Name != tt.dbnames[i] {
+				t.Errorf("Result %d: expected %s, got %s", i, tt.dbnames[i], stat.Name)
+			}
+		}
+	})
+}
+
+func TestFallbackDBsStatsConcurrency(t *testing.T) {
+	var mu sync.Mutex
+	var running, maxRunning int
+	client := &Client{
+		driverClient: &mock.Client{
+			DBFunc: func(name string, _ map[string]interface{}) (driver.DB, error) {
+				return &mock.DB{
+					StatsFunc: func(_ context.Context) (*driver.DBStats, error) {
+						mu.Lock()
+						running++
+						if running > maxRunning {
+							maxRunning = running
+						}
+						mu.Unlock()
+						time.Sleep(time.Millisecond)
+						mu.Lock()
+						running--
+						mu.Unlock()
+						return &driver.DBStats{Name: name}, nil
+					},
+				}, nil
+			},
+		},
+	}
+	names := dbNames(50)
+	stats, err := client.DBsStats(context.Background(), names, DBsStatsConcurrency(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if maxRunning > 3 {
+		t.Errorf("Expected at most 3 concurrent requests, got %d", maxRunning)
+	}
+	for i, stat := range stats {
+		if stat.Name != names[i] {
+			t.Errorf("Result %d: expected %s, got %s", i, names[i], stat.Name)
+		}
+	}
+}
+
+func TestFallbackDBsStatsAbort(t *testing.T) {
+	var mu sync.Mutex
+	var calls int
+	client := &Client{
+		driverClient: &mock.Client{
+			DBFunc: func(name string, _ map[string]interface{}) (driver.DB, error) {
+				return &mock.DB{
+					StatsFunc: func(ctx context.Context) (*driver.DBStats, error) {
+						mu.Lock()
+						calls++
+						mu.Unlock()
+						if name == "db000" {
+							return nil, errors.New("stats failure")
+						}
+						time.Sleep(time.Millisecond)
+						return &driver.DBStats{Name: name}, ctx.Err()
+					},
+				}, nil
+			},
+		},
+	}
+	_, err := client.DBsStats(context.Background(), dbNames(100), DBsStatsConcurrency(1))
+	if err == nil || err.Error() != "stats failure" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected the first error to abort remaining requests, but %d were made", calls)
+	}
+}
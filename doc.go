@@ -19,12 +19,12 @@ officially supported drivers are:
  - CouchDB: https://github.com/go-kivik/couchdb
  - PouchDB: https://github.com/go-kivik/pouchdb (requires GopherJS)
  - KivikMock: https://github.com/go-kivik/kivikmock
+ - Memory: github.com/go-kivik/kivik/v4/memorydb (registered as "memory")
 
-The Filesystem and Memory drivers are also available, but in early stages of
-development, and so many features do not yet work:
+The Filesystem driver is also available, but in early stages of development,
+and so many features do not yet work:
 
  - Filesystem: https://github.com/go-kivik/fsdb
- - MemroyDB: https://github.com/go-kivik/memorydb
 
 The kivik driver system is modeled after the standard library's `sql` and
 `sql/driver` packages, although the client API is completely different due to
@@ -46,6 +46,32 @@ type Change struct {
 	// Doc is the raw, un-decoded JSON document. This is only populated when
 	// include_docs=true is set.
 	Doc json.RawMessage `json:"doc"`
+
+	// The following fields are optional. When a driver leaves them unset,
+	// kivik reads the equivalent fields from Doc, if present.
+
+	// WinningRev is the winning revision of the document.
+	WinningRev string `json:"-"`
+	// Conflicts is the list of conflicting leaf revisions, as returned in
+	// the document's _conflicts field with conflicts=true.
+	Conflicts []string `json:"-"`
+	// DeletedConflicts is the list of deleted conflicting leaf revisions, as
+	// returned in the document's _deleted_conflicts field.
+	DeletedConflicts []string `json:"-"`
+	// Revisions is the revision history of the winning revision, as returned
+	// in the document's _revisions field.
+	Revisions *Revisions `json:"-"`
+	// RawRow is the raw row, as returned by the backend, useful if you need
+	// additional backend-specific information.
+	RawRow json.RawMessage `json:"-"`
+}
+
+// Revisions represents the _revisions field of a document.
+type Revisions struct {
+	// Start is the generation number of the newest revision.
+	Start int64 `json:"start"`
+	// IDs are the revision hashes, newest first.
+	IDs []string `json:"ids"`
 }
 
 // ChangedRevs represents a "changes" field of a result in the /_changes stream.
@@ -195,6 +195,14 @@ type DB interface {
 	SetSecurity(ctx context.Context, security *Security) error
 	// Changes returns a Rows iterator for the changes feed. In continuous mode,
 	// the iterator will continue indefinitely, until Close is called.
+	//
+	// Filters are requested with the "filter" option, as in CouchDB's query
+	// parameters, and their arguments as follows: "doc_ids" is a []string,
+	// "selector" is a json.RawMessage, and "view" is a string. The query
+	// parameters of a design document filter are passed as top-level options.
+	// "heartbeat" and "timeout" are given in milliseconds. A driver which
+	// cannot apply a filter should return an error with status 501, in which
+	// case kivik emulates the _doc_ids, _selector and _design filters.
 	Changes(ctx context.Context, options map[string]interface{}) (Changes, error)
 	// PutAttachment uploads an attachment to the specified document, returning
 	// the new revision.
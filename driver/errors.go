@@ -12,6 +12,8 @@
 
 package driver
 
+import "net/http"
+
 type err string
 
 func (e err) Error() string {
@@ -21,3 +23,20 @@ func (e err) Error() string {
 // EOQ should be returned by a view iterator at the end of each query result
 // set.
 const EOQ = err("EOQ")
+
+type notImplementedError string
+
+func (e notImplementedError) Error() string {
+	return string(e)
+}
+
+func (e notImplementedError) StatusCode() int {
+	return http.StatusNotImplemented
+}
+
+// ErrNotImplemented may be returned by a method of an optional interface, to
+// indicate that the interface is not actually supported. This allows a type,
+// such as a driver wrapper, to implement optional interfaces conditionally.
+// Kivik treats this error as though the interface were not implemented, and
+// falls back to emulation where it would otherwise do so.
+const ErrNotImplemented = notImplementedError("kivik: not supported by driver")
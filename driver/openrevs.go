@@ -0,0 +1,52 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package driver
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// OpenRev is a single revision of a document, as returned by the open_revs
+// parameter.
+type OpenRev struct {
+	// Rev is the revision ID.
+	Rev string
+	// Missing is true if the requested revision was not found, in which case
+	// Doc is nil.
+	Missing bool
+	// Doc is the raw JSON document at this revision.
+	Doc json.RawMessage
+	// Attachments will be nil except when attachments=true, and the
+	// attachment content is not inlined in Doc.
+	Attachments Attachments
+}
+
+// OpenRevs is an iterator over the revisions of a document.
+type OpenRevs interface {
+	// Next is called to populate *OpenRev with the next revision.
+	//
+	// Next should return io.EOF after the last revision.
+	Next(*OpenRev) error
+	// Close closes the iterator.
+	Close() error
+}
+
+// OpenRevsGetter is an optional interface that may be implemented by a DB, to
+// fetch several revisions of a document in a single request.
+type OpenRevsGetter interface {
+	// OpenRevs returns the requested revisions of docID, in the order
+	// requested. If revs is empty, all leaf revisions, including deleted
+	// ones, are returned. Options are those accepted by Get.
+	OpenRevs(ctx context.Context, docID string, revs []string, options map[string]interface{}) (OpenRevs, error)
+}
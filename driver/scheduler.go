@@ -0,0 +1,143 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// SchedulerJobs is a page of results from the /_scheduler/jobs endpoint.
+type SchedulerJobs struct {
+	TotalRows int64           `json:"total_rows"`
+	Offset    int64           `json:"offset"`
+	Jobs      []*SchedulerJob `json:"jobs"`
+}
+
+// SchedulerJob is a replication job known to the replication scheduler.
+type SchedulerJob struct {
+	Database  string           `json:"database"`
+	JobID     string           `json:"id"`
+	PID       string           `json:"pid"`
+	DocID     string           `json:"doc_id"`
+	Source    string           `json:"source"`
+	Target    string           `json:"target"`
+	User      string           `json:"user"`
+	Node      string           `json:"node"`
+	StartTime time.Time        `json:"start_time"`
+	History   []SchedulerEvent `json:"history"`
+	Info      *SchedulerInfo   `json:"info"`
+}
+
+// SchedulerEvent is an entry in the history of a replication job.
+type SchedulerEvent struct {
+	Timestamp time.Time `json:"timestamp"`
+	Type      string    `json:"type"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+// SchedulerInfo contains the replication statistics, or the error, reported
+// for a job or document by the scheduler.
+type SchedulerInfo struct {
+	DocsRead              int64  `json:"docs_read"`
+	DocsWritten           int64  `json:"docs_written"`
+	DocWriteFailures      int64  `json:"doc_write_failures"`
+	MissingRevisionsFound int64  `json:"missing_revisions_found"`
+	RevisionsChecked      int64  `json:"revisions_checked"`
+	ChangesPending        int64  `json:"changes_pending"`
+	SourceSeq             string `json:"source_seq"`
+	CheckpointedSourceSeq string `json:"checkpointed_source_seq"`
+	ThroughSeq            string `json:"through_seq"`
+	Error                 string `json:"error"`
+}
+
+// UnmarshalJSON also accepts a bare string, as reported for errors by
+// CouchDB 2.x, which is stored in Error. Sequences may be JSON strings or
+// numbers, as reported when the source is CouchDB 1.x or PouchDB.
+func (i *SchedulerInfo) UnmarshalJSON(data []byte) error {
+	var msg string
+	if err := json.Unmarshal(data, &msg); err == nil {
+		*i = SchedulerInfo{Error: msg}
+		return nil
+	}
+	type alias SchedulerInfo
+	info := struct {
+		*alias
+		SourceSeq             schedulerSeq `json:"source_seq"`
+		CheckpointedSourceSeq schedulerSeq `json:"checkpointed_source_seq"`
+		ThroughSeq            schedulerSeq `json:"through_seq"`
+	}{alias: (*alias)(i)}
+	if err := json.Unmarshal(data, &info); err != nil {
+		return err
+	}
+	i.SourceSeq = string(info.SourceSeq)
+	i.CheckpointedSourceSeq = string(info.CheckpointedSourceSeq)
+	i.ThroughSeq = string(info.ThroughSeq)
+	return nil
+}
+
+// schedulerSeq is an update sequence, which may be reported as either a JSON
+// string or a number, depending on the source server.
+type schedulerSeq string
+
+func (s *schedulerSeq) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		*s = schedulerSeq(str)
+		return nil
+	}
+	var num json.Number
+	if err := json.Unmarshal(data, &num); err != nil {
+		return err
+	}
+	*s = schedulerSeq(num)
+	return nil
+}
+
+// SchedulerDocs is a page of results from the /_scheduler/docs endpoint.
+type SchedulerDocs struct {
+	TotalRows int64           `json:"total_rows"`
+	Offset    int64           `json:"offset"`
+	Docs      []*SchedulerDoc `json:"docs"`
+}
+
+// SchedulerDoc is the scheduler's view of a replication document.
+type SchedulerDoc struct {
+	Database    string         `json:"database"`
+	DocID       string         `json:"doc_id"`
+	JobID       string         `json:"id"`
+	Node        string         `json:"node"`
+	Source      string         `json:"source"`
+	Target      string         `json:"target"`
+	State       string         `json:"state"`
+	Info        *SchedulerInfo `json:"info"`
+	ErrorCount  int            `json:"error_count"`
+	LastUpdated time.Time      `json:"last_updated"`
+	StartTime   time.Time      `json:"start_time"`
+}
+
+// Scheduler is an optional interface that may be implemented by a Client to
+// provide access to the replication scheduler.
+type Scheduler interface {
+	// SchedulerJobs returns the replication jobs known to the scheduler. The
+	// limit and skip options may be used for pagination.
+	SchedulerJobs(ctx context.Context, options map[string]interface{}) (*SchedulerJobs, error)
+	// SchedulerDocs returns the scheduler's state for the documents in
+	// replicatorDB, or in all replicator databases if replicatorDB is empty.
+	// The limit, skip and states options may be used to filter the results.
+	SchedulerDocs(ctx context.Context, replicatorDB string, options map[string]interface{}) (*SchedulerDocs, error)
+	// SchedulerDoc returns the scheduler's state for a single replication
+	// document.
+	SchedulerDoc(ctx context.Context, replicatorDB, docID string) (*SchedulerDoc, error)
+}
@@ -42,6 +42,34 @@ type Searcher interface {
 	Search(ctx context.Context, ddoc, index, query string, options map[string]interface{}) (Rows, error)
 	// SearchInfo returns statistics about the specified search index.
 	SearchInfo(ctx context.Context, ddoc, index string) (*SearchInfo, error)
-	// SerachAnalyze tests the results of Lucene analyzer tokenization on sample text.
+	// SearchAnalyze tests the results of Lucene analyzer tokenization on sample text.
 	SearchAnalyze(ctx context.Context, text string) ([]string, error)
 }
+
+// Facet is a single facet result of a full-text search, as returned when the
+// counts or ranges parameters are set.
+type Facet struct {
+	// Name is the name of the faceted field.
+	Name string
+	// Type is the facet type, either "counts" or "ranges".
+	Type string
+	// Values maps each facet value, or range name, to the number of matching
+	// documents.
+	Values map[string]int64
+}
+
+// Faceter is an optional interface that may be implemented by a Rows returned
+// by Search, to expose facet counts and ranges.
+type Faceter interface {
+	// Facets returns the facets included in the search result, if any.
+	Facets() []Facet
+}
+
+// SearchGrouper is an optional interface that may be implemented by a Rows
+// returned by Search, when the group_field parameter is used. Rows should
+// return EOQ at the end of each group.
+type SearchGrouper interface {
+	// SearchGroup returns the group_field value of the current group, and the
+	// total number of rows matched within that group.
+	SearchGroup() (by string, totalRows int64)
+}
@@ -0,0 +1,27 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package driver
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// ActiveTasker is an optional interface that may be implemented by a Client
+// to list the tasks running on the server.
+type ActiveTasker interface {
+	// ActiveTasks returns one JSON object per running task, in the format
+	// returned by CouchDB's /_active_tasks endpoint. See
+	// https://docs.couchdb.org/en/stable/api/server/common.html#active-tasks
+	ActiveTasks(ctx context.Context) ([]json.RawMessage, error)
+}
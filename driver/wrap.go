@@ -0,0 +1,127 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package driver
+
+import (
+	"context"
+	"sync"
+)
+
+// Call describes a single call to a driver method, as passed to an
+// Interceptor.
+type Call struct {
+	// Driver is the name of the wrapped driver, as passed to Wrap.
+	Driver string
+	// Method is the name of the method being called, such as "AllDBs" or
+	// "Get". Methods of optional interfaces use the same names.
+	Method string
+	// DBName is the name of the database, for DB methods, and for Client
+	// methods which accept a database name.
+	DBName string
+	// DocID is the document ID, for methods which operate on a single
+	// document.
+	DocID string
+	// Options are the options passed to the method, if any.
+	Options map[string]interface{}
+	// Iterator is true if the method returns an iterator, such as Rows or
+	// Changes. See OnIteratorClose.
+	Iterator bool
+
+	mu      sync.Mutex
+	onClose []func(count int64, err error)
+}
+
+// OnIteratorClose registers fn to be called once the iterator returned by the
+// call has been closed. count is the number of items read from the iterator,
+// and err is the error which terminated iteration, if any, not counting
+// io.EOF. fn is never called if the call returns an error, or for calls which
+// do not return an iterator.
+func (c *Call) OnIteratorClose(fn func(count int64, err error)) {
+	c.mu.Lock()
+	c.onClose = append(c.onClose, fn)
+	c.mu.Unlock()
+}
+
+func (c *Call) closeFuncs() []func(int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.onClose
+}
+
+// Invoker performs the intercepted call, or passes it on to the next
+// Interceptor in the chain.
+type Invoker func(ctx context.Context) error
+
+// Interceptor intercepts a driver method call. It must call invoke to perform
+// the call, unless it wishes to prevent it, and should return the error
+// returned by invoke, or an error of its own. An Interceptor may call invoke
+// more than once, such as to retry after refreshing authentication.
+type Interceptor func(ctx context.Context, call *Call, invoke Invoker) error
+
+// Wrap returns a Driver which wraps d, passing every method call of the
+// Clients and DBs it returns through interceptors, in order. name identifies
+// the wrapped driver to interceptors, as Call.Driver.
+//
+// Rows, Changes, BulkResults and DBUpdates iterators returned by wrapped
+// methods are wrapped as well, so that interceptors can observe their
+// lifetime with Call.OnIteratorClose.
+//
+// The wrapped Client and DB implement every optional interface defined by this
+// package. When the wrapped value does not implement an optional interface,
+// the corresponding method returns ErrNotImplemented without invoking any
+// interceptors, which Kivik treats as though the interface were not
+// implemented at all.
+func Wrap(name string, d Driver, interceptors ...Interceptor) Driver {
+	return &wrappedDriver{
+		Driver:  d,
+		wrapper: &wrapper{driver: name, interceptors: interceptors},
+	}
+}
+
+type wrapper struct {
+	driver       string
+	interceptors []Interceptor
+}
+
+func (w *wrapper) call(method string) *Call {
+	return &Call{Driver: w.driver, Method: method}
+}
+
+// intercept passes call through the interceptor chain, ending with fn.
+func (w *wrapper) intercept(ctx context.Context, call *Call, fn Invoker) error {
+	return w.chain(0, call, fn)(ctx)
+}
+
+func (w *wrapper) chain(i int, call *Call, fn Invoker) Invoker {
+	if i == len(w.interceptors) {
+		return fn
+	}
+	return func(ctx context.Context) error {
+		return w.interceptors[i](ctx, call, w.chain(i+1, call, fn))
+	}
+}
+
+type wrappedDriver struct {
+	Driver
+	*wrapper
+}
+
+var _ Driver = &wrappedDriver{}
+
+func (d *wrappedDriver) NewClient(name string, options map[string]interface{}) (Client, error) {
+	client, err := d.Driver.NewClient(name, options)
+	if err != nil {
+		return nil, err
+	}
+	return &wrappedClient{client: client, wrapper: d.wrapper}, nil
+}
@@ -0,0 +1,249 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package driver_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func wrapClient(t *testing.T, client driver.Client, interceptors ...driver.Interceptor) driver.Client {
+	t.Helper()
+	d := driver.Wrap("mock", &mock.Driver{
+		NewClientFunc: func(_ string, _ map[string]interface{}) (driver.Client, error) {
+			return client, nil
+		},
+	}, interceptors...)
+	c, err := d.NewClient("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func wrapDB(t *testing.T, db driver.DB, interceptors ...driver.Interceptor) driver.DB {
+	t.Helper()
+	c := wrapClient(t, &mock.Client{
+		DBFunc: func(_ string, _ map[string]interface{}) (driver.DB, error) {
+			return db, nil
+		},
+	}, interceptors...)
+	wdb, err := c.DB("foo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wdb
+}
+
+func TestWrapInterceptorOrder(t *testing.T) {
+	var log []string
+	logger := func(name string) driver.Interceptor {
+		return func(ctx context.Context, call *driver.Call, invoke driver.Invoker) error {
+			log = append(log, name+" before "+call.Method)
+			err := invoke(ctx)
+			log = append(log, name+" after "+call.Method)
+			return err
+		}
+	}
+	c := wrapClient(t, &mock.Client{
+		VersionFunc: func(_ context.Context) (*driver.Version, error) {
+			log = append(log, "Version")
+			return &driver.Version{Version: "1.0"}, nil
+		},
+	}, logger("a"), logger("b"))
+	ver, err := c.Version(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ver.Version != "1.0" {
+		t.Errorf("Unexpected version: %s", ver.Version)
+	}
+	expected := []string{"a before Version", "b before Version", "Version", "b after Version", "a after Version"}
+	if d := testy.DiffInterface(expected, log); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestWrapInterceptorError(t *testing.T) {
+	var called bool
+	c := wrapClient(t, &mock.Client{
+		AllDBsFunc: func(_ context.Context, _ map[string]interface{}) ([]string, error) {
+			called = true
+			return nil, nil
+		},
+	}, func(_ context.Context, _ *driver.Call, _ driver.Invoker) error {
+		return errors.New("denied")
+	})
+	_, err := c.AllDBs(context.Background(), nil)
+	testy.Error(t, "denied", err)
+	if called {
+		t.Error("driver should not have been called")
+	}
+}
+
+func TestWrapCall(t *testing.T) {
+	var got *driver.Call
+	db := wrapDB(t, &mock.DB{
+		GetFunc: func(_ context.Context, _ string, _ map[string]interface{}) (*driver.Document, error) {
+			return &driver.Document{}, nil
+		},
+	}, func(ctx context.Context, call *driver.Call, invoke driver.Invoker) error {
+		got = call
+		return invoke(ctx)
+	})
+	opts := map[string]interface{}{"rev": "1-xxx"}
+	if _, err := db.Get(context.Background(), "bar", opts); err != nil {
+		t.Fatal(err)
+	}
+	if got.Driver != "mock" || got.Method != "Get" || got.DBName != "foo" || got.DocID != "bar" || got.Iterator {
+		t.Errorf("Unexpected call: %+v", got)
+	}
+	if d := testy.DiffInterface(opts, got.Options); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestWrapOptionalInterfaces(t *testing.T) {
+	var calls int
+	counter := func(ctx context.Context, _ *driver.Call, invoke driver.Invoker) error {
+		calls++
+		return invoke(ctx)
+	}
+	t.Run("implemented", func(t *testing.T) {
+		calls = 0
+		db := wrapDB(t, &mock.Copier{
+			CopyFunc: func(_ context.Context, _, _ string, _ map[string]interface{}) (string, error) {
+				return "1-xxx", nil
+			},
+		}, counter)
+		copier, ok := db.(driver.Copier)
+		if !ok {
+			t.Fatal("wrapped DB should implement Copier")
+		}
+		rev, err := copier.Copy(context.Background(), "bar", "baz", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rev != "1-xxx" || calls != 1 {
+			t.Errorf("Unexpected result: rev=%s, calls=%d", rev, calls)
+		}
+	})
+	t.Run("not implemented", func(t *testing.T) {
+		calls = 0
+		db := wrapDB(t, &mock.DB{}, counter)
+		_, err := db.(driver.Copier).Copy(context.Background(), "bar", "baz", nil)
+		if err != driver.ErrNotImplemented {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if calls != 0 {
+			t.Errorf("interceptors should not be called, got %d calls", calls)
+		}
+	})
+	t.Run("legacy Finder", func(t *testing.T) {
+		db := wrapDB(t, &mock.Finder{
+			FindFunc: func(_ context.Context, _ interface{}) (driver.Rows, error) {
+				return &mock.Rows{}, nil
+			},
+		})
+		if _, err := db.(driver.OptsFinder).Find(context.Background(), nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("legacy DBUpdater", func(t *testing.T) {
+		c := wrapClient(t, &mock.DBUpdater{
+			DBUpdatesFunc: func(_ context.Context) (driver.DBUpdates, error) {
+				return &mock.DBUpdates{}, nil
+			},
+		})
+		if _, err := c.(driver.DBUpdaterWithOptions).DBUpdates(context.Background(), nil); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestWrapIteratorClose(t *testing.T) {
+	type tt struct {
+		rows      []error
+		closeErr  error
+		wantCount int64
+		wantErr   string
+	}
+	tests := testy.NewTable()
+	tests.Add("success", tt{
+		rows:      []error{nil, nil, driver.EOQ, nil, io.EOF},
+		wantCount: 3,
+	})
+	tests.Add("iteration error", tt{
+		rows:      []error{nil, errors.New("read error")},
+		wantCount: 1,
+		wantErr:   "read error",
+	})
+	tests.Add("close error", tt{
+		rows:     []error{io.EOF},
+		closeErr: errors.New("close error"),
+		wantErr:  "close error",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		var closes int
+		var count int64
+		var iterErr error
+		results := tt.rows
+		db := wrapDB(t, &mock.DB{
+			QueryFunc: func(_ context.Context, _, _ string, _ map[string]interface{}) (driver.Rows, error) {
+				return &mock.Rows{
+					NextFunc: func(_ *driver.Row) error {
+						err := results[0]
+						results = results[1:]
+						return err
+					},
+					CloseFunc: func() error { return tt.closeErr },
+				}, nil
+			},
+		}, func(ctx context.Context, call *driver.Call, invoke driver.Invoker) error {
+			if !call.Iterator {
+				t.Errorf("Query should be flagged as an iterator")
+			}
+			call.OnIteratorClose(func(c int64, err error) {
+				closes++
+				count, iterErr = c, err
+			})
+			return invoke(ctx)
+		})
+		rows, err := db.Query(context.Background(), "_design/foo", "bar", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for {
+			if err := rows.Next(&driver.Row{}); err != nil && err != driver.EOQ {
+				break
+			}
+		}
+		_ = rows.Close()
+		_ = rows.Close()
+		if closes != 1 {
+			t.Errorf("OnIteratorClose callback called %d times", closes)
+		}
+		if count != tt.wantCount {
+			t.Errorf("Unexpected count: %d", count)
+		}
+		testy.Error(t, tt.wantErr, iterErr)
+	})
+}
@@ -0,0 +1,353 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package driver
+
+import (
+	"context"
+	"encoding/json"
+)
+
+type wrappedClient struct {
+	client Client
+	*wrapper
+}
+
+var (
+	_ Client               = &wrappedClient{}
+	_ DBsStatser           = &wrappedClient{}
+	_ ClientReplicator     = &wrappedClient{}
+	_ Authenticator        = &wrappedClient{}
+	_ DBUpdaterWithOptions = &wrappedClient{}
+	_ Pinger               = &wrappedClient{}
+	_ Cluster              = &wrappedClient{}
+	_ ClientCloser         = &wrappedClient{}
+	_ Configer             = &wrappedClient{}
+	_ Sessioner            = &wrappedClient{}
+	_ ActiveTasker         = &wrappedClient{}
+	_ Scheduler            = &wrappedClient{}
+)
+
+func (c *wrappedClient) Version(ctx context.Context) (ver *Version, err error) {
+	err = c.intercept(ctx, c.call("Version"), func(ctx context.Context) error {
+		ver, err = c.client.Version(ctx)
+		return err
+	})
+	return ver, err
+}
+
+func (c *wrappedClient) AllDBs(ctx context.Context, options map[string]interface{}) (dbs []string, err error) {
+	call := c.call("AllDBs")
+	call.Options = options
+	err = c.intercept(ctx, call, func(ctx context.Context) error {
+		dbs, err = c.client.AllDBs(ctx, options)
+		return err
+	})
+	return dbs, err
+}
+
+func (c *wrappedClient) DBExists(ctx context.Context, dbName string, options map[string]interface{}) (exists bool, err error) {
+	call := c.call("DBExists")
+	call.DBName, call.Options = dbName, options
+	err = c.intercept(ctx, call, func(ctx context.Context) error {
+		exists, err = c.client.DBExists(ctx, dbName, options)
+		return err
+	})
+	return exists, err
+}
+
+func (c *wrappedClient) CreateDB(ctx context.Context, dbName string, options map[string]interface{}) error {
+	call := c.call("CreateDB")
+	call.DBName, call.Options = dbName, options
+	return c.intercept(ctx, call, func(ctx context.Context) error {
+		return c.client.CreateDB(ctx, dbName, options)
+	})
+}
+
+func (c *wrappedClient) DestroyDB(ctx context.Context, dbName string, options map[string]interface{}) error {
+	call := c.call("DestroyDB")
+	call.DBName, call.Options = dbName, options
+	return c.intercept(ctx, call, func(ctx context.Context) error {
+		return c.client.DestroyDB(ctx, dbName, options)
+	})
+}
+
+// DB is not intercepted, as it takes no context, and does not normally
+// perform any I/O.
+func (c *wrappedClient) DB(dbName string, options map[string]interface{}) (DB, error) {
+	db, err := c.client.DB(dbName, options)
+	if err != nil {
+		return nil, err
+	}
+	return &wrappedDB{db: db, name: dbName, wrapper: c.wrapper}, nil
+}
+
+func (c *wrappedClient) DBsStats(ctx context.Context, dbNames []string) (stats []*DBStats, err error) {
+	statser, ok := c.client.(DBsStatser)
+	if !ok {
+		return nil, ErrNotImplemented
+	}
+	err = c.intercept(ctx, c.call("DBsStats"), func(ctx context.Context) error {
+		stats, err = statser.DBsStats(ctx, dbNames)
+		return err
+	})
+	return stats, err
+}
+
+func (c *wrappedClient) Replicate(ctx context.Context, targetDSN, sourceDSN string, options map[string]interface{}) (rep Replication, err error) {
+	replicator, ok := c.client.(ClientReplicator)
+	if !ok {
+		return nil, ErrNotImplemented
+	}
+	call := c.call("Replicate")
+	call.Options = options
+	err = c.intercept(ctx, call, func(ctx context.Context) error {
+		rep, err = replicator.Replicate(ctx, targetDSN, sourceDSN, options)
+		return err
+	})
+	return rep, err
+}
+
+func (c *wrappedClient) GetReplications(ctx context.Context, options map[string]interface{}) (reps []Replication, err error) {
+	replicator, ok := c.client.(ClientReplicator)
+	if !ok {
+		return nil, ErrNotImplemented
+	}
+	call := c.call("GetReplications")
+	call.Options = options
+	err = c.intercept(ctx, call, func(ctx context.Context) error {
+		reps, err = replicator.GetReplications(ctx, options)
+		return err
+	})
+	return reps, err
+}
+
+func (c *wrappedClient) Authenticate(ctx context.Context, authenticator interface{}) error {
+	auth, ok := c.client.(Authenticator)
+	if !ok {
+		return ErrNotImplemented
+	}
+	return c.intercept(ctx, c.call("Authenticate"), func(ctx context.Context) error {
+		return auth.Authenticate(ctx, authenticator)
+	})
+}
+
+// DBUpdates satisfies DBUpdaterWithOptions, for drivers which implement either
+// DBUpdater or DBUpdaterWithOptions.
+func (c *wrappedClient) DBUpdates(ctx context.Context, options map[string]interface{}) (updates DBUpdates, err error) {
+	var updaterFunc func(context.Context, map[string]interface{}) (DBUpdates, error)
+	switch t := c.client.(type) {
+	case DBUpdaterWithOptions:
+		updaterFunc = t.DBUpdates
+	case DBUpdater:
+		updaterFunc = func(ctx context.Context, _ map[string]interface{}) (DBUpdates, error) {
+			return t.DBUpdates(ctx)
+		}
+	default:
+		return nil, ErrNotImplemented
+	}
+	call := c.call("DBUpdates")
+	call.Options = options
+	call.Iterator = true
+	err = c.intercept(ctx, call, func(ctx context.Context) error {
+		updates, err = updaterFunc(ctx, options)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &wrappedDBUpdates{DBUpdates: updates, iterState: newIterState(call)}, nil
+}
+
+func (c *wrappedClient) ActiveTasks(ctx context.Context) (tasks []json.RawMessage, err error) {
+	tasker, ok := c.client.(ActiveTasker)
+	if !ok {
+		return nil, ErrNotImplemented
+	}
+	err = c.intercept(ctx, c.call("ActiveTasks"), func(ctx context.Context) error {
+		tasks, err = tasker.ActiveTasks(ctx)
+		return err
+	})
+	return tasks, err
+}
+
+func (c *wrappedClient) SchedulerJobs(ctx context.Context, options map[string]interface{}) (jobs *SchedulerJobs, err error) {
+	scheduler, ok := c.client.(Scheduler)
+	if !ok {
+		return nil, ErrNotImplemented
+	}
+	call := c.call("SchedulerJobs")
+	call.Options = options
+	err = c.intercept(ctx, call, func(ctx context.Context) error {
+		jobs, err = scheduler.SchedulerJobs(ctx, options)
+		return err
+	})
+	return jobs, err
+}
+
+func (c *wrappedClient) SchedulerDocs(ctx context.Context, replicatorDB string, options map[string]interface{}) (docs *SchedulerDocs, err error) {
+	scheduler, ok := c.client.(Scheduler)
+	if !ok {
+		return nil, ErrNotImplemented
+	}
+	call := c.call("SchedulerDocs")
+	call.DBName, call.Options = replicatorDB, options
+	err = c.intercept(ctx, call, func(ctx context.Context) error {
+		docs, err = scheduler.SchedulerDocs(ctx, replicatorDB, options)
+		return err
+	})
+	return docs, err
+}
+
+func (c *wrappedClient) SchedulerDoc(ctx context.Context, replicatorDB, docID string) (doc *SchedulerDoc, err error) {
+	scheduler, ok := c.client.(Scheduler)
+	if !ok {
+		return nil, ErrNotImplemented
+	}
+	call := c.call("SchedulerDoc")
+	call.DBName, call.DocID = replicatorDB, docID
+	err = c.intercept(ctx, call, func(ctx context.Context) error {
+		doc, err = scheduler.SchedulerDoc(ctx, replicatorDB, docID)
+		return err
+	})
+	return doc, err
+}
+
+func (c *wrappedClient) Ping(ctx context.Context) (up bool, err error) {
+	pinger, ok := c.client.(Pinger)
+	if !ok {
+		return false, ErrNotImplemented
+	}
+	err = c.intercept(ctx, c.call("Ping"), func(ctx context.Context) error {
+		up, err = pinger.Ping(ctx)
+		return err
+	})
+	return up, err
+}
+
+func (c *wrappedClient) ClusterStatus(ctx context.Context, options map[string]interface{}) (status string, err error) {
+	cluster, ok := c.client.(Cluster)
+	if !ok {
+		return "", ErrNotImplemented
+	}
+	call := c.call("ClusterStatus")
+	call.Options = options
+	err = c.intercept(ctx, call, func(ctx context.Context) error {
+		status, err = cluster.ClusterStatus(ctx, options)
+		return err
+	})
+	return status, err
+}
+
+func (c *wrappedClient) ClusterSetup(ctx context.Context, action interface{}) error {
+	cluster, ok := c.client.(Cluster)
+	if !ok {
+		return ErrNotImplemented
+	}
+	return c.intercept(ctx, c.call("ClusterSetup"), func(ctx context.Context) error {
+		return cluster.ClusterSetup(ctx, action)
+	})
+}
+
+func (c *wrappedClient) Membership(ctx context.Context) (membership *ClusterMembership, err error) {
+	cluster, ok := c.client.(Cluster)
+	if !ok {
+		return nil, ErrNotImplemented
+	}
+	err = c.intercept(ctx, c.call("Membership"), func(ctx context.Context) error {
+		membership, err = cluster.Membership(ctx)
+		return err
+	})
+	return membership, err
+}
+
+func (c *wrappedClient) Close(ctx context.Context) error {
+	closer, ok := c.client.(ClientCloser)
+	if !ok {
+		return ErrNotImplemented
+	}
+	return c.intercept(ctx, c.call("Close"), func(ctx context.Context) error {
+		return closer.Close(ctx)
+	})
+}
+
+func (c *wrappedClient) Config(ctx context.Context, node string) (config Config, err error) {
+	configer, ok := c.client.(Configer)
+	if !ok {
+		return nil, ErrNotImplemented
+	}
+	err = c.intercept(ctx, c.call("Config"), func(ctx context.Context) error {
+		config, err = configer.Config(ctx, node)
+		return err
+	})
+	return config, err
+}
+
+func (c *wrappedClient) ConfigSection(ctx context.Context, node, section string) (config ConfigSection, err error) {
+	configer, ok := c.client.(Configer)
+	if !ok {
+		return nil, ErrNotImplemented
+	}
+	err = c.intercept(ctx, c.call("ConfigSection"), func(ctx context.Context) error {
+		config, err = configer.ConfigSection(ctx, node, section)
+		return err
+	})
+	return config, err
+}
+
+func (c *wrappedClient) ConfigValue(ctx context.Context, node, section, key string) (value string, err error) {
+	configer, ok := c.client.(Configer)
+	if !ok {
+		return "", ErrNotImplemented
+	}
+	err = c.intercept(ctx, c.call("ConfigValue"), func(ctx context.Context) error {
+		value, err = configer.ConfigValue(ctx, node, section, key)
+		return err
+	})
+	return value, err
+}
+
+func (c *wrappedClient) SetConfigValue(ctx context.Context, node, section, key, value string) (oldValue string, err error) {
+	configer, ok := c.client.(Configer)
+	if !ok {
+		return "", ErrNotImplemented
+	}
+	err = c.intercept(ctx, c.call("SetConfigValue"), func(ctx context.Context) error {
+		oldValue, err = configer.SetConfigValue(ctx, node, section, key, value)
+		return err
+	})
+	return oldValue, err
+}
+
+func (c *wrappedClient) DeleteConfigKey(ctx context.Context, node, section, key string) (oldValue string, err error) {
+	configer, ok := c.client.(Configer)
+	if !ok {
+		return "", ErrNotImplemented
+	}
+	err = c.intercept(ctx, c.call("DeleteConfigKey"), func(ctx context.Context) error {
+		oldValue, err = configer.DeleteConfigKey(ctx, node, section, key)
+		return err
+	})
+	return oldValue, err
+}
+
+func (c *wrappedClient) Session(ctx context.Context) (session *Session, err error) {
+	sessioner, ok := c.client.(Sessioner)
+	if !ok {
+		return nil, ErrNotImplemented
+	}
+	err = c.intercept(ctx, c.call("Session"), func(ctx context.Context) error {
+		session, err = sessioner.Session(ctx)
+		return err
+	})
+	return session, err
+}
@@ -0,0 +1,461 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package driver
+
+import "context"
+
+type wrappedDB struct {
+	db   DB
+	name string
+	*wrapper
+}
+
+var (
+	_ DB                   = &wrappedDB{}
+	_ DesignDocer          = &wrappedDB{}
+	_ LocalDocer           = &wrappedDB{}
+	_ MetaGetter           = &wrappedDB{}
+	_ Flusher              = &wrappedDB{}
+	_ Copier               = &wrappedDB{}
+	_ AttachmentMetaGetter = &wrappedDB{}
+	_ Purger               = &wrappedDB{}
+	_ BulkDocer            = &wrappedDB{}
+	_ BulkGetter           = &wrappedDB{}
+	_ OptsFinder           = &wrappedDB{}
+	_ RevsDiffer           = &wrappedDB{}
+	_ OpenRevsGetter       = &wrappedDB{}
+	_ PartitionedDB        = &wrappedDB{}
+	_ Searcher             = &wrappedDB{}
+	_ DBCloser             = &wrappedDB{}
+)
+
+func (d *wrappedDB) call(method, docID string, options map[string]interface{}) *Call {
+	call := d.wrapper.call(method)
+	call.DBName, call.DocID, call.Options = d.name, docID, options
+	return call
+}
+
+func (d *wrappedDB) rows(ctx context.Context, call *Call, fn func(context.Context) (Rows, error)) (rows Rows, err error) {
+	call.Iterator = true
+	err = d.intercept(ctx, call, func(ctx context.Context) error {
+		rows, err = fn(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &wrappedRows{Rows: rows, iterState: newIterState(call)}, nil
+}
+
+func (d *wrappedDB) AllDocs(ctx context.Context, options map[string]interface{}) (Rows, error) {
+	return d.rows(ctx, d.call("AllDocs", "", options), func(ctx context.Context) (Rows, error) {
+		return d.db.AllDocs(ctx, options)
+	})
+}
+
+func (d *wrappedDB) Get(ctx context.Context, docID string, options map[string]interface{}) (doc *Document, err error) {
+	err = d.intercept(ctx, d.call("Get", docID, options), func(ctx context.Context) error {
+		doc, err = d.db.Get(ctx, docID, options)
+		return err
+	})
+	return doc, err
+}
+
+func (d *wrappedDB) CreateDoc(ctx context.Context, doc interface{}, options map[string]interface{}) (docID, rev string, err error) {
+	err = d.intercept(ctx, d.call("CreateDoc", "", options), func(ctx context.Context) error {
+		docID, rev, err = d.db.CreateDoc(ctx, doc, options)
+		return err
+	})
+	return docID, rev, err
+}
+
+func (d *wrappedDB) Put(ctx context.Context, docID string, doc interface{}, options map[string]interface{}) (rev string, err error) {
+	err = d.intercept(ctx, d.call("Put", docID, options), func(ctx context.Context) error {
+		rev, err = d.db.Put(ctx, docID, doc, options)
+		return err
+	})
+	return rev, err
+}
+
+func (d *wrappedDB) Delete(ctx context.Context, docID, rev string, options map[string]interface{}) (newRev string, err error) {
+	err = d.intercept(ctx, d.call("Delete", docID, options), func(ctx context.Context) error {
+		newRev, err = d.db.Delete(ctx, docID, rev, options)
+		return err
+	})
+	return newRev, err
+}
+
+func (d *wrappedDB) Stats(ctx context.Context) (stats *DBStats, err error) {
+	err = d.intercept(ctx, d.call("Stats", "", nil), func(ctx context.Context) error {
+		stats, err = d.db.Stats(ctx)
+		return err
+	})
+	return stats, err
+}
+
+func (d *wrappedDB) Compact(ctx context.Context) error {
+	return d.intercept(ctx, d.call("Compact", "", nil), func(ctx context.Context) error {
+		return d.db.Compact(ctx)
+	})
+}
+
+func (d *wrappedDB) CompactView(ctx context.Context, ddocID string) error {
+	return d.intercept(ctx, d.call("CompactView", ddocID, nil), func(ctx context.Context) error {
+		return d.db.CompactView(ctx, ddocID)
+	})
+}
+
+func (d *wrappedDB) ViewCleanup(ctx context.Context) error {
+	return d.intercept(ctx, d.call("ViewCleanup", "", nil), func(ctx context.Context) error {
+		return d.db.ViewCleanup(ctx)
+	})
+}
+
+func (d *wrappedDB) Security(ctx context.Context) (security *Security, err error) {
+	err = d.intercept(ctx, d.call("Security", "", nil), func(ctx context.Context) error {
+		security, err = d.db.Security(ctx)
+		return err
+	})
+	return security, err
+}
+
+func (d *wrappedDB) SetSecurity(ctx context.Context, security *Security) error {
+	return d.intercept(ctx, d.call("SetSecurity", "", nil), func(ctx context.Context) error {
+		return d.db.SetSecurity(ctx, security)
+	})
+}
+
+func (d *wrappedDB) Changes(ctx context.Context, options map[string]interface{}) (changes Changes, err error) {
+	call := d.call("Changes", "", options)
+	call.Iterator = true
+	err = d.intercept(ctx, call, func(ctx context.Context) error {
+		changes, err = d.db.Changes(ctx, options)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &wrappedChanges{Changes: changes, iterState: newIterState(call)}, nil
+}
+
+func (d *wrappedDB) PutAttachment(ctx context.Context, docID, rev string, att *Attachment, options map[string]interface{}) (newRev string, err error) {
+	err = d.intercept(ctx, d.call("PutAttachment", docID, options), func(ctx context.Context) error {
+		newRev, err = d.db.PutAttachment(ctx, docID, rev, att, options)
+		return err
+	})
+	return newRev, err
+}
+
+func (d *wrappedDB) GetAttachment(ctx context.Context, docID, filename string, options map[string]interface{}) (att *Attachment, err error) {
+	err = d.intercept(ctx, d.call("GetAttachment", docID, options), func(ctx context.Context) error {
+		att, err = d.db.GetAttachment(ctx, docID, filename, options)
+		return err
+	})
+	return att, err
+}
+
+func (d *wrappedDB) DeleteAttachment(ctx context.Context, docID, rev, filename string, options map[string]interface{}) (newRev string, err error) {
+	err = d.intercept(ctx, d.call("DeleteAttachment", docID, options), func(ctx context.Context) error {
+		newRev, err = d.db.DeleteAttachment(ctx, docID, rev, filename, options)
+		return err
+	})
+	return newRev, err
+}
+
+func (d *wrappedDB) Query(ctx context.Context, ddoc, view string, options map[string]interface{}) (Rows, error) {
+	return d.rows(ctx, d.call("Query", ddoc, options), func(ctx context.Context) (Rows, error) {
+		return d.db.Query(ctx, ddoc, view, options)
+	})
+}
+
+func (d *wrappedDB) DesignDocs(ctx context.Context, options map[string]interface{}) (Rows, error) {
+	ddocer, ok := d.db.(DesignDocer)
+	if !ok {
+		return nil, ErrNotImplemented
+	}
+	return d.rows(ctx, d.call("DesignDocs", "", options), func(ctx context.Context) (Rows, error) {
+		return ddocer.DesignDocs(ctx, options)
+	})
+}
+
+func (d *wrappedDB) LocalDocs(ctx context.Context, options map[string]interface{}) (Rows, error) {
+	ldocer, ok := d.db.(LocalDocer)
+	if !ok {
+		return nil, ErrNotImplemented
+	}
+	return d.rows(ctx, d.call("LocalDocs", "", options), func(ctx context.Context) (Rows, error) {
+		return ldocer.LocalDocs(ctx, options)
+	})
+}
+
+func (d *wrappedDB) GetMeta(ctx context.Context, docID string, options map[string]interface{}) (size int64, rev string, err error) {
+	metaGetter, ok := d.db.(MetaGetter)
+	if !ok {
+		return 0, "", ErrNotImplemented
+	}
+	err = d.intercept(ctx, d.call("GetMeta", docID, options), func(ctx context.Context) error {
+		size, rev, err = metaGetter.GetMeta(ctx, docID, options)
+		return err
+	})
+	return size, rev, err
+}
+
+func (d *wrappedDB) Flush(ctx context.Context) error {
+	flusher, ok := d.db.(Flusher)
+	if !ok {
+		return ErrNotImplemented
+	}
+	return d.intercept(ctx, d.call("Flush", "", nil), func(ctx context.Context) error {
+		return flusher.Flush(ctx)
+	})
+}
+
+func (d *wrappedDB) Copy(ctx context.Context, targetID, sourceID string, options map[string]interface{}) (targetRev string, err error) {
+	copier, ok := d.db.(Copier)
+	if !ok {
+		return "", ErrNotImplemented
+	}
+	err = d.intercept(ctx, d.call("Copy", targetID, options), func(ctx context.Context) error {
+		targetRev, err = copier.Copy(ctx, targetID, sourceID, options)
+		return err
+	})
+	return targetRev, err
+}
+
+func (d *wrappedDB) GetAttachmentMeta(ctx context.Context, docID, filename string, options map[string]interface{}) (att *Attachment, err error) {
+	metaGetter, ok := d.db.(AttachmentMetaGetter)
+	if !ok {
+		return nil, ErrNotImplemented
+	}
+	err = d.intercept(ctx, d.call("GetAttachmentMeta", docID, options), func(ctx context.Context) error {
+		att, err = metaGetter.GetAttachmentMeta(ctx, docID, filename, options)
+		return err
+	})
+	return att, err
+}
+
+func (d *wrappedDB) Purge(ctx context.Context, docRevMap map[string][]string) (result *PurgeResult, err error) {
+	purger, ok := d.db.(Purger)
+	if !ok {
+		return nil, ErrNotImplemented
+	}
+	err = d.intercept(ctx, d.call("Purge", "", nil), func(ctx context.Context) error {
+		result, err = purger.Purge(ctx, docRevMap)
+		return err
+	})
+	return result, err
+}
+
+func (d *wrappedDB) BulkDocs(ctx context.Context, docs []interface{}, options map[string]interface{}) (results BulkResults, err error) {
+	bulkDocer, ok := d.db.(BulkDocer)
+	if !ok {
+		return nil, ErrNotImplemented
+	}
+	call := d.call("BulkDocs", "", options)
+	call.Iterator = true
+	err = d.intercept(ctx, call, func(ctx context.Context) error {
+		results, err = bulkDocer.BulkDocs(ctx, docs, options)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &wrappedBulkResults{BulkResults: results, iterState: newIterState(call)}, nil
+}
+
+func (d *wrappedDB) BulkGet(ctx context.Context, docs []BulkGetReference, options map[string]interface{}) (Rows, error) {
+	bulkGetter, ok := d.db.(BulkGetter)
+	if !ok {
+		return nil, ErrNotImplemented
+	}
+	return d.rows(ctx, d.call("BulkGet", "", options), func(ctx context.Context) (Rows, error) {
+		return bulkGetter.BulkGet(ctx, docs, options)
+	})
+}
+
+// optsFinder returns an OptsFinder for the wrapped DB, adapting a Finder if
+// necessary.
+func (d *wrappedDB) optsFinder() (OptsFinder, bool) {
+	switch t := d.db.(type) {
+	case OptsFinder:
+		return t, true
+	case Finder:
+		return finderAdapter{t}, true
+	}
+	return nil, false
+}
+
+func (d *wrappedDB) Find(ctx context.Context, query interface{}, options map[string]interface{}) (Rows, error) {
+	finder, ok := d.optsFinder()
+	if !ok {
+		return nil, ErrNotImplemented
+	}
+	return d.rows(ctx, d.call("Find", "", options), func(ctx context.Context) (Rows, error) {
+		return finder.Find(ctx, query, options)
+	})
+}
+
+func (d *wrappedDB) CreateIndex(ctx context.Context, ddoc, name string, index interface{}, options map[string]interface{}) error {
+	finder, ok := d.optsFinder()
+	if !ok {
+		return ErrNotImplemented
+	}
+	return d.intercept(ctx, d.call("CreateIndex", ddoc, options), func(ctx context.Context) error {
+		return finder.CreateIndex(ctx, ddoc, name, index, options)
+	})
+}
+
+func (d *wrappedDB) GetIndexes(ctx context.Context, options map[string]interface{}) (indexes []Index, err error) {
+	finder, ok := d.optsFinder()
+	if !ok {
+		return nil, ErrNotImplemented
+	}
+	err = d.intercept(ctx, d.call("GetIndexes", "", options), func(ctx context.Context) error {
+		indexes, err = finder.GetIndexes(ctx, options)
+		return err
+	})
+	return indexes, err
+}
+
+func (d *wrappedDB) DeleteIndex(ctx context.Context, ddoc, name string, options map[string]interface{}) error {
+	finder, ok := d.optsFinder()
+	if !ok {
+		return ErrNotImplemented
+	}
+	return d.intercept(ctx, d.call("DeleteIndex", ddoc, options), func(ctx context.Context) error {
+		return finder.DeleteIndex(ctx, ddoc, name, options)
+	})
+}
+
+func (d *wrappedDB) Explain(ctx context.Context, query interface{}, options map[string]interface{}) (plan *QueryPlan, err error) {
+	finder, ok := d.optsFinder()
+	if !ok {
+		return nil, ErrNotImplemented
+	}
+	err = d.intercept(ctx, d.call("Explain", "", options), func(ctx context.Context) error {
+		plan, err = finder.Explain(ctx, query, options)
+		return err
+	})
+	return plan, err
+}
+
+func (d *wrappedDB) RevsDiff(ctx context.Context, revMap interface{}) (Rows, error) {
+	revsDiffer, ok := d.db.(RevsDiffer)
+	if !ok {
+		return nil, ErrNotImplemented
+	}
+	return d.rows(ctx, d.call("RevsDiff", "", nil), func(ctx context.Context) (Rows, error) {
+		return revsDiffer.RevsDiff(ctx, revMap)
+	})
+}
+
+func (d *wrappedDB) OpenRevs(ctx context.Context, docID string, revs []string, options map[string]interface{}) (openRevs OpenRevs, err error) {
+	getter, ok := d.db.(OpenRevsGetter)
+	if !ok {
+		return nil, ErrNotImplemented
+	}
+	call := d.call("OpenRevs", docID, options)
+	call.Iterator = true
+	err = d.intercept(ctx, call, func(ctx context.Context) error {
+		openRevs, err = getter.OpenRevs(ctx, docID, revs, options)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &wrappedOpenRevs{OpenRevs: openRevs, iterState: newIterState(call)}, nil
+}
+
+func (d *wrappedDB) PartitionStats(ctx context.Context, name string) (stats *PartitionStats, err error) {
+	pdb, ok := d.db.(PartitionedDB)
+	if !ok {
+		return nil, ErrNotImplemented
+	}
+	err = d.intercept(ctx, d.call("PartitionStats", "", nil), func(ctx context.Context) error {
+		stats, err = pdb.PartitionStats(ctx, name)
+		return err
+	})
+	return stats, err
+}
+
+func (d *wrappedDB) Search(ctx context.Context, ddoc, index, query string, options map[string]interface{}) (Rows, error) {
+	searcher, ok := d.db.(Searcher)
+	if !ok {
+		return nil, ErrNotImplemented
+	}
+	return d.rows(ctx, d.call("Search", ddoc, options), func(ctx context.Context) (Rows, error) {
+		return searcher.Search(ctx, ddoc, index, query, options)
+	})
+}
+
+func (d *wrappedDB) SearchInfo(ctx context.Context, ddoc, index string) (info *SearchInfo, err error) {
+	searcher, ok := d.db.(Searcher)
+	if !ok {
+		return nil, ErrNotImplemented
+	}
+	err = d.intercept(ctx, d.call("SearchInfo", ddoc, nil), func(ctx context.Context) error {
+		info, err = searcher.SearchInfo(ctx, ddoc, index)
+		return err
+	})
+	return info, err
+}
+
+func (d *wrappedDB) SearchAnalyze(ctx context.Context, text string) (tokens []string, err error) {
+	searcher, ok := d.db.(Searcher)
+	if !ok {
+		return nil, ErrNotImplemented
+	}
+	err = d.intercept(ctx, d.call("SearchAnalyze", "", nil), func(ctx context.Context) error {
+		tokens, err = searcher.SearchAnalyze(ctx, text)
+		return err
+	})
+	return tokens, err
+}
+
+func (d *wrappedDB) Close(ctx context.Context) error {
+	closer, ok := d.db.(DBCloser)
+	if !ok {
+		return ErrNotImplemented
+	}
+	return d.intercept(ctx, d.call("Close", "", nil), func(ctx context.Context) error {
+		return closer.Close(ctx)
+	})
+}
+
+// finderAdapter adapts a Finder to the OptsFinder interface, discarding
+// options.
+type finderAdapter struct {
+	Finder
+}
+
+var _ OptsFinder = finderAdapter{}
+
+func (f finderAdapter) Find(ctx context.Context, query interface{}, _ map[string]interface{}) (Rows, error) {
+	return f.Finder.Find(ctx, query)
+}
+
+func (f finderAdapter) CreateIndex(ctx context.Context, ddoc, name string, index interface{}, _ map[string]interface{}) error {
+	return f.Finder.CreateIndex(ctx, ddoc, name, index)
+}
+
+func (f finderAdapter) GetIndexes(ctx context.Context, _ map[string]interface{}) ([]Index, error) {
+	return f.Finder.GetIndexes(ctx)
+}
+
+func (f finderAdapter) DeleteIndex(ctx context.Context, ddoc, name string, _ map[string]interface{}) error {
+	return f.Finder.DeleteIndex(ctx, ddoc, name)
+}
+
+func (f finderAdapter) Explain(ctx context.Context, query interface{}, _ map[string]interface{}) (*QueryPlan, error) {
+	return f.Finder.Explain(ctx, query)
+}
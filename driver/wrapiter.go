@@ -0,0 +1,186 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package driver
+
+import (
+	"io"
+	"sync"
+)
+
+// iterState tracks the progress of a wrapped iterator, and reports it to the
+// Call's OnIteratorClose callbacks when the iterator is closed.
+type iterState struct {
+	call *Call
+
+	mu     sync.Mutex
+	count  int64
+	err    error
+	closed bool
+}
+
+func newIterState(call *Call) *iterState {
+	return &iterState{call: call}
+}
+
+// next records the result of a call to the wrapped iterator's Next method.
+func (s *iterState) next(err error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch err {
+	case nil:
+		s.count++
+	case io.EOF, EOQ:
+	default:
+		if s.err == nil {
+			s.err = err
+		}
+	}
+	return err
+}
+
+// close calls the Call's OnIteratorClose callbacks, the first time it is
+// called.
+func (s *iterState) close(err error) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return err
+	}
+	s.closed = true
+	count, iterErr := s.count, s.err
+	if iterErr == nil {
+		iterErr = err
+	}
+	s.mu.Unlock()
+	for _, fn := range s.call.closeFuncs() {
+		fn(count, iterErr)
+	}
+	return err
+}
+
+type wrappedRows struct {
+	Rows
+	*iterState
+}
+
+var (
+	_ Rows          = &wrappedRows{}
+	_ RowsWarner    = &wrappedRows{}
+	_ Bookmarker    = &wrappedRows{}
+	_ QueryIndexer  = &wrappedRows{}
+	_ Faceter       = &wrappedRows{}
+	_ SearchGrouper = &wrappedRows{}
+)
+
+func (r *wrappedRows) Next(row *Row) error {
+	return r.next(r.Rows.Next(row))
+}
+
+func (r *wrappedRows) Close() error {
+	return r.close(r.Rows.Close())
+}
+
+func (r *wrappedRows) Warning() string {
+	if w, ok := r.Rows.(RowsWarner); ok {
+		return w.Warning()
+	}
+	return ""
+}
+
+func (r *wrappedRows) Bookmark() string {
+	if b, ok := r.Rows.(Bookmarker); ok {
+		return b.Bookmark()
+	}
+	return ""
+}
+
+func (r *wrappedRows) QueryIndex() int {
+	if qi, ok := r.Rows.(QueryIndexer); ok {
+		return qi.QueryIndex()
+	}
+	return 0
+}
+
+func (r *wrappedRows) Facets() []Facet {
+	if f, ok := r.Rows.(Faceter); ok {
+		return f.Facets()
+	}
+	return nil
+}
+
+func (r *wrappedRows) SearchGroup() (string, int64) {
+	if g, ok := r.Rows.(SearchGrouper); ok {
+		return g.SearchGroup()
+	}
+	return "", 0
+}
+
+type wrappedChanges struct {
+	Changes
+	*iterState
+}
+
+var _ Changes = &wrappedChanges{}
+
+func (c *wrappedChanges) Next(change *Change) error {
+	return c.next(c.Changes.Next(change))
+}
+
+func (c *wrappedChanges) Close() error {
+	return c.close(c.Changes.Close())
+}
+
+type wrappedBulkResults struct {
+	BulkResults
+	*iterState
+}
+
+var _ BulkResults = &wrappedBulkResults{}
+
+func (r *wrappedBulkResults) Next(result *BulkResult) error {
+	return r.next(r.BulkResults.Next(result))
+}
+
+func (r *wrappedBulkResults) Close() error {
+	return r.close(r.BulkResults.Close())
+}
+
+type wrappedDBUpdates struct {
+	DBUpdates
+	*iterState
+}
+
+var _ DBUpdates = &wrappedDBUpdates{}
+
+func (u *wrappedDBUpdates) Next(update *DBUpdate) error {
+	return u.next(u.DBUpdates.Next(update))
+}
+
+func (u *wrappedDBUpdates) Close() error {
+	return u.close(u.DBUpdates.Close())
+}
+
+type wrappedOpenRevs struct {
+	OpenRevs
+	*iterState
+}
+
+var _ OpenRevs = &wrappedOpenRevs{}
+
+func (r *wrappedOpenRevs) Next(rev *OpenRev) error {
+	return r.next(r.OpenRevs.Next(rev))
+}
+
+func (r *wrappedOpenRevs) Close() error {
+	return r.close(r.OpenRevs.Close())
+}
@@ -18,6 +18,8 @@ import (
 	"strings"
 
 	"golang.org/x/xerrors"
+
+	"github.com/go-kivik/kivik/v4/driver"
 )
 
 // Error represents an error returned by Kivik.
@@ -154,3 +156,9 @@ func StatusCode(err error) int {
 		return http.StatusInternalServerError
 	}
 }
+
+// isNotImplemented returns true if err indicates that the driver does not
+// actually support an optional interface. See driver.ErrNotImplemented.
+func isNotImplemented(err error) bool {
+	return xerrors.Is(err, driver.ErrNotImplemented)
+}
@@ -24,13 +24,19 @@ var findNotImplemented = &Error{HTTPStatus: http.StatusNotImplemented, Message:
 // Find executes a query using the new /_find interface. The query must be
 // JSON-marshalable to a valid query.
 // See http://docs.couchdb.org/en/2.0.0/api/database/find.html#db-find
+//
+// If the driver does not support Find, the query is emulated by reading every
+// document with AllDocs, and evaluating the selector on the client. Options
+// are merged into the query. See the mango package for details.
 func (db *DB) Find(ctx context.Context, query interface{}, options ...Options) (*Rows, error) {
 	if finder, ok := db.driverDB.(driver.OptsFinder); ok {
 		rowsi, err := finder.Find(ctx, query, mergeOptions(options...))
-		if err != nil {
+		switch {
+		case err == nil:
+			return newRows(ctx, rowsi), nil
+		case !isNotImplemented(err):
 			return nil, err
 		}
-		return newRows(ctx, rowsi), nil
 	}
 	// nolint:staticcheck
 	if finder, ok := db.driverDB.(driver.Finder); ok {
@@ -40,7 +46,7 @@ func (db *DB) Find(ctx context.Context, query interface{}, options ...Options) (
 		}
 		return newRows(ctx, rowsi), nil
 	}
-	return nil, findNotImplemented
+	return db.fallbackFind(ctx, query, mergeOptions(options...))
 }
 
 // CreateIndex creates an index if it doesn't already exist. ddoc and name may
@@ -35,12 +35,12 @@ func TestFind(t *testing.T) {
 		err      string
 	}{
 		{
-			name: "non-finder",
+			name: "non-finder, missing selector",
 			db: &DB{
 				driverDB: &mock.DB{},
 			},
-			status: http.StatusNotImplemented,
-			err:    "kivik: driver does not support Find interface",
+			status: http.StatusBadRequest,
+			err:    "Missing required key: selector",
 		},
 		{
 			name: "db error",
@@ -120,7 +120,7 @@ func TestFind(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			result, err := test.db.Find(context.Background(), test.query)
 			testy.StatusError(t, test.err, test.status, err)
-			result.cancel = nil // Determinism
+			result.ctx, result.stop = nil, nil // Determinism
 			if d := testy.DiffInterface(test.expected, result); d != nil {
 				t.Error(d)
 			}
@@ -0,0 +1,261 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/mango"
+)
+
+// findNoIndexWarning is the warning CouchDB returns when a query is not
+// served by an index. It is always returned by emulated queries.
+const findNoIndexWarning = "No matching index found, create an index to optimize query time."
+
+// findQuery is the subset of a /_find request supported by fallbackFind.
+type findQuery struct {
+	Selector json.RawMessage `json:"selector"`
+	Limit    *int64          `json:"limit"`
+	Skip     int64           `json:"skip"`
+	Sort     []interface{}   `json:"sort"`
+	Fields   []string        `json:"fields"`
+}
+
+type findSort struct {
+	field string
+	desc  bool
+}
+
+// parseFindQuery parses query, with the members of opts overriding those of
+// the query, as they do when sent to the server.
+func parseFindQuery(query interface{}, opts map[string]interface{}) (*findQuery, []findSort, error) {
+	q := &findQuery{}
+	for _, part := range []interface{}{query, opts} {
+		raw, err := json.Marshal(part)
+		if err != nil {
+			return nil, nil, &Error{HTTPStatus: http.StatusBadRequest, Err: err}
+		}
+		if err := json.Unmarshal(raw, q); err != nil {
+			return nil, nil, &Error{HTTPStatus: http.StatusBadRequest, Err: err}
+		}
+	}
+	if len(q.Selector) == 0 {
+		return nil, nil, &Error{HTTPStatus: http.StatusBadRequest, Message: "Missing required key: selector"}
+	}
+	if q.Limit != nil && *q.Limit < 0 {
+		return nil, nil, &Error{HTTPStatus: http.StatusBadRequest, Message: "Invalid limit: must not be negative"}
+	}
+	if q.Skip < 0 {
+		return nil, nil, &Error{HTTPStatus: http.StatusBadRequest, Message: "Invalid skip: must not be negative"}
+	}
+	sorts := make([]findSort, 0, len(q.Sort))
+	for _, field := range q.Sort {
+		s, ok := parseFindSort(field)
+		if !ok {
+			return nil, nil, &Error{HTTPStatus: http.StatusBadRequest, Message: "Invalid sort field"}
+		}
+		sorts = append(sorts, s)
+	}
+	return q, sorts, nil
+}
+
+// parseFindSort parses a single sort field, either "field" or
+// {"field": "asc|desc"}.
+func parseFindSort(field interface{}) (findSort, bool) {
+	switch t := field.(type) {
+	case string:
+		return findSort{field: t}, true
+	case map[string]interface{}:
+		if len(t) != 1 {
+			return findSort{}, false
+		}
+		for name, dir := range t {
+			switch dir {
+			case "asc":
+				return findSort{field: name}, true
+			case "desc":
+				return findSort{field: name, desc: true}, true
+			}
+		}
+	}
+	return findSort{}, false
+}
+
+// fallbackFind emulates Find by reading every document in the database, and
+// evaluating the selector on the client. Options are merged into the query.
+func (db *DB) fallbackFind(ctx context.Context, query interface{}, opts map[string]interface{}) (*Rows, error) {
+	q, sorts, err := parseFindQuery(query, opts)
+	if err != nil {
+		return nil, err
+	}
+	sel, err := mango.New(q.Selector)
+	if err != nil {
+		return nil, &Error{HTTPStatus: http.StatusBadRequest, Err: err}
+	}
+	rowsi, err := db.driverDB.AllDocs(ctx, map[string]interface{}{"include_docs": true})
+	if err != nil {
+		return nil, err
+	}
+	defer rowsi.Close() // nolint: errcheck
+	var docs []map[string]interface{}
+	for {
+		row := &driver.Row{}
+		if err := rowsi.Next(row); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		if strings.HasPrefix(row.ID, "_design/") {
+			continue
+		}
+		doc, err := decodeFindDoc(row)
+		if err != nil {
+			return nil, err
+		}
+		if doc != nil && sel.Match(doc) {
+			docs = append(docs, doc)
+		}
+	}
+	if len(sorts) > 0 {
+		sort.SliceStable(docs, func(i, j int) bool {
+			return compareFindDocs(docs[i], docs[j], sorts) < 0
+		})
+	}
+	limit := int64(25)
+	if q.Limit != nil {
+		limit = *q.Limit
+	}
+	if q.Skip >= int64(len(docs)) {
+		docs = nil
+	} else {
+		docs = docs[q.Skip:]
+	}
+	if limit < int64(len(docs)) {
+		docs = docs[:limit]
+	}
+	results := make([]json.RawMessage, len(docs))
+	for i, doc := range docs {
+		if len(q.Fields) > 0 {
+			doc = projectFields(doc, q.Fields)
+		}
+		if results[i], err = json.Marshal(doc); err != nil {
+			return nil, err
+		}
+	}
+	return newRows(ctx, &findRows{docs: results}), nil
+}
+
+func decodeFindDoc(row *driver.Row) (map[string]interface{}, error) {
+	var r io.Reader = row.DocReader
+	if r == nil {
+		if len(row.Doc) == 0 {
+			return nil, nil
+		}
+		r = bytes.NewReader(row.Doc)
+	}
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var doc map[string]interface{}
+	err := dec.Decode(&doc)
+	return doc, err
+}
+
+// compareFindDocs compares two documents by the requested sort fields.
+// Documents missing a field sort before those which have it.
+func compareFindDocs(a, b map[string]interface{}, sorts []findSort) int {
+	for _, s := range sorts {
+		av, aok := mango.Field(a, s.field)
+		bv, bok := mango.Field(b, s.field)
+		var c int
+		switch {
+		case !aok && !bok:
+		case !aok:
+			c = -1
+		case !bok:
+			c = 1
+		default:
+			c = mango.Compare(av, bv)
+		}
+		if s.desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// projectFields returns a copy of doc containing only the requested fields.
+func projectFields(doc map[string]interface{}, fields []string) map[string]interface{} {
+	result := map[string]interface{}{}
+	for _, field := range fields {
+		value, ok := mango.Field(doc, field)
+		if !ok {
+			continue
+		}
+		path := strings.Split(strings.Replace(field, `\.`, "\x00", -1), ".")
+		target := result
+		for i, name := range path {
+			name = strings.Replace(name, "\x00", ".", -1)
+			if i == len(path)-1 {
+				target[name] = value
+				break
+			}
+			next, ok := target[name].(map[string]interface{})
+			if !ok {
+				next = map[string]interface{}{}
+				target[name] = next
+			}
+			target = next
+		}
+	}
+	return result
+}
+
+// findRows is a driver.Rows over the results of an emulated Find.
+type findRows struct {
+	docs []json.RawMessage
+}
+
+var (
+	_ driver.Rows       = &findRows{}
+	_ driver.RowsWarner = &findRows{}
+)
+
+func (r *findRows) Next(row *driver.Row) error {
+	if len(r.docs) == 0 {
+		return io.EOF
+	}
+	row.Doc = r.docs[0]
+	r.docs = r.docs[1:]
+	return nil
+}
+
+func (r *findRows) Close() error {
+	r.docs = nil
+	return nil
+}
+
+func (r *findRows) UpdateSeq() string { return "" }
+func (r *findRows) Offset() int64     { return 0 }
+func (r *findRows) TotalRows() int64  { return 0 }
+func (r *findRows) Warning() string   { return findNoIndexWarning }
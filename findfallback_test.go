@@ -0,0 +1,155 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+	"github.com/go-kivik/kivik/v4/mango"
+)
+
+func TestFindFallback(t *testing.T) {
+	allDocs := []string{
+		`{"_id":"_design/foo","name":"design"}`,
+		`{"_id":"a","name":"Alice","age":30,"address":{"city":"Oslo"}}`,
+		`{"_id":"b","name":"Bob","age":42,"address":{"city":"Bergen"}}`,
+		`{"_id":"c","name":"Carol","age":25}`,
+		`{"_id":"d","name":"Dave","age":42}`,
+	}
+	db := &DB{
+		driverDB: &mock.DB{
+			AllDocsFunc: func(_ context.Context, options map[string]interface{}) (driver.Rows, error) {
+				if options["include_docs"] != true {
+					t.Errorf("include_docs not requested")
+				}
+				docs := allDocs
+				return &mock.Rows{
+					NextFunc: func(row *driver.Row) error {
+						if len(docs) == 0 {
+							return io.EOF
+						}
+						var doc struct {
+							ID string `json:"_id"`
+						}
+						_ = json.Unmarshal([]byte(docs[0]), &doc)
+						row.ID = doc.ID
+						row.Doc = json.RawMessage(docs[0])
+						docs = docs[1:]
+						return nil
+					},
+					CloseFunc: func() error { return nil },
+				}, nil
+			},
+		},
+	}
+	type tt struct {
+		query    interface{}
+		options  Options
+		expected []string
+		status   int
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("selector", tt{
+		query:    map[string]interface{}{"selector": map[string]interface{}{"age": 42}},
+		expected: []string{`{"_id":"b","address":{"city":"Bergen"},"age":42,"name":"Bob"}`, `{"_id":"d","age":42,"name":"Dave"}`},
+	})
+	tests.Add("design docs excluded", tt{
+		query:    `{"selector":{"name":"design"}}`,
+		expected: nil,
+	})
+	tests.Add("sort, skip, limit and fields", tt{
+		query: map[string]interface{}{
+			"selector": map[string]interface{}{"age": map[string]interface{}{"$gt": 0}},
+			"sort":     []interface{}{map[string]string{"age": "desc"}, "name"},
+			"skip":     1,
+			"limit":    2,
+			"fields":   []string{"name", "address.city"},
+		},
+		expected: []string{`{"name":"Dave"}`, `{"address":{"city":"Oslo"},"name":"Alice"}`},
+	})
+	tests.Add("query builder", tt{
+		query:    mango.NewQuery(mango.Lt("age", 30)).Fields("_id"),
+		expected: []string{`{"_id":"c"}`},
+	})
+	tests.Add("options merged into query", tt{
+		query:    map[string]interface{}{"selector": map[string]interface{}{"age": 42}, "limit": 5},
+		options:  Options{"limit": 1, "fields": []string{"_id"}},
+		expected: []string{`{"_id":"b"}`},
+	})
+	tests.Add("negative limit", tt{
+		query:  map[string]interface{}{"selector": map[string]interface{}{}, "limit": -1},
+		status: http.StatusBadRequest,
+		err:    "Invalid limit: must not be negative",
+	})
+	tests.Add("negative skip", tt{
+		query:  map[string]interface{}{"selector": map[string]interface{}{}, "skip": -1},
+		status: http.StatusBadRequest,
+		err:    "Invalid skip: must not be negative",
+	})
+	tests.Add("negative limit option", tt{
+		query:   map[string]interface{}{"selector": map[string]interface{}{}},
+		options: Options{"limit": -1},
+		status:  http.StatusBadRequest,
+		err:     "Invalid limit: must not be negative",
+	})
+	tests.Add("missing selector", tt{
+		query:  map[string]interface{}{},
+		status: http.StatusBadRequest,
+		err:    "Missing required key: selector",
+	})
+	tests.Add("invalid selector", tt{
+		query:  map[string]interface{}{"selector": map[string]interface{}{"age": map[string]interface{}{"$gte ": 1}}},
+		status: http.StatusBadRequest,
+		err:    "mango: $gte : unknown operator",
+	})
+	tests.Add("invalid sort", tt{
+		query:  map[string]interface{}{"selector": map[string]interface{}{}, "sort": []interface{}{map[string]string{"age": "up"}}},
+		status: http.StatusBadRequest,
+		err:    "Invalid sort field",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		query := tt.query
+		if s, ok := query.(string); ok {
+			query = json.RawMessage(s)
+		}
+		rows, err := db.Find(context.Background(), query, tt.options)
+		testy.StatusError(t, tt.err, tt.status, err)
+		var got []string
+		for rows.Next() {
+			var doc json.RawMessage
+			if err := rows.ScanDoc(&doc); err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, string(doc))
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface(tt.expected, got); d != nil {
+			t.Error(d)
+		}
+		if w := rows.Warning(); w != findNoIndexWarning {
+			t.Errorf("Unexpected warning: %s", w)
+		}
+	})
+}
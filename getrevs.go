@@ -0,0 +1,245 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// OpenRevs is an iterator over the revisions of a document, as returned by
+// DB.GetRevs.
+type OpenRevs struct {
+	*iter
+}
+
+// Next prepares the next revision for reading. It returns true on success, or
+// false if there are no more revisions or an error occurred. Err should be
+// consulted to determine any error.
+func (r *OpenRevs) Next() bool {
+	return r.iter.Next()
+}
+
+// Err returns the error, if any, that was encountered during iteration. Err
+// may be called after an explicit or implicit Close.
+func (r *OpenRevs) Err() error {
+	return r.iter.Err()
+}
+
+// Close closes the iterator, preventing further enumeration. Close is
+// idempotent and does not affect the result of Err.
+func (r *OpenRevs) Close() error {
+	return r.iter.Close()
+}
+
+type openRevsIterator struct{ driver.OpenRevs }
+
+var _ iterator = &openRevsIterator{}
+
+func (r *openRevsIterator) Next(i interface{}) error {
+	return r.OpenRevs.Next(i.(*driver.OpenRev))
+}
+
+func newOpenRevs(ctx context.Context, revsi driver.OpenRevs) *OpenRevs {
+	return &OpenRevs{
+		iter: newIterator(ctx, &openRevsIterator{revsi}, &driver.OpenRev{}),
+	}
+}
+
+// Rev returns the revision ID of the current result.
+func (r *OpenRevs) Rev() string {
+	runlock, err := r.rlock()
+	if err != nil {
+		return ""
+	}
+	defer runlock()
+	return r.curVal.(*driver.OpenRev).Rev
+}
+
+// Missing returns true if the current revision was requested, but not found.
+func (r *OpenRevs) Missing() bool {
+	runlock, err := r.rlock()
+	if err != nil {
+		return false
+	}
+	defer runlock()
+	return r.curVal.(*driver.OpenRev).Missing
+}
+
+// ScanDoc unmarshals the document at the current revision into dest. It
+// returns a status 404 error if the revision is missing.
+func (r *OpenRevs) ScanDoc(dest interface{}) error {
+	runlock, err := r.rlock()
+	if err != nil {
+		return err
+	}
+	defer runlock()
+	rev := r.curVal.(*driver.OpenRev)
+	if rev.Missing {
+		return &Error{HTTPStatus: http.StatusNotFound, Message: "kivik: missing revision " + rev.Rev}
+	}
+	return json.Unmarshal(rev.Doc, dest)
+}
+
+// Attachments returns an iterator over the attachments of the current
+// revision, when attachments=true is set and the driver does not include
+// their content in the document. It returns nil otherwise.
+func (r *OpenRevs) Attachments() *AttachmentsIterator {
+	runlock, err := r.rlock()
+	if err != nil {
+		return nil
+	}
+	defer runlock()
+	atts := r.curVal.(*driver.OpenRev).Attachments
+	if atts == nil {
+		return nil
+	}
+	if emulated, ok := atts.(*emulatedAttachments); ok {
+		emulated.take()
+	}
+	return &AttachmentsIterator{atti: atts}
+}
+
+// GetRevs returns an iterator over the requested revisions of a document, in
+// the order requested, or over all of its leaf revisions, including deleted
+// ones, if revs is empty. This corresponds to CouchDB's open_revs parameter.
+// Revisions which are not found are reported by OpenRevs.Missing, rather than
+// as an error. Options are those accepted by Get.
+//
+// If the driver does not support fetching several revisions at once, each
+// revision is fetched with a separate call to Get. In that case, when revs is
+// empty, the leaf revisions are read from the _conflicts and
+// _deleted_conflicts of the winning revision, so a document whose leaves are
+// all deleted is reported as not found.
+func (db *DB) GetRevs(ctx context.Context, docID string, revs []string, options ...Options) (*OpenRevs, error) {
+	if db.err != nil {
+		return nil, db.err
+	}
+	if docID == "" {
+		return nil, missingArg("docID")
+	}
+	opts := mergeOptions(options...)
+	if getter, ok := db.driverDB.(driver.OpenRevsGetter); ok {
+		revsi, err := getter.OpenRevs(ctx, docID, revs, opts)
+		if StatusCode(err) != http.StatusNotImplemented {
+			if err != nil {
+				return nil, err
+			}
+			return newOpenRevs(ctx, revsi), nil
+		}
+	}
+	if len(revs) == 0 {
+		var err error
+		if revs, err = db.leafRevs(ctx, docID); err != nil {
+			return nil, err
+		}
+	}
+	return newOpenRevs(ctx, &emulatedOpenRevs{
+		ctx:   ctx,
+		db:    db,
+		docID: docID,
+		revs:  revs,
+		opts:  opts,
+	}), nil
+}
+
+// leafRevs returns the leaf revisions of a document, winning revision first.
+func (db *DB) leafRevs(ctx context.Context, docID string) ([]string, error) {
+	var meta changeMeta
+	err := db.Get(ctx, docID, Options{"conflicts": true, "deleted_conflicts": true}).ScanDoc(&meta)
+	if err != nil {
+		return nil, err
+	}
+	revs := append([]string{meta.Rev}, meta.Conflicts...)
+	return append(revs, meta.DeletedConflicts...), nil
+}
+
+// emulatedOpenRevs fetches each revision with a call to Get.
+type emulatedOpenRevs struct {
+	ctx   context.Context
+	db    *DB
+	docID string
+	revs  []string
+	opts  Options
+	atts  *emulatedAttachments
+}
+
+var _ driver.OpenRevs = &emulatedOpenRevs{}
+
+// emulatedAttachments are the attachments of the current revision read by
+// emulatedOpenRevs. They are closed when the iterator moves on, unless the
+// caller has taken them with OpenRevs.Attachments.
+type emulatedAttachments struct {
+	driver.Attachments
+	taken int32
+}
+
+func (a *emulatedAttachments) take() {
+	atomic.StoreInt32(&a.taken, 1)
+}
+
+// closeAttachments closes the attachments of the current revision, if the
+// caller did not take them.
+func (r *emulatedOpenRevs) closeAttachments() error {
+	atts := r.atts
+	r.atts = nil
+	if atts == nil || atomic.LoadInt32(&atts.taken) == 1 {
+		return nil
+	}
+	return atts.Close()
+}
+
+func (r *emulatedOpenRevs) Next(rev *driver.OpenRev) error {
+	if err := r.closeAttachments(); err != nil {
+		return err
+	}
+	if len(r.revs) == 0 {
+		return io.EOF
+	}
+	*rev = driver.OpenRev{Rev: r.revs[0]}
+	r.revs = r.revs[1:]
+	opts := Options{"rev": rev.Rev}
+	for k, v := range r.opts {
+		if k != "rev" {
+			opts[k] = v
+		}
+	}
+	doc, err := r.db.driverDB.Get(r.ctx, r.docID, opts)
+	if StatusCode(err) == http.StatusNotFound {
+		rev.Missing = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer doc.Body.Close() // nolint: errcheck
+	if rev.Doc, err = ioutil.ReadAll(doc.Body); err != nil {
+		return err
+	}
+	if doc.Attachments != nil {
+		r.atts = &emulatedAttachments{Attachments: doc.Attachments}
+		rev.Attachments = r.atts
+	}
+	return nil
+}
+
+func (r *emulatedOpenRevs) Close() error {
+	r.revs = nil
+	return r.closeAttachments()
+}
@@ -0,0 +1,238 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+type openRevResult struct {
+	Rev     string
+	Missing bool
+	Doc     map[string]interface{}
+}
+
+func TestGetRevs(t *testing.T) {
+	type tst struct {
+		db       *DB
+		revs     []string
+		options  Options
+		expected []openRevResult
+		status   int
+		err      string
+	}
+	leaves := map[string]string{
+		"2-a": `{"_id":"foo","_rev":"2-a","_conflicts":["2-b"],"_deleted_conflicts":["2-c"]}`,
+		"2-b": `{"_id":"foo","_rev":"2-b"}`,
+		"2-c": `{"_id":"foo","_rev":"2-c","_deleted":true}`,
+	}
+	var getOpts []map[string]interface{}
+	fallbackDB := &mock.DB{
+		GetFunc: func(_ context.Context, _ string, opts map[string]interface{}) (*driver.Document, error) {
+			getOpts = append(getOpts, opts)
+			rev, _ := opts["rev"].(string)
+			if rev == "" {
+				rev = "2-a"
+			}
+			doc, ok := leaves[rev]
+			if !ok {
+				return nil, &Error{HTTPStatus: http.StatusNotFound, Message: "missing"}
+			}
+			return &driver.Document{Rev: rev, Body: body(doc)}, nil
+		},
+	}
+	tests := testy.NewTable()
+	tests.Add("native", tst{
+		db: &DB{driverDB: &mock.OpenRevsGetter{
+			OpenRevsFunc: func(_ context.Context, docID string, revs []string, opts map[string]interface{}) (driver.OpenRevs, error) {
+				if docID != "foo" || len(revs) != 0 || opts["latest"] != true {
+					t.Errorf("Unexpected arguments: %s %v %v", docID, revs, opts)
+				}
+				results := []driver.OpenRev{
+					{Rev: "2-a", Doc: json.RawMessage(`{"_rev":"2-a"}`)},
+					{Rev: "2-x", Missing: true},
+				}
+				return &mock.OpenRevs{
+					NextFunc: func(rev *driver.OpenRev) error {
+						if len(results) == 0 {
+							return io.EOF
+						}
+						*rev = results[0]
+						results = results[1:]
+						return nil
+					},
+					CloseFunc: func() error { return nil },
+				}, nil
+			},
+		}},
+		options: Options{"latest": true},
+		expected: []openRevResult{
+			{Rev: "2-a", Doc: map[string]interface{}{"_rev": "2-a"}},
+			{Rev: "2-x", Missing: true},
+		},
+	})
+	tests.Add("native error", tst{
+		db: &DB{driverDB: &mock.OpenRevsGetter{
+			OpenRevsFunc: func(context.Context, string, []string, map[string]interface{}) (driver.OpenRevs, error) {
+				return nil, &Error{HTTPStatus: http.StatusNotFound, Message: "not found"}
+			},
+		}},
+		status: http.StatusNotFound,
+		err:    "not found",
+	})
+	tests.Add("not implemented", tst{
+		db: &DB{driverDB: &mock.OpenRevsGetter{
+			DB: fallbackDB,
+			OpenRevsFunc: func(context.Context, string, []string, map[string]interface{}) (driver.OpenRevs, error) {
+				return nil, &Error{HTTPStatus: http.StatusNotImplemented, Message: "not implemented"}
+			},
+		}},
+		revs: []string{"2-b"},
+		expected: []openRevResult{
+			{Rev: "2-b", Doc: map[string]interface{}{"_id": "foo", "_rev": "2-b"}},
+		},
+	})
+	tests.Add("fallback, requested revs", tst{
+		db:   &DB{driverDB: fallbackDB},
+		revs: []string{"2-c", "3-x"},
+		expected: []openRevResult{
+			{Rev: "2-c", Doc: map[string]interface{}{"_id": "foo", "_rev": "2-c", "_deleted": true}},
+			{Rev: "3-x", Missing: true},
+		},
+	})
+	tests.Add("fallback, all leaves", tst{
+		db: &DB{driverDB: fallbackDB},
+		expected: []openRevResult{
+			{Rev: "2-a", Doc: map[string]interface{}{"_id": "foo", "_rev": "2-a", "_conflicts": []interface{}{"2-b"}, "_deleted_conflicts": []interface{}{"2-c"}}},
+			{Rev: "2-b", Doc: map[string]interface{}{"_id": "foo", "_rev": "2-b"}},
+			{Rev: "2-c", Doc: map[string]interface{}{"_id": "foo", "_rev": "2-c", "_deleted": true}},
+		},
+	})
+	tests.Add("fallback, document not found", tst{
+		db: &DB{driverDB: &mock.DB{
+			GetFunc: func(context.Context, string, map[string]interface{}) (*driver.Document, error) {
+				return nil, &Error{HTTPStatus: http.StatusNotFound, Message: "deleted"}
+			},
+		}},
+		status: http.StatusNotFound,
+		err:    "deleted",
+	})
+	tests.Add("fallback, get error", tst{
+		db: &DB{driverDB: &mock.DB{
+			GetFunc: func(context.Context, string, map[string]interface{}) (*driver.Document, error) {
+				return nil, &Error{HTTPStatus: http.StatusBadGateway, Message: "broken"}
+			},
+		}},
+		revs:   []string{"2-a"},
+		status: http.StatusBadGateway,
+		err:    "broken",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tst) {
+		openRevs, err := tt.db.GetRevs(context.Background(), "foo", tt.revs, tt.options)
+		if err == nil {
+			var results []openRevResult
+			for openRevs.Next() {
+				result := openRevResult{Rev: openRevs.Rev(), Missing: openRevs.Missing()}
+				if !result.Missing {
+					if err := openRevs.ScanDoc(&result.Doc); err != nil {
+						t.Fatal(err)
+					}
+				}
+				results = append(results, result)
+			}
+			if d := testy.DiffInterface(tt.expected, results); d != nil {
+				t.Error(d)
+			}
+			err = openRevs.Err()
+		}
+		testy.StatusError(t, tt.err, tt.status, err)
+	})
+
+	t.Run("fallback options", func(t *testing.T) {
+		getOpts = nil
+		db := &DB{driverDB: fallbackDB}
+		openRevs, err := db.GetRevs(context.Background(), "foo", []string{"2-b"}, Options{"attachments": true, "rev": "1-x"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for openRevs.Next() {
+		}
+		expected := []map[string]interface{}{{"rev": "2-b", "attachments": true}}
+		if d := testy.DiffInterface(expected, getOpts); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("fallback closes attachments", func(t *testing.T) {
+		var closed []string
+		db := &DB{driverDB: &mock.DB{
+			GetFunc: func(_ context.Context, _ string, opts map[string]interface{}) (*driver.Document, error) {
+				rev := opts["rev"].(string)
+				return &driver.Document{
+					Rev:  rev,
+					Body: body(`{}`),
+					Attachments: &mock.Attachments{
+						CloseFunc: func() error {
+							closed = append(closed, rev)
+							return nil
+						},
+					},
+				}, nil
+			},
+		}}
+		openRevs, err := db.GetRevs(context.Background(), "foo", []string{"2-a", "2-b", "2-c"}, Options{"attachments": true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for openRevs.Next() {
+			if openRevs.Rev() == "2-b" {
+				_ = openRevs.Attachments()
+			}
+		}
+		if err := openRevs.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface([]string{"2-a", "2-c"}, closed); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("missing revision", func(t *testing.T) {
+		openRevs, err := (&DB{driverDB: fallbackDB}).GetRevs(context.Background(), "foo", []string{"3-x"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !openRevs.Next() {
+			t.Fatal(openRevs.Err())
+		}
+		var doc interface{}
+		testy.StatusError(t, "kivik: missing revision 3-x", http.StatusNotFound, openRevs.ScanDoc(&doc))
+	})
+	t.Run("db error", func(t *testing.T) {
+		db := &DB{err: &Error{HTTPStatus: http.StatusNotFound, Message: "db not found"}}
+		_, err := db.GetRevs(context.Background(), "foo", nil)
+		testy.StatusError(t, "db not found", http.StatusNotFound, err)
+	})
+	t.Run("no doc ID", func(t *testing.T) {
+		_, err := (&DB{}).GetRevs(context.Background(), "", nil)
+		testy.StatusError(t, "kivik: docID required", http.StatusBadRequest, err)
+	})
+}
@@ -0,0 +1,262 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package erlterm
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/big"
+)
+
+// maxDecompressedSize bounds the memory used to inflate a compressed term.
+const maxDecompressedSize = 16 << 20
+
+var errTruncated = errors.New("erlterm: unexpected end of input")
+
+// Decode parses data in the external term format, including compressed terms
+// as produced by term_to_binary/2 with the compressed option. Terms are
+// returned as the following Go types:
+//
+//   - Atom
+//   - []byte, for binaries
+//   - String
+//   - int64, or *big.Int for integers which do not fit in an int64
+//   - float64
+//   - Tuple and List
+func Decode(data []byte) (interface{}, error) {
+	if len(data) == 0 || data[0] != tagVersion {
+		return nil, errors.New("erlterm: missing version tag")
+	}
+	data = data[1:]
+	if len(data) > 0 && data[0] == tagCompressed {
+		var err error
+		if data, err = decompress(data[1:]); err != nil {
+			return nil, err
+		}
+	}
+	d := &decoder{data: data}
+	term, err := d.term()
+	if err != nil {
+		return nil, err
+	}
+	if len(d.data) != 0 {
+		return nil, errors.New("erlterm: trailing data after term")
+	}
+	return term, nil
+}
+
+func decompress(data []byte) ([]byte, error) {
+	if len(data) < 4 {
+		return nil, errTruncated
+	}
+	size := binary.BigEndian.Uint32(data)
+	if size > maxDecompressedSize {
+		return nil, fmt.Errorf("erlterm: compressed term too large: %d bytes", size)
+	}
+	r, err := zlib.NewReader(bytes.NewReader(data[4:]))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close() // nolint: errcheck
+	out, err := ioutil.ReadAll(io.LimitReader(r, int64(size)+1))
+	if err != nil {
+		return nil, err
+	}
+	if uint32(len(out)) != size {
+		return nil, errors.New("erlterm: compressed term size mismatch")
+	}
+	return out, nil
+}
+
+type decoder struct {
+	data []byte
+}
+
+func (d *decoder) next(n int) ([]byte, error) {
+	if n < 0 || len(d.data) < n {
+		return nil, errTruncated
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b, nil
+}
+
+func (d *decoder) uint8() (int, error) {
+	b, err := d.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return int(b[0]), nil
+}
+
+func (d *decoder) uint16() (int, error) {
+	b, err := d.next(2)
+	if err != nil {
+		return 0, err
+	}
+	return int(binary.BigEndian.Uint16(b)), nil
+}
+
+func (d *decoder) uint32() (int, error) {
+	b, err := d.next(4)
+	if err != nil {
+		return 0, err
+	}
+	n := binary.BigEndian.Uint32(b)
+	if uint64(n) > uint64(len(d.data)) {
+		// Every element occupies at least one byte, so a longer length can
+		// only be the result of corrupt input.
+		return 0, errTruncated
+	}
+	return int(n), nil
+}
+
+func (d *decoder) term() (interface{}, error) {
+	tag, err := d.uint8()
+	if err != nil {
+		return nil, err
+	}
+	switch tag {
+	case tagSmallInt:
+		n, err := d.uint8()
+		return int64(n), err
+	case tagInt:
+		b, err := d.next(4)
+		if err != nil {
+			return nil, err
+		}
+		return int64(int32(binary.BigEndian.Uint32(b))), nil
+	case tagSmallBig:
+		n, err := d.uint8()
+		if err != nil {
+			return nil, err
+		}
+		return d.bigInt(n)
+	case tagLargeBig:
+		n, err := d.uint32()
+		if err != nil {
+			return nil, err
+		}
+		return d.bigInt(n)
+	case tagNewFloat:
+		b, err := d.next(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	case tagAtom, tagAtomUTF8:
+		n, err := d.uint16()
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.next(n)
+		return Atom(b), err
+	case tagSmallAtom, tagSmallAtomU8:
+		n, err := d.uint8()
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.next(n)
+		return Atom(b), err
+	case tagBinary:
+		n, err := d.uint32()
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.next(n)
+		return append([]byte(nil), b...), err
+	case tagString:
+		n, err := d.uint16()
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.next(n)
+		return String(b), err
+	case tagNil:
+		return List{}, nil
+	case tagList:
+		n, err := d.uint32()
+		if err != nil {
+			return nil, err
+		}
+		list, err := d.terms(n)
+		if err != nil {
+			return nil, err
+		}
+		tail, err := d.term()
+		if err != nil {
+			return nil, err
+		}
+		if t, ok := tail.(List); !ok || len(t) != 0 {
+			return nil, errors.New("erlterm: improper lists are not supported")
+		}
+		return List(list), nil
+	case tagSmallTuple:
+		n, err := d.uint8()
+		if err != nil {
+			return nil, err
+		}
+		t, err := d.terms(n)
+		return Tuple(t), err
+	case tagLargeTuple:
+		n, err := d.uint32()
+		if err != nil {
+			return nil, err
+		}
+		t, err := d.terms(n)
+		return Tuple(t), err
+	}
+	return nil, fmt.Errorf("erlterm: unsupported tag %d", tag)
+}
+
+func (d *decoder) terms(n int) ([]interface{}, error) {
+	terms := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		t, err := d.term()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, t)
+	}
+	return terms, nil
+}
+
+// bigInt decodes the sign byte and n little-endian digits of a big integer.
+func (d *decoder) bigInt(n int) (interface{}, error) {
+	sign, err := d.uint8()
+	if err != nil {
+		return nil, err
+	}
+	digits, err := d.next(n)
+	if err != nil {
+		return nil, err
+	}
+	be := make([]byte, n)
+	for i, b := range digits {
+		be[n-1-i] = b
+	}
+	i := new(big.Int).SetBytes(be)
+	if sign != 0 {
+		i.Neg(i)
+	}
+	if i.IsInt64() {
+		return i.Int64(), nil
+	}
+	return i, nil
+}
@@ -0,0 +1,142 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package erlterm
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"math/big"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+)
+
+func compressed(t *testing.T, term []byte) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	w := zlib.NewWriter(buf)
+	if _, err := w.Write(term[1:]); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	out := []byte{tagVersion, tagCompressed, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(out[2:], uint32(len(term)-1))
+	return append(out, buf.Bytes()...)
+}
+
+func TestDecode(t *testing.T) {
+	type tst struct {
+		data     []byte
+		expected interface{}
+		err      string
+	}
+	huge, _ := new(big.Int).SetString("-123456789012345678901234567890", 10)
+	tests := testy.NewTable()
+	tests.Add("small int", tst{
+		data:     []byte{131, 97, 42},
+		expected: int64(42),
+	})
+	tests.Add("negative int", tst{
+		data:     []byte{131, 98, 0xff, 0xff, 0xff, 0xfe},
+		expected: int64(-2),
+	})
+	tests.Add("small big", tst{
+		data:     []byte{131, 110, 4, 0, 0xff, 0xff, 0xff, 0xff},
+		expected: int64(4294967295),
+	})
+	tests.Add("huge", tst{
+		data:     mustEncode(huge),
+		expected: huge,
+	})
+	tests.Add("float", tst{
+		data:     mustEncode(1.5),
+		expected: 1.5,
+	})
+	tests.Add("atoms", tst{
+		data:     []byte{131, 104, 2, 100, 0, 1, 'a', 119, 2, 'b', 'c'},
+		expected: Tuple{Atom("a"), Atom("bc")},
+	})
+	tests.Add("binary and string", tst{
+		data:     mustEncode(List{"abc", String("def")}),
+		expected: List{[]byte("abc"), String("def")},
+	})
+	tests.Add("nil", tst{
+		data:     []byte{131, 106},
+		expected: List{},
+	})
+	tests.Add("nested", tst{
+		data: mustEncode(List{
+			Tuple{Atom("node1@127.0.0.1"), List{0, int64(2147483647)}, Tuple{42, "abc", Atom("node1@127.0.0.1")}},
+		}),
+		expected: List{
+			Tuple{Atom("node1@127.0.0.1"), List{int64(0), int64(2147483647)}, Tuple{int64(42), []byte("abc"), Atom("node1@127.0.0.1")}},
+		},
+	})
+	tests.Add("missing version", tst{
+		data: []byte{97, 1},
+		err:  "erlterm: missing version tag",
+	})
+	tests.Add("truncated", tst{
+		data: []byte{131, 109, 0, 0, 0, 3, 'a'},
+		err:  "erlterm: unexpected end of input",
+	})
+	tests.Add("trailing data", tst{
+		data: []byte{131, 97, 1, 97},
+		err:  "erlterm: trailing data after term",
+	})
+	tests.Add("improper list", tst{
+		data: []byte{131, 108, 0, 0, 0, 1, 97, 1, 97, 2},
+		err:  "erlterm: improper lists are not supported",
+	})
+	tests.Add("unsupported tag", tst{
+		data: []byte{131, 116, 0, 0, 0, 0},
+		err:  "erlterm: unsupported tag 116",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tst) {
+		term, err := Decode(tt.data)
+		testy.Error(t, tt.err, err)
+		if d := testy.DiffInterface(tt.expected, term); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
+func TestDecodeCompressed(t *testing.T) {
+	term := List{Tuple{Atom("node1@127.0.0.1"), List{0, 100}, 7}}
+	data := compressed(t, mustEncode(term))
+	result, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := List{Tuple{Atom("node1@127.0.0.1"), List{int64(0), int64(100)}, int64(7)}}
+	if d := testy.DiffInterface(expected, result); d != nil {
+		t.Error(d)
+	}
+
+	binary.BigEndian.PutUint32(data[2:], 3)
+	if _, err := Decode(data); err == nil || err.Error() != "erlterm: compressed term size mismatch" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func mustEncode(term interface{}) []byte {
+	data, err := Encode(term)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
@@ -0,0 +1,309 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package erlterm encodes and decodes a subset of Erlang terms in the Erlang
+// external term format, as produced by term_to_binary/1. It exists to
+// reproduce hashes which CouchDB computes over Erlang terms, such as
+// replication IDs, and to inspect opaque values which CouchDB builds from
+// them, such as clustered update sequences.
+package erlterm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// Tags from the external term format specification.
+const (
+	tagVersion     = 131
+	tagNewFloat    = 70
+	tagSmallInt    = 97
+	tagInt         = 98
+	tagAtom        = 100
+	tagSmallTuple  = 104
+	tagLargeTuple  = 105
+	tagNil         = 106
+	tagString      = 107
+	tagList        = 108
+	tagBinary      = 109
+	tagSmallBig    = 110
+	tagLargeBig    = 111
+	tagCompressed  = 80
+	tagAtomUTF8    = 118
+	tagSmallAtomU8 = 119
+	tagSmallAtom   = 115
+	maxStringLen   = 65535
+	maxSmallTuple  = 255
+	maxAtomLength  = 255
+	minInt32Value  = math.MinInt32
+	maxInt32Value  = math.MaxInt32
+	maxSmallIntVal = 255
+)
+
+// Atom is an Erlang atom.
+type Atom string
+
+// String is an Erlang string, which is a list of bytes.
+type String string
+
+// Tuple is an Erlang tuple.
+type Tuple []interface{}
+
+// List is an Erlang list. A []interface{} is also encoded as a list.
+type List []interface{}
+
+// Prop is a single member of an Object.
+type Prop struct {
+	Key   string
+	Value interface{}
+}
+
+// Object is a JSON object in CouchDB's internal representation, the tuple
+// {[{Key, Value}, ...]}, with binary keys.
+type Object []Prop
+
+// Encode returns term in the external term format. The following Go types are
+// supported:
+//
+//   - Atom, and bool and nil, which are encoded as the atoms true, false and
+//     null, as in CouchDB's JSON representation
+//   - string and []byte, which are encoded as binaries
+//   - String
+//   - int, int64, *big.Int, and json.Number, which is encoded as an integer
+//     unless it contains a decimal point or an exponent
+//   - float64
+//   - Tuple, List, []interface{} and Object
+func Encode(term interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteByte(tagVersion)
+	if err := encode(buf, term); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encode(buf *bytes.Buffer, term interface{}) error {
+	switch t := term.(type) {
+	case nil:
+		return encodeAtom(buf, "null")
+	case bool:
+		if t {
+			return encodeAtom(buf, "true")
+		}
+		return encodeAtom(buf, "false")
+	case Atom:
+		return encodeAtom(buf, string(t))
+	case string:
+		encodeBinary(buf, []byte(t))
+	case []byte:
+		encodeBinary(buf, t)
+	case String:
+		encodeString(buf, string(t))
+	case int:
+		encodeInt(buf, big.NewInt(int64(t)))
+	case int64:
+		encodeInt(buf, big.NewInt(t))
+	case *big.Int:
+		encodeInt(buf, t)
+	case float64:
+		buf.WriteByte(tagNewFloat)
+		_ = binary.Write(buf, binary.BigEndian, math.Float64bits(t))
+	case json.Number:
+		return encodeNumber(buf, t)
+	case Tuple:
+		return encodeTuple(buf, t)
+	case List:
+		return encodeList(buf, t)
+	case []interface{}:
+		return encodeList(buf, t)
+	case Object:
+		props := make(List, len(t))
+		for i, p := range t {
+			props[i] = Tuple{p.Key, p.Value}
+		}
+		return encodeTuple(buf, Tuple{props})
+	default:
+		return fmt.Errorf("erlterm: unsupported type %T", term)
+	}
+	return nil
+}
+
+func encodeAtom(buf *bytes.Buffer, name string) error {
+	if len(name) > maxAtomLength {
+		return fmt.Errorf("erlterm: atom too long: %q", name)
+	}
+	buf.WriteByte(tagAtom)
+	_ = binary.Write(buf, binary.BigEndian, uint16(len(name)))
+	buf.WriteString(name)
+	return nil
+}
+
+func encodeBinary(buf *bytes.Buffer, b []byte) {
+	buf.WriteByte(tagBinary)
+	_ = binary.Write(buf, binary.BigEndian, uint32(len(b)))
+	buf.Write(b)
+}
+
+func encodeString(buf *bytes.Buffer, s string) {
+	switch {
+	case s == "":
+		buf.WriteByte(tagNil)
+	case len(s) <= maxStringLen:
+		buf.WriteByte(tagString)
+		_ = binary.Write(buf, binary.BigEndian, uint16(len(s)))
+		buf.WriteString(s)
+	default:
+		buf.WriteByte(tagList)
+		_ = binary.Write(buf, binary.BigEndian, uint32(len(s)))
+		for i := 0; i < len(s); i++ {
+			buf.WriteByte(tagSmallInt)
+			buf.WriteByte(s[i])
+		}
+		buf.WriteByte(tagNil)
+	}
+}
+
+func encodeInt(buf *bytes.Buffer, i *big.Int) {
+	if i.IsInt64() {
+		switch n := i.Int64(); {
+		case n >= 0 && n <= maxSmallIntVal:
+			buf.WriteByte(tagSmallInt)
+			buf.WriteByte(byte(n))
+			return
+		case n >= minInt32Value && n <= maxInt32Value:
+			buf.WriteByte(tagInt)
+			_ = binary.Write(buf, binary.BigEndian, int32(n))
+			return
+		}
+	}
+	digits := new(big.Int).Abs(i).Bytes() // big-endian
+	buf.WriteByte(tagSmallBig)
+	buf.WriteByte(byte(len(digits)))
+	if i.Sign() < 0 {
+		buf.WriteByte(1)
+	} else {
+		buf.WriteByte(0)
+	}
+	for j := len(digits) - 1; j >= 0; j-- {
+		buf.WriteByte(digits[j])
+	}
+}
+
+func encodeNumber(buf *bytes.Buffer, n json.Number) error {
+	if !strings.ContainsAny(string(n), ".eE") {
+		i, ok := new(big.Int).SetString(string(n), 10)
+		if !ok {
+			return fmt.Errorf("erlterm: invalid number %q", n)
+		}
+		encodeInt(buf, i)
+		return nil
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return err
+	}
+	return encode(buf, f)
+}
+
+func encodeTuple(buf *bytes.Buffer, t Tuple) error {
+	if len(t) <= maxSmallTuple {
+		buf.WriteByte(tagSmallTuple)
+		buf.WriteByte(byte(len(t)))
+	} else {
+		buf.WriteByte(tagLargeTuple)
+		_ = binary.Write(buf, binary.BigEndian, uint32(len(t)))
+	}
+	for _, e := range t {
+		if err := encode(buf, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func encodeList(buf *bytes.Buffer, l []interface{}) error {
+	if len(l) == 0 {
+		buf.WriteByte(tagNil)
+		return nil
+	}
+	buf.WriteByte(tagList)
+	_ = binary.Write(buf, binary.BigEndian, uint32(len(l)))
+	for _, e := range l {
+		if err := encode(buf, e); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte(tagNil)
+	return nil
+}
+
+// DecodeJSON decodes data as CouchDB would, preserving the order of object
+// members. Objects are returned as Object, arrays as []interface{}, strings as
+// string and numbers as json.Number.
+func DecodeJSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	v, err := decodeValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err == nil {
+		return nil, fmt.Errorf("erlterm: trailing data after JSON value")
+	}
+	return v, nil
+}
+
+func decodeValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			obj := Object{}
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				value, err := decodeValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				obj = append(obj, Prop{Key: key.(string), Value: value})
+			}
+			_, err := dec.Token()
+			return obj, err
+		case '[':
+			arr := []interface{}{}
+			for dec.More() {
+				value, err := decodeValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				arr = append(arr, value)
+			}
+			_, err := dec.Token()
+			return arr, err
+		}
+		return nil, fmt.Errorf("erlterm: unexpected delimiter %s", t)
+	default:
+		return t, nil
+	}
+}
@@ -0,0 +1,121 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package erlterm
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+)
+
+func TestEncode(t *testing.T) {
+	type tst struct {
+		term     interface{}
+		expected []byte
+		err      string
+	}
+	bigNum, _ := new(big.Int).SetString("-1099511627776", 10)
+	tests := testy.NewTable()
+	// Expected values are the output of term_to_binary/1 with OTP 25.
+	tests.Add("binary", tst{
+		term:     "abc",
+		expected: []byte{131, 109, 0, 0, 0, 3, 'a', 'b', 'c'},
+	})
+	tests.Add("empty string", tst{
+		term:     String(""),
+		expected: []byte{131, 106},
+	})
+	tests.Add("string", tst{
+		term:     String("abc"),
+		expected: []byte{131, 107, 0, 3, 'a', 'b', 'c'},
+	})
+	tests.Add("atom", tst{
+		term:     Atom("undefined"),
+		expected: []byte{131, 100, 0, 9, 'u', 'n', 'd', 'e', 'f', 'i', 'n', 'e', 'd'},
+	})
+	tests.Add("null", tst{
+		term:     nil,
+		expected: []byte{131, 100, 0, 4, 'n', 'u', 'l', 'l'},
+	})
+	tests.Add("integer", tst{
+		term:     5984,
+		expected: []byte{131, 98, 0, 0, 0x17, 0x60},
+	})
+	tests.Add("byte", tst{
+		term:     json.Number("42"),
+		expected: []byte{131, 97, 42},
+	})
+	tests.Add("negative", tst{
+		term:     -1,
+		expected: []byte{131, 98, 255, 255, 255, 255},
+	})
+	tests.Add("big", tst{
+		term:     bigNum,
+		expected: []byte{131, 110, 6, 1, 0, 0, 0, 0, 0, 1},
+	})
+	tests.Add("float", tst{
+		term:     json.Number("1.5"),
+		expected: []byte{131, 70, 63, 248, 0, 0, 0, 0, 0, 0},
+	})
+	tests.Add("tuple", tst{
+		term:     Tuple{Atom("a")},
+		expected: []byte{131, 104, 1, 100, 0, 1, 'a'},
+	})
+	tests.Add("list", tst{
+		term:     List{1, 2},
+		expected: []byte{131, 108, 0, 0, 0, 2, 97, 1, 97, 2, 106},
+	})
+	tests.Add("empty object", tst{
+		term:     Object{},
+		expected: []byte{131, 104, 1, 106},
+	})
+	tests.Add("object", tst{
+		term: Object{{Key: "a", Value: true}},
+		expected: []byte{
+			131, 104, 1, 108, 0, 0, 0, 1,
+			104, 2, 109, 0, 0, 0, 1, 'a', 100, 0, 4, 't', 'r', 'u', 'e',
+			106,
+		},
+	})
+	tests.Add("unsupported", tst{
+		term: struct{}{},
+		err:  "erlterm: unsupported type struct {}",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tst) {
+		result, err := Encode(tt.term)
+		testy.Error(t, tt.err, err)
+		if d := testy.DiffInterface(tt.expected, result); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
+func TestDecodeJSON(t *testing.T) {
+	result, err := DecodeJSON([]byte(`{"b":[1,"x",null],"a":{}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Object{
+		{Key: "b", Value: []interface{}{json.Number("1"), "x", nil}},
+		{Key: "a", Value: Object{}},
+	}
+	if d := testy.DiffInterface(expected, result); d != nil {
+		t.Error(d)
+	}
+	if _, err := DecodeJSON([]byte(`{} []`)); err == nil {
+		t.Error("Expected an error for trailing data")
+	}
+}
@@ -14,6 +14,7 @@ package mock
 
 import (
 	"context"
+	"encoding/json"
 
 	"github.com/go-kivik/kivik/v4/driver"
 )
@@ -133,6 +134,44 @@ func (c *Pinger) Ping(ctx context.Context) (bool, error) {
 	return c.PingFunc(ctx)
 }
 
+// ActiveTasker mocks driver.Client and driver.ActiveTasker
+type ActiveTasker struct {
+	*Client
+	ActiveTasksFunc func(context.Context) ([]json.RawMessage, error)
+}
+
+var _ driver.ActiveTasker = &ActiveTasker{}
+
+// ActiveTasks calls c.ActiveTasksFunc
+func (c *ActiveTasker) ActiveTasks(ctx context.Context) ([]json.RawMessage, error) {
+	return c.ActiveTasksFunc(ctx)
+}
+
+// Scheduler mocks driver.Client and driver.Scheduler
+type Scheduler struct {
+	*Client
+	SchedulerJobsFunc func(context.Context, map[string]interface{}) (*driver.SchedulerJobs, error)
+	SchedulerDocsFunc func(context.Context, string, map[string]interface{}) (*driver.SchedulerDocs, error)
+	SchedulerDocFunc  func(context.Context, string, string) (*driver.SchedulerDoc, error)
+}
+
+var _ driver.Scheduler = &Scheduler{}
+
+// SchedulerJobs calls c.SchedulerJobsFunc
+func (c *Scheduler) SchedulerJobs(ctx context.Context, options map[string]interface{}) (*driver.SchedulerJobs, error) {
+	return c.SchedulerJobsFunc(ctx, options)
+}
+
+// SchedulerDocs calls c.SchedulerDocsFunc
+func (c *Scheduler) SchedulerDocs(ctx context.Context, replicatorDB string, options map[string]interface{}) (*driver.SchedulerDocs, error) {
+	return c.SchedulerDocsFunc(ctx, replicatorDB, options)
+}
+
+// SchedulerDoc calls c.SchedulerDocFunc
+func (c *Scheduler) SchedulerDoc(ctx context.Context, replicatorDB, docID string) (*driver.SchedulerDoc, error) {
+	return c.SchedulerDocFunc(ctx, replicatorDB, docID)
+}
+
 // Cluster mocks driver.Client and driver.Cluster
 type Cluster struct {
 	*Client
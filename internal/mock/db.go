@@ -337,3 +337,28 @@ type PartitionedDB struct {
 func (db *PartitionedDB) PartitionStats(ctx context.Context, name string) (*driver.PartitionStats, error) {
 	return db.PartitionStatsFunc(ctx, name)
 }
+
+// Searcher mocks a driver.DB and driver.Searcher.
+type Searcher struct {
+	*DB
+	SearchFunc        func(context.Context, string, string, string, map[string]interface{}) (driver.Rows, error)
+	SearchInfoFunc    func(context.Context, string, string) (*driver.SearchInfo, error)
+	SearchAnalyzeFunc func(context.Context, string) ([]string, error)
+}
+
+var _ driver.Searcher = &Searcher{}
+
+// Search calls db.SearchFunc
+func (db *Searcher) Search(ctx context.Context, ddoc, index, query string, opts map[string]interface{}) (driver.Rows, error) {
+	return db.SearchFunc(ctx, ddoc, index, query, opts)
+}
+
+// SearchInfo calls db.SearchInfoFunc
+func (db *Searcher) SearchInfo(ctx context.Context, ddoc, index string) (*driver.SearchInfo, error) {
+	return db.SearchInfoFunc(ctx, ddoc, index)
+}
+
+// SearchAnalyze calls db.SearchAnalyzeFunc
+func (db *Searcher) SearchAnalyze(ctx context.Context, text string) ([]string, error) {
+	return db.SearchAnalyzeFunc(ctx, text)
+}
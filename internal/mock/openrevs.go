@@ -0,0 +1,52 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package mock
+
+import (
+	"context"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// OpenRevs mocks driver.OpenRevs
+type OpenRevs struct {
+	// ID identifies a specific OpenRevs instance
+	ID        string
+	NextFunc  func(*driver.OpenRev) error
+	CloseFunc func() error
+}
+
+var _ driver.OpenRevs = &OpenRevs{}
+
+// Next calls r.NextFunc
+func (r *OpenRevs) Next(rev *driver.OpenRev) error {
+	return r.NextFunc(rev)
+}
+
+// Close calls r.CloseFunc
+func (r *OpenRevs) Close() error {
+	return r.CloseFunc()
+}
+
+// OpenRevsGetter mocks a driver.DB and driver.OpenRevsGetter.
+type OpenRevsGetter struct {
+	*DB
+	OpenRevsFunc func(ctx context.Context, docID string, revs []string, options map[string]interface{}) (driver.OpenRevs, error)
+}
+
+var _ driver.OpenRevsGetter = &OpenRevsGetter{}
+
+// OpenRevs calls db.OpenRevsFunc
+func (db *OpenRevsGetter) OpenRevs(ctx context.Context, docID string, revs []string, options map[string]interface{}) (driver.OpenRevs, error) {
+	return db.OpenRevsFunc(ctx, docID, revs, options)
+}
@@ -90,3 +90,29 @@ var _ driver.QueryIndexer = &QueryIndexer{}
 func (r *QueryIndexer) QueryIndex() int {
 	return r.QueryIndexFunc()
 }
+
+// Faceter provides driver.Faceter.
+type Faceter struct {
+	*Rows
+	FacetsFunc func() []driver.Facet
+}
+
+var _ driver.Faceter = &Faceter{}
+
+// Facets calls r.FacetsFunc
+func (r *Faceter) Facets() []driver.Facet {
+	return r.FacetsFunc()
+}
+
+// SearchGrouper provides driver.SearchGrouper.
+type SearchGrouper struct {
+	*Rows
+	SearchGroupFunc func() (string, int64)
+}
+
+var _ driver.SearchGrouper = &SearchGrouper{}
+
+// SearchGroup calls r.SearchGroupFunc
+func (r *SearchGrouper) SearchGroup() (string, int64) {
+	return r.SearchGroupFunc()
+}
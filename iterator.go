@@ -29,13 +29,15 @@ type iterator interface {
 type iter struct {
 	feed iterator
 
-	mu      sync.RWMutex
-	ready   bool // Set to true once Next() has been called
-	closed  bool
-	lasterr error // non-nil only if closed is true
-	eoq     bool
+	mu       sync.RWMutex
+	ready    bool // Set to true once Next() has been called
+	closed   bool
+	lasterr  error // non-nil only if closed is true
+	closeErr error // the error returned by feed.Close
+	eoq      bool
 
-	cancel func() // cancel function to exit context goroutine when iterator is closed
+	ctx  context.Context
+	stop func() bool // stops watching ctx, once the iterator is closed
 
 	curVal interface{}
 }
@@ -58,22 +60,22 @@ func (i *iter) rlock() (unlock func(), err error) {
 // ctx is a possibly-cancellable context
 // zeroValue is an empty instance of the data type this iterator iterates over
 // feed is the iterator interface, which typically wraps a driver.X iterator
+//
+// Cancellation of ctx is observed by Next, and the iterator is also closed as
+// soon as ctx is done (see watchContext). From Go 1.21, no goroutine is started
+// unless that happens.
 func newIterator(ctx context.Context, feed iterator, zeroValue interface{}) *iter {
 	i := &iter{
 		feed:   feed,
 		curVal: zeroValue,
+		ctx:    ctx,
+	}
+	if ctx.Done() != nil {
+		i.watchContext()
 	}
-	ctx, i.cancel = context.WithCancel(ctx)
-	go i.awaitDone(ctx)
 	return i
 }
 
-// awaitDone blocks until the rows are closed or the context is cancelled, then closes the iterator if it's still open.
-func (i *iter) awaitDone(ctx context.Context) {
-	<-ctx.Done()
-	_ = i.close(ctx.Err())
-}
-
 // Next prepares the next iterator result value for reading. It returns true on
 // success, or false if there is no next result or an error occurs while
 // preparing it. Err should be consulted to distinguish between the two.
@@ -93,6 +95,12 @@ func (i *iter) next() (doClose, ok bool) {
 	}
 	i.ready = true
 	i.eoq = false
+	if i.ctx != nil {
+		if err := i.ctx.Err(); err != nil {
+			i.lasterr = err
+			return true, false
+		}
+	}
 	err := i.feed.Next(i.curVal)
 	if err == driver.EOQ {
 		i.eoq = true
@@ -135,14 +143,23 @@ func (i *iter) close(err error) error {
 	}
 
 	err = i.feed.Close()
+	i.closeErr = err
 
-	if i.cancel != nil {
-		i.cancel()
+	if i.stop != nil {
+		i.stop()
 	}
 
 	return err
 }
 
+// feedCloseErr returns the error returned when the underlying feed was
+// closed, whether explicitly or implicitly.
+func (i *iter) feedCloseErr() error {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.closeErr
+}
+
 // Err returns the error, if any, that was encountered during iteration. Err
 // may be called after an explicit or implicit Close.
 func (i *iter) Err() error {
@@ -0,0 +1,42 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+//go:build !go1.21
+// +build !go1.21
+
+package kivik
+
+import "sync"
+
+// watchContext arranges for the iterator to be closed when its context is
+// done. Go versions before 1.21 lack context.AfterFunc, so a goroutine waits
+// for the context, until the iterator is closed.
+func (i *iter) watchContext() {
+	ctx := i.ctx
+	done := make(chan struct{})
+	var once sync.Once
+	i.stop = func() bool {
+		stopped := false
+		once.Do(func() {
+			close(done)
+			stopped = true
+		})
+		return stopped
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = i.close(ctx.Err())
+		case <-done:
+		}
+	}()
+}
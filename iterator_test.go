@@ -16,6 +16,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"runtime"
 	"testing"
 	"time"
 
@@ -75,6 +76,67 @@ func TestCancelledIterator(t *testing.T) {
 	}
 }
 
+// benchFeed is like TestFeed, without the delay.
+type benchFeed struct {
+	max, i int64
+}
+
+func (f *benchFeed) Close() error { return nil }
+func (f *benchFeed) Next(ifce interface{}) error {
+	if f.i >= f.max {
+		return io.EOF
+	}
+	*ifce.(*int64) = f.i
+	f.i++
+	return nil
+}
+
+func BenchmarkIterator(b *testing.B) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tests := []struct {
+		name string
+		ctx  context.Context
+	}{
+		{name: "background", ctx: context.Background()},
+		{name: "cancelable", ctx: cancelled},
+	}
+	for _, test := range tests {
+		b.Run(test.name, func(b *testing.B) {
+			b.ReportAllocs()
+			var val int64
+			for n := 0; n < b.N; n++ {
+				iter := newIterator(test.ctx, &benchFeed{max: 10}, &val)
+				for iter.Next() {
+				}
+				if err := iter.Err(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkOpenIterators holds b.N iterators open at once, and reports the
+// number of goroutines started per open iterator.
+func BenchmarkOpenIterators(b *testing.B) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b.ReportAllocs()
+	iters := make([]*iter, b.N)
+	var val int64
+	before := runtime.NumGoroutine()
+	b.ResetTimer()
+	for n := range iters {
+		iters[n] = newIterator(ctx, &benchFeed{max: 1}, &val)
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(runtime.NumGoroutine()-before)/float64(b.N), "goroutines/op")
+	for _, iter := range iters {
+		_ = iter.Close()
+	}
+}
+
 func ExampleRows_eOQ() {
 	client, err := New("couch", "http://example.com:5984/")
 	if err != nil {
@@ -112,3 +174,32 @@ func ExampleRows_eOQ() {
 		panic(err)
 	}
 }
+
+type closeNotifyFeed struct {
+	benchFeed
+	closed chan struct{}
+}
+
+func (f *closeNotifyFeed) Close() error {
+	close(f.closed)
+	return nil
+}
+
+func TestIteratorClosedOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	feed := &closeNotifyFeed{benchFeed: benchFeed{max: 10}, closed: make(chan struct{})}
+	var val int64
+	iter := newIterator(ctx, feed, &val)
+	cancel()
+	select {
+	case <-feed.closed:
+	case <-time.After(time.Second):
+		t.Fatal("feed not closed after context cancellation")
+	}
+	if iter.Next() {
+		t.Error("Next should return false after cancellation")
+	}
+	if err := iter.Err(); err != context.Canceled {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -64,7 +64,15 @@ func New(driverName, dataSourceName string, options ...Options) (*Client, error)
 	if driveri == nil {
 		return nil, &Error{HTTPStatus: http.StatusBadRequest, Message: fmt.Sprintf("kivik: unknown driver %q (forgotten import?)", driverName)}
 	}
-	client, err := driveri.NewClient(dataSourceName, mergeOptions(options...))
+	opts := mergeOptions(options...)
+	if interceptors := middleware(options); len(interceptors) > 0 {
+		driveri = driver.Wrap(driverName, driveri, interceptors...)
+		delete(opts, middlewareKey)
+		if len(opts) == 0 {
+			opts = nil
+		}
+	}
+	client, err := driveri.NewClient(dataSourceName, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -160,51 +168,34 @@ func missingArg(arg string) error {
 	return &Error{HTTPStatus: http.StatusBadRequest, Message: fmt.Sprintf("kivik: %s required", arg)}
 }
 
-// DBsStats returns database statistics about one or more databases.
-func (c *Client) DBsStats(ctx context.Context, dbnames []string) ([]*DBStats, error) {
-	dbstats, err := c.nativeDBsStats(ctx, dbnames)
+// DBsStats returns database statistics about one or more databases. The
+// returned slice is in the same order as dbnames, with a nil entry for any
+// database which does not exist.
+//
+// If the driver implements driver.DBsStatser, dbnames is passed to it in
+// batches (see DBsStatsBatchSize). Otherwise, or if the server does not
+// support the request, DB.Stats is called for each database, concurrently
+// (see DBsStatsConcurrency).
+func (c *Client) DBsStats(ctx context.Context, dbnames []string, options ...Options) ([]*DBStats, error) {
+	opts := mergeOptions(options...)
+	dbstats, err := c.nativeDBsStats(ctx, dbnames, intOption(opts, dbsStatsBatchSizeKey, defaultDBsStatsBatchSize))
 	switch StatusCode(err) {
 	case http.StatusNotFound, http.StatusNotImplemented:
-		return c.fallbackDBsStats(ctx, dbnames)
+		return c.fallbackDBsStats(ctx, dbnames, intOption(opts, dbsStatsConcurrencyKey, defaultDBsStatsConcurrency))
 	}
 	return dbstats, err
 }
 
-func (c *Client) fallbackDBsStats(ctx context.Context, dbnames []string) ([]*DBStats, error) {
-	dbstats := make([]*DBStats, len(dbnames))
-	for i, dbname := range dbnames {
-		stat, err := c.DB(dbname).Stats(ctx)
-		if err != nil {
-			return nil, err
-		}
-		dbstats[i] = stat
-	}
-	return dbstats, nil
-}
-
-func (c *Client) nativeDBsStats(ctx context.Context, dbnames []string) ([]*DBStats, error) {
-	statser, ok := c.driverClient.(driver.DBsStatser)
-	if !ok {
-		return nil, &Error{HTTPStatus: http.StatusNotImplemented, Message: "kivik: not supported by driver"}
-	}
-	stats, err := statser.DBsStats(ctx, dbnames)
-	if err != nil {
-		return nil, err
-	}
-	dbstats := make([]*DBStats, len(stats))
-	for i, stat := range stats {
-		dbstats[i] = driverStats2kivikStats(stat)
-	}
-	return dbstats, nil
-}
-
 // Ping returns true if the database is online and available for requests,
 // for instance by querying the /_up endpoint. If the underlying driver
 // supports the Pinger interface, it will be used. Otherwise, a fallback is
 // made to calling Version.
 func (c *Client) Ping(ctx context.Context) (bool, error) {
 	if pinger, ok := c.driverClient.(driver.Pinger); ok {
-		return pinger.Ping(ctx)
+		up, err := pinger.Ping(ctx)
+		if !isNotImplemented(err) {
+			return up, err
+		}
 	}
 	_, err := c.driverClient.Version(ctx)
 	return err == nil, err
@@ -213,7 +204,9 @@ func (c *Client) Ping(ctx context.Context) (bool, error) {
 // Close cleans up any resources used by Client.
 func (c *Client) Close(ctx context.Context) error {
 	if closer, ok := c.driverClient.(driver.ClientCloser); ok {
-		return closer.Close(ctx)
+		if err := closer.Close(ctx); !isNotImplemented(err) {
+			return err
+		}
 	}
 	return nil
 }
@@ -592,6 +592,28 @@ func TestDBsStats(t *testing.T) {
 			err:     "fallback failure",
 			status:  500,
 		},
+		{
+			name: "fallback missing database",
+			client: &Client{
+				driverClient: &mock.Client{
+					DBFunc: func(name string, _ map[string]interface{}) (driver.DB, error) {
+						return &mock.DB{
+							StatsFunc: func(_ context.Context) (*driver.DBStats, error) {
+								if name == "foo" {
+									return &driver.DBStats{Name: "foo", DiskSize: 123}, nil
+								}
+								return nil, &Error{HTTPStatus: http.StatusNotFound, Message: "Database does not exist."}
+							},
+						}, nil
+					},
+				},
+			},
+			dbnames: []string{"foo", "bar"},
+			expected: []*DBStats{
+				{Name: "foo", DiskSize: 123},
+				nil,
+			},
+		},
 		{
 			name: "fallback db connect error",
 			client: &Client{
@@ -0,0 +1,122 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package kivikotel provides OpenTelemetry tracing for Kivik clients.
+//
+// Tracing is enabled by passing the middleware to kivik.New:
+//
+//	client, err := kivik.New("couch", dsn, kivikotel.Middleware())
+//
+// A span is created for every driver method call. Spans for calls which return
+// an iterator, such as DB.Query or DB.Changes, remain open until the iterator
+// is closed, and record the number of items read.
+package kivikotel
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// instrumentationName identifies this package as the source of spans.
+const instrumentationName = "github.com/go-kivik/kivik/v4/kivikotel"
+
+// Attribute keys set on spans.
+const (
+	DriverKey     = attribute.Key("kivik.driver")
+	MethodKey     = attribute.Key("kivik.method")
+	DBNameKey     = attribute.Key("db.name")
+	DocIDKey      = attribute.Key("kivik.doc_id")
+	StatusCodeKey = attribute.Key("kivik.status_code")
+	RowCountKey   = attribute.Key("kivik.row_count")
+)
+
+type config struct {
+	provider trace.TracerProvider
+}
+
+// Option configures the tracing middleware.
+type Option func(*config)
+
+// WithTracerProvider sets the TracerProvider used to create spans. By default,
+// the global TracerProvider is used.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(c *config) {
+		c.provider = provider
+	}
+}
+
+// Middleware returns an option which enables tracing, when passed to
+// kivik.New.
+func Middleware(options ...Option) kivik.Options {
+	return kivik.WithMiddleware(Interceptor(options...))
+}
+
+// Interceptor returns a driver.Interceptor which creates a span for each
+// driver method call.
+func Interceptor(options ...Option) driver.Interceptor {
+	cfg := &config{}
+	for _, opt := range options {
+		opt(cfg)
+	}
+	if cfg.provider == nil {
+		cfg.provider = otel.GetTracerProvider()
+	}
+	tracer := cfg.provider.Tracer(instrumentationName)
+	return func(ctx context.Context, call *driver.Call, invoke driver.Invoker) error {
+		attrs := []attribute.KeyValue{
+			DriverKey.String(call.Driver),
+			MethodKey.String(call.Method),
+		}
+		if call.DBName != "" {
+			attrs = append(attrs, DBNameKey.String(call.DBName))
+		}
+		if call.DocID != "" {
+			attrs = append(attrs, DocIDKey.String(call.DocID))
+		}
+		ctx, span := tracer.Start(ctx, "kivik."+call.Method,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(attrs...),
+		)
+		err := invoke(ctx)
+		if err != nil || !call.Iterator {
+			endSpan(span, err)
+			return err
+		}
+		call.OnIteratorClose(func(count int64, err error) {
+			span.SetAttributes(RowCountKey.Int64(count))
+			endSpan(span, err)
+		})
+		return nil
+	}
+}
+
+// endSpan records the outcome of a call, with a status code of 200 on
+// success, and ends span.
+func endSpan(span trace.Span, err error) {
+	if err == nil {
+		span.SetAttributes(StatusCodeKey.Int(http.StatusOK))
+		span.End()
+		return
+	}
+	span.SetAttributes(StatusCodeKey.Int(kivik.StatusCode(err)))
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	span.End()
+}
@@ -0,0 +1,157 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivikotel
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func init() {
+	kivik.Register("oteltest", &mock.Driver{
+		NewClientFunc: func(_ string, _ map[string]interface{}) (driver.Client, error) {
+			return &mock.Client{
+				AllDBsFunc: func(_ context.Context, _ map[string]interface{}) ([]string, error) {
+					return []string{"foo"}, nil
+				},
+				DBFunc: func(_ string, _ map[string]interface{}) (driver.DB, error) {
+					return &mock.DB{
+						GetFunc: func(_ context.Context, _ string, _ map[string]interface{}) (*driver.Document, error) {
+							return nil, &kivik.Error{HTTPStatus: http.StatusNotFound, Message: "missing"}
+						},
+						QueryFunc: func(_ context.Context, _, _ string, _ map[string]interface{}) (driver.Rows, error) {
+							rows := 3
+							return &mock.Rows{
+								NextFunc: func(r *driver.Row) error {
+									if rows == 0 {
+										return io.EOF
+									}
+									rows--
+									r.ID = "x"
+									return nil
+								},
+								CloseFunc: func() error { return nil },
+							}, nil
+						},
+					}, nil
+				},
+			}, nil
+		},
+	})
+}
+
+func newTestClient(t *testing.T) (*kivik.Client, *tracetest.SpanRecorder) {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	client, err := kivik.New("oteltest", "", Middleware(WithTracerProvider(provider)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, recorder
+}
+
+func spanAttrs(span sdktrace.ReadOnlySpan) map[attribute.Key]interface{} {
+	attrs := map[attribute.Key]interface{}{}
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value.AsInterface()
+	}
+	return attrs
+}
+
+func TestInterceptor(t *testing.T) {
+	ctx := context.Background()
+	t.Run("success", func(t *testing.T) {
+		client, recorder := newTestClient(t)
+		if _, err := client.AllDBs(ctx); err != nil {
+			t.Fatal(err)
+		}
+		spans := recorder.Ended()
+		if len(spans) != 1 {
+			t.Fatalf("Expected 1 span, got %d", len(spans))
+		}
+		if name := spans[0].Name(); name != "kivik.AllDBs" {
+			t.Errorf("Unexpected span name: %s", name)
+		}
+		expected := map[attribute.Key]interface{}{
+			DriverKey:     "oteltest",
+			MethodKey:     "AllDBs",
+			StatusCodeKey: int64(http.StatusOK),
+		}
+		if d := testy.DiffInterface(expected, spanAttrs(spans[0])); d != nil {
+			t.Error(d)
+		}
+		if status := spans[0].Status(); status.Code != codes.Unset {
+			t.Errorf("Unexpected span status: %v", status)
+		}
+	})
+	t.Run("error", func(t *testing.T) {
+		client, recorder := newTestClient(t)
+		err := client.DB("foo").Get(ctx, "bar").Err
+		testy.StatusError(t, "missing", http.StatusNotFound, err)
+		spans := recorder.Ended()
+		if len(spans) != 1 {
+			t.Fatalf("Expected 1 span, got %d", len(spans))
+		}
+		expected := map[attribute.Key]interface{}{
+			DriverKey:     "oteltest",
+			MethodKey:     "Get",
+			DBNameKey:     "foo",
+			DocIDKey:      "bar",
+			StatusCodeKey: int64(http.StatusNotFound),
+		}
+		if d := testy.DiffInterface(expected, spanAttrs(spans[0])); d != nil {
+			t.Error(d)
+		}
+		if status := spans[0].Status(); status.Code != codes.Error {
+			t.Errorf("Unexpected span status: %v", status)
+		}
+	})
+	t.Run("iterator", func(t *testing.T) {
+		client, recorder := newTestClient(t)
+		rows, err := client.DB("foo").Query(ctx, "_design/foo", "bar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := len(recorder.Ended()); n != 0 {
+			t.Errorf("Span should remain open until the iterator is closed, got %d ended spans", n)
+		}
+		for rows.Next() {
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		spans := recorder.Ended()
+		if len(spans) != 1 {
+			t.Fatalf("Expected 1 span, got %d", len(spans))
+		}
+		if count := spanAttrs(spans[0])[RowCountKey]; count != int64(3) {
+			t.Errorf("Unexpected row count: %v", count)
+		}
+		if status := spanAttrs(spans[0])[StatusCodeKey]; status != int64(http.StatusOK) {
+			t.Errorf("Unexpected status code: %v", status)
+		}
+	})
+}
@@ -0,0 +1,147 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package kivikprom provides a Prometheus implementation of metrics.Collector.
+//
+//	collector := kivikprom.NewCollector()
+//	prometheus.MustRegister(collector)
+//	client, err := kivik.New("couch", dsn, metrics.Middleware(collector))
+package kivikprom
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/go-kivik/kivik/v4/metrics"
+)
+
+// Collector is a metrics.Collector which exports metrics to Prometheus. It
+// must be registered with a prometheus.Registerer to be exported.
+type Collector struct {
+	latency   *prometheus.HistogramVec
+	errors    *prometheus.CounterVec
+	rows      *prometheus.HistogramVec
+	iterators *prometheus.GaugeVec
+}
+
+var (
+	_ metrics.Collector    = &Collector{}
+	_ prometheus.Collector = &Collector{}
+)
+
+type config struct {
+	namespace      string
+	latencyBuckets []float64
+	rowBuckets     []float64
+}
+
+// Option configures a Collector.
+type Option func(*config)
+
+// WithNamespace sets the namespace of the exported metrics. The default is
+// "kivik".
+func WithNamespace(namespace string) Option {
+	return func(c *config) {
+		c.namespace = namespace
+	}
+}
+
+// WithLatencyBuckets sets the buckets, in seconds, of the request duration
+// histogram. The default is prometheus.DefBuckets.
+func WithLatencyBuckets(buckets []float64) Option {
+	return func(c *config) {
+		c.latencyBuckets = buckets
+	}
+}
+
+// WithRowBuckets sets the buckets of the rows-per-iterator histogram. The
+// default is exponential, from 1 to 100,000.
+func WithRowBuckets(buckets []float64) Option {
+	return func(c *config) {
+		c.rowBuckets = buckets
+	}
+}
+
+// NewCollector returns a new Collector.
+func NewCollector(options ...Option) *Collector {
+	cfg := &config{
+		namespace:      "kivik",
+		latencyBuckets: prometheus.DefBuckets,
+		rowBuckets:     prometheus.ExponentialBuckets(1, 10, 6),
+	}
+	for _, opt := range options {
+		opt(cfg)
+	}
+	labels := []string{"driver", "method"}
+	return &Collector{
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: cfg.namespace,
+			Name:      "request_duration_seconds",
+			Help:      "Duration of driver method calls.",
+			Buckets:   cfg.latencyBuckets,
+		}, labels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: cfg.namespace,
+			Name:      "request_errors_total",
+			Help:      "Failed driver method calls and iterators, by HTTP status.",
+		}, append(labels, "status")),
+		rows: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: cfg.namespace,
+			Name:      "iterator_rows",
+			Help:      "Number of items read from each closed iterator.",
+			Buckets:   cfg.rowBuckets,
+		}, labels),
+		iterators: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: cfg.namespace,
+			Name:      "open_iterators",
+			Help:      "Number of iterators which have not yet been closed.",
+		}, labels),
+	}
+}
+
+// Describe satisfies the prometheus.Collector interface.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.latency.Describe(ch)
+	c.errors.Describe(ch)
+	c.rows.Describe(ch)
+	c.iterators.Describe(ch)
+}
+
+// Collect satisfies the prometheus.Collector interface.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.latency.Collect(ch)
+	c.errors.Collect(ch)
+	c.rows.Collect(ch)
+	c.iterators.Collect(ch)
+}
+
+// ObserveLatency satisfies the metrics.Collector interface.
+func (c *Collector) ObserveLatency(labels metrics.Labels, d time.Duration) {
+	c.latency.WithLabelValues(labels.Driver, labels.Method).Observe(d.Seconds())
+}
+
+// IncErrors satisfies the metrics.Collector interface.
+func (c *Collector) IncErrors(labels metrics.Labels, status int) {
+	c.errors.WithLabelValues(labels.Driver, labels.Method, strconv.Itoa(status)).Inc()
+}
+
+// ObserveRows satisfies the metrics.Collector interface.
+func (c *Collector) ObserveRows(labels metrics.Labels, count int64) {
+	c.rows.WithLabelValues(labels.Driver, labels.Method).Observe(float64(count))
+}
+
+// AddOpenIterators satisfies the metrics.Collector interface.
+func (c *Collector) AddOpenIterators(labels metrics.Labels, delta int) {
+	c.iterators.WithLabelValues(labels.Driver, labels.Method).Add(float64(delta))
+}
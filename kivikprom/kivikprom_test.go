@@ -0,0 +1,52 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivikprom
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/go-kivik/kivik/v4/metrics"
+)
+
+func TestCollector(t *testing.T) {
+	c := NewCollector()
+	get := metrics.Labels{Driver: "couch", Method: "Get"}
+	changes := metrics.Labels{Driver: "couch", Method: "Changes"}
+
+	c.ObserveLatency(get, 10*time.Millisecond)
+	c.IncErrors(get, 404)
+	c.IncErrors(get, 404)
+	c.AddOpenIterators(changes, 1)
+	c.AddOpenIterators(changes, 1)
+	c.AddOpenIterators(changes, -1)
+	c.ObserveRows(changes, 5)
+
+	expected := `
+# HELP kivik_open_iterators Number of iterators which have not yet been closed.
+# TYPE kivik_open_iterators gauge
+kivik_open_iterators{driver="couch",method="Changes"} 1
+# HELP kivik_request_errors_total Failed driver method calls and iterators, by HTTP status.
+# TYPE kivik_request_errors_total counter
+kivik_request_errors_total{driver="couch",method="Get",status="404"} 2
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "kivik_open_iterators", "kivik_request_errors_total"); err != nil {
+		t.Error(err)
+	}
+	if n := testutil.CollectAndCount(c, "kivik_request_duration_seconds", "kivik_iterator_rows"); n != 2 {
+		t.Errorf("Unexpected number of histograms: %d", n)
+	}
+}
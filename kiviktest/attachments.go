@@ -0,0 +1,104 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kiviktest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+func newAttachment(content string) *driver.Attachment {
+	return &driver.Attachment{
+		Filename:    "foo.txt",
+		ContentType: "text/plain",
+		Content:     ioutil.NopCloser(strings.NewReader(content)),
+	}
+}
+
+func (s *suite) testAttachments(t *testing.T) {
+	ctx, cancel := s.context()
+	defer cancel()
+	db, name := s.newDB(t, ctx)
+	defer s.destroyDB(name)
+	if !s.Capabilities.Has(Attachments) {
+		_, err := db.PutAttachment(ctx, "foo", "", newAttachment("Hello, World!"), nil)
+		unsupported(t, "PutAttachment", err)
+		return
+	}
+	rev, err := db.PutAttachment(ctx, "foo", "", newAttachment("Hello, World!"), nil)
+	if err != nil {
+		t.Fatalf("PutAttachment failed: %s", err)
+	}
+
+	t.Run("stub", func(t *testing.T) {
+		doc, err := db.Get(ctx, "foo", nil)
+		if err != nil {
+			t.Fatalf("Get failed: %s", err)
+		}
+		atts, _ := decodeDoc(t, doc)["_attachments"].(map[string]interface{})
+		att, _ := atts["foo.txt"].(map[string]interface{})
+		if stub, _ := att["stub"].(bool); !stub {
+			t.Errorf("Expected a stub for foo.txt, got %v", atts)
+		}
+	})
+	t.Run("stub preserved", func(t *testing.T) {
+		newRev, err := db.Put(ctx, "foo", map[string]interface{}{
+			"_id":  "foo",
+			"_rev": rev,
+			"_attachments": map[string]interface{}{
+				"foo.txt": map[string]interface{}{"stub": true},
+			},
+		}, nil)
+		if err != nil {
+			t.Fatalf("Put with attachment stub failed: %s", err)
+		}
+		rev = newRev
+		att, err := db.GetAttachment(ctx, "foo", "foo.txt", nil)
+		if err != nil {
+			t.Fatalf("GetAttachment failed: %s", err)
+		}
+		defer att.Content.Close() // nolint: errcheck
+		content, err := ioutil.ReadAll(att.Content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != "Hello, World!" {
+			t.Errorf("Unexpected attachment content: %q", content)
+		}
+	})
+	t.Run("unknown stub", func(t *testing.T) {
+		_, err := db.Put(ctx, "foo", map[string]interface{}{
+			"_id":  "foo",
+			"_rev": rev,
+			"_attachments": map[string]interface{}{
+				"bar.txt": map[string]interface{}{"stub": true},
+			},
+		}, nil)
+		checkStatus(t, "Put with an unknown attachment stub", http.StatusPreconditionFailed, err)
+	})
+	t.Run("missing", func(t *testing.T) {
+		_, err := db.GetAttachment(ctx, "foo", "bar.txt", nil)
+		checkStatus(t, "GetAttachment of a missing attachment", http.StatusNotFound, err)
+	})
+	t.Run("delete", func(t *testing.T) {
+		if _, err := db.DeleteAttachment(ctx, "foo", rev, "foo.txt", nil); err != nil {
+			t.Fatalf("DeleteAttachment failed: %s", err)
+		}
+		_, err := db.GetAttachment(ctx, "foo", "foo.txt", nil)
+		checkStatus(t, "GetAttachment of a deleted attachment", http.StatusNotFound, err)
+	})
+}
@@ -0,0 +1,83 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kiviktest
+
+import (
+	"io"
+	"testing"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// readChanges reads changes until io.EOF, returning the IDs read. changes
+// is closed before returning.
+func readChanges(t *testing.T, changes driver.Changes) []string {
+	t.Helper()
+	defer changes.Close() // nolint: errcheck
+	var ids []string
+	for {
+		var change driver.Change
+		err := changes.Next(&change)
+		if err == io.EOF {
+			return ids
+		}
+		if err != nil {
+			t.Fatalf("Next returned %s; changes feeds must end with io.EOF", err)
+		}
+		ids = append(ids, change.ID)
+	}
+}
+
+func (s *suite) testChanges(t *testing.T) {
+	ctx, cancel := s.context()
+	defer cancel()
+	db, name := s.newDB(t, ctx)
+	defer s.destroyDB(name)
+	if !s.Capabilities.Has(Changes) {
+		_, err := db.Changes(ctx, nil)
+		unsupported(t, "Changes", err)
+		return
+	}
+	put(t, ctx, db, "a", "b")
+
+	var lastSeq string
+	t.Run("normal", func(t *testing.T) {
+		changes, err := db.Changes(ctx, nil)
+		if err != nil {
+			t.Fatalf("Changes failed: %s", err)
+		}
+		checkList(t, []string{"a", "b"}, readChanges(t, changes))
+		lastSeq = changes.LastSeq()
+		if lastSeq == "" {
+			t.Error("LastSeq should be set after iteration")
+		}
+	})
+	t.Run("since", func(t *testing.T) {
+		if lastSeq == "" {
+			t.Skip("no last_seq")
+		}
+		put(t, ctx, db, "c")
+		changes, err := db.Changes(ctx, map[string]interface{}{"since": lastSeq})
+		if err != nil {
+			t.Fatalf("Changes failed: %s", err)
+		}
+		checkList(t, []string{"c"}, readChanges(t, changes))
+	})
+	t.Run("limit", func(t *testing.T) {
+		changes, err := db.Changes(ctx, map[string]interface{}{"limit": 1})
+		if err != nil {
+			t.Fatalf("Changes failed: %s", err)
+		}
+		checkList(t, []string{"a"}, readChanges(t, changes))
+	})
+}
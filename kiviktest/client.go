@@ -0,0 +1,68 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kiviktest
+
+import (
+	"net/http"
+	"testing"
+)
+
+func (s *suite) testClient(t *testing.T) {
+	ctx, cancel := s.context()
+	defer cancel()
+	t.Run("Version", func(t *testing.T) {
+		ver, err := s.client.Version(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ver == nil {
+			t.Error("Version returned nil")
+		}
+	})
+	t.Run("lifecycle", func(t *testing.T) {
+		name := s.dbName()
+		if err := s.client.CreateDB(ctx, name, nil); err != nil {
+			t.Fatalf("CreateDB failed: %s", err)
+		}
+		defer s.destroyDB(name)
+		if exists, err := s.client.DBExists(ctx, name, nil); err != nil || !exists {
+			t.Errorf("DBExists returned %t, %v for a new database", exists, err)
+		}
+		dbs, err := s.client.AllDBs(ctx, nil)
+		if err != nil {
+			t.Fatalf("AllDBs failed: %s", err)
+		}
+		if !contains(dbs, name) {
+			t.Errorf("AllDBs did not include %q", name)
+		}
+		err = s.client.CreateDB(ctx, name, nil)
+		checkStatus(t, "CreateDB of an existing database", http.StatusPreconditionFailed, err)
+		if err := s.client.DestroyDB(ctx, name, nil); err != nil {
+			t.Fatalf("DestroyDB failed: %s", err)
+		}
+		if exists, err := s.client.DBExists(ctx, name, nil); err != nil || exists {
+			t.Errorf("DBExists returned %t, %v for a destroyed database", exists, err)
+		}
+		err = s.client.DestroyDB(ctx, name, nil)
+		checkStatus(t, "DestroyDB of a missing database", http.StatusNotFound, err)
+	})
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kiviktest
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// decodeDoc decodes and closes the body of doc.
+func decodeDoc(t *testing.T, doc *driver.Document) map[string]interface{} {
+	t.Helper()
+	defer doc.Body.Close() // nolint: errcheck
+	var result map[string]interface{}
+	if err := json.NewDecoder(doc.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode document: %s", err)
+	}
+	return result
+}
+
+func (s *suite) testDocs(t *testing.T) {
+	ctx, cancel := s.context()
+	defer cancel()
+	db, name := s.newDB(t, ctx)
+	defer s.destroyDB(name)
+
+	rev, err := db.Put(ctx, "foo", map[string]interface{}{"_id": "foo", "value": 1}, nil)
+	if err != nil {
+		t.Fatalf("Put failed: %s", err)
+	}
+	if rev == "" {
+		t.Fatal("Put returned an empty rev")
+	}
+	t.Run("Get", func(t *testing.T) {
+		doc, err := db.Get(ctx, "foo", nil)
+		if err != nil {
+			t.Fatalf("Get failed: %s", err)
+		}
+		if doc.Rev != rev {
+			t.Errorf("Get returned rev %q, expected %q", doc.Rev, rev)
+		}
+		body := decodeDoc(t, doc)
+		if body["_id"] != "foo" || body["_rev"] != rev {
+			t.Errorf("Unexpected document: %v", body)
+		}
+	})
+	t.Run("Get missing", func(t *testing.T) {
+		_, err := db.Get(ctx, "missing", nil)
+		checkStatus(t, "Get of a missing document", http.StatusNotFound, err)
+	})
+	t.Run("Put conflict", func(t *testing.T) {
+		_, err := db.Put(ctx, "foo", map[string]interface{}{"_id": "foo"}, nil)
+		checkStatus(t, "Put without the current rev", http.StatusConflict, err)
+	})
+	t.Run("update and delete", func(t *testing.T) {
+		rev2, err := db.Put(ctx, "foo", map[string]interface{}{"_id": "foo", "_rev": rev, "value": 2}, nil)
+		if err != nil {
+			t.Fatalf("Put failed: %s", err)
+		}
+		_, err = db.Delete(ctx, "foo", rev, nil)
+		checkStatus(t, "Delete of a stale rev", http.StatusConflict, err)
+		if _, err := db.Delete(ctx, "foo", rev2, nil); err != nil {
+			t.Fatalf("Delete failed: %s", err)
+		}
+		_, err = db.Get(ctx, "foo", nil)
+		checkStatus(t, "Get of a deleted document", http.StatusNotFound, err)
+	})
+	t.Run("CreateDoc", func(t *testing.T) {
+		docID, rev, err := db.CreateDoc(ctx, map[string]interface{}{"value": 1}, nil)
+		if err != nil {
+			t.Fatalf("CreateDoc failed: %s", err)
+		}
+		if docID == "" || rev == "" {
+			t.Fatalf("CreateDoc returned id %q, rev %q", docID, rev)
+		}
+		if _, err := db.Get(ctx, docID, nil); err != nil {
+			t.Errorf("Get of created document failed: %s", err)
+		}
+	})
+	t.Run("Stats", func(t *testing.T) {
+		stats, err := db.Stats(ctx)
+		if err != nil {
+			t.Fatalf("Stats failed: %s", err)
+		}
+		if stats.Name != name {
+			t.Errorf("Stats returned name %q, expected %q", stats.Name, name)
+		}
+	})
+}
+
+// decodeRowDoc decodes the document included in row.
+func decodeRowDoc(row *driver.Row, i interface{}) error {
+	if row.DocReader != nil {
+		return json.NewDecoder(row.DocReader).Decode(i)
+	}
+	return json.Unmarshal(row.Doc, i)
+}
@@ -0,0 +1,245 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package kiviktest provides a conformance test suite for Kivik drivers.
+//
+// The suite exercises a driver.Driver directly, checking the behavior the
+// kivik package depends on: iterators which end with io.EOF (and EOQ between
+// the result sets of a multi-query view), Offset, TotalRows and UpdateSeq
+// being available once iteration completes, the status codes of common
+// errors, attachment stubs, and the behavior of every optional interface.
+//
+// A driver author runs the suite from an ordinary test:
+//
+//	func TestConformance(t *testing.T) {
+//		kiviktest.Run(t, kiviktest.Config{
+//			Driver:       &myDriver{},
+//			DSN:          os.Getenv("MYDRIVER_DSN"),
+//			Capabilities: kiviktest.Attachments | kiviktest.Changes,
+//		})
+//	}
+//
+// Features a driver does not claim are skipped, with one exception: if a
+// driver satisfies an optional interface, or implements a required method,
+// for which it does not claim the capability, calling it must return an
+// error with status 501 (Not Implemented).
+package kiviktest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// Capability is a set of optional features supported by a driver.
+type Capability uint64
+
+// The capabilities which may be claimed by a driver. Features not listed here
+// are required of every driver.
+const (
+	// Attachments indicates support for PutAttachment, GetAttachment,
+	// DeleteAttachment and attachment stubs.
+	Attachments Capability = 1 << iota
+	// Changes indicates support for the changes feed.
+	Changes
+	// Views indicates support for DB.Query.
+	Views
+	// MultiQuery indicates support for the `queries` option to DB.Query,
+	// which returns EOQ between result sets.
+	MultiQuery
+	// Find indicates support for driver.OptsFinder, including bookmarks.
+	Find
+	// Security indicates support for DB.Security and DB.SetSecurity.
+	Security
+	// MetaGetter indicates support for driver.MetaGetter.
+	MetaGetter
+	// AttachmentMetaGetter indicates support for driver.AttachmentMetaGetter.
+	AttachmentMetaGetter
+	// Copier indicates support for driver.Copier.
+	Copier
+	// DesignDocer indicates support for driver.DesignDocer.
+	DesignDocer
+	// LocalDocer indicates support for driver.LocalDocer.
+	LocalDocer
+	// BulkDocer indicates support for driver.BulkDocer.
+	BulkDocer
+	// BulkGetter indicates support for driver.BulkGetter.
+	BulkGetter
+	// RevsDiffer indicates support for driver.RevsDiffer.
+	RevsDiffer
+	// Purger indicates support for driver.Purger.
+	Purger
+	// Flusher indicates support for driver.Flusher.
+	Flusher
+	// DBsStatser indicates support for driver.DBsStatser.
+	DBsStatser
+	// Pinger indicates support for driver.Pinger.
+	Pinger
+	// ActiveTasker indicates support for driver.ActiveTasker.
+	ActiveTasker
+	// Scheduler indicates support for driver.Scheduler.
+	Scheduler
+	// OpenRevsGetter indicates support for driver.OpenRevsGetter.
+	OpenRevsGetter
+)
+
+// Has returns true if c includes all of the capabilities in want.
+func (c Capability) Has(want Capability) bool {
+	return c&want == want
+}
+
+// Config configures a run of the conformance suite.
+type Config struct {
+	// Driver is the driver under test.
+	Driver driver.Driver
+	// DSN is the data source name passed to Driver.NewClient.
+	DSN string
+	// Options are passed to Driver.NewClient.
+	Options map[string]interface{}
+	// Capabilities are the optional features the driver claims to support.
+	Capabilities Capability
+	// DBPrefix is prepended to the names of the databases created by the
+	// suite. It defaults to "kiviktest-".
+	DBPrefix string
+	// DesignDoc is used to test views. It must define a view named "ids",
+	// which emits the document ID as the key, and null as the value, for
+	// every document. By default, a design document with a JavaScript map
+	// function is used.
+	DesignDoc interface{}
+	// Timeout limits the duration of each test. It defaults to 30 seconds.
+	Timeout time.Duration
+}
+
+var dbCounter int64
+
+// suite holds the state shared by the tests of a single run.
+type suite struct {
+	Config
+	client driver.Client
+}
+
+// Run runs the conformance suite against cfg.Driver.
+func Run(t *testing.T, cfg Config) {
+	if cfg.Driver == nil {
+		t.Fatal("kiviktest: Config.Driver is required")
+	}
+	if cfg.DBPrefix == "" {
+		cfg.DBPrefix = "kiviktest-"
+	}
+	if cfg.DesignDoc == nil {
+		cfg.DesignDoc = map[string]interface{}{
+			"views": map[string]interface{}{
+				"ids": map[string]interface{}{
+					"map": "function(doc) { emit(doc._id, null); }",
+				},
+			},
+		}
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	client, err := cfg.Driver.NewClient(cfg.DSN, cfg.Options)
+	if err != nil {
+		t.Fatalf("NewClient failed: %s", err)
+	}
+	s := &suite{Config: cfg, client: client}
+	t.Run("Client", s.testClient)
+	t.Run("Docs", s.testDocs)
+	t.Run("AllDocs", s.testAllDocs)
+	t.Run("Views", s.testViews)
+	t.Run("Find", s.testFind)
+	t.Run("Changes", s.testChanges)
+	t.Run("Attachments", s.testAttachments)
+	t.Run("Security", s.testSecurity)
+	t.Run("Optional", s.testOptional)
+	if closer, ok := client.(driver.ClientCloser); ok {
+		err := closer.Close(context.Background())
+		if err != nil && kivik.StatusCode(err) != http.StatusNotImplemented {
+			t.Errorf("Close failed: %s", err)
+		}
+	}
+}
+
+// context returns a context which times out after s.Timeout.
+func (s *suite) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), s.Timeout)
+}
+
+// dbName returns a unique database name.
+func (s *suite) dbName() string {
+	return fmt.Sprintf("%s%d-%d", s.DBPrefix, time.Now().Unix(), atomic.AddInt64(&dbCounter, 1))
+}
+
+// newDB creates a database. The caller should defer a call to destroyDB.
+func (s *suite) newDB(t *testing.T, ctx context.Context) (driver.DB, string) {
+	t.Helper()
+	name := s.dbName()
+	if err := s.client.CreateDB(ctx, name, nil); err != nil {
+		t.Fatalf("CreateDB failed: %s", err)
+	}
+	db, err := s.client.DB(name, nil)
+	if err != nil {
+		t.Fatalf("DB failed: %s", err)
+	}
+	return db, name
+}
+
+// destroyDB destroys a database created by newDB.
+func (s *suite) destroyDB(name string) {
+	_ = s.client.DestroyDB(context.Background(), name, nil)
+}
+
+// put stores an empty document with each of ids, failing the test on error.
+func put(t *testing.T, ctx context.Context, db driver.DB, ids ...string) map[string]string {
+	t.Helper()
+	revs := make(map[string]string, len(ids))
+	for _, id := range ids {
+		rev, err := db.Put(ctx, id, map[string]interface{}{"_id": id}, nil)
+		if err != nil {
+			t.Fatalf("Put(%q) failed: %s", id, err)
+		}
+		revs[id] = rev
+	}
+	return revs
+}
+
+// checkStatus reports an error if err does not have the expected status.
+// A zero status means err is expected to be nil.
+func checkStatus(t *testing.T, method string, want int, err error) {
+	t.Helper()
+	if want == 0 {
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", method, err)
+		}
+		return
+	}
+	if err == nil {
+		t.Errorf("%s: expected status %d, got no error", method, want)
+		return
+	}
+	if got := kivik.StatusCode(err); got != want {
+		t.Errorf("%s: expected status %d, got %d: %s", method, want, got, err)
+	}
+}
+
+// unsupported checks that a method, for which the driver does not claim
+// capability, returns 501.
+func unsupported(t *testing.T, method string, err error) {
+	t.Helper()
+	checkStatus(t, method, http.StatusNotImplemented, err)
+}
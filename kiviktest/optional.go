@@ -0,0 +1,286 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kiviktest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// optionalTest describes the expected behavior of an optional interface.
+type optionalTest struct {
+	name string
+	cap  Capability
+	// missingDB indicates that call should be made against a database which
+	// does not exist, rather than against a missing document.
+	missingDB bool
+	// status is the status expected when the capability is claimed.
+	status int
+	// call calls the method, and returns false if the optional interface is
+	// not implemented.
+	call func(ctx context.Context, c driver.Client, db driver.DB, dbName string) (bool, error)
+}
+
+var optionalTests = []optionalTest{
+	{
+		name:   "DBsStatser",
+		cap:    DBsStatser,
+		status: 0,
+		call: func(ctx context.Context, c driver.Client, _ driver.DB, dbName string) (bool, error) {
+			statser, ok := c.(driver.DBsStatser)
+			if !ok {
+				return false, nil
+			}
+			_, err := statser.DBsStats(ctx, []string{dbName})
+			return true, err
+		},
+	},
+	{
+		name:   "Pinger",
+		cap:    Pinger,
+		status: 0,
+		call: func(ctx context.Context, c driver.Client, _ driver.DB, _ string) (bool, error) {
+			pinger, ok := c.(driver.Pinger)
+			if !ok {
+				return false, nil
+			}
+			_, err := pinger.Ping(ctx)
+			return true, err
+		},
+	},
+	{
+		name:   "ActiveTasker",
+		cap:    ActiveTasker,
+		status: 0,
+		call: func(ctx context.Context, c driver.Client, _ driver.DB, _ string) (bool, error) {
+			tasker, ok := c.(driver.ActiveTasker)
+			if !ok {
+				return false, nil
+			}
+			_, err := tasker.ActiveTasks(ctx)
+			return true, err
+		},
+	},
+	{
+		name:   "Scheduler",
+		cap:    Scheduler,
+		status: 0,
+		call: func(ctx context.Context, c driver.Client, _ driver.DB, _ string) (bool, error) {
+			scheduler, ok := c.(driver.Scheduler)
+			if !ok {
+				return false, nil
+			}
+			_, err := scheduler.SchedulerJobs(ctx, nil)
+			return true, err
+		},
+	},
+	{
+		name:   "MetaGetter",
+		cap:    MetaGetter,
+		status: http.StatusNotFound,
+		call: func(ctx context.Context, _ driver.Client, db driver.DB, _ string) (bool, error) {
+			getter, ok := db.(driver.MetaGetter)
+			if !ok {
+				return false, nil
+			}
+			_, _, err := getter.GetMeta(ctx, "missing", nil)
+			return true, err
+		},
+	},
+	{
+		name:   "AttachmentMetaGetter",
+		cap:    AttachmentMetaGetter,
+		status: http.StatusNotFound,
+		call: func(ctx context.Context, _ driver.Client, db driver.DB, _ string) (bool, error) {
+			getter, ok := db.(driver.AttachmentMetaGetter)
+			if !ok {
+				return false, nil
+			}
+			_, err := getter.GetAttachmentMeta(ctx, "missing", "foo.txt", nil)
+			return true, err
+		},
+	},
+	{
+		name:   "Copier",
+		cap:    Copier,
+		status: http.StatusNotFound,
+		call: func(ctx context.Context, _ driver.Client, db driver.DB, _ string) (bool, error) {
+			copier, ok := db.(driver.Copier)
+			if !ok {
+				return false, nil
+			}
+			_, err := copier.Copy(ctx, "target", "missing", nil)
+			return true, err
+		},
+	},
+	{
+		name:      "DesignDocer",
+		cap:       DesignDocer,
+		missingDB: true,
+		status:    http.StatusNotFound,
+		call: func(ctx context.Context, _ driver.Client, db driver.DB, _ string) (bool, error) {
+			docer, ok := db.(driver.DesignDocer)
+			if !ok {
+				return false, nil
+			}
+			return true, closeRows(docer.DesignDocs(ctx, nil))
+		},
+	},
+	{
+		name:      "LocalDocer",
+		cap:       LocalDocer,
+		missingDB: true,
+		status:    http.StatusNotFound,
+		call: func(ctx context.Context, _ driver.Client, db driver.DB, _ string) (bool, error) {
+			docer, ok := db.(driver.LocalDocer)
+			if !ok {
+				return false, nil
+			}
+			return true, closeRows(docer.LocalDocs(ctx, nil))
+		},
+	},
+	{
+		name:      "BulkDocer",
+		cap:       BulkDocer,
+		missingDB: true,
+		status:    http.StatusNotFound,
+		call: func(ctx context.Context, _ driver.Client, db driver.DB, _ string) (bool, error) {
+			docer, ok := db.(driver.BulkDocer)
+			if !ok {
+				return false, nil
+			}
+			results, err := docer.BulkDocs(ctx, []interface{}{map[string]string{"_id": "foo"}}, nil)
+			if err != nil {
+				return true, err
+			}
+			return true, results.Close()
+		},
+	},
+	{
+		name:      "BulkGetter",
+		cap:       BulkGetter,
+		missingDB: true,
+		status:    http.StatusNotFound,
+		call: func(ctx context.Context, _ driver.Client, db driver.DB, _ string) (bool, error) {
+			getter, ok := db.(driver.BulkGetter)
+			if !ok {
+				return false, nil
+			}
+			return true, closeRows(getter.BulkGet(ctx, []driver.BulkGetReference{{ID: "foo"}}, nil))
+		},
+	},
+	{
+		name:      "RevsDiffer",
+		cap:       RevsDiffer,
+		missingDB: true,
+		status:    http.StatusNotFound,
+		call: func(ctx context.Context, _ driver.Client, db driver.DB, _ string) (bool, error) {
+			differ, ok := db.(driver.RevsDiffer)
+			if !ok {
+				return false, nil
+			}
+			return true, closeRows(differ.RevsDiff(ctx, map[string][]string{"foo": {"1-abc"}}))
+		},
+	},
+	{
+		name:      "Purger",
+		cap:       Purger,
+		missingDB: true,
+		status:    http.StatusNotFound,
+		call: func(ctx context.Context, _ driver.Client, db driver.DB, _ string) (bool, error) {
+			purger, ok := db.(driver.Purger)
+			if !ok {
+				return false, nil
+			}
+			_, err := purger.Purge(ctx, map[string][]string{"foo": {"1-abc"}})
+			return true, err
+		},
+	},
+	{
+		name:      "Flusher",
+		cap:       Flusher,
+		missingDB: true,
+		status:    http.StatusNotFound,
+		call: func(ctx context.Context, _ driver.Client, db driver.DB, _ string) (bool, error) {
+			flusher, ok := db.(driver.Flusher)
+			if !ok {
+				return false, nil
+			}
+			return true, flusher.Flush(ctx)
+		},
+	},
+	{
+		name:      "OpenRevsGetter",
+		cap:       OpenRevsGetter,
+		missingDB: true,
+		status:    http.StatusNotFound,
+		call: func(ctx context.Context, _ driver.Client, db driver.DB, _ string) (bool, error) {
+			getter, ok := db.(driver.OpenRevsGetter)
+			if !ok {
+				return false, nil
+			}
+			revs, err := getter.OpenRevs(ctx, "foo", nil, nil)
+			if err != nil {
+				return true, err
+			}
+			return true, revs.Close()
+		},
+	},
+}
+
+func closeRows(rows driver.Rows, err error) error {
+	if err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// testOptional checks the status returned by each optional interface. When
+// the capability is claimed, the interface must be implemented, and return
+// the expected status. Otherwise, if the interface is implemented anyway, it
+// must return 501.
+func (s *suite) testOptional(t *testing.T) {
+	ctx, cancel := s.context()
+	defer cancel()
+	db, name := s.newDB(t, ctx)
+	defer s.destroyDB(name)
+	missing, missingErr := s.client.DB(s.dbName(), nil)
+
+	for _, test := range optionalTests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			if !s.Capabilities.Has(test.cap) {
+				if implemented, err := test.call(ctx, s.client, db, name); implemented {
+					unsupported(t, test.name, err)
+				}
+				return
+			}
+			target := db
+			if test.missingDB {
+				if missingErr != nil {
+					checkStatus(t, "DB for a missing database", http.StatusNotFound, missingErr)
+					return
+				}
+				target = missing
+			}
+			implemented, err := test.call(ctx, s.client, target, name)
+			if !implemented {
+				t.Fatalf("%s capability claimed, but not implemented", test.name)
+			}
+			checkStatus(t, test.name, test.status, err)
+		})
+	}
+}
@@ -0,0 +1,196 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kiviktest
+
+import (
+	"io"
+	"testing"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// readRows reads rows until io.EOF, returning the IDs read, and the number
+// of times EOQ was returned. rows is closed before returning.
+func readRows(t *testing.T, rows driver.Rows) (ids []string, eoqs int) {
+	t.Helper()
+	defer rows.Close() // nolint: errcheck
+	for {
+		var row driver.Row
+		switch err := rows.Next(&row); err {
+		case nil:
+			ids = append(ids, row.ID)
+		case driver.EOQ:
+			eoqs++
+		case io.EOF:
+			return ids, eoqs
+		default:
+			t.Fatalf("Next returned %s; iterators must end with io.EOF", err)
+		}
+	}
+}
+
+func checkList(t *testing.T, want, got []string) {
+	t.Helper()
+	if len(want) != len(got) {
+		t.Errorf("Expected rows %v, got %v", want, got)
+		return
+	}
+	for i := range want {
+		if want[i] != got[i] {
+			t.Errorf("Expected rows %v, got %v", want, got)
+			return
+		}
+	}
+}
+
+func (s *suite) testAllDocs(t *testing.T) {
+	ctx, cancel := s.context()
+	defer cancel()
+	db, name := s.newDB(t, ctx)
+	defer s.destroyDB(name)
+	put(t, ctx, db, "c", "a", "b")
+
+	t.Run("all", func(t *testing.T) {
+		rows, err := db.AllDocs(ctx, map[string]interface{}{"update_seq": true})
+		if err != nil {
+			t.Fatalf("AllDocs failed: %s", err)
+		}
+		ids, _ := readRows(t, rows)
+		checkList(t, []string{"a", "b", "c"}, ids)
+		if total := rows.TotalRows(); total != 3 {
+			t.Errorf("TotalRows should be 3 after iteration, got %d", total)
+		}
+		if offset := rows.Offset(); offset != 0 {
+			t.Errorf("Offset should be 0 after iteration, got %d", offset)
+		}
+		if rows.UpdateSeq() == "" {
+			t.Error("UpdateSeq should be set after iteration, when requested")
+		}
+	})
+	t.Run("startkey", func(t *testing.T) {
+		rows, err := db.AllDocs(ctx, map[string]interface{}{"startkey": "b"})
+		if err != nil {
+			t.Fatalf("AllDocs failed: %s", err)
+		}
+		ids, _ := readRows(t, rows)
+		checkList(t, []string{"b", "c"}, ids)
+		if offset := rows.Offset(); offset != 1 {
+			t.Errorf("Offset should be 1 after iteration, got %d", offset)
+		}
+	})
+}
+
+func (s *suite) testViews(t *testing.T) {
+	ctx, cancel := s.context()
+	defer cancel()
+	db, name := s.newDB(t, ctx)
+	defer s.destroyDB(name)
+	if !s.Capabilities.Has(Views) {
+		_, err := db.Query(ctx, "kiviktest", "ids", nil)
+		unsupported(t, "Query", err)
+		return
+	}
+	if _, err := db.Put(ctx, "_design/kiviktest", s.DesignDoc, nil); err != nil {
+		t.Fatalf("Put of design document failed: %s", err)
+	}
+	put(t, ctx, db, "a", "b", "c")
+
+	t.Run("Query", func(t *testing.T) {
+		rows, err := db.Query(ctx, "kiviktest", "ids", map[string]interface{}{"update_seq": true})
+		if err != nil {
+			t.Fatalf("Query failed: %s", err)
+		}
+		ids, eoqs := readRows(t, rows)
+		checkList(t, []string{"a", "b", "c"}, ids)
+		if eoqs != 0 {
+			t.Errorf("Single query returned EOQ %d times", eoqs)
+		}
+		if total := rows.TotalRows(); total != 3 {
+			t.Errorf("TotalRows should be 3 after iteration, got %d", total)
+		}
+		if rows.UpdateSeq() == "" {
+			t.Error("UpdateSeq should be set after iteration, when requested")
+		}
+	})
+	t.Run("MultiQuery", func(t *testing.T) {
+		if !s.Capabilities.Has(MultiQuery) {
+			t.Skip("MultiQuery not supported")
+		}
+		rows, err := db.Query(ctx, "kiviktest", "ids", map[string]interface{}{
+			"queries": []interface{}{
+				map[string]interface{}{"keys": []string{"a"}},
+				map[string]interface{}{"keys": []string{"b", "c"}},
+			},
+		})
+		if err != nil {
+			t.Fatalf("Query failed: %s", err)
+		}
+		ids, eoqs := readRows(t, rows)
+		checkList(t, []string{"a", "b", "c"}, ids)
+		if eoqs != 1 {
+			t.Errorf("Expected EOQ once between two result sets, got %d", eoqs)
+		}
+	})
+}
+
+func (s *suite) testFind(t *testing.T) {
+	ctx, cancel := s.context()
+	defer cancel()
+	db, name := s.newDB(t, ctx)
+	defer s.destroyDB(name)
+	finder, ok := db.(driver.OptsFinder)
+	if !s.Capabilities.Has(Find) {
+		if ok {
+			_, err := finder.Find(ctx, map[string]interface{}{"selector": map[string]interface{}{}}, nil)
+			unsupported(t, "Find", err)
+		}
+		return
+	}
+	if !ok {
+		t.Fatal("Find capability claimed, but DB does not implement driver.OptsFinder")
+	}
+	put(t, ctx, db, "a", "b", "c")
+	rows, err := finder.Find(ctx, map[string]interface{}{
+		"selector": map[string]interface{}{"_id": map[string]interface{}{"$gt": "a"}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Find failed: %s", err)
+	}
+	var got []string
+	defer rows.Close() // nolint: errcheck
+	for {
+		var row driver.Row
+		err := rows.Next(&row)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next returned %s; iterators must end with io.EOF", err)
+		}
+		var doc struct {
+			ID string `json:"_id"`
+		}
+		if err := decodeRowDoc(&row, &doc); err != nil {
+			t.Fatalf("Failed to decode document: %s", err)
+		}
+		got = append(got, doc.ID)
+	}
+	checkList(t, []string{"b", "c"}, got)
+	bookmarker, ok := rows.(driver.Bookmarker)
+	if !ok {
+		t.Fatal("Find results must implement driver.Bookmarker")
+	}
+	if bookmarker.Bookmark() == "" {
+		t.Error("Bookmark should be set after iteration")
+	}
+}
@@ -0,0 +1,42 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kiviktest
+
+import (
+	"testing"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+func (s *suite) testSecurity(t *testing.T) {
+	ctx, cancel := s.context()
+	defer cancel()
+	db, name := s.newDB(t, ctx)
+	defer s.destroyDB(name)
+	if !s.Capabilities.Has(Security) {
+		_, err := db.Security(ctx)
+		unsupported(t, "Security", err)
+		return
+	}
+	err := db.SetSecurity(ctx, &driver.Security{
+		Admins: driver.Members{Names: []string{"bob"}},
+	})
+	if err != nil {
+		t.Fatalf("SetSecurity failed: %s", err)
+	}
+	sec, err := db.Security(ctx)
+	if err != nil {
+		t.Fatalf("Security failed: %s", err)
+	}
+	checkList(t, []string{"bob"}, sec.Admins.Names)
+}
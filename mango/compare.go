@@ -0,0 +1,38 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package mango
+
+import (
+	"encoding/json"
+
+	"github.com/go-kivik/kivik/v4/collate"
+)
+
+// Compare compares two decoded JSON values, returning -1, 0 or 1, in the
+// same order CouchDB uses to evaluate Mango selectors and sort results. It is
+// equivalent to collate.Compare.
+func Compare(a, b interface{}) int {
+	return collate.Compare(a, b)
+}
+
+// number returns v as a float64, if it is a number.
+func number(v interface{}) (float64, bool) {
+	switch t := v.(type) {
+	case float64:
+		return t, true
+	case json.Number:
+		f, err := t.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,162 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package mango
+
+import "encoding/json"
+
+// Expr is a selector expression, built with the functions in this package,
+// such as Eq and And. Invalid arguments are reported when the expression is
+// marshaled, or by Query.Validate. The zero Expr is the empty selector, which
+// matches every document.
+//
+// Functions which take a field name accept the dotted notation used by
+// CouchDB. An empty field name applies the operator to the value being
+// matched, which is useful within ElemMatch, AllMatch and KeyMapMatch:
+//
+//	mango.ElemMatch("scores", mango.Gt("", 10))
+type Expr struct {
+	field string
+	op    string
+	arg   interface{}
+	err   error
+}
+
+// cond returns an expression applying op to field, checking the argument
+// with the same rules used to parse selectors.
+func cond(field, op string, arg interface{}) Expr {
+	norm, err := normalize(arg)
+	if err == nil {
+		_, err = parseOperator(op, norm)
+	}
+	return Expr{field: field, op: op, arg: arg, err: err}
+}
+
+// Eq matches values equal to value.
+func Eq(field string, value interface{}) Expr { return cond(field, opEq, value) }
+
+// Ne matches values not equal to value.
+func Ne(field string, value interface{}) Expr { return cond(field, opNe, value) }
+
+// Lt matches values less than value.
+func Lt(field string, value interface{}) Expr { return cond(field, opLt, value) }
+
+// Lte matches values less than or equal to value.
+func Lte(field string, value interface{}) Expr { return cond(field, opLte, value) }
+
+// Gt matches values greater than value.
+func Gt(field string, value interface{}) Expr { return cond(field, opGt, value) }
+
+// Gte matches values greater than or equal to value.
+func Gte(field string, value interface{}) Expr { return cond(field, opGte, value) }
+
+// Exists matches if the field exists, or does not exist if exists is false.
+func Exists(field string, exists bool) Expr { return cond(field, opExists, exists) }
+
+// Type matches values of the named JSON type: "null", "boolean", "number",
+// "string", "array" or "object".
+func Type(field, typ string) Expr { return cond(field, opType, typ) }
+
+// In matches values equal to any of values. At least one value is required.
+func In(field string, values ...interface{}) Expr { return list(field, opIn, values) }
+
+// Nin matches values equal to none of values. At least one value is required.
+func Nin(field string, values ...interface{}) Expr { return list(field, opNin, values) }
+
+// All matches arrays containing every one of values. At least one value is
+// required.
+func All(field string, values ...interface{}) Expr { return list(field, opAll, values) }
+
+func list(field, op string, values []interface{}) Expr {
+	if len(values) == 0 {
+		return Expr{err: opError(op, "at least one value is required")}
+	}
+	return cond(field, op, values)
+}
+
+// Size matches arrays of length size.
+func Size(field string, size int) Expr {
+	if size < 0 {
+		return Expr{err: opError(opSize, "argument must not be negative")}
+	}
+	return cond(field, opSize, size)
+}
+
+// Mod matches integers which, divided by divisor, leave remainder.
+func Mod(field string, divisor, remainder int64) Expr {
+	return cond(field, opMod, []int64{divisor, remainder})
+}
+
+// Regex matches strings matching pattern.
+func Regex(field, pattern string) Expr { return cond(field, opRegex, pattern) }
+
+// BeginsWith matches strings beginning with prefix.
+func BeginsWith(field, prefix string) Expr { return cond(field, opBeginsWith, prefix) }
+
+// ElemMatch matches arrays with at least one element matching expr.
+func ElemMatch(field string, expr Expr) Expr { return sub(field, opElemMatch, expr) }
+
+// AllMatch matches non-empty arrays for which every element matches expr.
+func AllMatch(field string, expr Expr) Expr { return sub(field, opAllMatch, expr) }
+
+// KeyMapMatch matches objects with at least one key matching expr.
+func KeyMapMatch(field string, expr Expr) Expr { return sub(field, opKeyMapMatch, expr) }
+
+// Not matches if expr does not.
+func Not(expr Expr) Expr { return sub("", opNot, expr) }
+
+func sub(field, op string, expr Expr) Expr {
+	return Expr{field: field, op: op, arg: expr, err: expr.err}
+}
+
+// And matches if every one of exprs matches. At least one expression is
+// required.
+func And(exprs ...Expr) Expr { return combine(opAnd, exprs) }
+
+// Or matches if any one of exprs matches. At least one expression is
+// required.
+func Or(exprs ...Expr) Expr { return combine(opOr, exprs) }
+
+// Nor matches if none of exprs match. At least one expression is required.
+func Nor(exprs ...Expr) Expr { return combine(opNor, exprs) }
+
+func combine(op string, exprs []Expr) Expr {
+	if len(exprs) == 0 {
+		return Expr{err: opError(op, "at least one expression is required")}
+	}
+	for _, expr := range exprs {
+		if expr.err != nil {
+			return Expr{err: expr.err}
+		}
+	}
+	return Expr{op: op, arg: exprs}
+}
+
+// Err returns the first error encountered while building expr.
+func (e Expr) Err() error {
+	return e.err
+}
+
+// MarshalJSON satisfies the json.Marshaler interface.
+func (e Expr) MarshalJSON() ([]byte, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	if e.op == "" {
+		return []byte("{}"), nil
+	}
+	var v interface{} = map[string]interface{}{e.op: e.arg}
+	if e.field != "" {
+		v = map[string]interface{}{e.field: v}
+	}
+	return json.Marshal(v)
+}
@@ -0,0 +1,141 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package mango evaluates CouchDB Mango selectors against JSON documents.
+//
+// A selector is parsed once with New, then may be matched against any number
+// of documents:
+//
+//	sel, err := mango.New(map[string]interface{}{
+//		"year": map[string]interface{}{"$gte": 2000},
+//		"tags": map[string]interface{}{"$all": []string{"go", "couchdb"}},
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	if sel.Match(doc) {
+//		// ...
+//	}
+//
+// Documents are expected to be decoded JSON: map[string]interface{},
+// []interface{}, string, float64, json.Number, bool or nil, nested to any
+// depth. Other values, such as structs, are converted by round-tripping
+// through encoding/json.
+//
+// Queries for DB.Find and DB.Explain, and index definitions for
+// DB.CreateIndex, may be built with NewQuery and NewIndex, using Expr values
+// in place of hand-written maps:
+//
+//	query := mango.NewQuery(mango.And(
+//		mango.Gte("year", 2000),
+//		mango.All("tags", "go", "couchdb"),
+//	)).Sort(mango.Desc("year")).Limit(10)
+//	rows, err := db.Find(ctx, query)
+//
+// All of CouchDB's selector operators are supported. Regular expressions
+// use Go's regexp syntax, which differs from the PCRE syntax used by CouchDB
+// in a few corner cases, such as lookahead assertions, which are not
+// supported.
+package mango
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Selector is a parsed Mango selector.
+type Selector struct {
+	m matcher
+}
+
+// New parses a Mango selector. selector may be a []byte or json.RawMessage
+// containing JSON, or any value which marshals to a JSON object.
+func New(selector interface{}) (*Selector, error) {
+	sel, err := normalize(selector)
+	if err != nil {
+		return nil, err
+	}
+	m, err := parse(sel)
+	if err != nil {
+		return nil, err
+	}
+	return &Selector{m: m}, nil
+}
+
+// Match returns true if doc matches the selector.
+func (s *Selector) Match(doc interface{}) bool {
+	if !isJSON(doc) {
+		var err error
+		if doc, err = normalize(doc); err != nil {
+			return false
+		}
+	}
+	return s.m.match(doc)
+}
+
+// normalize converts v to the types produced by json.Unmarshal, by
+// round-tripping it through encoding/json.
+func normalize(v interface{}) (interface{}, error) {
+	var raw []byte
+	switch t := v.(type) {
+	case json.RawMessage:
+		raw = t
+	case []byte:
+		raw = t
+	default:
+		var err error
+		if raw, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+	var result interface{}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// isJSON returns true if v is already of a type produced by json.Unmarshal.
+// Containers are assumed to hold only such types.
+func isJSON(v interface{}) bool {
+	switch v.(type) {
+	case nil, bool, float64, json.Number, string, []interface{}, map[string]interface{}:
+		return true
+	}
+	return false
+}
+
+// Error is returned by New for an invalid selector.
+type Error struct {
+	// Operator is the operator which failed to parse, if any.
+	Operator string
+	Err      error
+}
+
+func (e *Error) Error() string {
+	if e.Operator == "" {
+		return "mango: " + e.Err.Error()
+	}
+	return fmt.Sprintf("mango: %s: %s", e.Operator, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func opError(op, format string, args ...interface{}) error {
+	return &Error{Operator: op, Err: fmt.Errorf(format, args...)}
+}
+
+var errNotObject = &Error{Err: errors.New("selector must be a JSON object")}
@@ -0,0 +1,185 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package mango
+
+import (
+	"encoding/json"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+)
+
+const testDoc = `{
+	"_id": "foo",
+	"name": "Bob",
+	"age": 42,
+	"admin": false,
+	"manager": null,
+	"tags": ["go", "couchdb", "json"],
+	"scores": [{"game": "chess", "score": 5}, {"game": "go", "score": 9}],
+	"address": {"city": "Oslo", "zip": "0150"},
+	"a.b": 1,
+	"labels": {"env-prod": true, "team": "core"}
+}`
+
+func TestMatch(t *testing.T) {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(testDoc), &doc); err != nil {
+		t.Fatal(err)
+	}
+	type tt struct {
+		selector string
+		want     bool
+	}
+	tests := testy.NewTable()
+	tests.Add("empty", tt{`{}`, true})
+	tests.Add("implicit eq", tt{`{"name": "Bob"}`, true})
+	tests.Add("implicit eq mismatch", tt{`{"name": "Alice"}`, false})
+	tests.Add("implicit and", tt{`{"name": "Bob", "age": 43}`, false})
+	tests.Add("nested field", tt{`{"address.city": "Oslo"}`, true})
+	tests.Add("nested object", tt{`{"address": {"city": {"$eq": "Oslo"}}}`, true})
+	tests.Add("escaped period", tt{`{"a\\.b": 1}`, true})
+	tests.Add("array index", tt{`{"tags.1": "couchdb"}`, true})
+	tests.Add("$eq", tt{`{"age": {"$eq": 42}}`, true})
+	tests.Add("$ne", tt{`{"age": {"$ne": 42}}`, false})
+	tests.Add("$gt", tt{`{"age": {"$gt": 40}}`, true})
+	tests.Add("$gte", tt{`{"age": {"$gte": 42}}`, true})
+	tests.Add("$lt", tt{`{"age": {"$lt": 42}}`, false})
+	tests.Add("$lte", tt{`{"age": {"$lte": 42}}`, true})
+	tests.Add("$gt across types", tt{`{"name": {"$gt": 1000}}`, true})
+	tests.Add("$gt null", tt{`{"manager": {"$gt": null}}`, false})
+	tests.Add("missing field", tt{`{"missing": {"$ne": 1}}`, false})
+	tests.Add("$exists true", tt{`{"manager": {"$exists": true}}`, true})
+	tests.Add("$exists false", tt{`{"missing": {"$exists": false}}`, true})
+	tests.Add("$exists false on nested missing", tt{`{"address.missing.x": {"$exists": false}}`, true})
+	tests.Add("$type", tt{`{"manager": {"$type": "null"}, "admin": {"$type": "boolean"}, "age": {"$type": "number"}, "tags": {"$type": "array"}, "address": {"$type": "object"}}`, true})
+	tests.Add("$type mismatch", tt{`{"age": {"$type": "string"}}`, false})
+	tests.Add("$in", tt{`{"name": {"$in": ["Alice", "Bob"]}}`, true})
+	tests.Add("$in array field", tt{`{"tags": {"$in": ["rust", "json"]}}`, true})
+	tests.Add("$nin", tt{`{"tags": {"$nin": ["rust", "json"]}}`, false})
+	tests.Add("$size", tt{`{"tags": {"$size": 3}}`, true})
+	tests.Add("$size mismatch", tt{`{"tags": {"$size": 2}}`, false})
+	tests.Add("$mod", tt{`{"age": {"$mod": [5, 2]}}`, true})
+	tests.Add("$mod mismatch", tt{`{"age": {"$mod": [5, 1]}}`, false})
+	tests.Add("$regex", tt{`{"name": {"$regex": "^B.b$"}}`, true})
+	tests.Add("$regex non-string", tt{`{"age": {"$regex": "4"}}`, false})
+	tests.Add("$beginsWith", tt{`{"name": {"$beginsWith": "Bo"}}`, true})
+	tests.Add("$all", tt{`{"tags": {"$all": ["json", "go"]}}`, true})
+	tests.Add("$all missing element", tt{`{"tags": {"$all": ["json", "rust"]}}`, false})
+	tests.Add("$elemMatch", tt{`{"scores": {"$elemMatch": {"game": "go", "score": {"$gt": 8}}}}`, true})
+	tests.Add("$elemMatch scalar", tt{`{"tags": {"$elemMatch": {"$eq": "couchdb"}}}`, true})
+	tests.Add("$elemMatch mismatch", tt{`{"scores": {"$elemMatch": {"game": "chess", "score": {"$gt": 8}}}}`, false})
+	tests.Add("$allMatch", tt{`{"scores": {"$allMatch": {"score": {"$gt": 4}}}}`, true})
+	tests.Add("$allMatch mismatch", tt{`{"scores": {"$allMatch": {"score": {"$gt": 5}}}}`, false})
+	tests.Add("$keyMapMatch", tt{`{"labels": {"$keyMapMatch": {"$regex": "^env-"}}}`, true})
+	tests.Add("$keyMapMatch mismatch", tt{`{"labels": {"$keyMapMatch": {"$eq": "owner"}}}`, false})
+	tests.Add("$and", tt{`{"$and": [{"name": "Bob"}, {"age": 42}]}`, true})
+	tests.Add("$or", tt{`{"$or": [{"name": "Alice"}, {"age": 42}]}`, true})
+	tests.Add("$nor", tt{`{"$nor": [{"name": "Alice"}, {"age": 42}]}`, false})
+	tests.Add("$not", tt{`{"$not": {"name": "Alice"}}`, true})
+	tests.Add("field $not", tt{`{"age": {"$not": {"$gt": 50}}}`, true})
+	tests.Add("field $not on missing", tt{`{"missing": {"$not": {"$eq": 1}}}`, true})
+	tests.Add("$eq object", tt{`{"address": {"$eq": {"zip": "0150", "city": "Oslo"}}}`, true})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		sel, err := New(json.RawMessage(tt.selector))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := sel.Match(doc); got != tt.want {
+			t.Errorf("Expected %t, got %t", tt.want, got)
+		}
+	})
+}
+
+func TestMatchStruct(t *testing.T) {
+	sel, err := New(map[string]interface{}{
+		"tags": map[string]interface{}{"$all": []string{"a"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := struct {
+		Tags []string `json:"tags"`
+	}{Tags: []string{"a", "b"}}
+	if !sel.Match(doc) {
+		t.Error("Expected struct to match")
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	type tt struct {
+		selector string
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("invalid JSON", tt{`{`, "unexpected end of JSON input"})
+	tests.Add("not an object", tt{`[]`, "mango: selector must be a JSON object"})
+	tests.Add("unknown operator", tt{`{"a": {"$gte ": 1}}`, "mango: $gte : unknown operator"})
+	tests.Add("$and not array", tt{`{"$and": {}}`, "mango: $and: argument must be an array of selectors"})
+	tests.Add("$or element", tt{`{"$or": [1]}`, "mango: selector must be a JSON object"})
+	tests.Add("$not not object", tt{`{"$not": 1}`, "mango: $not: argument must be a selector"})
+	tests.Add("$exists", tt{`{"a": {"$exists": 1}}`, "mango: $exists: argument must be a boolean"})
+	tests.Add("$type", tt{`{"a": {"$type": "int"}}`, "mango: $type: argument must be one of null, boolean, number, string, array or object"})
+	tests.Add("$in", tt{`{"a": {"$in": 1}}`, "mango: $in: argument must be an array"})
+	tests.Add("$size", tt{`{"a": {"$size": 1.5}}`, "mango: $size: argument must be an integer"})
+	tests.Add("$mod arity", tt{`{"a": {"$mod": [1]}}`, "mango: $mod: argument must be an array of two integers"})
+	tests.Add("$mod zero", tt{`{"a": {"$mod": [0, 1]}}`, "mango: $mod: divisor must not be zero"})
+	tests.Add("$regex", tt{`{"a": {"$regex": "("}}`, "mango: $regex: error parsing regexp: missing closing ): `(`"})
+	tests.Add("empty field", tt{`{"a..b": 1}`, "mango: invalid field name: a..b"})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		_, err := New([]byte(tt.selector))
+		testy.Error(t, tt.err, err)
+	})
+}
+
+func TestField(t *testing.T) {
+	doc := map[string]interface{}{"a": map[string]interface{}{"b": []interface{}{"x", "y"}}}
+	if v, ok := Field(doc, "a.b.1"); !ok || v != "y" {
+		t.Errorf("Unexpected result: %v, %t", v, ok)
+	}
+	if _, ok := Field(doc, "a.c"); ok {
+		t.Error("Expected missing field")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	ordered := []string{
+		`null`, `false`, `true`, `-1`, `0`, `1.5`, `2`, `""`, `"a"`, `"b"`,
+		`[]`, `[1]`, `[1,2]`, `[2]`, `{}`, `{"a":1}`, `{"a":2}`, `{"b":1}`,
+	}
+	values := make([]interface{}, len(ordered))
+	for i, raw := range ordered {
+		if err := json.Unmarshal([]byte(raw), &values[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range values {
+		for j := range values {
+			var want int
+			switch {
+			case i < j:
+				want = -1
+			case i > j:
+				want = 1
+			}
+			if got := Compare(values[i], values[j]); got != want {
+				t.Errorf("Compare(%s, %s) = %d, want %d", ordered[i], ordered[j], got, want)
+			}
+		}
+	}
+	if Compare(json.Number("1.0"), float64(1)) != 0 {
+		t.Error("json.Number should compare equal to float64")
+	}
+}
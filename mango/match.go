@@ -0,0 +1,239 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package mango
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type andMatcher []matcher
+
+func (m andMatcher) match(v interface{}) bool {
+	for _, sub := range m {
+		if !sub.match(v) {
+			return false
+		}
+	}
+	return true
+}
+
+type orMatcher []matcher
+
+func (m orMatcher) match(v interface{}) bool {
+	for _, sub := range m {
+		if sub.match(v) {
+			return true
+		}
+	}
+	return false
+}
+
+type notMatcher struct {
+	m matcher
+}
+
+func (m *notMatcher) match(v interface{}) bool {
+	return !m.m.match(v)
+}
+
+type fieldMatcher struct {
+	path []string
+	m    matcher
+}
+
+func (m *fieldMatcher) match(v interface{}) bool {
+	return m.m.match(field(v, m.path))
+}
+
+// elemMatcher implements $elemMatch, and $allMatch when all is true.
+type elemMatcher struct {
+	m   matcher
+	all bool
+}
+
+func (m *elemMatcher) match(v interface{}) bool {
+	list, ok := v.([]interface{})
+	if !ok || len(list) == 0 {
+		return false
+	}
+	for _, elem := range list {
+		if m.m.match(elem) != m.all {
+			return !m.all
+		}
+	}
+	return m.all
+}
+
+type keyMapMatcher struct {
+	m matcher
+}
+
+func (m *keyMapMatcher) match(v interface{}) bool {
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	for key := range obj {
+		if m.m.match(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// Field returns the value of the named field of doc, using the same dotted
+// notation as selectors. The second return value is false if the field does
+// not exist.
+func Field(doc interface{}, name string) (interface{}, bool) {
+	path, err := splitField(name)
+	if err != nil {
+		return nil, false
+	}
+	v := field(doc, path)
+	if _, ok := v.(undefined); ok {
+		return nil, false
+	}
+	return v, true
+}
+
+// field resolves path within v, returning undefined if it does not exist.
+// Array elements may be addressed by index.
+func field(v interface{}, path []string) interface{} {
+	for _, name := range path {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if v, ok = t[name]; !ok {
+				return undefined{}
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(name)
+			if err != nil || i < 0 || i >= len(t) {
+				return undefined{}
+			}
+			v = t[i]
+		default:
+			return undefined{}
+		}
+	}
+	return v
+}
+
+// condition is a single operator applied to a value.
+type condition struct {
+	op  string
+	arg interface{}
+	re  *regexp.Regexp
+}
+
+func (c *condition) match(v interface{}) bool {
+	if _, ok := v.(undefined); ok {
+		return c.op == opExists && !c.arg.(bool)
+	}
+	switch c.op {
+	case opEq:
+		return Compare(v, c.arg) == 0
+	case opNe:
+		return Compare(v, c.arg) != 0
+	case opLt:
+		return Compare(v, c.arg) < 0
+	case opLte:
+		return Compare(v, c.arg) <= 0
+	case opGt:
+		return Compare(v, c.arg) > 0
+	case opGte:
+		return Compare(v, c.arg) >= 0
+	case opExists:
+		return c.arg.(bool)
+	case opType:
+		return typeName(v) == c.arg
+	case opIn:
+		return in(v, c.arg.([]interface{}))
+	case opNin:
+		return !in(v, c.arg.([]interface{}))
+	case opAll:
+		list, ok := v.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, want := range c.arg.([]interface{}) {
+			if !contains(list, want) {
+				return false
+			}
+		}
+		return true
+	case opSize:
+		list, ok := v.([]interface{})
+		size, _ := integer(c.arg)
+		return ok && int64(len(list)) == size
+	case opMod:
+		n, ok := integer(v)
+		if !ok {
+			return false
+		}
+		args := c.arg.([]interface{})
+		divisor, _ := integer(args[0])
+		remainder, _ := integer(args[1])
+		return n%divisor == remainder
+	case opRegex:
+		s, ok := v.(string)
+		return ok && c.re.MatchString(s)
+	case opBeginsWith:
+		s, ok := v.(string)
+		return ok && strings.HasPrefix(s, c.arg.(string))
+	}
+	return false
+}
+
+// in implements $in. If v is an array, any of its elements may match.
+func in(v interface{}, args []interface{}) bool {
+	if list, ok := v.([]interface{}); ok {
+		for _, elem := range list {
+			if contains(args, elem) {
+				return true
+			}
+		}
+		return false
+	}
+	return contains(args, v)
+}
+
+func contains(list []interface{}, v interface{}) bool {
+	for _, elem := range list {
+		if Compare(elem, v) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64, json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return ""
+}
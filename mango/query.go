@@ -0,0 +1,263 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package mango
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// Sort is a sort field, for Query.Sort and NewIndex.
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+// Asc sorts field in ascending order.
+func Asc(field string) Sort { return Sort{Field: field} }
+
+// Desc sorts field in descending order.
+func Desc(field string) Sort { return Sort{Field: field, Desc: true} }
+
+// MarshalJSON satisfies the json.Marshaler interface.
+func (s Sort) MarshalJSON() ([]byte, error) {
+	if s.Field == "" {
+		return nil, errors.New("mango: sort field is required")
+	}
+	dir := "asc"
+	if s.Desc {
+		dir = "desc"
+	}
+	return json.Marshal(map[string]string{s.Field: dir})
+}
+
+// Query builds a request for DB.Find or DB.Explain. Methods modify and return
+// the receiver, so that calls may be chained:
+//
+//	query := mango.NewQuery(mango.Gte("year", 2000)).
+//		Fields("_id", "title").
+//		Sort(mango.Desc("year")).
+//		Limit(10)
+//	rows, err := db.Find(ctx, query)
+//
+// A Query marshals to the JSON body expected by CouchDB's /_find endpoint.
+type Query struct {
+	selector Expr
+	q        queryJSON
+}
+
+type queryJSON struct {
+	Selector       Expr        `json:"selector"`
+	Fields         []string    `json:"fields,omitempty"`
+	Sort           []Sort      `json:"sort,omitempty"`
+	Limit          *int64      `json:"limit,omitempty"`
+	Skip           int64       `json:"skip,omitempty"`
+	Bookmark       string      `json:"bookmark,omitempty"`
+	UseIndex       interface{} `json:"use_index,omitempty"`
+	R              int         `json:"r,omitempty"`
+	Conflicts      bool        `json:"conflicts,omitempty"`
+	Update         *bool       `json:"update,omitempty"`
+	Stable         bool        `json:"stable,omitempty"`
+	ExecutionStats bool        `json:"execution_stats,omitempty"`
+}
+
+// NewQuery returns a query for documents matching selector.
+func NewQuery(selector Expr) *Query {
+	return &Query{selector: selector}
+}
+
+// Fields limits the fields returned for each document.
+func (q *Query) Fields(fields ...string) *Query {
+	q.q.Fields = append(q.q.Fields, fields...)
+	return q
+}
+
+// Sort sets the sort order of results.
+func (q *Query) Sort(sort ...Sort) *Query {
+	q.q.Sort = append(q.q.Sort, sort...)
+	return q
+}
+
+// Limit sets the maximum number of results. CouchDB defaults to 25.
+func (q *Query) Limit(limit int64) *Query {
+	q.q.Limit = &limit
+	return q
+}
+
+// Skip skips the first skip results.
+func (q *Query) Skip(skip int64) *Query {
+	q.q.Skip = skip
+	return q
+}
+
+// Bookmark resumes a query from the bookmark returned by a previous query.
+func (q *Query) Bookmark(bookmark string) *Query {
+	q.q.Bookmark = bookmark
+	return q
+}
+
+// UseIndex instructs CouchDB to use the index in the named design document.
+// If name is empty, any index in the design document may be used.
+func (q *Query) UseIndex(ddoc, name string) *Query {
+	if name == "" {
+		q.q.UseIndex = ddoc
+	} else {
+		q.q.UseIndex = []string{ddoc, name}
+	}
+	return q
+}
+
+// R sets the read quorum.
+func (q *Query) R(r int) *Query {
+	q.q.R = r
+	return q
+}
+
+// Conflicts includes conflicting revisions in each document.
+func (q *Query) Conflicts(conflicts bool) *Query {
+	q.q.Conflicts = conflicts
+	return q
+}
+
+// Update controls whether the index is updated before the query is run.
+// CouchDB defaults to true.
+func (q *Query) Update(update bool) *Query {
+	q.q.Update = &update
+	return q
+}
+
+// Stable requests results from a single, stable set of shard replicas.
+func (q *Query) Stable(stable bool) *Query {
+	q.q.Stable = stable
+	return q
+}
+
+// ExecutionStats requests execution statistics with the results.
+func (q *Query) ExecutionStats(stats bool) *Query {
+	q.q.ExecutionStats = stats
+	return q
+}
+
+// Validate returns the first error in the query, if any.
+func (q *Query) Validate() error {
+	if err := q.selector.Err(); err != nil {
+		return err
+	}
+	if q.q.Limit != nil && *q.q.Limit < 0 {
+		return errors.New("mango: limit must not be negative")
+	}
+	if q.q.Skip < 0 {
+		return errors.New("mango: skip must not be negative")
+	}
+	if q.q.R < 0 {
+		return errors.New("mango: r must not be negative")
+	}
+	if ddoc, ok := q.q.UseIndex.(string); ok && ddoc == "" {
+		return errors.New("mango: use_index requires a design document")
+	}
+	for _, f := range q.q.Fields {
+		if f == "" {
+			return errors.New("mango: field names must not be empty")
+		}
+	}
+	for _, s := range q.q.Sort {
+		if s.Field == "" {
+			return errors.New("mango: sort field is required")
+		}
+		if s.Desc != q.q.Sort[0].Desc {
+			return errors.New("mango: sort fields must all be sorted in the same direction")
+		}
+	}
+	return nil
+}
+
+// MarshalJSON satisfies the json.Marshaler interface. It returns an error if
+// the query is invalid.
+func (q *Query) MarshalJSON() ([]byte, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	body := q.q
+	body.Selector = q.selector
+	return json.Marshal(body)
+}
+
+// Index builds an index definition for DB.CreateIndex:
+//
+//	index := mango.NewIndex("type", "year").
+//		PartialFilter(mango.Eq("archived", false))
+//	err := db.CreateIndex(ctx, "ddoc", "by-year", index)
+type Index struct {
+	fields  []Sort
+	partial *Expr
+}
+
+// NewIndex returns an index on fields, in ascending order.
+func NewIndex(fields ...string) *Index {
+	idx := &Index{}
+	for _, f := range fields {
+		idx.fields = append(idx.fields, Asc(f))
+	}
+	return idx
+}
+
+// NewSortedIndex returns an index on fields, in the given order. CouchDB
+// requires every field of an index to be sorted in the same direction.
+func NewSortedIndex(fields ...Sort) *Index {
+	return &Index{fields: fields}
+}
+
+// PartialFilter limits the index to documents matching expr.
+func (i *Index) PartialFilter(expr Expr) *Index {
+	i.partial = &expr
+	return i
+}
+
+// Validate returns the first error in the index definition, if any.
+func (i *Index) Validate() error {
+	if len(i.fields) == 0 {
+		return errors.New("mango: an index requires at least one field")
+	}
+	for _, f := range i.fields {
+		if f.Field == "" {
+			return errors.New("mango: index field names must not be empty")
+		}
+		if f.Desc != i.fields[0].Desc {
+			return errors.New("mango: index fields must all be sorted in the same direction")
+		}
+	}
+	if i.partial != nil {
+		return i.partial.Err()
+	}
+	return nil
+}
+
+// MarshalJSON satisfies the json.Marshaler interface. It returns an error if
+// the index is invalid.
+func (i *Index) MarshalJSON() ([]byte, error) {
+	if err := i.Validate(); err != nil {
+		return nil, err
+	}
+	fields := make([]interface{}, len(i.fields))
+	for n, f := range i.fields {
+		if f.Desc {
+			fields[n] = f
+		} else {
+			fields[n] = f.Field
+		}
+	}
+	return json.Marshal(struct {
+		Fields  []interface{} `json:"fields"`
+		Partial *Expr         `json:"partial_filter_selector,omitempty"`
+	}{Fields: fields, Partial: i.partial})
+}
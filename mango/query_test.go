@@ -0,0 +1,185 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package mango
+
+import (
+	"encoding/json"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+)
+
+func TestExprMarshalJSON(t *testing.T) {
+	type tt struct {
+		expr Expr
+		want string
+		err  string
+	}
+	tests := testy.NewTable()
+	tests.Add("zero", tt{expr: Expr{}, want: `{}`})
+	tests.Add("Eq", tt{expr: Eq("name", "Bob"), want: `{"name":{"$eq":"Bob"}}`})
+	tests.Add("Gte", tt{expr: Gte("year", 2000), want: `{"year":{"$gte":2000}}`})
+	tests.Add("In", tt{expr: In("tag", "a", "b"), want: `{"tag":{"$in":["a","b"]}}`})
+	tests.Add("In without values", tt{expr: In("tag"), err: "mango: $in: at least one value is required"})
+	tests.Add("Mod", tt{expr: Mod("n", 3, 1), want: `{"n":{"$mod":[3,1]}}`})
+	tests.Add("Mod zero divisor", tt{expr: Mod("n", 0, 1), err: "mango: $mod: divisor must not be zero"})
+	tests.Add("Type", tt{expr: Type("n", "int"), err: "mango: $type: argument must be one of null, boolean, number, string, array or object"})
+	tests.Add("Regex", tt{expr: Regex("s", "("), err: "mango: $regex: error parsing regexp: missing closing ): `(`"})
+	tests.Add("Size", tt{expr: Size("tags", 2), want: `{"tags":{"$size":2}}`})
+	tests.Add("Exists", tt{expr: Exists("x", false), want: `{"x":{"$exists":false}}`})
+	tests.Add("ElemMatch", tt{
+		expr: ElemMatch("scores", And(Gt("", 1), Lt("", 5))),
+		want: `{"scores":{"$elemMatch":{"$and":[{"$gt":1},{"$lt":5}]}}}`,
+	})
+	tests.Add("KeyMapMatch", tt{
+		expr: KeyMapMatch("labels", BeginsWith("", "env-")),
+		want: `{"labels":{"$keyMapMatch":{"$beginsWith":"env-"}}}`,
+	})
+	tests.Add("Not", tt{expr: Not(Eq("a", 1)), want: `{"$not":{"a":{"$eq":1}}}`})
+	tests.Add("Or", tt{
+		expr: Or(Eq("a", 1), Nor(Eq("b", 2))),
+		want: `{"$or":[{"a":{"$eq":1}},{"$nor":[{"b":{"$eq":2}}]}]}`,
+	})
+	tests.Add("empty And", tt{expr: And(), err: "mango: $and: at least one expression is required"})
+	tests.Add("nested error", tt{expr: Or(Eq("a", 1), AllMatch("b", Size("", -1))), err: "mango: $size: argument must not be negative"})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		testy.Error(t, tt.err, tt.expr.Err())
+		got, err := json.Marshal(tt.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("Unexpected JSON: %s", got)
+		}
+	})
+}
+
+func TestQueryMarshalJSON(t *testing.T) {
+	type tt struct {
+		query *Query
+		want  string
+		err   string
+	}
+	tests := testy.NewTable()
+	tests.Add("selector only", tt{
+		query: NewQuery(Eq("a", 1)),
+		want:  `{"selector":{"a":{"$eq":1}}}`,
+	})
+	tests.Add("all options", tt{
+		query: NewQuery(Gte("year", 2000)).
+			Fields("_id", "title").
+			Sort(Desc("year")).
+			Limit(0).
+			Skip(10).
+			Bookmark("xyz").
+			UseIndex("ddoc", "by-year").
+			R(2).
+			Conflicts(true).
+			Update(false).
+			Stable(true).
+			ExecutionStats(true),
+		want: `{"selector":{"year":{"$gte":2000}},"fields":["_id","title"],"sort":[{"year":"desc"}],"limit":0,"skip":10,"bookmark":"xyz","use_index":["ddoc","by-year"],"r":2,"conflicts":true,"update":false,"stable":true,"execution_stats":true}`,
+	})
+	tests.Add("use_index ddoc only", tt{
+		query: NewQuery(Expr{}).UseIndex("ddoc", ""),
+		want:  `{"selector":{},"use_index":"ddoc"}`,
+	})
+	tests.Add("invalid selector", tt{
+		query: NewQuery(Or()),
+		err:   "mango: $or: at least one expression is required",
+	})
+	tests.Add("negative limit", tt{
+		query: NewQuery(Expr{}).Limit(-1),
+		err:   "mango: limit must not be negative",
+	})
+	tests.Add("mixed sort", tt{
+		query: NewQuery(Expr{}).Sort(Asc("a"), Desc("b")),
+		err:   "mango: sort fields must all be sorted in the same direction",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		testy.Error(t, tt.err, tt.query.Validate())
+		got, err := json.Marshal(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("Unexpected JSON: %s", got)
+		}
+	})
+}
+
+func TestQueryMarshalError(t *testing.T) {
+	_, err := json.Marshal(NewQuery(Eq("a", 1)).Limit(-1))
+	testy.ErrorRE(t, "mango: limit must not be negative", err)
+}
+
+func TestQuerySelectorMatches(t *testing.T) {
+	raw, err := json.Marshal(And(Gte("year", 2000), ElemMatch("tags", Eq("", "go"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sel, err := New(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := map[string]interface{}{"year": float64(2010), "tags": []interface{}{"go"}}
+	if !sel.Match(doc) {
+		t.Error("Expected built selector to match")
+	}
+}
+
+func TestIndexMarshalJSON(t *testing.T) {
+	type tt struct {
+		index *Index
+		want  string
+		err   string
+	}
+	tests := testy.NewTable()
+	tests.Add("fields", tt{
+		index: NewIndex("type", "year"),
+		want:  `{"fields":["type","year"]}`,
+	})
+	tests.Add("descending", tt{
+		index: NewSortedIndex(Desc("type"), Desc("year")),
+		want:  `{"fields":[{"type":"desc"},{"year":"desc"}]}`,
+	})
+	tests.Add("partial filter", tt{
+		index: NewIndex("year").PartialFilter(Eq("archived", false)),
+		want:  `{"fields":["year"],"partial_filter_selector":{"archived":{"$eq":false}}}`,
+	})
+	tests.Add("no fields", tt{
+		index: NewIndex(),
+		err:   "mango: an index requires at least one field",
+	})
+	tests.Add("mixed directions", tt{
+		index: NewSortedIndex(Asc("a"), Desc("b")),
+		err:   "mango: index fields must all be sorted in the same direction",
+	})
+	tests.Add("invalid partial filter", tt{
+		index: NewIndex("a").PartialFilter(Type("a", "int")),
+		err:   "mango: $type: argument must be one of null, boolean, number, string, array or object",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		testy.Error(t, tt.err, tt.index.Validate())
+		got, err := json.Marshal(tt.index)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("Unexpected JSON: %s", got)
+		}
+	})
+}
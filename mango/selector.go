@@ -0,0 +1,240 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package mango
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// matcher is a node in a parsed selector.
+type matcher interface {
+	// match reports whether v matches. v is undefined when the field being
+	// matched does not exist.
+	match(v interface{}) bool
+}
+
+// undefined is passed to a matcher in place of a missing field.
+type undefined struct{}
+
+func parse(sel interface{}) (matcher, error) {
+	obj, ok := sel.(map[string]interface{})
+	if !ok {
+		return nil, errNotObject
+	}
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	all := make(andMatcher, 0, len(keys))
+	for _, key := range keys {
+		var m matcher
+		var err error
+		if strings.HasPrefix(key, "$") {
+			m, err = parseOperator(key, obj[key])
+		} else {
+			m, err = parseField(key, obj[key])
+		}
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, m)
+	}
+	if len(all) == 1 {
+		return all[0], nil
+	}
+	return all, nil
+}
+
+func parseField(field string, arg interface{}) (matcher, error) {
+	path, err := splitField(field)
+	if err != nil {
+		return nil, err
+	}
+	if obj, ok := arg.(map[string]interface{}); ok && len(obj) > 0 {
+		m, err := parse(obj)
+		if err != nil {
+			return nil, err
+		}
+		return &fieldMatcher{path: path, m: m}, nil
+	}
+	return &fieldMatcher{path: path, m: &condition{op: opEq, arg: arg}}, nil
+}
+
+// splitField splits a field name on periods. A period may be escaped with a
+// backslash.
+func splitField(field string) ([]string, error) {
+	var path []string
+	var part strings.Builder
+	for i := 0; i < len(field); i++ {
+		switch c := field[i]; c {
+		case '\\':
+			if i+1 < len(field) && field[i+1] == '.' {
+				part.WriteByte('.')
+				i++
+				continue
+			}
+			part.WriteByte(c)
+		case '.':
+			path = append(path, part.String())
+			part.Reset()
+		default:
+			part.WriteByte(c)
+		}
+	}
+	path = append(path, part.String())
+	for _, p := range path {
+		if p == "" {
+			return nil, &Error{Err: errors.New("invalid field name: " + field)}
+		}
+	}
+	return path, nil
+}
+
+// Operators.
+const (
+	opAnd         = "$and"
+	opOr          = "$or"
+	opNor         = "$nor"
+	opNot         = "$not"
+	opEq          = "$eq"
+	opNe          = "$ne"
+	opLt          = "$lt"
+	opLte         = "$lte"
+	opGt          = "$gt"
+	opGte         = "$gte"
+	opExists      = "$exists"
+	opType        = "$type"
+	opIn          = "$in"
+	opNin         = "$nin"
+	opSize        = "$size"
+	opMod         = "$mod"
+	opRegex       = "$regex"
+	opBeginsWith  = "$beginsWith"
+	opAll         = "$all"
+	opElemMatch   = "$elemMatch"
+	opAllMatch    = "$allMatch"
+	opKeyMapMatch = "$keyMapMatch"
+)
+
+var jsonTypes = map[string]bool{
+	"null":    true,
+	"boolean": true,
+	"number":  true,
+	"string":  true,
+	"array":   true,
+	"object":  true,
+}
+
+func parseOperator(op string, arg interface{}) (matcher, error) {
+	switch op {
+	case opAnd, opOr, opNor:
+		list, ok := arg.([]interface{})
+		if !ok {
+			return nil, opError(op, "argument must be an array of selectors")
+		}
+		ms := make([]matcher, len(list))
+		for i, sel := range list {
+			m, err := parse(sel)
+			if err != nil {
+				return nil, err
+			}
+			ms[i] = m
+		}
+		switch op {
+		case opAnd:
+			return andMatcher(ms), nil
+		case opOr:
+			return orMatcher(ms), nil
+		}
+		return &notMatcher{orMatcher(ms)}, nil
+	case opNot, opElemMatch, opAllMatch, opKeyMapMatch:
+		if _, ok := arg.(map[string]interface{}); !ok {
+			return nil, opError(op, "argument must be a selector")
+		}
+		m, err := parse(arg)
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case opNot:
+			return &notMatcher{m}, nil
+		case opElemMatch:
+			return &elemMatcher{m: m}, nil
+		case opAllMatch:
+			return &elemMatcher{m: m, all: true}, nil
+		}
+		return &keyMapMatcher{m}, nil
+	case opEq, opNe, opLt, opLte, opGt, opGte:
+		return &condition{op: op, arg: arg}, nil
+	case opExists:
+		if _, ok := arg.(bool); !ok {
+			return nil, opError(op, "argument must be a boolean")
+		}
+	case opType:
+		if t, _ := arg.(string); !jsonTypes[t] {
+			return nil, opError(op, "argument must be one of null, boolean, number, string, array or object")
+		}
+	case opIn, opNin, opAll:
+		if _, ok := arg.([]interface{}); !ok {
+			return nil, opError(op, "argument must be an array")
+		}
+	case opSize:
+		if _, ok := integer(arg); !ok {
+			return nil, opError(op, "argument must be an integer")
+		}
+	case opMod:
+		args, _ := arg.([]interface{})
+		if len(args) != 2 {
+			return nil, opError(op, "argument must be an array of two integers")
+		}
+		divisor, ok1 := integer(args[0])
+		_, ok2 := integer(args[1])
+		if !ok1 || !ok2 {
+			return nil, opError(op, "argument must be an array of two integers")
+		}
+		if divisor == 0 {
+			return nil, opError(op, "divisor must not be zero")
+		}
+	case opRegex:
+		pattern, ok := arg.(string)
+		if !ok {
+			return nil, opError(op, "argument must be a string")
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, &Error{Operator: op, Err: err}
+		}
+		return &condition{op: op, arg: arg, re: re}, nil
+	case opBeginsWith:
+		if _, ok := arg.(string); !ok {
+			return nil, opError(op, "argument must be a string")
+		}
+	default:
+		return nil, opError(op, "unknown operator")
+	}
+	return &condition{op: op, arg: arg}, nil
+}
+
+// integer returns v as an int64, if it is a whole number.
+func integer(v interface{}) (int64, bool) {
+	f, ok := number(v)
+	if !ok || f != math.Trunc(f) || math.IsInf(f, 0) {
+		return 0, false
+	}
+	return int64(f), true
+}
@@ -0,0 +1,299 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// rows is a driver.Rows over a precomputed result set.
+type rows struct {
+	rows      []driver.Row
+	offset    int64
+	totalRows int64
+	updateSeq string
+}
+
+var _ driver.Rows = &rows{}
+
+func (r *rows) Next(row *driver.Row) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	*row = r.rows[0]
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func (r *rows) Close() error {
+	r.rows = nil
+	return nil
+}
+
+func (r *rows) UpdateSeq() string { return r.updateSeq }
+func (r *rows) Offset() int64     { return r.offset }
+func (r *rows) TotalRows() int64  { return r.totalRows }
+
+type allDocsOptions struct {
+	descending   bool
+	startKey     string
+	hasStartKey  bool
+	endKey       string
+	hasEndKey    bool
+	key          string
+	hasKey       bool
+	keys         []string
+	hasKeys      bool
+	limit        int64
+	skip         int64
+	inclusiveEnd bool
+	includeDocs  bool
+	updateSeq    bool
+	get          *getOptions
+}
+
+func parseAllDocsOptions(opts map[string]interface{}) (*allDocsOptions, error) {
+	o := &allDocsOptions{limit: -1, inclusiveEnd: true}
+	var err error
+	if o.startKey, o.hasStartKey, err = stringOpt(opts, "startkey", "start_key"); err != nil {
+		return nil, err
+	}
+	if o.endKey, o.hasEndKey, err = stringOpt(opts, "endkey", "end_key"); err != nil {
+		return nil, err
+	}
+	if o.key, o.hasKey, err = stringOpt(opts, "key"); err != nil {
+		return nil, err
+	}
+	if o.keys, o.hasKeys, err = stringsOpt(opts, "keys"); err != nil {
+		return nil, err
+	}
+	if limit, ok, err := intOpt(opts, "limit"); err != nil {
+		return nil, err
+	} else if ok {
+		o.limit = limit
+	}
+	if o.skip, _, err = intOpt(opts, "skip"); err != nil {
+		return nil, err
+	}
+	if _, ok := opts["inclusive_end"]; ok {
+		if o.inclusiveEnd, err = boolOpt(opts, "inclusive_end"); err != nil {
+			return nil, err
+		}
+	}
+	for key, target := range map[string]*bool{
+		"descending":   &o.descending,
+		"include_docs": &o.includeDocs,
+		"update_seq":   &o.updateSeq,
+	} {
+		if *target, err = boolOpt(opts, key); err != nil {
+			return nil, err
+		}
+	}
+	conflicts, err := boolOpt(opts, "conflicts")
+	if err != nil {
+		return nil, err
+	}
+	o.get = &getOptions{conflicts: conflicts}
+	return o, nil
+}
+
+// inRange reports whether id falls within the requested key range, and
+// whether iteration should stop, as id is past the end of the range.
+func (o *allDocsOptions) inRange(id string) (include, stop bool) {
+	before := func(a, b string) bool { return a < b }
+	if o.descending {
+		before = func(a, b string) bool { return a > b }
+	}
+	if o.hasKey && id != o.key {
+		return false, before(o.key, id)
+	}
+	if o.hasStartKey && before(id, o.startKey) {
+		return false, false
+	}
+	if o.hasEndKey {
+		if before(o.endKey, id) || (!o.inclusiveEnd && id == o.endKey) {
+			return false, true
+		}
+	}
+	return true, false
+}
+
+func keyJSON(id string) json.RawMessage {
+	key, _ := json.Marshal(id)
+	return key
+}
+
+// docRow returns an _all_docs row for doc. The caller must hold db.mu.
+func docRow(doc *document, o *allDocsOptions) (driver.Row, error) {
+	winner := doc.winner()
+	value := map[string]interface{}{"rev": winner.String()}
+	row := driver.Row{ID: doc.id, Key: keyJSON(doc.id)}
+	if winner.deleted {
+		value["deleted"] = true
+	} else if o.includeDocs {
+		var err error
+		if row.Doc, err = docJSON(doc, winner, o.get); err != nil {
+			return row, err
+		}
+	}
+	row.Value, _ = json.Marshal(value)
+	return row, nil
+}
+
+// allDocs returns the rows of the _all_docs index, restricted to IDs
+// accepted by filter.
+func (d *db) allDocs(opts map[string]interface{}, filter func(string) bool) (driver.Rows, error) {
+	o, err := parseAllDocsOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	db, err := d.database()
+	if err != nil {
+		return nil, err
+	}
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	result := &rows{}
+	if o.updateSeq {
+		result.updateSeq = formatSeq(db.seq)
+	}
+	ids := make([]string, 0, len(db.docs))
+	for id, doc := range db.docs {
+		if filter(id) && !doc.winner().deleted {
+			ids = append(ids, id)
+		}
+	}
+	result.totalRows = int64(len(ids))
+	if o.hasKeys {
+		for _, key := range o.keys {
+			doc, ok := db.docs[key]
+			if !ok || !filter(key) {
+				result.rows = append(result.rows, driver.Row{
+					Key:   keyJSON(key),
+					Error: &kivik.Error{HTTPStatus: http.StatusNotFound, Message: "not_found"},
+				})
+				continue
+			}
+			row, err := docRow(doc, o)
+			if err != nil {
+				return nil, err
+			}
+			result.rows = append(result.rows, row)
+		}
+		return result, nil
+	}
+	if o.descending {
+		sort.Sort(sort.Reverse(sort.StringSlice(ids)))
+	} else {
+		sort.Strings(ids)
+	}
+	var matched []string
+	for i, id := range ids {
+		include, stop := o.inRange(id)
+		if stop {
+			break
+		}
+		if !include {
+			continue
+		}
+		if matched == nil {
+			result.offset = int64(i)
+		}
+		matched = append(matched, id)
+	}
+	if matched == nil {
+		result.offset = result.totalRows
+	}
+	if o.skip > 0 {
+		result.offset += o.skip
+		if o.skip >= int64(len(matched)) {
+			matched = nil
+		} else {
+			matched = matched[o.skip:]
+		}
+	}
+	if o.limit >= 0 && o.limit < int64(len(matched)) {
+		matched = matched[:o.limit]
+	}
+	for _, id := range matched {
+		row, err := docRow(db.docs[id], o)
+		if err != nil {
+			return nil, err
+		}
+		result.rows = append(result.rows, row)
+	}
+	return result, nil
+}
+
+func (d *db) AllDocs(_ context.Context, opts map[string]interface{}) (driver.Rows, error) {
+	return d.allDocs(opts, func(string) bool { return true })
+}
+
+func (d *db) DesignDocs(_ context.Context, opts map[string]interface{}) (driver.Rows, error) {
+	return d.allDocs(opts, func(id string) bool {
+		return strings.HasPrefix(id, designPrefix)
+	})
+}
+
+// LocalDocs lists _local documents, in ID order. Only the include_docs,
+// descending, limit and skip options are supported.
+func (d *db) LocalDocs(_ context.Context, opts map[string]interface{}) (driver.Rows, error) {
+	o, err := parseAllDocsOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	db, err := d.database()
+	if err != nil {
+		return nil, err
+	}
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	ids := make([]string, 0, len(db.local))
+	for id := range db.local {
+		ids = append(ids, id)
+	}
+	if o.descending {
+		sort.Sort(sort.Reverse(sort.StringSlice(ids)))
+	} else {
+		sort.Strings(ids)
+	}
+	if o.skip >= int64(len(ids)) {
+		ids = nil
+	} else {
+		ids = ids[o.skip:]
+	}
+	if o.limit >= 0 && o.limit < int64(len(ids)) {
+		ids = ids[:o.limit]
+	}
+	result := &rows{rows: make([]driver.Row, len(ids)), offset: o.skip}
+	for i, id := range ids {
+		doc, rev, err := db.getLocal(id)
+		if err != nil {
+			return nil, err
+		}
+		result.rows[i] = driver.Row{ID: id, Key: keyJSON(id)}
+		result.rows[i].Value, _ = json.Marshal(map[string]string{"rev": rev})
+		if o.includeDocs {
+			result.rows[i].Doc = doc
+		}
+	}
+	return result, nil
+}
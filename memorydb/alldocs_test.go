@@ -0,0 +1,113 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"context"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+func TestAllDocs(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	for _, id := range []string{"c", "a", "e", "b", "d", "_design/foo"} {
+		if _, err := db.Put(ctx, id, map[string]interface{}{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	type tt struct {
+		options   kivik.Options
+		ids       []string
+		offset    int64
+		totalRows int64
+	}
+	tests := testy.NewTable()
+	tests.Add("all", tt{
+		ids:       []string{"_design/foo", "a", "b", "c", "d", "e"},
+		totalRows: 6,
+	})
+	tests.Add("range", tt{
+		options:   kivik.Options{"startkey": "b", "endkey": "d"},
+		ids:       []string{"b", "c", "d"},
+		offset:    2,
+		totalRows: 6,
+	})
+	tests.Add("exclusive end", tt{
+		options:   kivik.Options{"start_key": "b", "end_key": "d", "inclusive_end": false},
+		ids:       []string{"b", "c"},
+		offset:    2,
+		totalRows: 6,
+	})
+	tests.Add("descending", tt{
+		options:   kivik.Options{"descending": true, "startkey": "c", "limit": 2},
+		ids:       []string{"c", "b"},
+		offset:    2,
+		totalRows: 6,
+	})
+	tests.Add("skip and limit", tt{
+		options:   kivik.Options{"skip": 2, "limit": 2},
+		ids:       []string{"b", "c"},
+		offset:    2,
+		totalRows: 6,
+	})
+	tests.Add("keys", tt{
+		options:   kivik.Options{"keys": []string{"e", "missing", "a"}},
+		ids:       []string{"e", "", "a"},
+		totalRows: 6,
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		rows, err := db.AllDocs(ctx, tt.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for rows.Next() {
+			ids = append(ids, rows.ID())
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface(tt.ids, ids); d != nil {
+			t.Error(d)
+		}
+		if rows.Offset() != tt.offset || rows.TotalRows() != tt.totalRows {
+			t.Errorf("Unexpected offset/total_rows: %d/%d", rows.Offset(), rows.TotalRows())
+		}
+	})
+}
+
+func TestDesignDocs(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	for _, id := range []string{"a", "_design/foo", "_design/bar"} {
+		if _, err := db.Put(ctx, id, map[string]interface{}{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rows, err := db.DesignDocs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for rows.Next() {
+		ids = append(ids, rows.ID())
+	}
+	if d := testy.DiffInterface([]string{"_design/bar", "_design/foo"}, ids); d != nil {
+		t.Error(d)
+	}
+}
@@ -0,0 +1,224 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+var errAttachmentMissing = &kivik.Error{HTTPStatus: http.StatusNotFound, Message: "Document is missing attachment"}
+
+// attachment is a stored attachment. Attachments are immutable, so may be
+// shared between revisions.
+type attachment struct {
+	contentType string
+	data        []byte
+	digest      string
+	revpos      int64
+}
+
+func newAttachment(contentType string, data []byte, revpos int64) *attachment {
+	sum := md5.Sum(data)
+	return &attachment{
+		contentType: contentType,
+		data:        data,
+		digest:      "md5-" + base64.StdEncoding.EncodeToString(sum[:]),
+		revpos:      revpos,
+	}
+}
+
+// attachmentInput is an entry in the _attachments field of a document, as
+// submitted for writing.
+type attachmentInput struct {
+	ContentType string `json:"content_type"`
+	Data        []byte `json:"data"`
+	Stub        bool   `json:"stub"`
+	Follows     bool   `json:"follows"`
+}
+
+// attachmentJSON is an entry in the _attachments field of a rendered document.
+type attachmentJSON struct {
+	ContentType string `json:"content_type"`
+	Digest      string `json:"digest"`
+	Length      int64  `json:"length"`
+	RevPos      int64  `json:"revpos"`
+	Stub        bool   `json:"stub,omitempty"`
+	Data        []byte `json:"data,omitempty"`
+}
+
+func attachmentsJSON(atts map[string]*attachment, includeData bool) map[string]attachmentJSON {
+	result := make(map[string]attachmentJSON, len(atts))
+	for name, att := range atts {
+		a := attachmentJSON{
+			ContentType: att.contentType,
+			Digest:      att.digest,
+			Length:      int64(len(att.data)),
+			RevPos:      att.revpos,
+		}
+		if includeData {
+			a.Data = att.data
+		} else {
+			a.Stub = true
+		}
+		result[name] = a
+	}
+	return result
+}
+
+// resolveAttachments converts submitted attachments to stored attachments,
+// for a new revision at pos. Stubs are resolved against parent, and its
+// ancestors.
+func resolveAttachments(docID string, input map[string]*attachmentInput, parent *revision, pos int64) (map[string]*attachment, error) {
+	if len(input) == 0 {
+		return nil, nil
+	}
+	atts := make(map[string]*attachment, len(input))
+	for name, in := range input {
+		switch {
+		case in.Follows:
+			return nil, badRequest("memorydb: multipart attachments are not supported")
+		case in.Stub:
+			att := findAttachment(parent, name)
+			if att == nil {
+				return nil, &kivik.Error{HTTPStatus: http.StatusPreconditionFailed, Message: "Invalid attachment stub in " + docID + " for " + name}
+			}
+			atts[name] = att
+		default:
+			contentType := in.ContentType
+			if contentType == "" {
+				contentType = "application/octet-stream"
+			}
+			atts[name] = newAttachment(contentType, in.Data, pos)
+		}
+	}
+	return atts, nil
+}
+
+func findAttachment(rev *revision, name string) *attachment {
+	for ; rev != nil; rev = rev.parent {
+		if att, ok := rev.attachments[name]; ok {
+			return att
+		}
+	}
+	return nil
+}
+
+// updateAttachment stores a new revision of a document, with the named
+// attachment replaced by att, or removed if att is nil.
+func (d *db) updateAttachment(docID, rev, filename string, att *attachmentInput) (string, error) {
+	db, err := d.database()
+	if err != nil {
+		return "", err
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	fields := &docFields{id: docID, rev: rev, attachments: map[string]*attachmentInput{}}
+	if doc, ok := db.docs[docID]; ok {
+		parent := doc.winner()
+		if rev != "" {
+			if parent, ok = doc.revs[rev]; !ok {
+				return "", errConflict
+			}
+		}
+		if !parent.deleted {
+			fields.body = parent.body
+			for name := range parent.attachments {
+				fields.attachments[name] = &attachmentInput{Stub: true}
+			}
+		}
+	}
+	if att == nil {
+		if _, ok := fields.attachments[filename]; !ok {
+			return "", errAttachmentMissing
+		}
+		delete(fields.attachments, filename)
+	} else {
+		fields.attachments[filename] = att
+	}
+	return db.update(fields)
+}
+
+func (d *db) PutAttachment(_ context.Context, docID, rev string, att *driver.Attachment, _ map[string]interface{}) (string, error) {
+	data, err := ioutil.ReadAll(att.Content)
+	if err != nil {
+		return "", badRequest("%s", err)
+	}
+	return d.updateAttachment(docID, rev, att.Filename, &attachmentInput{
+		ContentType: att.ContentType,
+		Data:        data,
+	})
+}
+
+func (d *db) DeleteAttachment(_ context.Context, docID, rev, filename string, _ map[string]interface{}) (string, error) {
+	return d.updateAttachment(docID, rev, filename, nil)
+}
+
+func (d *db) getAttachment(docID, filename string, opts map[string]interface{}) (*attachment, error) {
+	rev, _, err := stringOpt(opts, "rev")
+	if err != nil {
+		return nil, err
+	}
+	db, err := d.database()
+	if err != nil {
+		return nil, err
+	}
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	_, r, err := db.getRev(docID, rev)
+	if err != nil {
+		return nil, err
+	}
+	att, ok := r.attachments[filename]
+	if !ok {
+		return nil, errAttachmentMissing
+	}
+	return att, nil
+}
+
+func (d *db) GetAttachment(_ context.Context, docID, filename string, opts map[string]interface{}) (*driver.Attachment, error) {
+	att, err := d.getAttachment(docID, filename, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &driver.Attachment{
+		Filename:    filename,
+		ContentType: att.contentType,
+		Content:     ioutil.NopCloser(bytes.NewReader(att.data)),
+		Size:        int64(len(att.data)),
+		Digest:      att.digest,
+		RevPos:      att.revpos,
+	}, nil
+}
+
+func (d *db) GetAttachmentMeta(_ context.Context, docID, filename string, opts map[string]interface{}) (*driver.Attachment, error) {
+	att, err := d.getAttachment(docID, filename, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &driver.Attachment{
+		Filename:    filename,
+		ContentType: att.contentType,
+		Stub:        true,
+		Size:        int64(len(att.data)),
+		Digest:      att.digest,
+		RevPos:      att.revpos,
+	}, nil
+}
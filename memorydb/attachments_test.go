@@ -0,0 +1,90 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+func TestAttachments(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	rev, err := db.PutAttachment(ctx, "foo", &kivik.Attachment{
+		Filename:    "foo.txt",
+		ContentType: "text/plain",
+		Content:     ioutil.NopCloser(strings.NewReader("Hello, World!")),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Run("get", func(t *testing.T) {
+		att, err := db.GetAttachment(ctx, "foo", "foo.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer att.Content.Close() // nolint: errcheck
+		content, err := ioutil.ReadAll(att.Content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != "Hello, World!" {
+			t.Errorf("Unexpected content: %s", content)
+		}
+		if att.ContentType != "text/plain" || att.Digest != "md5-ZajifYh5KDgxtmS9i38K1A==" {
+			t.Errorf("Unexpected metadata: %s %s", att.ContentType, att.Digest)
+		}
+	})
+	t.Run("stub", func(t *testing.T) {
+		var doc struct {
+			Attachments map[string]map[string]interface{} `json:"_attachments"`
+		}
+		if err := db.Get(ctx, "foo").ScanDoc(&doc); err != nil {
+			t.Fatal(err)
+		}
+		if stub, _ := doc.Attachments["foo.txt"]["stub"].(bool); !stub {
+			t.Errorf("Expected a stub, got %v", doc.Attachments)
+		}
+	})
+	t.Run("stub preserved on update", func(t *testing.T) {
+		rev2, err := db.Put(ctx, "foo", map[string]interface{}{
+			"_rev": rev,
+			"_attachments": map[string]interface{}{
+				"foo.txt": map[string]interface{}{"stub": true},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		att, err := db.GetAttachmentMeta(ctx, "foo", "foo.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if att.Size != 13 {
+			t.Errorf("Unexpected size: %d", att.Size)
+		}
+		rev3, err := db.DeleteAttachment(ctx, "foo", rev2, "foo.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = db.GetAttachment(ctx, "foo", "foo.txt", kivik.Options{"rev": rev3})
+		testy.StatusErrorRE(t, "attachment", http.StatusNotFound, err)
+	})
+}
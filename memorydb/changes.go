@@ -0,0 +1,384 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+const (
+	feedNormal     = "normal"
+	feedLongpoll   = "longpoll"
+	feedContinuous = "continuous"
+)
+
+// feed implements the waiting and termination logic shared by the changes
+// and _db_updates feeds.
+type feed struct {
+	ctx     context.Context
+	mode    string
+	timeout time.Duration
+	// remaining is the number of items which may still be returned, or -1
+	// for no limit.
+	remaining int64
+	// done is set once no more items will be fetched.
+	done bool
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func newFeed(ctx context.Context, opts map[string]interface{}, defaultMode string) (*feed, error) {
+	f := &feed{ctx: ctx, mode: defaultMode, remaining: -1, closed: make(chan struct{})}
+	mode, _, err := stringOpt(opts, "feed")
+	if err != nil {
+		return nil, err
+	}
+	switch mode {
+	case "":
+	case feedNormal, feedLongpoll, feedContinuous:
+		f.mode = mode
+	default:
+		return nil, badRequest("Supported `feed` types: normal, continuous, longpoll")
+	}
+	if limit, ok, err := intOpt(opts, "limit"); err != nil {
+		return nil, err
+	} else if ok {
+		f.remaining = limit
+	}
+	timeout, _, err := intOpt(opts, "timeout")
+	if err != nil {
+		return nil, err
+	}
+	f.timeout = time.Duration(timeout) * time.Millisecond
+	return f, nil
+}
+
+// wait blocks until ready is closed, returning io.EOF if the feed is closed
+// or times out first.
+func (f *feed) wait(ready <-chan struct{}) error {
+	var timeout <-chan time.Time
+	if f.timeout > 0 {
+		timer := time.NewTimer(f.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-ready:
+		return nil
+	case <-f.ctx.Done():
+		return f.ctx.Err()
+	case <-f.closed:
+		return io.EOF
+	case <-timeout:
+		return io.EOF
+	}
+}
+
+// fetched records that n items were fetched, and returns the number which may
+// be returned, according to the limit.
+func (f *feed) fetched(n int) int {
+	if f.mode != feedContinuous && n > 0 {
+		f.done = true
+	}
+	if f.remaining >= 0 && int64(n) > f.remaining {
+		n = int(f.remaining)
+	}
+	if f.remaining >= 0 {
+		f.remaining -= int64(n)
+		if f.remaining == 0 {
+			f.done = true
+		}
+	}
+	return n
+}
+
+func (f *feed) Close() error {
+	f.closeOnce.Do(func() { close(f.closed) })
+	return nil
+}
+
+type changesOptions struct {
+	since       int64
+	descending  bool
+	includeDocs bool
+	allDocs     bool
+	filter      func(id string) bool
+	get         *getOptions
+}
+
+func parseChangesOptions(opts map[string]interface{}) (*changesOptions, error) {
+	o := &changesOptions{filter: func(string) bool { return true }}
+	var err error
+	if o.descending, err = boolOpt(opts, "descending"); err != nil {
+		return nil, err
+	}
+	if o.includeDocs, err = boolOpt(opts, "include_docs"); err != nil {
+		return nil, err
+	}
+	conflicts, err := boolOpt(opts, "conflicts")
+	if err != nil {
+		return nil, err
+	}
+	o.get = &getOptions{conflicts: conflicts}
+	style, _, err := stringOpt(opts, "style")
+	if err != nil {
+		return nil, err
+	}
+	switch style {
+	case "", "main_only":
+	case "all_docs":
+		o.allDocs = true
+	default:
+		return nil, badRequest("Invalid style: %s", style)
+	}
+	filter, _, err := stringOpt(opts, "filter")
+	if err != nil {
+		return nil, err
+	}
+	switch filter {
+	case "":
+	case "_doc_ids":
+		docIDs, _, err := stringsOpt(opts, "doc_ids")
+		if err != nil {
+			return nil, err
+		}
+		ids := make(map[string]bool, len(docIDs))
+		for _, id := range docIDs {
+			ids[id] = true
+		}
+		o.filter = func(id string) bool { return ids[id] }
+	case "_design":
+		o.filter = func(id string) bool { return strings.HasPrefix(id, designPrefix) }
+	default:
+		return nil, &kivik.Error{HTTPStatus: http.StatusNotImplemented, Message: "memorydb: filter " + filter + " is not supported"}
+	}
+	return o, nil
+}
+
+type changes struct {
+	*feed
+	db      *database
+	opts    *changesOptions
+	buf     []driver.Change
+	lastSeq string
+	pending int64
+	ready   <-chan struct{}
+}
+
+var _ driver.Changes = &changes{}
+
+// Changes supports the normal, longpoll and continuous feeds. The
+// descending option is only honored for the normal feed.
+func (d *db) Changes(ctx context.Context, opts map[string]interface{}) (driver.Changes, error) {
+	f, err := newFeed(ctx, opts, feedNormal)
+	if err != nil {
+		return nil, err
+	}
+	o, err := parseChangesOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	db, err := d.database()
+	if err != nil {
+		return nil, err
+	}
+	db.mu.RLock()
+	o.since, err = seqOpt(opts, db.seq)
+	db.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	if f.mode != feedNormal {
+		o.descending = false
+	}
+	c := &changes{feed: f, db: db, opts: o, lastSeq: formatSeq(o.since)}
+	if err := c.fetch(); err != nil {
+		return nil, err
+	}
+	if f.mode == feedNormal {
+		f.done = true
+	}
+	return c, nil
+}
+
+// fetch reads the changes following the last returned seq into the buffer.
+func (c *changes) fetch() error {
+	c.db.mu.RLock()
+	defer c.db.mu.RUnlock()
+	c.ready = c.db.wait()
+	docs := make([]*document, 0)
+	for _, doc := range c.db.docs {
+		if doc.seq > c.opts.since && c.opts.filter(doc.id) {
+			docs = append(docs, doc)
+		}
+	}
+	sort.Slice(docs, func(i, j int) bool {
+		if c.opts.descending {
+			return docs[i].seq > docs[j].seq
+		}
+		return docs[i].seq < docs[j].seq
+	})
+	n := c.fetched(len(docs))
+	c.pending = int64(len(docs) - n)
+	docs = docs[:n]
+	last := c.opts.since
+	for _, doc := range docs {
+		leaves := doc.leaves()
+		change := driver.Change{
+			ID:         doc.id,
+			Seq:        formatSeq(doc.seq),
+			Deleted:    leaves[0].deleted,
+			Changes:    driver.ChangedRevs{leaves[0].String()},
+			WinningRev: leaves[0].String(),
+		}
+		if c.opts.get.conflicts {
+			conflicts, deleted := doc.conflicts()
+			if len(conflicts) > 0 {
+				change.Conflicts = conflicts
+			}
+			if len(deleted) > 0 {
+				change.DeletedConflicts = deleted
+			}
+		}
+		if c.opts.allDocs {
+			for _, leaf := range leaves[1:] {
+				change.Changes = append(change.Changes, leaf.String())
+			}
+		}
+		if c.opts.includeDocs {
+			var err error
+			if change.Doc, err = docJSON(doc, leaves[0], c.opts.get); err != nil {
+				return err
+			}
+		}
+		c.buf = append(c.buf, change)
+		last = doc.seq
+		if doc.seq > c.opts.since {
+			c.opts.since = doc.seq
+		}
+	}
+	switch {
+	case c.mode == feedNormal && c.pending == 0 && !c.opts.descending:
+		c.lastSeq = formatSeq(c.db.seq)
+	case len(docs) > 0:
+		c.lastSeq = formatSeq(last)
+	}
+	return nil
+}
+
+func (c *changes) Next(change *driver.Change) error {
+	for len(c.buf) == 0 {
+		if c.done {
+			return io.EOF
+		}
+		if err := c.wait(c.ready); err != nil {
+			return err
+		}
+		if err := c.fetch(); err != nil {
+			return err
+		}
+	}
+	*change = c.buf[0]
+	c.buf = c.buf[1:]
+	return nil
+}
+
+func (c *changes) LastSeq() string { return c.lastSeq }
+func (c *changes) Pending() int64  { return c.pending }
+func (c *changes) ETag() string    { return "" }
+
+type dbUpdates struct {
+	*feed
+	server *server
+	since  int64
+	buf    []driver.DBUpdate
+	ready  <-chan struct{}
+}
+
+var _ driver.DBUpdates = &dbUpdates{}
+
+// DBUpdates supports the normal, longpoll and continuous feeds. The default
+// is a continuous feed, starting from now.
+func (c *client) DBUpdates(ctx context.Context, opts map[string]interface{}) (driver.DBUpdates, error) {
+	f, err := newFeed(ctx, opts, feedContinuous)
+	if err != nil {
+		return nil, err
+	}
+	c.server.mu.RLock()
+	since := c.server.updateSeq
+	c.server.mu.RUnlock()
+	if _, ok := opts["since"]; ok {
+		if since, err = seqOpt(opts, since); err != nil {
+			return nil, err
+		}
+	}
+	u := &dbUpdates{feed: f, server: c.server, since: since}
+	u.fetch()
+	if f.mode == feedNormal {
+		f.done = true
+	}
+	return u, nil
+}
+
+// fetch reads the updates following u.since into the buffer.
+func (u *dbUpdates) fetch() {
+	u.server.mu.RLock()
+	defer u.server.mu.RUnlock()
+	u.ready = u.server.wait()
+	if u.since >= u.server.updateSeq {
+		return
+	}
+	names := make([]string, 0)
+	for name, update := range u.server.updates {
+		if update.seq > u.since {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return u.server.updates[names[i]].seq < u.server.updates[names[j]].seq
+	})
+	for _, name := range names[:u.fetched(len(names))] {
+		update := u.server.updates[name]
+		u.buf = append(u.buf, driver.DBUpdate{
+			DBName: name,
+			Type:   update.eventType,
+			Seq:    formatSeq(update.seq),
+		})
+		u.since = update.seq
+	}
+}
+
+func (u *dbUpdates) Next(update *driver.DBUpdate) error {
+	for len(u.buf) == 0 {
+		if u.done {
+			return io.EOF
+		}
+		if err := u.wait(u.ready); err != nil {
+			return err
+		}
+		u.fetch()
+	}
+	*update = u.buf[0]
+	u.buf = u.buf[1:]
+	return nil
+}
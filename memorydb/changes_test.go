@@ -0,0 +1,164 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+func changeIDs(t *testing.T, changes *kivik.Changes) []string {
+	t.Helper()
+	var ids []string
+	for changes.Next() {
+		ids = append(ids, changes.ID())
+	}
+	if err := changes.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+func TestChanges(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	for _, id := range []string{"a", "b", "c", "d"} {
+		if _, err := db.Put(ctx, id, map[string]interface{}{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type tt struct {
+		options kivik.Options
+		ids     []string
+		lastSeq string
+		pending int64
+		status  int
+		err     string
+	}
+	tests := testy.NewTable()
+	tests.Add("normal", tt{
+		ids:     []string{"a", "b", "c", "d"},
+		lastSeq: "4",
+	})
+	tests.Add("since", tt{
+		options: kivik.Options{"since": "2"},
+		ids:     []string{"c", "d"},
+		lastSeq: "4",
+	})
+	tests.Add("limit", tt{
+		options: kivik.Options{"limit": 1},
+		ids:     []string{"a"},
+		lastSeq: "1",
+		pending: 3,
+	})
+	tests.Add("descending", tt{
+		options: kivik.Options{"descending": true, "limit": 2},
+		ids:     []string{"d", "c"},
+		lastSeq: "3",
+		pending: 2,
+	})
+	tests.Add("doc_ids", tt{
+		options: kivik.Options{"filter": "_doc_ids", "doc_ids": []string{"b", "d"}},
+		ids:     []string{"b", "d"},
+		lastSeq: "4",
+	})
+	tests.Add("emulated selector", tt{
+		options: kivik.ChangesOptions{
+			Selector: map[string]interface{}{"_id": map[string]interface{}{"$gt": "b"}},
+			Limit:    1,
+		}.Options(),
+		ids:     []string{"c"},
+		lastSeq: "3",
+	})
+	tests.Add("unsupported filter", tt{
+		options: kivik.Options{"filter": "foo/bar"},
+		status:  http.StatusNotImplemented,
+		err:     "memorydb: filter foo/bar is not supported",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		changes, err := db.Changes(ctx, tt.options)
+		testy.StatusError(t, tt.err, tt.status, err)
+		ids := changeIDs(t, changes)
+		if d := testy.DiffInterface(tt.ids, ids); d != nil {
+			t.Error(d)
+		}
+		if changes.LastSeq() != tt.lastSeq {
+			t.Errorf("Unexpected last_seq: %s", changes.LastSeq())
+		}
+		if changes.Pending() != tt.pending {
+			t.Errorf("Unexpected pending: %d", changes.Pending())
+		}
+	})
+}
+
+func TestChangesLongpoll(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	changes, err := db.Changes(ctx, kivik.Options{"feed": "longpoll", "since": "now"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_, _ = db.Put(ctx, "foo", map[string]interface{}{})
+	}()
+	if d := testy.DiffInterface([]string{"foo"}, changeIDs(t, changes)); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestChangesContinuous(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	if _, err := db.Put(ctx, "foo", map[string]interface{}{}); err != nil {
+		t.Fatal(err)
+	}
+	changes, err := db.Changes(ctx, kivik.Options{"feed": "continuous"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changes.Next() || changes.ID() != "foo" {
+		t.Fatalf("Expected foo, got %q: %v", changes.ID(), changes.Err())
+	}
+	go func() {
+		_, _ = db.Put(ctx, "bar", map[string]interface{}{})
+	}()
+	if !changes.Next() || changes.ID() != "bar" {
+		t.Fatalf("Expected bar, got %q: %v", changes.ID(), changes.Err())
+	}
+	if err := changes.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if changes.Next() {
+		t.Error("Expected no more changes after Close")
+	}
+}
+
+func TestChangesTimeout(t *testing.T) {
+	db := newDB(t)
+	changes, err := db.Changes(context.Background(), kivik.Options{"feed": "continuous", "timeout": 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := changeIDs(t, changes); len(ids) != 0 {
+		t.Errorf("Unexpected changes: %v", ids)
+	}
+}
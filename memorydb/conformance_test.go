@@ -0,0 +1,53 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"testing"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/kiviktest"
+)
+
+const capabilities = kiviktest.Attachments |
+	kiviktest.Changes |
+	kiviktest.Security |
+	kiviktest.MetaGetter |
+	kiviktest.AttachmentMetaGetter |
+	kiviktest.DesignDocer |
+	kiviktest.LocalDocer |
+	kiviktest.BulkDocer |
+	kiviktest.BulkGetter |
+	kiviktest.RevsDiffer |
+	kiviktest.OpenRevsGetter |
+	kiviktest.Purger |
+	kiviktest.Flusher |
+	kiviktest.DBsStatser |
+	kiviktest.Pinger
+
+func TestConformance(t *testing.T) {
+	t.Run("direct", func(t *testing.T) {
+		kiviktest.Run(t, kiviktest.Config{
+			Driver:       &memDriver{},
+			Capabilities: capabilities,
+		})
+	})
+	// A wrapped driver satisfies every optional interface, so this also
+	// checks that unsupported ones report 501.
+	t.Run("wrapped", func(t *testing.T) {
+		kiviktest.Run(t, kiviktest.Config{
+			Driver:       driver.Wrap("memory", &memDriver{}),
+			Capabilities: capabilities,
+		})
+	})
+}
@@ -0,0 +1,180 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"context"
+	"sync"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// database holds the contents of a single database.
+type database struct {
+	name   string
+	server *server
+
+	mu       sync.RWMutex
+	docs     map[string]*document
+	local    map[string]*localDoc
+	seq      int64
+	purgeSeq int64
+	security *driver.Security
+	notifier
+}
+
+func newDatabase(name string, s *server) *database {
+	return &database{
+		name:     name,
+		server:   s,
+		docs:     make(map[string]*document),
+		local:    make(map[string]*localDoc),
+		security: &driver.Security{},
+		notifier: newNotifier(),
+	}
+}
+
+// commit records a change to doc, which must already be stored in the
+// database. The caller must hold db.mu.
+func (db *database) commit(doc *document, rev *revision) {
+	db.updated()
+	doc.seq = db.seq
+	if rev != nil {
+		rev.seq = db.seq
+	}
+}
+
+// updated increments the update sequence, and wakes changes feeds and
+// DBUpdates waiting for an update. The caller must hold db.mu.
+func (db *database) updated() {
+	db.seq++
+	db.notify()
+	db.server.mu.Lock()
+	db.server.dbUpdated(db.name, "updated")
+	db.server.mu.Unlock()
+}
+
+func (db *database) stats() *driver.DBStats {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	stats := &driver.DBStats{
+		Name:      db.name,
+		UpdateSeq: formatSeq(db.seq),
+	}
+	for _, doc := range db.docs {
+		if doc.winner().deleted {
+			stats.DeletedCount++
+		} else {
+			stats.DocCount++
+		}
+	}
+	return stats
+}
+
+// db is a handle to a database, which may not exist.
+type db struct {
+	server *server
+	name   string
+}
+
+var (
+	_ driver.DB                   = &db{}
+	_ driver.DesignDocer          = &db{}
+	_ driver.LocalDocer           = &db{}
+	_ driver.MetaGetter           = &db{}
+	_ driver.AttachmentMetaGetter = &db{}
+	_ driver.Purger               = &db{}
+	_ driver.BulkDocer            = &db{}
+	_ driver.BulkGetter           = &db{}
+	_ driver.RevsDiffer           = &db{}
+	_ driver.OpenRevsGetter       = &db{}
+	_ driver.Flusher              = &db{}
+)
+
+func (d *db) database() (*database, error) {
+	return d.server.db(d.name)
+}
+
+func (d *db) Stats(_ context.Context) (*driver.DBStats, error) {
+	db, err := d.database()
+	if err != nil {
+		return nil, err
+	}
+	return db.stats(), nil
+}
+
+// Compact is a no-op, as there is nothing to compact.
+func (d *db) Compact(_ context.Context) error {
+	_, err := d.database()
+	return err
+}
+
+// CompactView is a no-op, as views are not supported.
+func (d *db) CompactView(_ context.Context, _ string) error {
+	_, err := d.database()
+	return err
+}
+
+// ViewCleanup is a no-op, as views are not supported.
+func (d *db) ViewCleanup(_ context.Context) error {
+	_, err := d.database()
+	return err
+}
+
+// Flush is a no-op, as everything is always in memory.
+func (d *db) Flush(_ context.Context) error {
+	_, err := d.database()
+	return err
+}
+
+func (d *db) Security(_ context.Context) (*driver.Security, error) {
+	db, err := d.database()
+	if err != nil {
+		return nil, err
+	}
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return copySecurity(db.security), nil
+}
+
+func (d *db) SetSecurity(_ context.Context, security *driver.Security) error {
+	db, err := d.database()
+	if err != nil {
+		return err
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.security = copySecurity(security)
+	return nil
+}
+
+func copySecurity(sec *driver.Security) *driver.Security {
+	copyMembers := func(m driver.Members) driver.Members {
+		return driver.Members{
+			Names: append([]string(nil), m.Names...),
+			Roles: append([]string(nil), m.Roles...),
+		}
+	}
+	return &driver.Security{
+		Admins:  copyMembers(sec.Admins),
+		Members: copyMembers(sec.Members),
+	}
+}
+
+// Query returns an error, as views are not supported.
+func (d *db) Query(_ context.Context, _, _ string, _ map[string]interface{}) (driver.Rows, error) {
+	if _, err := d.database(); err != nil {
+		return nil, err
+	}
+	return nil, errViews
+}
@@ -0,0 +1,742 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+const (
+	designPrefix = "_design/"
+	localPrefix  = "_local/"
+)
+
+// docFields is a document, as submitted for writing, split into its special
+// fields and body.
+type docFields struct {
+	id          string
+	rev         string
+	deleted     bool
+	revisions   *revisionsField
+	attachments map[string]*attachmentInput
+	body        map[string]interface{}
+}
+
+type revisionsField struct {
+	Start int64    `json:"start"`
+	IDs   []string `json:"ids"`
+}
+
+// ignoredFields are special fields which CouchDB accepts, but ignores, on
+// write.
+var ignoredFields = map[string]bool{
+	"_conflicts":         true,
+	"_deleted_conflicts": true,
+	"_local_seq":         true,
+	"_revs_info":         true,
+}
+
+func decodeDoc(doc interface{}) (*docFields, error) {
+	var data []byte
+	switch t := doc.(type) {
+	case json.RawMessage:
+		data = t
+	case []byte:
+		data = t
+	case io.Reader:
+		var err error
+		if data, err = ioutil.ReadAll(t); err != nil {
+			return nil, badRequest("%s", err)
+		}
+	default:
+		var err error
+		if data, err = json.Marshal(doc); err != nil {
+			return nil, badRequest("%s", err)
+		}
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var m map[string]interface{}
+	if err := dec.Decode(&m); err != nil || m == nil {
+		return nil, badRequest("Document must be a JSON object")
+	}
+	fields := &docFields{body: make(map[string]interface{}, len(m))}
+	for key, value := range m {
+		if !strings.HasPrefix(key, "_") {
+			fields.body[key] = value
+			continue
+		}
+		var ok bool
+		switch key {
+		case "_id":
+			fields.id, ok = value.(string)
+		case "_rev":
+			fields.rev, ok = value.(string)
+		case "_deleted":
+			fields.deleted, ok = value.(bool)
+		case "_revisions":
+			fields.revisions = &revisionsField{}
+			ok = remarshal(value, fields.revisions) == nil
+		case "_attachments":
+			ok = remarshal(value, &fields.attachments) == nil
+		default:
+			if !ignoredFields[key] {
+				return nil, badRequest("Bad special document member: %s", key)
+			}
+			ok = true
+		}
+		if !ok {
+			return nil, badRequest("Invalid value for %s", key)
+		}
+	}
+	return fields, nil
+}
+
+// remarshal converts a decoded JSON value to a typed value.
+func remarshal(value, target interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
+
+func validateDocID(docID string) error {
+	if docID == "" {
+		return badRequest("Document id must not be empty")
+	}
+	if strings.HasPrefix(docID, "_") && !strings.HasPrefix(docID, designPrefix) {
+		return badRequest("Only reserved document ids may start with underscore.")
+	}
+	return nil
+}
+
+func newUUID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// getOptions are the options which control the rendering of a document.
+type getOptions struct {
+	rev              string
+	attachments      bool
+	revs             bool
+	revsInfo         bool
+	conflicts        bool
+	deletedConflicts bool
+	localSeq         bool
+}
+
+func parseGetOptions(opts map[string]interface{}) (*getOptions, error) {
+	o := &getOptions{}
+	var err error
+	if o.rev, _, err = stringOpt(opts, "rev"); err != nil {
+		return nil, err
+	}
+	for key, target := range map[string]*bool{
+		"attachments":       &o.attachments,
+		"revs":              &o.revs,
+		"revs_info":         &o.revsInfo,
+		"conflicts":         &o.conflicts,
+		"deleted_conflicts": &o.deletedConflicts,
+		"local_seq":         &o.localSeq,
+	} {
+		if *target, err = boolOpt(opts, key); err != nil {
+			return nil, err
+		}
+	}
+	meta, err := boolOpt(opts, "meta")
+	if err != nil {
+		return nil, err
+	}
+	if meta {
+		o.conflicts, o.deletedConflicts, o.revsInfo = true, true, true
+	}
+	return o, nil
+}
+
+// docJSON renders rev of doc as JSON. The caller must hold db.mu.
+func docJSON(doc *document, rev *revision, o *getOptions) (json.RawMessage, error) {
+	m := make(map[string]interface{}, len(rev.body)+2)
+	for k, v := range rev.body {
+		m[k] = v
+	}
+	m["_id"] = doc.id
+	m["_rev"] = rev.String()
+	if rev.deleted {
+		m["_deleted"] = true
+	}
+	if len(rev.attachments) > 0 {
+		m["_attachments"] = attachmentsJSON(rev.attachments, o.attachments)
+	}
+	if o.revs {
+		history := rev.history()
+		ids := make([]string, len(history))
+		for i, r := range history {
+			ids[i] = r.hash
+		}
+		m["_revisions"] = revisionsField{Start: rev.pos, IDs: ids}
+	}
+	if o.revsInfo {
+		history := rev.history()
+		info := make([]map[string]string, len(history))
+		for i, r := range history {
+			status := "available"
+			switch {
+			case r.missing:
+				status = "missing"
+			case r.deleted:
+				status = "deleted"
+			}
+			info[i] = map[string]string{"rev": r.String(), "status": status}
+		}
+		m["_revs_info"] = info
+	}
+	if rev == doc.winner() {
+		conflicts, deleted := doc.conflicts()
+		if o.conflicts && len(conflicts) > 0 {
+			m["_conflicts"] = conflicts
+		}
+		if o.deletedConflicts && len(deleted) > 0 {
+			m["_deleted_conflicts"] = deleted
+		}
+	}
+	if o.localSeq {
+		m["_local_seq"] = rev.seq
+	}
+	return json.Marshal(m)
+}
+
+// getRev returns the requested revision of a document, or the winning
+// revision if rev is empty. The caller must hold db.mu.
+func (db *database) getRev(docID, rev string) (*document, *revision, error) {
+	doc, ok := db.docs[docID]
+	if !ok {
+		return nil, nil, errMissing
+	}
+	if rev == "" {
+		winner := doc.winner()
+		if winner.deleted {
+			return nil, nil, errDeleted
+		}
+		return doc, winner, nil
+	}
+	r, ok := doc.revs[rev]
+	if !ok || r.missing {
+		return nil, nil, errMissing
+	}
+	return doc, r, nil
+}
+
+func (d *db) Get(_ context.Context, docID string, opts map[string]interface{}) (*driver.Document, error) {
+	o, err := parseGetOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	db, err := d.database()
+	if err != nil {
+		return nil, err
+	}
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	var body json.RawMessage
+	var rev string
+	if strings.HasPrefix(docID, localPrefix) {
+		body, rev, err = db.getLocal(docID)
+	} else {
+		var doc *document
+		var r *revision
+		if doc, r, err = db.getRev(docID, o.rev); err == nil {
+			rev = r.String()
+			body, err = docJSON(doc, r, o)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &driver.Document{
+		ContentLength: int64(len(body)),
+		Rev:           rev,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+	}, nil
+}
+
+func (d *db) GetMeta(ctx context.Context, docID string, opts map[string]interface{}) (int64, string, error) {
+	doc, err := d.Get(ctx, docID, opts)
+	if err != nil {
+		return 0, "", err
+	}
+	return doc.ContentLength, doc.Rev, nil
+}
+
+func (d *db) CreateDoc(ctx context.Context, doc interface{}, opts map[string]interface{}) (string, string, error) {
+	fields, err := decodeDoc(doc)
+	if err != nil {
+		return "", "", err
+	}
+	if fields.id == "" {
+		fields.id = newUUID()
+	}
+	rev, err := d.put(fields, opts)
+	return fields.id, rev, err
+}
+
+func (d *db) Put(_ context.Context, docID string, doc interface{}, opts map[string]interface{}) (string, error) {
+	fields, err := decodeDoc(doc)
+	if err != nil {
+		return "", err
+	}
+	fields.id = docID
+	return d.put(fields, opts)
+}
+
+func (d *db) Delete(_ context.Context, docID, rev string, opts map[string]interface{}) (string, error) {
+	return d.put(&docFields{id: docID, rev: rev, deleted: true}, opts)
+}
+
+func (d *db) put(fields *docFields, opts map[string]interface{}) (string, error) {
+	optRev, _, err := stringOpt(opts, "rev")
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case fields.rev == "":
+		fields.rev = optRev
+	case optRev != "" && optRev != fields.rev:
+		return "", badRequest("Document rev from request body and query string have different values")
+	}
+	newEdits := true
+	if _, ok := opts["new_edits"]; ok {
+		if newEdits, err = boolOpt(opts, "new_edits"); err != nil {
+			return "", err
+		}
+	}
+	db, err := d.database()
+	if err != nil {
+		return "", err
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if strings.HasPrefix(fields.id, localPrefix) {
+		return db.putLocal(fields)
+	}
+	if fields.deleted && fields.body == nil {
+		if _, ok := db.docs[fields.id]; !ok {
+			return "", errMissing
+		}
+		if fields.rev == "" {
+			return "", errConflict
+		}
+	}
+	if !newEdits {
+		return db.replicate(fields)
+	}
+	return db.update(fields)
+}
+
+// update stores a new revision of a document, as a child of the revision
+// given in fields. The caller must hold db.mu.
+func (db *database) update(fields *docFields) (string, error) {
+	if err := validateDocID(fields.id); err != nil {
+		return "", err
+	}
+	doc, exists := db.docs[fields.id]
+	var parent *revision
+	switch {
+	case !exists && fields.rev != "":
+		return "", errConflict
+	case !exists:
+		doc = newDocument(fields.id)
+	case fields.rev == "":
+		parent = doc.winner()
+		if !parent.deleted {
+			return "", errConflict
+		}
+	default:
+		if _, _, err := parseRev(fields.rev); err != nil {
+			return "", err
+		}
+		p, ok := doc.revs[fields.rev]
+		if !ok || p.missing || p.children > 0 {
+			return "", errConflict
+		}
+		parent = p
+	}
+	rev := &revision{
+		pos:     1,
+		parent:  parent,
+		deleted: fields.deleted,
+		body:    fields.body,
+	}
+	if parent != nil {
+		rev.pos = parent.pos + 1
+	}
+	if rev.body == nil {
+		rev.body = map[string]interface{}{}
+	}
+	atts, err := resolveAttachments(fields.id, fields.attachments, parent, rev.pos)
+	if err != nil {
+		return "", err
+	}
+	rev.attachments = atts
+	rev.hash = newRevHash(parent, rev.deleted, rev.body, atts)
+	doc.add(rev)
+	db.docs[fields.id] = doc
+	db.commit(doc, rev)
+	return rev.String(), nil
+}
+
+// replicate stores a revision with new_edits=false, as done by replication.
+// The caller must hold db.mu.
+func (db *database) replicate(fields *docFields) (string, error) {
+	if err := validateDocID(fields.id); err != nil {
+		return "", err
+	}
+	if fields.rev == "" {
+		return "", badRequest("_rev is required with new_edits=false")
+	}
+	pos, hash, err := parseRev(fields.rev)
+	if err != nil {
+		return "", err
+	}
+	revisions := fields.revisions
+	if revisions == nil {
+		revisions = &revisionsField{Start: pos, IDs: []string{hash}}
+	}
+	if revisions.Start != pos || len(revisions.IDs) == 0 || revisions.IDs[0] != hash {
+		return "", badRequest("_rev does not match _revisions")
+	}
+	doc, exists := db.docs[fields.id]
+	if !exists {
+		doc = newDocument(fields.id)
+	}
+	// Resolve attachment stubs against the nearest known ancestor, before
+	// modifying the tree.
+	var parent *revision
+	for i, id := range revisions.IDs[1:] {
+		if r, ok := doc.revs[fmt.Sprintf("%d-%s", pos-int64(i)-1, id)]; ok {
+			parent = r
+			break
+		}
+	}
+	atts, err := resolveAttachments(fields.id, fields.attachments, parent, pos)
+	if err != nil {
+		return "", err
+	}
+	rev, err := doc.addPath(revisions.Start, revisions.IDs)
+	if err != nil {
+		return "", err
+	}
+	if rev == nil {
+		// Already stored
+		return fields.rev, nil
+	}
+	rev.missing = false
+	rev.deleted = fields.deleted
+	rev.body = fields.body
+	if rev.body == nil {
+		rev.body = map[string]interface{}{}
+	}
+	rev.attachments = atts
+	db.docs[fields.id] = doc
+	db.commit(doc, rev)
+	return fields.rev, nil
+}
+
+type bulkResults struct {
+	results []driver.BulkResult
+}
+
+var _ driver.BulkResults = &bulkResults{}
+
+func (r *bulkResults) Next(result *driver.BulkResult) error {
+	if len(r.results) == 0 {
+		return io.EOF
+	}
+	*result = r.results[0]
+	r.results = r.results[1:]
+	return nil
+}
+
+func (r *bulkResults) Close() error {
+	r.results = nil
+	return nil
+}
+
+// BulkDocs stores each document in turn. With new_edits=false, only failures
+// are reported, as CouchDB does.
+func (d *db) BulkDocs(_ context.Context, docs []interface{}, opts map[string]interface{}) (driver.BulkResults, error) {
+	if _, err := d.database(); err != nil {
+		return nil, err
+	}
+	newEdits := true
+	if _, ok := opts["new_edits"]; ok {
+		var err error
+		if newEdits, err = boolOpt(opts, "new_edits"); err != nil {
+			return nil, err
+		}
+	}
+	putOpts := map[string]interface{}{"new_edits": newEdits}
+	results := make([]driver.BulkResult, 0, len(docs))
+	for _, doc := range docs {
+		fields, err := decodeDoc(doc)
+		if err != nil {
+			results = append(results, driver.BulkResult{Error: err})
+			continue
+		}
+		if fields.id == "" && newEdits {
+			fields.id = newUUID()
+		}
+		rev, err := d.put(fields, putOpts)
+		if err == nil && !newEdits {
+			continue
+		}
+		results = append(results, driver.BulkResult{ID: fields.id, Rev: rev, Error: err})
+	}
+	return &bulkResults{results: results}, nil
+}
+
+// BulkGet returns one row per reference, with Doc set to the requested
+// revision, or Error set if it could not be found.
+func (d *db) BulkGet(_ context.Context, refs []driver.BulkGetReference, opts map[string]interface{}) (driver.Rows, error) {
+	o, err := parseGetOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	db, err := d.database()
+	if err != nil {
+		return nil, err
+	}
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	result := make([]driver.Row, len(refs))
+	for i, ref := range refs {
+		result[i].ID = ref.ID
+		doc, rev, err := db.getRev(ref.ID, ref.Rev)
+		if err == nil {
+			result[i].Doc, err = docJSON(doc, rev, o)
+		}
+		result[i].Error = err
+	}
+	return &rows{rows: result}, nil
+}
+
+// OpenRevs returns the requested revisions of a document, or all of its leaf
+// revisions, winning revision first, if revs is empty.
+func (d *db) OpenRevs(_ context.Context, docID string, revs []string, opts map[string]interface{}) (driver.OpenRevs, error) {
+	o, err := parseGetOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	db, err := d.database()
+	if err != nil {
+		return nil, err
+	}
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	doc, ok := db.docs[docID]
+	if !ok {
+		return nil, errMissing
+	}
+	if len(revs) == 0 {
+		for _, leaf := range doc.leaves() {
+			revs = append(revs, leaf.String())
+		}
+	}
+	results := make([]driver.OpenRev, len(revs))
+	for i, rev := range revs {
+		results[i].Rev = rev
+		r, ok := doc.revs[rev]
+		if !ok || r.missing {
+			results[i].Missing = true
+			continue
+		}
+		if results[i].Doc, err = docJSON(doc, r, o); err != nil {
+			return nil, err
+		}
+	}
+	return &openRevs{revs: results}, nil
+}
+
+type openRevs struct {
+	revs []driver.OpenRev
+}
+
+var _ driver.OpenRevs = &openRevs{}
+
+func (r *openRevs) Next(rev *driver.OpenRev) error {
+	if len(r.revs) == 0 {
+		return io.EOF
+	}
+	*rev = r.revs[0]
+	r.revs = r.revs[1:]
+	return nil
+}
+
+func (r *openRevs) Close() error {
+	r.revs = nil
+	return nil
+}
+
+// RevsDiff returns one row for each document with missing revisions, in
+// document ID order.
+func (d *db) RevsDiff(_ context.Context, revMap interface{}) (driver.Rows, error) {
+	var revs map[string][]string
+	if err := remarshal(revMap, &revs); err != nil {
+		return nil, badRequest("%s", err)
+	}
+	db, err := d.database()
+	if err != nil {
+		return nil, err
+	}
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	ids := make([]string, 0, len(revs))
+	for id := range revs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	result := make([]driver.Row, 0, len(ids))
+	for _, id := range ids {
+		doc := db.docs[id]
+		var diff driver.RevDiff
+		var maxPos int64
+		for _, rev := range revs[id] {
+			if doc != nil {
+				if _, ok := doc.revs[rev]; ok {
+					continue
+				}
+			}
+			diff.Missing = append(diff.Missing, rev)
+			if pos, _, err := parseRev(rev); err == nil && pos > maxPos {
+				maxPos = pos
+			}
+		}
+		if len(diff.Missing) == 0 {
+			continue
+		}
+		if doc != nil {
+			for _, leaf := range doc.leaves() {
+				if leaf.pos < maxPos {
+					diff.PossibleAncestors = append(diff.PossibleAncestors, leaf.String())
+				}
+			}
+		}
+		key, _ := json.Marshal(id)
+		value, _ := json.Marshal(diff)
+		result = append(result, driver.Row{ID: id, Key: key, Value: value})
+	}
+	return &rows{rows: result}, nil
+}
+
+// Purge removes the given leaf revisions, along with any ancestors not shared
+// with another branch. Documents left with no revisions are removed entirely.
+func (d *db) Purge(_ context.Context, docRevMap map[string][]string) (*driver.PurgeResult, error) {
+	db, err := d.database()
+	if err != nil {
+		return nil, err
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	purged := make(map[string][]string)
+	for id, revs := range docRevMap {
+		doc, ok := db.docs[id]
+		if !ok {
+			continue
+		}
+		for _, rev := range revs {
+			r, ok := doc.revs[rev]
+			if !ok || r.children > 0 {
+				continue
+			}
+			doc.remove(r)
+			purged[id] = append(purged[id], rev)
+		}
+		if len(purged[id]) == 0 {
+			continue
+		}
+		db.purgeSeq++
+		if len(doc.revs) == 0 {
+			delete(db.docs, id)
+			db.updated()
+			continue
+		}
+		db.commit(doc, nil)
+	}
+	return &driver.PurgeResult{Seq: db.purgeSeq, Purged: purged}, nil
+}
+
+// localDoc is a _local document, which is not replicated, and has no
+// revision history.
+type localDoc struct {
+	rev  int64
+	body map[string]interface{}
+}
+
+func (l *localDoc) revString() string {
+	return fmt.Sprintf("0-%d", l.rev)
+}
+
+// getLocal returns the rendered local document. The caller must hold db.mu.
+func (db *database) getLocal(docID string) (json.RawMessage, string, error) {
+	doc, ok := db.local[docID]
+	if !ok {
+		return nil, "", errMissing
+	}
+	m := make(map[string]interface{}, len(doc.body)+2)
+	for k, v := range doc.body {
+		m[k] = v
+	}
+	m["_id"] = docID
+	m["_rev"] = doc.revString()
+	body, err := json.Marshal(m)
+	return body, doc.revString(), err
+}
+
+// putLocal stores or deletes a local document. The caller must hold db.mu.
+func (db *database) putLocal(fields *docFields) (string, error) {
+	doc, exists := db.local[fields.id]
+	switch {
+	case !exists && fields.deleted:
+		return "", errMissing
+	case exists && fields.rev != doc.revString():
+		return "", errConflict
+	case !exists && fields.rev != "":
+		return "", errConflict
+	}
+	if fields.deleted {
+		delete(db.local, fields.id)
+		return "0-0", nil
+	}
+	if !exists {
+		doc = &localDoc{}
+	}
+	doc.rev++
+	doc.body = fields.body
+	db.local[fields.id] = doc
+	return doc.revString(), nil
+}
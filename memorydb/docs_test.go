@@ -0,0 +1,423 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+func TestPutGet(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	rev, err := db.Put(ctx, "foo", map[string]interface{}{"value": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Run("get", func(t *testing.T) {
+		var doc map[string]interface{}
+		if err := db.Get(ctx, "foo").ScanDoc(&doc); err != nil {
+			t.Fatal(err)
+		}
+		expected := map[string]interface{}{"_id": "foo", "_rev": rev, "value": float64(1)}
+		if d := testy.DiffInterface(expected, doc); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("meta", func(t *testing.T) {
+		_, got, err := db.GetMeta(ctx, "foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != rev {
+			t.Errorf("Unexpected rev: %s", got)
+		}
+	})
+	t.Run("conflict", func(t *testing.T) {
+		_, err := db.Put(ctx, "foo", map[string]interface{}{"value": 2})
+		testy.StatusError(t, "Document update conflict.", http.StatusConflict, err)
+	})
+	t.Run("missing", func(t *testing.T) {
+		err := db.Get(ctx, "bar").Err
+		testy.StatusError(t, "missing", http.StatusNotFound, err)
+	})
+	t.Run("invalid id", func(t *testing.T) {
+		_, err := db.Put(ctx, "_foo", map[string]interface{}{})
+		testy.StatusErrorRE(t, "Only reserved document ids", http.StatusBadRequest, err)
+	})
+	t.Run("update and delete", func(t *testing.T) {
+		rev2, err := db.Put(ctx, "foo", map[string]interface{}{"_rev": rev, "value": 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rev2[:2] != "2-" {
+			t.Errorf("Unexpected rev: %s", rev2)
+		}
+		if _, err := db.Delete(ctx, "foo", rev); err == nil {
+			t.Error("Expected a conflict deleting a stale revision")
+		}
+		if _, err := db.Delete(ctx, "foo", rev2); err != nil {
+			t.Fatal(err)
+		}
+		err = db.Get(ctx, "foo").Err
+		testy.StatusError(t, "deleted", http.StatusNotFound, err)
+		var doc map[string]interface{}
+		if err := db.Get(ctx, "foo", kivik.Options{"rev": rev}).ScanDoc(&doc); err != nil {
+			t.Fatal(err)
+		}
+		if doc["value"] != float64(1) {
+			t.Errorf("Unexpected old revision: %v", doc)
+		}
+	})
+}
+
+func TestCreateDoc(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	id, rev, err := db.CreateDoc(ctx, map[string]interface{}{"foo": "bar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id == "" || rev == "" {
+		t.Fatalf("Unexpected result: id=%q, rev=%q", id, rev)
+	}
+	if err := db.Get(ctx, id).Err; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReplicatedConflicts(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	newEdits := kivik.Options{"new_edits": false}
+	for _, doc := range []map[string]interface{}{
+		{"_id": "foo", "_rev": "2-aaa", "_revisions": map[string]interface{}{"start": 2, "ids": []string{"aaa", "xxx"}}, "v": "a"},
+		{"_id": "foo", "_rev": "2-bbb", "_revisions": map[string]interface{}{"start": 2, "ids": []string{"bbb", "xxx"}}, "v": "b"},
+	} {
+		if _, err := db.Put(ctx, "foo", doc, newEdits); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var doc map[string]interface{}
+	if err := db.Get(ctx, "foo", kivik.Options{"conflicts": true}).ScanDoc(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc["_rev"] != "2-bbb" {
+		t.Errorf("Unexpected winning rev: %v", doc["_rev"])
+	}
+	if d := testy.DiffInterface([]interface{}{"2-aaa"}, doc["_conflicts"]); d != nil {
+		t.Error(d)
+	}
+
+	t.Run("Changes", func(t *testing.T) {
+		changes, err := db.Changes(ctx, kivik.Options{"style": "all_docs", "conflicts": true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !changes.Next() {
+			t.Fatal(changes.Err())
+		}
+		if rev := changes.WinningRev(); rev != "2-bbb" {
+			t.Errorf("Unexpected winning rev: %s", rev)
+		}
+		if d := testy.DiffInterface([]string{"2-aaa"}, changes.Conflicts()); d != nil {
+			t.Error(d)
+		}
+		if err := changes.Close(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("RevsDiff", func(t *testing.T) {
+		rows, err := db.RevsDiff(ctx, map[string][]string{
+			"foo": {"2-aaa", "3-ccc"},
+			"bar": {"1-xxx"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := map[string]interface{}{}
+		for rows.Next() {
+			var value map[string]interface{}
+			if err := rows.ScanValue(&value); err != nil {
+				t.Fatal(err)
+			}
+			got[rows.ID()] = value["missing"]
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		expected := map[string]interface{}{
+			"bar": []interface{}{"1-xxx"},
+			"foo": []interface{}{"3-ccc"},
+		}
+		if d := testy.DiffInterface(expected, got); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("Purge", func(t *testing.T) {
+		result, err := db.Purge(ctx, map[string][]string{"foo": {"2-bbb"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface(map[string][]string{"foo": {"2-bbb"}}, result.Purged); d != nil {
+			t.Error(d)
+		}
+		_, rev, err := db.GetMeta(ctx, "foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rev != "2-aaa" {
+			t.Errorf("Unexpected rev after purge: %s", rev)
+		}
+	})
+}
+
+func TestConflictResolver(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	newEdits := kivik.Options{"new_edits": false}
+	for _, doc := range []map[string]interface{}{
+		{"_id": "foo", "_rev": "2-aaa", "_revisions": map[string]interface{}{"start": 2, "ids": []string{"aaa", "xxx"}}, "v": "a", "updated": 3},
+		{"_id": "foo", "_rev": "2-bbb", "_revisions": map[string]interface{}{"start": 2, "ids": []string{"bbb", "xxx"}}, "v": "b", "updated": 1},
+		{"_id": "foo", "_rev": "2-ccc", "_revisions": map[string]interface{}{"start": 2, "ids": []string{"ccc", "xxx"}}, "v": "c", "updated": 2},
+		{"_id": "bar", "_rev": "1-xxx", "v": "x"},
+	} {
+		if _, err := db.Put(ctx, doc["_id"].(string), doc, newEdits); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := kivik.NewConflictResolver(db, kivik.LastWriterWins("updated"))
+	resolutions, err := r.ResolveAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resolutions) != 1 || resolutions[0].DocID != "foo" || len(resolutions[0].Deleted) != 2 {
+		t.Fatalf("Unexpected resolutions: %+v", resolutions)
+	}
+	var doc map[string]interface{}
+	if err := db.Get(ctx, "foo", kivik.Options{"conflicts": true}).ScanDoc(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc["_rev"] != resolutions[0].Rev || doc["v"] != "a" {
+		t.Errorf("Unexpected merged document: %v", doc)
+	}
+	if _, ok := doc["_conflicts"]; ok {
+		t.Errorf("Conflicts remain: %v", doc["_conflicts"])
+	}
+	ids, err := r.Conflicted(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 0 {
+		t.Errorf("Unexpected conflicted documents: %v", ids)
+	}
+}
+
+func TestBulkGet(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	rev, err := db.Put(ctx, "foo", map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.BulkGet(ctx, []kivik.BulkGetReference{{ID: "foo"}, {ID: "bar"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for rows.Next() {
+		var doc map[string]interface{}
+		if err := rows.ScanDoc(&doc); err != nil {
+			got = append(got, rows.ID()+":"+err.Error())
+			continue
+		}
+		got = append(got, rows.ID()+":"+doc["_rev"].(string))
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface([]string{"foo:" + rev, "bar:missing"}, got); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestLocalDocs(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	rev, err := db.Put(ctx, "_local/foo", map[string]interface{}{"a": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rev != "0-1" {
+		t.Errorf("Unexpected rev: %s", rev)
+	}
+	if _, err := db.Put(ctx, "_local/foo", map[string]interface{}{"a": 2}); err == nil {
+		t.Error("Expected a conflict")
+	}
+	rows, err := db.LocalDocs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for rows.Next() {
+		ids = append(ids, rows.ID())
+	}
+	if d := testy.DiffInterface([]string{"_local/foo"}, ids); d != nil {
+		t.Error(d)
+	}
+	if _, err := db.Delete(ctx, "_local/foo", rev); err != nil {
+		t.Fatal(err)
+	}
+	err = db.Get(ctx, "_local/foo").Err
+	testy.StatusError(t, "missing", http.StatusNotFound, err)
+}
+
+func TestGetRevs(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	newEdits := kivik.Options{"new_edits": false}
+	for _, doc := range []map[string]interface{}{
+		{"_id": "foo", "_rev": "2-aaa", "_revisions": map[string]interface{}{"start": 2, "ids": []string{"aaa", "xxx"}}, "v": "a"},
+		{"_id": "foo", "_rev": "2-bbb", "_revisions": map[string]interface{}{"start": 2, "ids": []string{"bbb", "xxx"}}, "_deleted": true},
+	} {
+		if _, err := db.Put(ctx, "foo", doc, newEdits); err != nil {
+			t.Fatal(err)
+		}
+	}
+	revsOf := func(t *testing.T, revs []string) map[string]interface{} {
+		t.Helper()
+		openRevs, err := db.GetRevs(ctx, "foo", revs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := map[string]interface{}{}
+		for openRevs.Next() {
+			if openRevs.Missing() {
+				got[openRevs.Rev()] = "missing"
+				continue
+			}
+			var doc map[string]interface{}
+			if err := openRevs.ScanDoc(&doc); err != nil {
+				t.Fatal(err)
+			}
+			got[openRevs.Rev()] = doc["_deleted"] == true
+		}
+		if err := openRevs.Err(); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+	t.Run("all leaves", func(t *testing.T) {
+		expected := map[string]interface{}{"2-aaa": false, "2-bbb": true}
+		if d := testy.DiffInterface(expected, revsOf(t, nil)); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("requested revs", func(t *testing.T) {
+		expected := map[string]interface{}{"2-aaa": false, "3-ccc": "missing"}
+		if d := testy.DiffInterface(expected, revsOf(t, []string{"2-aaa", "3-ccc"})); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("missing document", func(t *testing.T) {
+		_, err := db.GetRevs(ctx, "bar", nil)
+		testy.StatusError(t, "missing", http.StatusNotFound, err)
+	})
+}
+
+func TestReplicateSelector(t *testing.T) {
+	ctx := context.Background()
+	source := newDB(t)
+	for id, kind := range map[string]string{"foo": "a", "bar": "b"} {
+		if _, err := source.Put(ctx, id, map[string]interface{}{"kind": kind}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client := source.Client()
+	if err := client.CreateDB(ctx, "target"); err != nil {
+		t.Fatal(err)
+	}
+	target := client.DB("target")
+	rep, err := kivik.Replicate(ctx, target, source, kivik.ReplicationOptions{
+		Selector: map[string]interface{}{"kind": "a"},
+	}.Options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rep.IsActive() {
+		if err := rep.Update(ctx); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := rep.Err(); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := target.AllDocs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for rows.Next() {
+		ids = append(ids, rows.ID())
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface([]string{"foo"}, ids); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestPurgeUpdatesSeq(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	rev, err := db.Put(ctx, "foo", map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, err := db.Stats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updatesCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	updates, err := db.Client().DBUpdates(updatesCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer updates.Close() // nolint: errcheck
+	if _, err := db.Purge(ctx, map[string][]string{"foo": {rev}}); err != nil {
+		t.Fatal(err)
+	}
+	if !updates.Next() {
+		t.Fatal(updates.Err())
+	}
+	if got := updates.DBName() + ":" + updates.Type(); got != "db:updated" {
+		t.Errorf("Unexpected update: %s", got)
+	}
+	after, err := db.Stats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before.UpdateSeq == after.UpdateSeq {
+		t.Errorf("Expected the update sequence to advance from %s", before.UpdateSeq)
+	}
+}
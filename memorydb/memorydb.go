@@ -0,0 +1,201 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package memorydb provides an in-memory Kivik driver, intended primarily for
+// use in tests.
+//
+// Importing this package registers the driver under the name "memory":
+//
+//	import _ "github.com/go-kivik/kivik/v4/memorydb"
+//
+//	client, err := kivik.New("memory", "")
+//
+// Each client created with an empty data source name has its own private set
+// of databases. Clients created with the same non-empty data source name share
+// the same databases, for the life of the process.
+//
+// The driver stores full revision trees, so conflicts, replication with
+// new_edits=false, _revs_diff and purging behave as they do in CouchDB.
+// Views are not supported. DB.Find works through kivik's client-side
+// emulation.
+package memorydb
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"sort"
+	"sync"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+func init() {
+	kivik.Register("memory", &memDriver{})
+}
+
+type memDriver struct {
+	mu      sync.Mutex
+	servers map[string]*server
+}
+
+var _ driver.Driver = &memDriver{}
+
+func (d *memDriver) NewClient(name string, _ map[string]interface{}) (driver.Client, error) {
+	if name == "" {
+		return &client{server: newServer()}, nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.servers == nil {
+		d.servers = make(map[string]*server)
+	}
+	s, ok := d.servers[name]
+	if !ok {
+		s = newServer()
+		d.servers[name] = s
+	}
+	return &client{server: s}, nil
+}
+
+// server holds the databases shared by all clients with the same data source
+// name.
+type server struct {
+	mu        sync.RWMutex
+	dbs       map[string]*database
+	updates   map[string]dbUpdate
+	updateSeq int64
+	notifier
+}
+
+// dbUpdate is the latest event recorded for a database.
+type dbUpdate struct {
+	seq       int64
+	eventType string
+}
+
+func newServer() *server {
+	return &server{
+		dbs:      make(map[string]*database),
+		updates:  make(map[string]dbUpdate),
+		notifier: newNotifier(),
+	}
+}
+
+// dbUpdated records an event in the _db_updates feed. As with CouchDB, only
+// the latest event for each database is retained. The caller must hold s.mu.
+func (s *server) dbUpdated(dbName, eventType string) {
+	s.updateSeq++
+	s.updates[dbName] = dbUpdate{seq: s.updateSeq, eventType: eventType}
+	s.notify()
+}
+
+func (s *server) db(dbName string) (*database, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	db, ok := s.dbs[dbName]
+	if !ok {
+		return nil, errDBNotFound
+	}
+	return db, nil
+}
+
+type client struct {
+	server *server
+}
+
+var (
+	_ driver.Client               = &client{}
+	_ driver.DBsStatser           = &client{}
+	_ driver.DBUpdaterWithOptions = &client{}
+	_ driver.Pinger               = &client{}
+)
+
+func (c *client) Version(_ context.Context) (*driver.Version, error) {
+	return &driver.Version{
+		Version: kivik.KivikVersion,
+		Vendor:  "Kivik Memory Driver",
+	}, nil
+}
+
+func (c *client) AllDBs(_ context.Context, _ map[string]interface{}) ([]string, error) {
+	c.server.mu.RLock()
+	defer c.server.mu.RUnlock()
+	dbs := make([]string, 0, len(c.server.dbs))
+	for name := range c.server.dbs {
+		dbs = append(dbs, name)
+	}
+	sort.Strings(dbs)
+	return dbs, nil
+}
+
+func (c *client) DBExists(_ context.Context, dbName string, _ map[string]interface{}) (bool, error) {
+	_, err := c.server.db(dbName)
+	return err == nil, nil
+}
+
+var validDBName = regexp.MustCompile(`^[a-z][a-z0-9_$()+/-]*$`)
+
+// systemDBs are the names beginning with an underscore, which CouchDB
+// permits.
+var systemDBs = map[string]bool{
+	"_users":          true,
+	"_replicator":     true,
+	"_global_changes": true,
+}
+
+func (c *client) CreateDB(_ context.Context, dbName string, _ map[string]interface{}) error {
+	if !validDBName.MatchString(dbName) && !systemDBs[dbName] {
+		return &kivik.Error{HTTPStatus: http.StatusBadRequest, Message: "Name: '" + dbName + "'. Only lowercase characters (a-z), digits (0-9), and any of the characters _, $, (, ), +, -, and / are allowed. Must begin with a letter."}
+	}
+	c.server.mu.Lock()
+	defer c.server.mu.Unlock()
+	if _, ok := c.server.dbs[dbName]; ok {
+		return &kivik.Error{HTTPStatus: http.StatusPreconditionFailed, Message: "The database could not be created, the file already exists."}
+	}
+	c.server.dbs[dbName] = newDatabase(dbName, c.server)
+	c.server.dbUpdated(dbName, "created")
+	return nil
+}
+
+func (c *client) DestroyDB(_ context.Context, dbName string, _ map[string]interface{}) error {
+	c.server.mu.Lock()
+	defer c.server.mu.Unlock()
+	if _, ok := c.server.dbs[dbName]; !ok {
+		return errDBNotFound
+	}
+	delete(c.server.dbs, dbName)
+	c.server.dbUpdated(dbName, "deleted")
+	return nil
+}
+
+func (c *client) DB(dbName string, _ map[string]interface{}) (driver.DB, error) {
+	return &db{server: c.server, name: dbName}, nil
+}
+
+// DBsStats returns nil for any database which does not exist.
+func (c *client) DBsStats(ctx context.Context, dbNames []string) ([]*driver.DBStats, error) {
+	stats := make([]*driver.DBStats, len(dbNames))
+	for i, dbName := range dbNames {
+		d, err := c.server.db(dbName)
+		if err != nil {
+			continue
+		}
+		stats[i] = d.stats()
+	}
+	return stats, nil
+}
+
+func (c *client) Ping(_ context.Context) (bool, error) {
+	return true, nil
+}
@@ -0,0 +1,223 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// newDB returns a new, empty database on a private server.
+func newDB(t *testing.T) *kivik.DB {
+	t.Helper()
+	client, err := kivik.New("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.CreateDB(context.Background(), "db"); err != nil {
+		t.Fatal(err)
+	}
+	return client.DB("db")
+}
+
+func TestCreateDB(t *testing.T) {
+	type tt struct {
+		dbName string
+		status int
+		err    string
+	}
+	tests := testy.NewTable()
+	tests.Add("success", tt{dbName: "foo"})
+	tests.Add("system db", tt{dbName: "_users"})
+	tests.Add("invalid name", tt{
+		dbName: "Foo",
+		status: http.StatusBadRequest,
+		err:    "Name: 'Foo'. Only lowercase characters (a-z), digits (0-9), and any of the characters _, $, (, ), +, -, and / are allowed. Must begin with a letter.",
+	})
+	tests.Add("already exists", tt{
+		dbName: "exists",
+		status: http.StatusPreconditionFailed,
+		err:    "The database could not be created, the file already exists.",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		client, err := kivik.New("memory", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx := context.Background()
+		if err := client.CreateDB(ctx, "exists"); err != nil {
+			t.Fatal(err)
+		}
+		err = client.CreateDB(ctx, tt.dbName)
+		testy.StatusError(t, tt.err, tt.status, err)
+	})
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	client, err := kivik.New("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"b", "a"} {
+		if err := client.CreateDB(ctx, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Run("AllDBs", func(t *testing.T) {
+		dbs, err := client.AllDBs(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface([]string{"a", "b"}, dbs); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("DBsStats", func(t *testing.T) {
+		stats, err := client.DBsStats(ctx, []string{"a", "missing"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(stats) != 2 || stats[0].Name != "a" || stats[1] != nil {
+			t.Errorf("Unexpected stats: %v", stats)
+		}
+	})
+	t.Run("DestroyDB", func(t *testing.T) {
+		if err := client.DestroyDB(ctx, "b"); err != nil {
+			t.Fatal(err)
+		}
+		if exists, _ := client.DBExists(ctx, "b"); exists {
+			t.Error("b should no longer exist")
+		}
+		err := client.DestroyDB(ctx, "b")
+		testy.StatusError(t, "Database does not exist.", http.StatusNotFound, err)
+	})
+	t.Run("missing db", func(t *testing.T) {
+		err := client.DB("missing").Get(ctx, "foo").Err
+		testy.StatusError(t, "Database does not exist.", http.StatusNotFound, err)
+	})
+}
+
+func TestSharedServer(t *testing.T) {
+	ctx := context.Background()
+	a, err := kivik.New("memory", "TestSharedServer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := kivik.New("memory", "TestSharedServer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.CreateDB(ctx, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	defer a.DestroyDB(ctx, "foo") // nolint: errcheck
+	if exists, _ := b.DBExists(ctx, "foo"); !exists {
+		t.Error("Clients with the same DSN should share databases")
+	}
+	c, err := kivik.New("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists, _ := c.DBExists(ctx, "foo"); exists {
+		t.Error("Clients with an empty DSN should not share databases")
+	}
+}
+
+func TestDBUpdates(t *testing.T) {
+	ctx := context.Background()
+	client, err := kivik.New("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.CreateDB(ctx, "before"); err != nil {
+		t.Fatal(err)
+	}
+	updates, err := client.DBUpdates(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer updates.Close() // nolint: errcheck
+	if err := client.CreateDB(ctx, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	if updates.Next() {
+		got = append(got, updates.DBName()+":"+updates.Type())
+	}
+	if _, err := client.DB("foo").Put(ctx, "bar", map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if updates.Next() {
+		got = append(got, updates.DBName()+":"+updates.Type())
+	}
+	if d := testy.DiffInterface([]string{"foo:created", "foo:updated"}, got); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestDBUpdatesCoalesced(t *testing.T) {
+	ctx := context.Background()
+	c := &client{server: newServer()}
+	if err := c.CreateDB(ctx, "foo", nil); err != nil {
+		t.Fatal(err)
+	}
+	d, _ := c.DB("foo", nil)
+	for i := 0; i < 5; i++ {
+		if _, err := d.Put(ctx, fmt.Sprintf("doc%d", i), map[string]string{}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(c.server.updates); n != 1 {
+		t.Errorf("Expected 1 retained update, got %d", n)
+	}
+	updates, err := c.DBUpdates(ctx, map[string]interface{}{"feed": "normal", "since": "0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer updates.Close() // nolint: errcheck
+	var got []driver.DBUpdate
+	var update driver.DBUpdate
+	for updates.Next(&update) == nil {
+		got = append(got, update)
+	}
+	expected := []driver.DBUpdate{{DBName: "foo", Type: "updated", Seq: "6"}}
+	if d := testy.DiffInterface(expected, got); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestSecurity(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	sec := &kivik.Security{Admins: kivik.Members{Names: []string{"bob"}}}
+	if err := db.SetSecurity(ctx, sec); err != nil {
+		t.Fatal(err)
+	}
+	sec.Admins.Names[0] = "alice"
+	got, err := db.Security(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface([]string{"bob"}, got.Admins.Names); d != nil {
+		t.Error(d)
+	}
+}
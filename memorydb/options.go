@@ -0,0 +1,178 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+var (
+	errDBNotFound = &kivik.Error{HTTPStatus: http.StatusNotFound, Message: "Database does not exist."}
+	errMissing    = &kivik.Error{HTTPStatus: http.StatusNotFound, Message: "missing"}
+	errDeleted    = &kivik.Error{HTTPStatus: http.StatusNotFound, Message: "deleted"}
+	errConflict   = &kivik.Error{HTTPStatus: http.StatusConflict, Message: "Document update conflict."}
+	errViews      = &kivik.Error{HTTPStatus: http.StatusNotImplemented, Message: "memorydb: views are not supported"}
+)
+
+func badRequest(format string, args ...interface{}) error {
+	return &kivik.Error{HTTPStatus: http.StatusBadRequest, Message: fmt.Sprintf(format, args...)}
+}
+
+func boolOpt(opts map[string]interface{}, key string) (bool, error) {
+	switch t := opts[key].(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return t, nil
+	case string:
+		b, err := strconv.ParseBool(t)
+		if err != nil {
+			return false, badRequest("invalid value for %s: %q", key, t)
+		}
+		return b, nil
+	default:
+		return false, badRequest("invalid value for %s: %v", key, t)
+	}
+}
+
+// intOpt returns the value of an integer option, and whether it was set.
+func intOpt(opts map[string]interface{}, key string) (int64, bool, error) {
+	switch t := opts[key].(type) {
+	case nil:
+		return 0, false, nil
+	case int:
+		return int64(t), true, nil
+	case int64:
+		return t, true, nil
+	case float64:
+		return int64(t), true, nil
+	case json.Number:
+		i, err := t.Int64()
+		if err != nil {
+			return 0, false, badRequest("invalid value for %s: %q", key, t)
+		}
+		return i, true, nil
+	case string:
+		i, err := strconv.ParseInt(t, 10, 64)
+		if err != nil {
+			return 0, false, badRequest("invalid value for %s: %q", key, t)
+		}
+		return i, true, nil
+	default:
+		return 0, false, badRequest("invalid value for %s: %v", key, t)
+	}
+}
+
+// stringOpt returns the value of a string option, and whether it was set.
+// JSON-encoded strings are accepted, as passed by some clients for key
+// options.
+func stringOpt(opts map[string]interface{}, keys ...string) (string, bool, error) {
+	for _, key := range keys {
+		switch t := opts[key].(type) {
+		case nil:
+			continue
+		case string:
+			return t, true, nil
+		case json.RawMessage:
+			var s string
+			if err := json.Unmarshal(t, &s); err != nil {
+				return "", false, badRequest("invalid value for %s: %s", key, t)
+			}
+			return s, true, nil
+		default:
+			return "", false, badRequest("invalid value for %s: %v", key, t)
+		}
+	}
+	return "", false, nil
+}
+
+// stringsOpt returns the value of a string list option, such as keys or
+// doc_ids, and whether it was set.
+func stringsOpt(opts map[string]interface{}, key string) ([]string, bool, error) {
+	switch t := opts[key].(type) {
+	case nil:
+		return nil, false, nil
+	case []string:
+		return t, true, nil
+	case []interface{}:
+		strs := make([]string, len(t))
+		for i, v := range t {
+			s, ok := v.(string)
+			if !ok {
+				return nil, false, badRequest("invalid value for %s: %v", key, t)
+			}
+			strs[i] = s
+		}
+		return strs, true, nil
+	case json.RawMessage:
+		var strs []string
+		if err := json.Unmarshal(t, &strs); err != nil {
+			return nil, false, badRequest("invalid value for %s: %s", key, t)
+		}
+		return strs, true, nil
+	default:
+		return nil, false, badRequest("invalid value for %s: %v", key, t)
+	}
+}
+
+func formatSeq(seq int64) string {
+	return strconv.FormatInt(seq, 10)
+}
+
+// seqOpt parses the since option of a changes feed. "now" is converted to
+// now, and values beyond now are clamped to it.
+func seqOpt(opts map[string]interface{}, now int64) (int64, error) {
+	if s, ok := opts["since"].(string); ok {
+		switch s {
+		case "now":
+			return now, nil
+		case "":
+			return 0, nil
+		}
+	}
+	seq, _, err := intOpt(opts, "since")
+	switch {
+	case err != nil:
+		return 0, err
+	case seq < 0:
+		return 0, nil
+	case seq > now:
+		return now, nil
+	}
+	return seq, nil
+}
+
+// notifier allows iterators to wait for updates. The owner must hold its lock
+// when calling wait or notify.
+type notifier struct {
+	ch chan struct{}
+}
+
+func newNotifier() notifier {
+	return notifier{ch: make(chan struct{})}
+}
+
+// wait returns a channel which is closed on the next update.
+func (n *notifier) wait() <-chan struct{} {
+	return n.ch
+}
+
+func (n *notifier) notify() {
+	close(n.ch)
+	n.ch = make(chan struct{})
+}
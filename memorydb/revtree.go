@@ -0,0 +1,178 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// revision is a single node in a document's revision tree.
+type revision struct {
+	pos    int64
+	hash   string
+	parent *revision
+	// children is the number of child revisions. A revision with no children
+	// is a leaf.
+	children int
+	// missing is true for ancestors whose content is not known, as created
+	// when storing a revision with new_edits=false.
+	missing     bool
+	deleted     bool
+	body        map[string]interface{}
+	attachments map[string]*attachment
+	// seq is the update sequence at which the revision was stored.
+	seq int64
+}
+
+func (r *revision) String() string {
+	return fmt.Sprintf("%d-%s", r.pos, r.hash)
+}
+
+// history returns r and its known ancestors, newest first.
+func (r *revision) history() []*revision {
+	var revs []*revision
+	for ; r != nil; r = r.parent {
+		revs = append(revs, r)
+	}
+	return revs
+}
+
+func parseRev(rev string) (int64, string, error) {
+	parts := strings.SplitN(rev, "-", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return 0, "", badRequest("Invalid rev format")
+	}
+	pos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || pos < 1 {
+		return 0, "", badRequest("Invalid rev format")
+	}
+	return pos, parts[1], nil
+}
+
+// newRevHash returns a deterministic hash for a new revision.
+func newRevHash(parent *revision, deleted bool, body map[string]interface{}, atts map[string]*attachment) string {
+	var parentRev string
+	if parent != nil {
+		parentRev = parent.String()
+	}
+	digests := make(map[string]string, len(atts))
+	for name, att := range atts {
+		digests[name] = att.digest
+	}
+	data, _ := json.Marshal([]interface{}{parentRev, deleted, body, digests})
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// document is a document and its revision tree.
+type document struct {
+	id   string
+	revs map[string]*revision
+	// seq is the update sequence of the latest change to the document.
+	seq int64
+}
+
+func newDocument(id string) *document {
+	return &document{id: id, revs: make(map[string]*revision)}
+}
+
+// add adds rev to the tree, as a child of its parent.
+func (d *document) add(rev *revision) {
+	if rev.parent != nil {
+		rev.parent.children++
+	}
+	d.revs[rev.String()] = rev
+}
+
+// leaves returns the leaf revisions of the document, winning revision first,
+// in the order used by CouchDB to choose a winner: non-deleted revisions
+// first, then by highest position, then by highest hash.
+func (d *document) leaves() []*revision {
+	leaves := make([]*revision, 0, 1)
+	for _, rev := range d.revs {
+		if rev.children == 0 {
+			leaves = append(leaves, rev)
+		}
+	}
+	sort.Slice(leaves, func(i, j int) bool {
+		a, b := leaves[i], leaves[j]
+		if a.deleted != b.deleted {
+			return !a.deleted
+		}
+		if a.pos != b.pos {
+			return a.pos > b.pos
+		}
+		return a.hash > b.hash
+	})
+	return leaves
+}
+
+func (d *document) winner() *revision {
+	return d.leaves()[0]
+}
+
+// conflicts returns the non-deleted leaves other than the winner, and the
+// deleted leaves other than the winner.
+func (d *document) conflicts() (conflicts, deleted []string) {
+	for _, rev := range d.leaves()[1:] {
+		if rev.deleted {
+			deleted = append(deleted, rev.String())
+		} else {
+			conflicts = append(conflicts, rev.String())
+		}
+	}
+	return conflicts, deleted
+}
+
+// addPath adds a revision with its ancestry, as provided by a document's
+// _revisions field, with new_edits=false. Ancestors which are not already
+// known are stored as missing. It returns the new leaf, or nil if the
+// revision was already stored.
+func (d *document) addPath(start int64, ids []string) (*revision, error) {
+	if len(ids) == 0 || start < int64(len(ids)) {
+		return nil, badRequest("Invalid _revisions")
+	}
+	if existing, ok := d.revs[fmt.Sprintf("%d-%s", start, ids[0])]; ok && !existing.missing {
+		return nil, nil
+	}
+	var parent *revision
+	for i := len(ids) - 1; i >= 0; i-- {
+		pos := start - int64(i)
+		key := fmt.Sprintf("%d-%s", pos, ids[i])
+		rev, ok := d.revs[key]
+		if !ok {
+			rev = &revision{pos: pos, hash: ids[i], parent: parent, missing: true}
+			d.add(rev)
+		}
+		parent = rev
+	}
+	return parent, nil
+}
+
+// remove removes the leaf rev, and any ancestors which are not shared with
+// another branch.
+func (d *document) remove(rev *revision) {
+	for rev != nil && rev.children == 0 {
+		delete(d.revs, rev.String())
+		rev = rev.parent
+		if rev != nil {
+			rev.children--
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package metrics records metrics about Kivik driver method calls.
+//
+// Metrics are recorded by passing the middleware to kivik.New, with a
+// Collector which forwards them to the metrics system of your choice:
+//
+//  client, err := kivik.New("couch", dsn, metrics.Middleware(collector))
+//
+// See the kivikprom package for a Prometheus collector.
+package metrics
+
+import (
+	"context"
+	"time"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// Labels identify the driver method call a metric refers to.
+type Labels struct {
+	// Driver is the name of the driver, as passed to kivik.New.
+	Driver string
+	// Method is the name of the driver method, such as "Get" or "Changes".
+	Method string
+}
+
+// Collector receives metrics from the middleware. Implementations must be
+// safe for concurrent use.
+type Collector interface {
+	// ObserveLatency records the duration of a driver method call. For
+	// methods which return an iterator, this is the time taken to return the
+	// iterator, not to read it.
+	ObserveLatency(labels Labels, d time.Duration)
+	// IncErrors records a failed driver method call, or iterator, by the HTTP
+	// status of the error, as returned by kivik.StatusCode.
+	IncErrors(labels Labels, status int)
+	// ObserveRows records the number of items read from an iterator, once it
+	// has been closed.
+	ObserveRows(labels Labels, count int64)
+	// AddOpenIterators adjusts the number of open iterators by delta, which
+	// is 1 when an iterator is returned, and -1 when it is closed.
+	AddOpenIterators(labels Labels, delta int)
+}
+
+// Middleware returns an option which enables metrics collection, when passed
+// to kivik.New.
+func Middleware(c Collector) kivik.Options {
+	return kivik.WithMiddleware(Interceptor(c))
+}
+
+// Interceptor returns a driver.Interceptor which reports metrics to c.
+func Interceptor(c Collector) driver.Interceptor {
+	return func(ctx context.Context, call *driver.Call, invoke driver.Invoker) error {
+		labels := Labels{Driver: call.Driver, Method: call.Method}
+		start := time.Now()
+		err := invoke(ctx)
+		c.ObserveLatency(labels, time.Since(start))
+		if err != nil {
+			c.IncErrors(labels, kivik.StatusCode(err))
+			return err
+		}
+		if call.Iterator {
+			c.AddOpenIterators(labels, 1)
+			call.OnIteratorClose(func(count int64, err error) {
+				c.AddOpenIterators(labels, -1)
+				c.ObserveRows(labels, count)
+				if err != nil {
+					c.IncErrors(labels, kivik.StatusCode(err))
+				}
+			})
+		}
+		return nil
+	}
+}
@@ -0,0 +1,147 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+type testCollector struct {
+	mu        sync.Mutex
+	latencies map[Labels]int
+	errors    map[Labels]map[int]int
+	rows      map[Labels]int64
+	open      map[Labels]int
+}
+
+var _ Collector = &testCollector{}
+
+func newTestCollector() *testCollector {
+	return &testCollector{
+		latencies: map[Labels]int{},
+		errors:    map[Labels]map[int]int{},
+		rows:      map[Labels]int64{},
+		open:      map[Labels]int{},
+	}
+}
+
+func (c *testCollector) ObserveLatency(labels Labels, _ time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.latencies[labels]++
+}
+
+func (c *testCollector) IncErrors(labels Labels, status int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.errors[labels] == nil {
+		c.errors[labels] = map[int]int{}
+	}
+	c.errors[labels][status]++
+}
+
+func (c *testCollector) ObserveRows(labels Labels, count int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rows[labels] += count
+}
+
+func (c *testCollector) AddOpenIterators(labels Labels, delta int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.open[labels] += delta
+}
+
+func init() {
+	kivik.Register("metricstest", &mock.Driver{
+		NewClientFunc: func(_ string, _ map[string]interface{}) (driver.Client, error) {
+			return &mock.Client{
+				DBFunc: func(_ string, _ map[string]interface{}) (driver.DB, error) {
+					return &mock.DB{
+						GetFunc: func(_ context.Context, _ string, _ map[string]interface{}) (*driver.Document, error) {
+							return nil, &kivik.Error{HTTPStatus: http.StatusNotFound, Message: "missing"}
+						},
+						ChangesFunc: func(_ context.Context, _ map[string]interface{}) (driver.Changes, error) {
+							changes := 2
+							return &mock.Changes{
+								NextFunc: func(c *driver.Change) error {
+									if changes == 0 {
+										return io.EOF
+									}
+									changes--
+									c.ID = "foo"
+									return nil
+								},
+								CloseFunc: func() error { return nil },
+							}, nil
+						},
+					}, nil
+				},
+			}, nil
+		},
+	})
+}
+
+func TestInterceptor(t *testing.T) {
+	c := newTestCollector()
+	client, err := kivik.New("metricstest", "", Middleware(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	db := client.DB("foo")
+
+	t.Run("error", func(t *testing.T) {
+		err := db.Get(ctx, "bar").Err
+		testy.StatusError(t, "missing", http.StatusNotFound, err)
+		get := Labels{Driver: "metricstest", Method: "Get"}
+		if n := c.latencies[get]; n != 1 {
+			t.Errorf("Unexpected latency observations: %d", n)
+		}
+		if d := testy.DiffInterface(map[int]int{http.StatusNotFound: 1}, c.errors[get]); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("iterator", func(t *testing.T) {
+		changes := Labels{Driver: "metricstest", Method: "Changes"}
+		feed, err := db.Changes(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := c.open[changes]; n != 1 {
+			t.Errorf("Expected 1 open iterator, got %d", n)
+		}
+		for feed.Next() {
+		}
+		if err := feed.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if n := c.open[changes]; n != 0 {
+			t.Errorf("Expected 0 open iterators, got %d", n)
+		}
+		if n := c.rows[changes]; n != 2 {
+			t.Errorf("Unexpected row count: %d", n)
+		}
+	})
+}
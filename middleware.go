@@ -0,0 +1,41 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import "github.com/go-kivik/kivik/v4/driver"
+
+// middlewareKey is the option key used by WithMiddleware. It is never passed
+// on to the driver.
+const middlewareKey = "kivik:middleware"
+
+// WithMiddleware returns an option which, when passed to New, wraps the
+// driver's Client, and every DB it returns, so that each driver method call
+// passes through interceptors, in order. WithMiddleware may be passed more
+// than once, in which case the interceptors are chained in the order given.
+//
+// The wrapped driver continues to expose every optional interface implemented
+// by the underlying driver. See driver.Wrap for details.
+func WithMiddleware(interceptors ...driver.Interceptor) Options {
+	return Options{middlewareKey: interceptors}
+}
+
+// middleware collects the interceptors from all options passed to New.
+func middleware(options []Options) []driver.Interceptor {
+	var interceptors []driver.Interceptor
+	for _, opts := range options {
+		if i, ok := opts[middlewareKey].([]driver.Interceptor); ok {
+			interceptors = append(interceptors, i...)
+		}
+	}
+	return interceptors
+}
@@ -0,0 +1,126 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+// middlewareTestOpts records the options passed to the middlewaretest driver.
+var middlewareTestOpts map[string]interface{}
+
+func init() {
+	Register("middlewaretest", &mock.Driver{
+		NewClientFunc: func(_ string, opts map[string]interface{}) (driver.Client, error) {
+			middlewareTestOpts = opts
+			return &mock.Client{
+				VersionFunc: func(_ context.Context) (*driver.Version, error) {
+					return &driver.Version{Version: "1.2.3"}, nil
+				},
+				DBFunc: func(_ string, _ map[string]interface{}) (driver.DB, error) {
+					return &mock.DB{
+						GetFunc: func(_ context.Context, _ string, _ map[string]interface{}) (*driver.Document, error) {
+							return &driver.Document{
+								ContentLength: 13,
+								Rev:           "1-xxx",
+								Body:          ioutil.NopCloser(strings.NewReader(`{"_rev":"1-xxx"}`)),
+							}, nil
+						},
+						PutFunc: func(_ context.Context, docID string, _ interface{}, _ map[string]interface{}) (string, error) {
+							return "1-" + docID, nil
+						},
+					}, nil
+				},
+			}, nil
+		},
+	})
+}
+
+func TestWithMiddleware(t *testing.T) {
+	var methods []string
+	recorder := func(prefix string) driver.Interceptor {
+		return func(ctx context.Context, call *driver.Call, invoke driver.Invoker) error {
+			methods = append(methods, prefix+call.Method)
+			return invoke(ctx)
+		}
+	}
+	client, err := New("middlewaretest", "",
+		Options{"foo": "bar"},
+		WithMiddleware(recorder("a:")),
+		WithMiddleware(recorder("b:")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	t.Run("options", func(t *testing.T) {
+		if d := testy.DiffInterface(map[string]interface{}{"foo": "bar"}, middlewareTestOpts); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("Ping falls back to Version", func(t *testing.T) {
+		methods = nil
+		up, err := client.Ping(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !up {
+			t.Error("Expected server to be up")
+		}
+		if d := testy.DiffInterface([]string{"a:Version", "b:Version"}, methods); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("GetMeta falls back to Get", func(t *testing.T) {
+		methods = nil
+		size, rev, err := client.DB("foo").GetMeta(ctx, "bar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size != 13 || rev != "1-xxx" {
+			t.Errorf("Unexpected result: size=%d, rev=%s", size, rev)
+		}
+		if d := testy.DiffInterface([]string{"a:Get", "b:Get"}, methods); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("BulkDocs falls back to Put", func(t *testing.T) {
+		results, err := client.DB("foo").BulkDocs(ctx, []interface{}{map[string]string{"_id": "bar"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !results.Next() {
+			t.Fatal(results.Err())
+		}
+		if results.ID() != "bar" || results.Rev() != "1-bar" {
+			t.Errorf("Unexpected result: %s %s", results.ID(), results.Rev())
+		}
+	})
+	t.Run("Close", func(t *testing.T) {
+		if err := client.DB("foo").Close(ctx); err != nil {
+			t.Error(err)
+		}
+		if err := client.Close(ctx); err != nil {
+			t.Error(err)
+		}
+	})
+}
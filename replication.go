@@ -47,50 +47,64 @@ type Replication struct {
 	Source string
 	Target string
 
-	infoMU    sync.RWMutex
-	info      *driver.ReplicationInfo
-	statusErr error
-	irep      driver.Replication
+	// updateMU serializes calls to irep.Update.
+	updateMU sync.Mutex
+
+	infoMU sync.RWMutex
+	info   *driver.ReplicationInfo
+	irep   driver.Replication
 }
 
 // DocsWritten returns the number of documents written, if known.
 func (r *Replication) DocsWritten() int64 {
-	if r != nil && r.info != nil {
-		r.infoMU.RLock()
-		defer r.infoMU.RUnlock()
-		return r.info.DocsWritten
+	if r == nil {
+		return 0
+	}
+	r.infoMU.RLock()
+	defer r.infoMU.RUnlock()
+	if r.info == nil {
+		return 0
 	}
-	return 0
+	return r.info.DocsWritten
 }
 
 // DocsRead returns the number of documents read, if known.
 func (r *Replication) DocsRead() int64 {
-	if r != nil && r.info != nil {
-		r.infoMU.RLock()
-		defer r.infoMU.RUnlock()
-		return r.info.DocsRead
+	if r == nil {
+		return 0
+	}
+	r.infoMU.RLock()
+	defer r.infoMU.RUnlock()
+	if r.info == nil {
+		return 0
 	}
-	return 0
+	return r.info.DocsRead
 }
 
 // DocWriteFailures returns the number of doc write failures, if known.
 func (r *Replication) DocWriteFailures() int64 {
-	if r != nil && r.info != nil {
-		r.infoMU.RLock()
-		defer r.infoMU.RUnlock()
-		return r.info.DocWriteFailures
+	if r == nil {
+		return 0
+	}
+	r.infoMU.RLock()
+	defer r.infoMU.RUnlock()
+	if r.info == nil {
+		return 0
 	}
-	return 0
+	return r.info.DocWriteFailures
 }
 
 // Progress returns the current replication progress, if known.
 func (r *Replication) Progress() float64 {
-	if r != nil && r.info != nil {
-		r.infoMU.RLock()
-		defer r.infoMU.RUnlock()
-		return r.info.Progress
+	if r == nil {
+		return 0
 	}
-	return 0
+	r.infoMU.RLock()
+	defer r.infoMU.RUnlock()
+	if r.info == nil {
+		return 0
+	}
+	return r.info.Progress
 }
 
 func newReplication(rep driver.Replication) *Replication {
@@ -153,11 +167,16 @@ func (r *Replication) Delete(ctx context.Context) error {
 // Update requests a replication state update from the server. If there is an
 // error retrieving the update, it is returned and the replication state is
 // unaltered.
+//
+// Update is safe to call concurrently with the other methods of Replication,
+// including Wait and Watch.
 func (r *Replication) Update(ctx context.Context) error {
 	var info driver.ReplicationInfo
-	r.statusErr = r.irep.Update(ctx, &info)
-	if r.statusErr != nil {
-		return r.statusErr
+	r.updateMU.Lock()
+	err := r.irep.Update(ctx, &info)
+	r.updateMU.Unlock()
+	if err != nil {
+		return err
 	}
 	r.infoMU.Lock()
 	r.info = &info
@@ -165,6 +184,125 @@ func (r *Replication) Update(ctx context.Context) error {
 	return nil
 }
 
+// replicationPollInterval is the interval at which Wait polls for updates.
+var replicationPollInterval = time.Second
+
+// Wait blocks until the replication reaches a terminal state, polling the
+// server for updates once per second. It returns nil if the replication
+// completed, or otherwise the error which stopped it. If ctx is cancelled, or
+// an update fails, Wait returns that error instead.
+//
+// Unlike IsActive, Wait does not treat the crashing and error states as
+// terminal, as CouchDB retries replications in those states. Only the
+// completed and failed states end the wait, except for replications started
+// by Replicate, which are not retried, and so also end in the error state.
+func (r *Replication) Wait(ctx context.Context) error {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+		if err := r.Update(ctx); err != nil {
+			return err
+		}
+		if r.finished() {
+			return r.terminalErr()
+		}
+		timer.Reset(replicationPollInterval)
+	}
+}
+
+// finished returns true if the replication has reached a state from which it
+// will not progress further.
+func (r *Replication) finished() bool {
+	switch r.State() {
+	case ReplicationComplete, ReplicationFailed:
+		return true
+	case ReplicationError:
+		_, local := r.irep.(*localReplication)
+		return local
+	}
+	return false
+}
+
+// terminalErr returns the error which stopped a finished replication.
+func (r *Replication) terminalErr() error {
+	state := r.State()
+	if state == ReplicationComplete {
+		return nil
+	}
+	if err := r.Err(); err != nil {
+		return err
+	}
+	return &Error{HTTPStatus: http.StatusInternalServerError, Message: "kivik: replication stopped in state " + string(state)}
+}
+
+// ReplicationStatus is a snapshot of a replication's progress, as delivered
+// by Watch.
+type ReplicationStatus struct {
+	ReplicationInfo
+	State ReplicationState
+	// Err is the error which caused the replication to abort, or the error
+	// returned by the most recent update.
+	Err error
+}
+
+// Watch polls the server for updates at the given interval, and sends a
+// snapshot of the replication's progress on the returned channel after each
+// update. The channel is closed after the replication reaches a terminal
+// state, as for Wait, after an update fails, or when ctx is cancelled. In the first two
+// cases, the final snapshot describes the terminal state or the failure.
+//
+// The caller must either receive from the channel until it is closed, or
+// cancel ctx.
+func (r *Replication) Watch(ctx context.Context, interval time.Duration) <-chan ReplicationStatus {
+	ch := make(chan ReplicationStatus)
+	go func() {
+		defer close(ch)
+		timer := time.NewTimer(0)
+		defer timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			}
+			err := r.Update(ctx)
+			status := r.status()
+			if err != nil {
+				status.Err = err
+			}
+			select {
+			case ch <- status:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil || r.finished() {
+				return
+			}
+			timer.Reset(interval)
+		}
+	}()
+	return ch
+}
+
+// status returns a snapshot of the replication's current state.
+func (r *Replication) status() ReplicationStatus {
+	status := ReplicationStatus{
+		State: r.State(),
+		Err:   r.Err(),
+	}
+	r.infoMU.RLock()
+	defer r.infoMU.RUnlock()
+	if r.info != nil {
+		status.ReplicationInfo = ReplicationInfo(*r.info)
+	}
+	return status
+}
+
 var replicationNotImplemented = &Error{HTTPStatus: http.StatusNotImplemented, Message: "kivik: driver does not support replication"}
 
 // GetReplications returns a list of defined replications in the _replicator
@@ -190,12 +328,18 @@ func (c *Client) GetReplications(ctx context.Context, options ...Options) ([]*Re
 //
 // To use an object for either "source" or "target", pass the desired object
 // in options. This will override targetDSN and sourceDSN function parameters.
+//
+// Options may be given as free-form Options, or with ReplicationOptions.
 func (c *Client) Replicate(ctx context.Context, targetDSN, sourceDSN string, options ...Options) (*Replication, error) {
 	replicator, ok := c.driverClient.(driver.ClientReplicator)
 	if !ok {
 		return nil, replicationNotImplemented
 	}
-	rep, err := replicator.Replicate(ctx, targetDSN, sourceDSN, mergeOptions(options...))
+	opts, err := replicationOptions(targetDSN, sourceDSN, options)
+	if err != nil {
+		return nil, err
+	}
+	rep, err := replicator.Replicate(ctx, targetDSN, sourceDSN, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -17,6 +17,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"sync"
 	"testing"
 	"time"
 
@@ -452,3 +453,166 @@ func TestReplicate(t *testing.T) {
 		})
 	}
 }
+
+// progressingReplication returns a mock replication which advances through
+// states, one per call to Update. Its Err returns err.
+func progressingReplication(err error, states ...ReplicationState) *mock.Replication {
+	var mu sync.Mutex
+	var i int
+	return &mock.Replication{
+		UpdateFunc: func(_ context.Context, info *driver.ReplicationInfo) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if i < len(states)-1 {
+				i++
+			}
+			*info = driver.ReplicationInfo{DocsRead: int64(i), DocsWritten: int64(i)}
+			return nil
+		},
+		StateFunc: func() string {
+			mu.Lock()
+			defer mu.Unlock()
+			return string(states[i])
+		},
+		ErrFunc: func() error { return err },
+	}
+}
+
+func TestReplicationWait(t *testing.T) {
+	defer func(interval time.Duration) {
+		replicationPollInterval = interval
+	}(replicationPollInterval)
+	replicationPollInterval = time.Millisecond
+
+	type tst struct {
+		rep     *Replication
+		timeout time.Duration
+		status  int
+		err     string
+	}
+	tests := testy.NewTable()
+	tests.Add("completed", tst{
+		rep: &Replication{irep: progressingReplication(nil,
+			ReplicationNotStarted, ReplicationStarted, ReplicationRunning, ReplicationComplete)},
+	})
+	tests.Add("failed", tst{
+		rep: &Replication{irep: progressingReplication(errors.New("source not found"),
+			ReplicationStarted, ReplicationFailed)},
+		status: http.StatusInternalServerError,
+		err:    "source not found",
+	})
+	tests.Add("recovered from crashing", tst{
+		rep: &Replication{irep: progressingReplication(nil,
+			ReplicationStarted, ReplicationCrashing, ReplicationRunning, ReplicationComplete)},
+	})
+	tests.Add("recovered from error", tst{
+		rep: &Replication{irep: progressingReplication(errors.New("source unreachable"),
+			ReplicationStarted, ReplicationError, ReplicationComplete)},
+	})
+	tests.Add("failed without cause", tst{
+		rep:    &Replication{irep: progressingReplication(nil, ReplicationStarted, ReplicationFailed)},
+		status: http.StatusInternalServerError,
+		err:    "kivik: replication stopped in state failed",
+	})
+	tests.Add("local error", tst{
+		rep: &Replication{irep: &localReplication{
+			state: ReplicationError,
+			err:   &Error{HTTPStatus: http.StatusNotImplemented, Message: "not supported"},
+		}},
+		status: http.StatusNotImplemented,
+		err:    "not supported",
+	})
+	tests.Add("update error", tst{
+		rep: &Replication{irep: &mock.Replication{
+			UpdateFunc: func(_ context.Context, _ *driver.ReplicationInfo) error {
+				return &Error{HTTPStatus: http.StatusNotFound, Message: "missing"}
+			},
+		}},
+		status: http.StatusNotFound,
+		err:    "missing",
+	})
+	tests.Add("timeout", tst{
+		rep:     &Replication{irep: progressingReplication(nil, ReplicationRunning)},
+		timeout: 10 * time.Millisecond,
+		status:  http.StatusInternalServerError,
+		err:     "context deadline exceeded",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tst) {
+		ctx := context.Background()
+		if tt.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+			defer cancel()
+		}
+		err := tt.rep.Wait(ctx)
+		testy.StatusError(t, tt.err, tt.status, err)
+	})
+}
+
+func TestReplicationWatch(t *testing.T) {
+	t.Run("completed", func(t *testing.T) {
+		r := &Replication{irep: progressingReplication(nil, ReplicationStarted, ReplicationRunning, ReplicationComplete)}
+		var states []ReplicationState
+		var last ReplicationStatus
+		for status := range r.Watch(context.Background(), time.Millisecond) {
+			states = append(states, status.State)
+			last = status
+		}
+		expected := []ReplicationState{ReplicationRunning, ReplicationComplete}
+		if d := testy.DiffInterface(expected, states); d != nil {
+			t.Error(d)
+		}
+		if last.DocsRead != 2 || last.DocsWritten != 2 || last.Err != nil {
+			t.Errorf("Unexpected final status: %+v", last)
+		}
+	})
+	t.Run("update error", func(t *testing.T) {
+		r := &Replication{irep: &mock.Replication{
+			UpdateFunc: func(_ context.Context, _ *driver.ReplicationInfo) error {
+				return errors.New("update failed")
+			},
+			StateFunc: func() string { return string(ReplicationRunning) },
+			ErrFunc:   func() error { return nil },
+		}}
+		var statuses []ReplicationStatus
+		for status := range r.Watch(context.Background(), time.Millisecond) {
+			statuses = append(statuses, status)
+		}
+		if len(statuses) != 1 {
+			t.Fatalf("Expected 1 status, got %d", len(statuses))
+		}
+		testy.Error(t, "update failed", statuses[0].Err)
+	})
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		r := &Replication{irep: progressingReplication(nil, ReplicationRunning)}
+		ch := r.Watch(ctx, time.Millisecond)
+		<-ch
+		cancel()
+		for range ch {
+		}
+	})
+}
+
+func TestReplicationConcurrentUpdate(t *testing.T) {
+	r := &Replication{irep: progressingReplication(nil, ReplicationStarted, ReplicationRunning, ReplicationRunning, ReplicationComplete)}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := r.Watch(ctx, time.Millisecond)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				_ = r.Update(ctx)
+				_ = r.DocsRead() + r.DocsWritten() + r.DocWriteFailures()
+				_ = r.Progress()
+			}
+		}()
+	}
+	for range ch {
+	}
+	wg.Wait()
+}
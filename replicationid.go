@@ -0,0 +1,483 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"crypto/md5" // nolint:gosec
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-kivik/kivik/v4/internal/erlterm"
+)
+
+// ReplicationIDSpec describes a replication, for ReplicationID.
+type ReplicationIDSpec struct {
+	// ServerUUID is the UUID of the CouchDB server which runs the
+	// replication, as reported in the uuid field of GET /.
+	ServerUUID string
+	// Source and Target are the replication endpoint URLs.
+	Source string
+	Target string
+	// Options are the replication options. Of these, only SourceAuth,
+	// TargetAuth, Filter, QueryParams, DocIDs, Selector, Continuous and
+	// CreateTarget affect the replication ID.
+	Options ReplicationOptions
+	// FilterCode is the source of the filter function named by
+	// Options.Filter, as stored in the source design document. It is required
+	// only for user-defined filters.
+	FilterCode string
+}
+
+// ReplicationID returns the ID which CouchDB 2.x and 3.x assign to the
+// replication described by spec. This is the value of the _replication_id
+// field of the replicator document, and is returned by
+// Replication.ReplicationID. Two replications with the same ID are
+// duplicates, and CouchDB runs only one of them.
+//
+// The ID consists of an MD5 hash, followed by "+continuous" and
+// "+create_target" as appropriate. The hash alone names the checkpoint
+// documents, as returned by ReplicationCheckpointID.
+//
+// The calculation follows CouchDB's version 4 replication IDs. Query
+// parameters are hashed with their keys in sorted order, so for the IDs to
+// match, the query_params object in the replicator document must also have
+// sorted keys.
+func ReplicationID(spec ReplicationIDSpec) (string, error) {
+	if spec.ServerUUID == "" {
+		return "", missingArg("ServerUUID")
+	}
+	opts := spec.Options
+	if err := opts.Validate(); err != nil {
+		return "", err
+	}
+	source, err := replicationIDEndpoint(spec.Source, opts.SourceAuth)
+	if err != nil {
+		return "", err
+	}
+	target, err := replicationIDEndpoint(spec.Target, opts.TargetAuth)
+	if err != nil {
+		return "", err
+	}
+	base := erlterm.List{spec.ServerUUID, source, target}
+	switch {
+	case opts.Filter != "":
+		params, err := replicationIDQueryParams(opts.QueryParams)
+		if err != nil {
+			return "", err
+		}
+		if strings.HasPrefix(opts.Filter, "_") {
+			base = append(base, opts.Filter, params)
+			break
+		}
+		if !strings.Contains(opts.Filter, "/") {
+			return "", badReplicationOption("filter must be of the form ddoc/filter")
+		}
+		if spec.FilterCode == "" {
+			return "", missingArg("FilterCode")
+		}
+		base = append(base, trimFilterCode(spec.FilterCode), params)
+	case len(opts.DocIDs) > 0:
+		ids := make(erlterm.List, len(opts.DocIDs))
+		for i, id := range opts.DocIDs {
+			ids[i] = id
+		}
+		base = append(base, ids)
+	case opts.Selector != nil:
+		selector, err := replicationIDSelector(opts.Selector)
+		if err != nil {
+			return "", err
+		}
+		base = append(base, selector)
+	}
+	term, err := erlterm.Encode(base)
+	if err != nil {
+		return "", &Error{HTTPStatus: http.StatusBadRequest, Err: err}
+	}
+	sum := md5.Sum(term) // nolint:gosec
+	id := hex.EncodeToString(sum[:])
+	if opts.Continuous {
+		id += "+continuous"
+	}
+	if opts.CreateTarget {
+		id += "+create_target"
+	}
+	return id, nil
+}
+
+// ReplicationCheckpointID returns the ID of the _local document in which
+// CouchDB stores checkpoints for the replication with the given ID.
+func ReplicationCheckpointID(replicationID string) string {
+	if i := strings.IndexByte(replicationID, '+'); i >= 0 {
+		replicationID = replicationID[:i]
+	}
+	return localDocPrefix + replicationID
+}
+
+// replicationIDEndpoint returns the representation of a replication endpoint
+// used in the replication ID: {User, Host, Port, Path, Headers, undefined}.
+func replicationIDEndpoint(dsn string, auth *ReplicationAuth) (erlterm.Tuple, error) {
+	u, err := url.Parse(dsn)
+	if err != nil || u.Host == "" {
+		return nil, badReplicationOption("invalid replication endpoint URL: " + dsn)
+	}
+	var user interface{} = erlterm.Atom("undefined")
+	headers := map[string]string{}
+	var headerUser string
+	if auth != nil {
+		for k, v := range auth.Headers {
+			if strings.EqualFold(k, "Authorization") && strings.HasPrefix(v, "Basic ") {
+				if creds, err := base64.StdEncoding.DecodeString(v[len("Basic "):]); err == nil {
+					headerUser = strings.SplitN(string(creds), ":", 2)[0]
+					continue
+				}
+			}
+			headers[k] = v
+		}
+	}
+	switch {
+	case auth != nil && auth.Username != "":
+		user = erlterm.String(auth.Username)
+	case u.User != nil && u.User.Username() != "":
+		user = erlterm.String(u.User.Username())
+	case headerUser != "":
+		user = erlterm.String(headerUser)
+	}
+	var port interface{} = erlterm.Atom("default")
+	if p := u.Port(); p != "" {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, badReplicationOption("invalid replication endpoint URL: " + dsn)
+		}
+		if !isDefaultPort(u.Scheme, n) {
+			port = n
+		}
+	}
+	path := u.EscapedPath()
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return erlterm.Tuple{
+		user,
+		erlterm.String(u.Hostname()),
+		port,
+		erlterm.String(path),
+		replicationIDHeaders(headers),
+		erlterm.Atom("undefined"),
+	}, nil
+}
+
+func isDefaultPort(scheme string, port int) bool {
+	switch scheme {
+	case "http":
+		return port == 80 || port == 5984
+	case "https":
+		return port == 443
+	}
+	return false
+}
+
+// replicationIDHeaders returns headers as a sorted list of {Name, Value}
+// string tuples.
+func replicationIDHeaders(headers map[string]string) erlterm.List {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		if name == "Accept" && headers[name] == "application/json" {
+			// A default header, which CouchDB excludes.
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	list := make(erlterm.List, len(names))
+	for i, name := range names {
+		list[i] = erlterm.Tuple{erlterm.String(name), erlterm.String(headers[name])}
+	}
+	return list
+}
+
+func replicationIDQueryParams(params map[string]interface{}) (interface{}, error) {
+	if len(params) == 0 {
+		return erlterm.Object{}, nil
+	}
+	return toEJSON(params)
+}
+
+// filterCodeRE matches filter code as CouchDB trims it. As in CouchDB, code
+// spanning several lines does not match, and is left untrimmed.
+var filterCodeRE = regexp.MustCompile(`^\s*(.*?)\s*$`)
+
+func trimFilterCode(code string) string {
+	return filterCodeRE.ReplaceAllString(code, "$1")
+}
+
+// toEJSON converts v to CouchDB's internal representation of JSON.
+func toEJSON(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, &Error{HTTPStatus: http.StatusBadRequest, Err: err}
+	}
+	ejson, err := erlterm.DecodeJSON(data)
+	if err != nil {
+		return nil, &Error{HTTPStatus: http.StatusBadRequest, Err: err}
+	}
+	return ejson, nil
+}
+
+func replicationIDSelector(selector interface{}) (interface{}, error) {
+	ejson, err := toEJSON(selector)
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := ejson.(erlterm.Object)
+	if !ok {
+		return nil, badSelector("selector must be an object")
+	}
+	normalized, err := normalizeSelector(obj)
+	if err != nil {
+		return nil, err
+	}
+	return ejsort(normalized), nil
+}
+
+func badSelector(msg string) error {
+	return &Error{HTTPStatus: http.StatusBadRequest, Message: "kivik: invalid selector: " + msg}
+}
+
+// normalizeSelector normalizes a Mango selector the way CouchDB does before
+// hashing it: implicit $eq and $and operators are made explicit, nested
+// fields are flattened to dotted paths, and $nor is rewritten in terms of
+// $not.
+func normalizeSelector(sel erlterm.Object) (interface{}, error) {
+	ops, err := normOps(sel)
+	if err != nil {
+		return nil, err
+	}
+	normalized := normNegations(normFields(ops, ""))
+	if obj, ok := normalized.(erlterm.Object); ok {
+		for _, p := range obj {
+			if p.Key == "" {
+				return nil, badSelector("missing field name")
+			}
+		}
+	}
+	return normalized, nil
+}
+
+func single(key string, value interface{}) erlterm.Object {
+	return erlterm.Object{{Key: key, Value: value}}
+}
+
+// isCondObj returns true for a non-empty object, which is treated as a set of
+// conditions or subfields, rather than a value to match.
+func isCondObj(v interface{}) bool {
+	obj, ok := v.(erlterm.Object)
+	return ok && len(obj) > 0
+}
+
+func normOps(v interface{}) (interface{}, error) {
+	obj, ok := v.(erlterm.Object)
+	if !ok {
+		return nil, badSelector("expected an object")
+	}
+	switch len(obj) {
+	case 0:
+		return obj, nil
+	case 1:
+	default:
+		args := make([]interface{}, len(obj))
+		for i, p := range obj {
+			arg, err := normOps(erlterm.Object{p})
+			if err != nil {
+				return nil, err
+			}
+			args[i] = arg
+		}
+		return single("$and", args), nil
+	}
+	key, arg := obj[0].Key, obj[0].Value
+	switch key {
+	case "$and", "$or", "$nor":
+		list, ok := arg.([]interface{})
+		if !ok {
+			return nil, badSelector("bad argument to " + key)
+		}
+		args := make([]interface{}, len(list))
+		for i, a := range list {
+			var err error
+			if args[i], err = normOps(a); err != nil {
+				return nil, err
+			}
+		}
+		return single(key, args), nil
+	case "$not", "$elemMatch", "$allMatch", "$keyMapMatch":
+		if _, ok := arg.(erlterm.Object); !ok {
+			return nil, badSelector("bad argument to " + key)
+		}
+		norm, err := normOps(arg)
+		if err != nil {
+			return nil, err
+		}
+		return single(key, norm), nil
+	case "$in", "$nin", "$all":
+		if _, ok := arg.([]interface{}); !ok {
+			return nil, badSelector("bad argument to " + key)
+		}
+		return obj, nil
+	case "$size":
+		if n, ok := arg.(json.Number); !ok || strings.ContainsAny(string(n), ".eE-") {
+			return nil, badSelector("bad argument to " + key)
+		}
+		return obj, nil
+	case "$mod":
+		if list, ok := arg.([]interface{}); !ok || len(list) != 2 {
+			return nil, badSelector("bad argument to " + key)
+		}
+		return obj, nil
+	case "$exists":
+		if _, ok := arg.(bool); !ok {
+			return nil, badSelector("bad argument to " + key)
+		}
+		return obj, nil
+	case "$regex", "$type", "$beginsWith", "$text":
+		if _, ok := arg.(string); !ok {
+			return nil, badSelector("bad argument to " + key)
+		}
+		return obj, nil
+	case "$lt", "$lte", "$eq", "$ne", "$gte", "$gt":
+		return obj, nil
+	}
+	if strings.HasPrefix(key, "$") {
+		return nil, badSelector("invalid operator " + key)
+	}
+	if isCondObj(arg) {
+		norm, err := normOps(arg)
+		if err != nil {
+			return nil, err
+		}
+		return single(key, norm), nil
+	}
+	return single(key, single("$eq", arg)), nil
+}
+
+// normFields moves field names down to the conditions to which they apply,
+// joining nested field names with dots.
+func normFields(v interface{}, path string) interface{} {
+	obj := v.(erlterm.Object)
+	if len(obj) == 0 {
+		if path == "" {
+			return obj
+		}
+		return erlterm.Tuple{path, obj}
+	}
+	key, arg := obj[0].Key, obj[0].Value
+	switch key {
+	case "$and", "$or", "$nor":
+		list := arg.([]interface{})
+		args := make([]interface{}, len(list))
+		for i, a := range list {
+			args[i] = normFields(a, path)
+		}
+		return single(key, args)
+	case "$not":
+		return single(key, normFields(arg, path))
+	case "$elemMatch", "$allMatch", "$keyMapMatch":
+		return single(path, single(key, normFields(arg, "")))
+	case "$default":
+		if path == "" {
+			return obj
+		}
+	}
+	if strings.HasPrefix(key, "$") {
+		return single(path, obj)
+	}
+	if path != "" {
+		key = path + "." + key
+	}
+	return normFields(arg, key)
+}
+
+// normNegations rewrites $nor in terms of $not, and removes double
+// negations.
+func normNegations(v interface{}) interface{} {
+	obj, ok := v.(erlterm.Object)
+	if !ok || len(obj) != 1 {
+		return v
+	}
+	key, arg := obj[0].Key, obj[0].Value
+	switch key {
+	case "$and", "$or":
+		list := arg.([]interface{})
+		args := make([]interface{}, len(list))
+		for i, a := range list {
+			args[i] = normNegations(a)
+		}
+		return single(key, args)
+	case "$nor":
+		list := arg.([]interface{})
+		args := make([]interface{}, len(list))
+		for i, a := range list {
+			args[i] = single("$not", normNegations(a))
+		}
+		return single("$and", args)
+	case "$not":
+		if inner, ok := arg.(erlterm.Object); ok && len(inner) == 1 && inner[0].Key == "$not" {
+			return normNegations(inner[0].Value)
+		}
+		return single(key, normNegations(arg))
+	case "$elemMatch", "$allMatch", "$keyMapMatch":
+		return single(key, normNegations(arg))
+	}
+	if !strings.HasPrefix(key, "$") {
+		return single(key, normNegations(arg))
+	}
+	return v
+}
+
+// ejsort sorts the members of all objects in v by key, as CouchDB does to
+// selectors before hashing them.
+func ejsort(v interface{}) interface{} {
+	switch t := v.(type) {
+	case erlterm.Object:
+		sorted := make(erlterm.Object, len(t))
+		for i, p := range t {
+			sorted[i] = erlterm.Prop{Key: p.Key, Value: ejsort(p.Value)}
+		}
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return sorted[i].Key < sorted[j].Key
+		})
+		return sorted
+	case []interface{}:
+		sorted := make([]interface{}, len(t))
+		for i, e := range t {
+			sorted[i] = ejsort(e)
+		}
+		return sorted
+	case erlterm.Tuple:
+		sorted := make(erlterm.Tuple, len(t))
+		for i, e := range t {
+			sorted[i] = ejsort(e)
+		}
+		return sorted
+	}
+	return v
+}
@@ -0,0 +1,235 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"net/http"
+	"time"
+)
+
+// replicationOptionsKey is the option key used by ReplicationOptions.Options.
+// It is never passed on to the driver.
+const replicationOptionsKey = "kivik:replication_options"
+
+// ReplicationOptions is a typed alternative to the free-form options accepted
+// by Client.Replicate and Replicate. Each field corresponds to the _replicator
+// document field noted in its comment. Pass the result of the Options method:
+//
+//	client.Replicate(ctx, target, source, kivik.ReplicationOptions{
+//		Continuous: true,
+//		DocIDs:     []string{"foo", "bar"},
+//	}.Options())
+//
+// The options are validated before they are passed to the driver. Free-form
+// options passed alongside take precedence over the typed ones.
+//
+// Replicate does not support CheckpointInterval, WorkerProcesses, Owner,
+// SourceAuth or TargetAuth, and returns an error if any of them is set.
+//
+// See https://docs.couchdb.org/en/stable/json-structure.html#replication-settings
+type ReplicationOptions struct {
+	// Continuous keeps the replication listening for new changes
+	// (continuous).
+	Continuous bool
+	// CreateTarget creates the target database, if it does not exist
+	// (create_target).
+	CreateTarget bool
+	// CreateTargetParams are the parameters used to create the target
+	// database, such as "q", "n" or "partitioned" (create_target_params).
+	// Requires CreateTarget.
+	CreateTargetParams map[string]interface{}
+	// DocIDs limits the replication to the named documents (doc_ids).
+	DocIDs []string
+	// Filter is the name of a filter function, in the form "ddoc/filter"
+	// (filter).
+	Filter string
+	// QueryParams are passed to the filter function (query_params). Requires
+	// Filter.
+	QueryParams map[string]interface{}
+	// Selector is a Mango selector used to filter documents (selector).
+	Selector interface{}
+	// SinceSeq is the sequence from which to start, ignoring any checkpoint
+	// (since_seq).
+	SinceSeq string
+	// UseCheckpoints enables or disables checkpoints (use_checkpoints). When
+	// nil, the server default, which is to use checkpoints, applies.
+	UseCheckpoints *bool
+	// CheckpointInterval is the interval between checkpoints
+	// (checkpoint_interval). It is sent in whole milliseconds.
+	CheckpointInterval time.Duration
+	// WorkerProcesses is the number of concurrent replication workers
+	// (worker_processes).
+	WorkerProcesses int
+	// WorkerBatchSize is the number of documents processed per batch
+	// (worker_batch_size).
+	WorkerBatchSize int
+	// Owner is the name of the user who owns the replication (owner).
+	Owner string
+	// SourceAuth and TargetAuth are the credentials used to connect to the
+	// source and target. When set, the source or target is sent as an object
+	// containing the DSN and the credentials.
+	SourceAuth *ReplicationAuth
+	TargetAuth *ReplicationAuth
+}
+
+// ReplicationAuth contains the credentials used to connect to a replication
+// source or target.
+type ReplicationAuth struct {
+	// Username and Password are used for HTTP basic authentication.
+	Username string
+	Password string
+	// Headers are additional HTTP headers, such as a bearer token, to send
+	// with each request.
+	Headers map[string]string
+}
+
+// Options returns o as an option which may be passed to Client.Replicate or
+// Replicate.
+func (o ReplicationOptions) Options() Options {
+	return Options{replicationOptionsKey: o}
+}
+
+func badReplicationOption(msg string) error {
+	return &Error{HTTPStatus: http.StatusBadRequest, Message: "kivik: " + msg}
+}
+
+// Validate returns an error if o contains incompatible or invalid settings.
+func (o ReplicationOptions) Validate() error {
+	selective := 0
+	for _, set := range []bool{len(o.DocIDs) > 0, o.Filter != "", o.Selector != nil} {
+		if set {
+			selective++
+		}
+	}
+	if selective > 1 {
+		return badReplicationOption("doc_ids, filter and selector are mutually exclusive")
+	}
+	if len(o.QueryParams) > 0 && o.Filter == "" {
+		return badReplicationOption("query_params requires filter")
+	}
+	if len(o.CreateTargetParams) > 0 && !o.CreateTarget {
+		return badReplicationOption("create_target_params requires create_target")
+	}
+	if o.CheckpointInterval < 0 {
+		return badReplicationOption("checkpoint_interval must not be negative")
+	}
+	if o.WorkerProcesses < 0 {
+		return badReplicationOption("worker_processes must not be negative")
+	}
+	if o.WorkerBatchSize < 0 {
+		return badReplicationOption("worker_batch_size must not be negative")
+	}
+	for name, auth := range map[string]*ReplicationAuth{"source": o.SourceAuth, "target": o.TargetAuth} {
+		if auth != nil && auth.Password != "" && auth.Username == "" {
+			return badReplicationOption(name + " password requires a username")
+		}
+	}
+	return nil
+}
+
+// options returns o as free-form options, with the source and target given
+// as objects if credentials were supplied.
+func (o ReplicationOptions) options(targetDSN, sourceDSN string) (Options, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	opts := Options{}
+	if o.Continuous {
+		opts["continuous"] = true
+	}
+	if o.CreateTarget {
+		opts["create_target"] = true
+	}
+	if len(o.CreateTargetParams) > 0 {
+		opts["create_target_params"] = o.CreateTargetParams
+	}
+	if len(o.DocIDs) > 0 {
+		opts["doc_ids"] = o.DocIDs
+	}
+	if o.Filter != "" {
+		opts["filter"] = o.Filter
+	}
+	if len(o.QueryParams) > 0 {
+		opts["query_params"] = o.QueryParams
+	}
+	if o.Selector != nil {
+		opts["selector"] = o.Selector
+	}
+	if o.SinceSeq != "" {
+		opts["since_seq"] = o.SinceSeq
+	}
+	if o.UseCheckpoints != nil {
+		opts["use_checkpoints"] = *o.UseCheckpoints
+	}
+	if o.CheckpointInterval > 0 {
+		opts["checkpoint_interval"] = int(o.CheckpointInterval / time.Millisecond)
+	}
+	if o.WorkerProcesses > 0 {
+		opts["worker_processes"] = o.WorkerProcesses
+	}
+	if o.WorkerBatchSize > 0 {
+		opts["worker_batch_size"] = o.WorkerBatchSize
+	}
+	if o.Owner != "" {
+		opts["owner"] = o.Owner
+	}
+	if o.SourceAuth != nil {
+		opts["source"] = o.SourceAuth.endpoint(sourceDSN)
+	}
+	if o.TargetAuth != nil {
+		opts["target"] = o.TargetAuth.endpoint(targetDSN)
+	}
+	return opts, nil
+}
+
+// endpoint returns the _replicator document representation of a source or
+// target with credentials.
+func (a *ReplicationAuth) endpoint(dsn string) map[string]interface{} {
+	endpoint := map[string]interface{}{"url": dsn}
+	if a.Username != "" {
+		endpoint["auth"] = map[string]interface{}{
+			"basic": map[string]interface{}{
+				"username": a.Username,
+				"password": a.Password,
+			},
+		}
+	}
+	if len(a.Headers) > 0 {
+		endpoint["headers"] = a.Headers
+	}
+	return endpoint
+}
+
+// replicationOptions merges options, replacing any ReplicationOptions with
+// the equivalent free-form options.
+func replicationOptions(targetDSN, sourceDSN string, options []Options) (Options, error) {
+	opts := mergeOptions(options...)
+	ro, ok := opts[replicationOptionsKey].(ReplicationOptions)
+	if !ok {
+		return opts, nil
+	}
+	delete(opts, replicationOptionsKey)
+	typed, err := ro.options(targetDSN, sourceDSN)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range typed {
+		if _, ok := opts[k]; !ok {
+			opts[k] = v
+		}
+	}
+	if len(opts) == 0 {
+		return nil, nil
+	}
+	return opts, nil
+}
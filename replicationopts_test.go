@@ -0,0 +1,207 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestReplicationOptions(t *testing.T) {
+	type tst struct {
+		options  []Options
+		expected Options
+		status   int
+		err      string
+	}
+	no := false
+	tests := testy.NewTable()
+	tests.Add("no options", tst{})
+	tests.Add("free-form only", tst{
+		options:  []Options{{"continuous": true}},
+		expected: Options{"continuous": true},
+	})
+	tests.Add("zero value", tst{
+		options: []Options{ReplicationOptions{}.Options()},
+	})
+	tests.Add("all fields", tst{
+		options: []Options{ReplicationOptions{
+			Continuous:         true,
+			CreateTarget:       true,
+			CreateTargetParams: map[string]interface{}{"q": 1},
+			Filter:             "ddoc/filter",
+			QueryParams:        map[string]interface{}{"type": "foo"},
+			SinceSeq:           "12-abc",
+			UseCheckpoints:     &no,
+			CheckpointInterval: 5 * time.Second,
+			WorkerProcesses:    2,
+			WorkerBatchSize:    100,
+			Owner:              "bob",
+			SourceAuth:         &ReplicationAuth{Username: "bob", Password: "abc123"},
+			TargetAuth:         &ReplicationAuth{Headers: map[string]string{"Authorization": "Bearer xyz"}},
+		}.Options()},
+		expected: Options{
+			"continuous":           true,
+			"create_target":        true,
+			"create_target_params": map[string]interface{}{"q": 1},
+			"filter":               "ddoc/filter",
+			"query_params":         map[string]interface{}{"type": "foo"},
+			"since_seq":            "12-abc",
+			"use_checkpoints":      false,
+			"checkpoint_interval":  5000,
+			"worker_processes":     2,
+			"worker_batch_size":    100,
+			"owner":                "bob",
+			"source": map[string]interface{}{
+				"url": "http://localhost:5984/source",
+				"auth": map[string]interface{}{
+					"basic": map[string]interface{}{"username": "bob", "password": "abc123"},
+				},
+			},
+			"target": map[string]interface{}{
+				"url":     "http://localhost:5984/target",
+				"headers": map[string]string{"Authorization": "Bearer xyz"},
+			},
+		},
+	})
+	tests.Add("doc ids and selector", tst{
+		options: []Options{ReplicationOptions{
+			DocIDs:   []string{"foo"},
+			Selector: map[string]interface{}{"type": "foo"},
+		}.Options()},
+		status: http.StatusBadRequest,
+		err:    "kivik: doc_ids, filter and selector are mutually exclusive",
+	})
+	tests.Add("filter and selector", tst{
+		options: []Options{ReplicationOptions{
+			Filter:   "ddoc/filter",
+			Selector: map[string]interface{}{"type": "foo"},
+		}.Options()},
+		status: http.StatusBadRequest,
+		err:    "kivik: doc_ids, filter and selector are mutually exclusive",
+	})
+	tests.Add("query params without filter", tst{
+		options: []Options{ReplicationOptions{
+			QueryParams: map[string]interface{}{"type": "foo"},
+		}.Options()},
+		status: http.StatusBadRequest,
+		err:    "kivik: query_params requires filter",
+	})
+	tests.Add("create target params without create target", tst{
+		options: []Options{ReplicationOptions{
+			CreateTargetParams: map[string]interface{}{"q": 1},
+		}.Options()},
+		status: http.StatusBadRequest,
+		err:    "kivik: create_target_params requires create_target",
+	})
+	tests.Add("negative batch size", tst{
+		options: []Options{ReplicationOptions{WorkerBatchSize: -1}.Options()},
+		status:  http.StatusBadRequest,
+		err:     "kivik: worker_batch_size must not be negative",
+	})
+	tests.Add("password without username", tst{
+		options: []Options{ReplicationOptions{SourceAuth: &ReplicationAuth{Password: "abc123"}}.Options()},
+		status:  http.StatusBadRequest,
+		err:     "kivik: source password requires a username",
+	})
+	tests.Add("free-form takes precedence", tst{
+		options: []Options{
+			ReplicationOptions{DocIDs: []string{"foo"}, Continuous: true}.Options(),
+			{"doc_ids": []string{"bar"}},
+		},
+		expected: Options{
+			"continuous": true,
+			"doc_ids":    []string{"bar"},
+		},
+	})
+
+	tests.Run(t, func(t *testing.T, tt tst) {
+		opts, err := replicationOptions("http://localhost:5984/target", "http://localhost:5984/source", tt.options)
+		testy.StatusError(t, tt.err, tt.status, err)
+		if d := testy.DiffInterface(tt.expected, opts); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
+func TestReplicateWithReplicationOptions(t *testing.T) {
+	t.Run("client", func(t *testing.T) {
+		var got map[string]interface{}
+		client := &Client{
+			driverClient: &mock.ClientReplicator{
+				ReplicateFunc: func(_ context.Context, _, _ string, opts map[string]interface{}) (driver.Replication, error) {
+					got = opts
+					return &mock.Replication{ID: "a"}, nil
+				},
+			},
+		}
+		_, err := client.Replicate(context.Background(), "target", "source", ReplicationOptions{
+			Continuous: true,
+			DocIDs:     []string{"foo"},
+		}.Options())
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := map[string]interface{}{
+			"continuous": true,
+			"doc_ids":    []string{"foo"},
+		}
+		if d := testy.DiffInterface(expected, got); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("client invalid", func(t *testing.T) {
+		client := &Client{
+			driverClient: &mock.ClientReplicator{
+				ReplicateFunc: func(_ context.Context, _, _ string, _ map[string]interface{}) (driver.Replication, error) {
+					return nil, errors.New("driver should not be called")
+				},
+			},
+		}
+		_, err := client.Replicate(context.Background(), "target", "source", ReplicationOptions{
+			Filter:   "ddoc/filter",
+			Selector: map[string]interface{}{},
+		}.Options())
+		testy.StatusError(t, "kivik: doc_ids, filter and selector are mutually exclusive", http.StatusBadRequest, err)
+	})
+	t.Run("local with auth", func(t *testing.T) {
+		client := &Client{driverClient: &mock.Client{
+			DBFunc: func(_ string, _ map[string]interface{}) (driver.DB, error) {
+				return &mock.DB{}, nil
+			},
+		}}
+		_, err := Replicate(context.Background(), client.DB("target"), client.DB("source"), ReplicationOptions{
+			SourceAuth: &ReplicationAuth{Username: "bob"},
+		}.Options())
+		testy.StatusError(t, "kivik: SourceAuth and TargetAuth are not supported by Replicate", http.StatusBadRequest, err)
+	})
+	t.Run("local with unsupported option", func(t *testing.T) {
+		client := &Client{driverClient: &mock.Client{
+			DBFunc: func(_ string, _ map[string]interface{}) (driver.DB, error) {
+				return &mock.DB{}, nil
+			},
+		}}
+		_, err := Replicate(context.Background(), client.DB("target"), client.DB("source"), ReplicationOptions{
+			CheckpointInterval: time.Second,
+		}.Options())
+		testy.StatusError(t, "kivik: option checkpoint_interval is not supported by Replicate", http.StatusNotImplemented, err)
+	})
+}
@@ -0,0 +1,791 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"crypto/md5" // nolint:gosec
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+const (
+	// defaultReplicationBatchSize matches CouchDB's default worker_batch_size.
+	defaultReplicationBatchSize = 500
+	// maxCheckpointHistory matches the number of history entries CouchDB
+	// retains in a replication checkpoint document.
+	maxCheckpointHistory = 50
+	// localDocPrefix is the prefix for non-replicating local documents.
+	localDocPrefix = "_local/"
+)
+
+// Replicate replicates the contents of source to target, using the CouchDB
+// replication protocol, performed entirely by Kivik. Unlike Client.Replicate,
+// which asks the server to perform the replication, source and target may be
+// any two DB handles, even those backed by different drivers.
+//
+// The replication is performed in the background. The returned Replication's
+// DocsRead, DocsWritten, DocWriteFailures and Progress values are updated as
+// the replication progresses, and State will report ReplicationComplete or
+// ReplicationError once it has finished. Cancelling ctx, or calling Delete,
+// aborts the replication.
+//
+// The replication protocol requires that source support Changes, and
+// preferably BulkGet (Get will be used for each document otherwise), and that
+// target support RevsDiff and BulkDocs with new_edits=false. Checkpoints are
+// stored as _local documents in both databases, so that an interrupted
+// replication can resume where it left off.
+//
+// The following options are recognized, and mirror the equivalent fields of a
+// _replicator document. They may also be given with ReplicationOptions.
+//
+//  - continuous (bool): Keep listening for changes after the initial pass.
+//  - create_target (bool): Create the target database if it does not exist.
+//  - create_target_params (map[string]interface{}): Options passed to
+//    CreateDB when the target is created.
+//  - doc_ids ([]string): Replicate only the named documents.
+//  - selector: Replicate only documents matching a Mango selector. The
+//    selector is evaluated on the client if the source does not support it.
+//  - filter (string): Replicate only documents accepted by the named filter
+//    function, in the form "ddoc/filter". The source must support filter
+//    functions.
+//  - query_params (map[string]interface{}): Parameters passed to the filter
+//    function.
+//  - since_seq (string): Start from this update sequence, ignoring any
+//    stored checkpoint.
+//  - use_checkpoints (bool): Read and write checkpoints. Defaults to true.
+//  - worker_batch_size (int): The number of changes processed per batch.
+//    Defaults to 500.
+//  - replication_id (string): The ID under which checkpoints are stored. Pass
+//    the result of ReplicationID to resume from the checkpoints written by a
+//    CouchDB server replicating the same databases.
+//
+// Any other option, including checkpoint_interval, worker_processes and
+// owner, results in a status 501 error.
+//
+// See https://docs.couchdb.org/en/stable/replication/protocol.html
+func Replicate(ctx context.Context, target, source *DB, options ...Options) (*Replication, error) {
+	if source == nil {
+		return nil, missingArg("source")
+	}
+	if target == nil {
+		return nil, missingArg("target")
+	}
+	if source.err != nil {
+		return nil, source.err
+	}
+	if target.err != nil {
+		return nil, target.err
+	}
+	if ro, ok := mergeOptions(options...)[replicationOptionsKey].(ReplicationOptions); ok && (ro.SourceAuth != nil || ro.TargetAuth != nil) {
+		return nil, &Error{HTTPStatus: http.StatusBadRequest, Message: "kivik: SourceAuth and TargetAuth are not supported by Replicate"}
+	}
+	opts, err := replicationOptions("", "", options)
+	if err != nil {
+		return nil, err
+	}
+	r, err := newLocalReplication(target, source, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.prepare(ctx); err != nil {
+		return nil, err
+	}
+	rep := newReplication(r)
+	r.onProgress = func(info driver.ReplicationInfo) {
+		rep.infoMU.Lock()
+		rep.info = &info
+		rep.infoMU.Unlock()
+	}
+	r.state = ReplicationRunning
+	r.startTime = time.Now()
+	ctx, r.cancel = context.WithCancel(ctx)
+	go r.run(ctx)
+	return rep, nil
+}
+
+// localReplication is a driver.Replication performed by Kivik itself.
+type localReplication struct {
+	source, target *DB
+	baseID         string
+	id             string
+	continuous     bool
+	createTarget   bool
+	useCheckpoints bool
+	batchSize      int
+	filter         Options
+	targetParams   Options
+	since          string
+
+	sessionID        string
+	sourceCheckpoint *checkpointDoc
+	targetCheckpoint *checkpointDoc
+
+	cancel     func()
+	done       chan struct{}
+	onProgress func(driver.ReplicationInfo)
+
+	mu              sync.RWMutex
+	state           ReplicationState
+	err             error
+	startTime       time.Time
+	endTime         time.Time
+	info            driver.ReplicationInfo
+	changesRead     int64
+	missingChecked  int64
+	missingFound    int64
+	startSeq        string
+	lastSeq         string
+	checkpointStart time.Time
+}
+
+var _ driver.Replication = &localReplication{}
+
+func newLocalReplication(target, source *DB, opts Options) (*localReplication, error) {
+	r := &localReplication{
+		source:         source,
+		target:         target,
+		useCheckpoints: true,
+		batchSize:      defaultReplicationBatchSize,
+		done:           make(chan struct{}),
+	}
+	for key := range opts {
+		if !localReplicationOptions[key] {
+			return nil, &Error{HTTPStatus: http.StatusNotImplemented, Message: "kivik: option " + key + " is not supported by Replicate"}
+		}
+	}
+	var err error
+	if r.continuous, err = boolOption(opts, "continuous"); err != nil {
+		return nil, err
+	}
+	if r.createTarget, err = boolOption(opts, "create_target"); err != nil {
+		return nil, err
+	}
+	if _, ok := opts["use_checkpoints"]; ok {
+		if r.useCheckpoints, err = boolOption(opts, "use_checkpoints"); err != nil {
+			return nil, err
+		}
+	}
+	if v, ok := opts["worker_batch_size"]; ok {
+		size, ok := v.(int)
+		if !ok || size < 1 {
+			return nil, &Error{HTTPStatus: http.StatusBadRequest, Message: "kivik: worker_batch_size must be a positive integer"}
+		}
+		r.batchSize = size
+	}
+	if v, ok := opts["doc_ids"]; ok {
+		ids, ok := v.([]string)
+		if !ok {
+			return nil, &Error{HTTPStatus: http.StatusBadRequest, Message: "kivik: doc_ids must be a []string"}
+		}
+		r.filter = Options{"filter": "_doc_ids", "doc_ids": ids}
+	}
+	if v, ok := opts["create_target_params"]; ok {
+		params, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, badReplicationOption("create_target_params must be a map[string]interface{}")
+		}
+		r.targetParams = Options(params)
+	}
+	filter, err := replicationFilter(opts)
+	if err != nil {
+		return nil, err
+	}
+	if filter != nil {
+		if r.filter != nil {
+			return nil, badReplicationOption("doc_ids, filter and selector are mutually exclusive")
+		}
+		r.filter = filter
+	}
+	if v, ok := opts["since_seq"]; ok {
+		since, ok := v.(string)
+		if !ok {
+			return nil, &Error{HTTPStatus: http.StatusBadRequest, Message: "kivik: since_seq must be a string"}
+		}
+		r.since = since
+	}
+	if v, ok := opts["replication_id"]; ok {
+		id, ok := v.(string)
+		if !ok || id == "" {
+			return nil, &Error{HTTPStatus: http.StatusBadRequest, Message: "kivik: replication_id must be a non-empty string"}
+		}
+		r.baseID = strings.TrimPrefix(ReplicationCheckpointID(id), localDocPrefix)
+	} else {
+		r.baseID = r.replicationID(opts)
+	}
+	r.id = r.baseID
+	if r.continuous {
+		r.id += "+continuous"
+	}
+	if r.createTarget {
+		r.id += "+create_target"
+	}
+	return r, nil
+}
+
+// localReplicationOptions are the options honoured by Replicate.
+var localReplicationOptions = map[string]bool{
+	"continuous": true, "create_target": true, "create_target_params": true,
+	"doc_ids": true, "selector": true, "filter": true, "query_params": true,
+	"since_seq": true, "use_checkpoints": true, "worker_batch_size": true,
+	"replication_id": true,
+}
+
+// replicationFilter returns the changes feed options which implement the
+// filter, query_params and selector replication options, or nil if none are
+// set.
+func replicationFilter(opts Options) (Options, error) {
+	selector, hasSelector := opts["selector"]
+	filter, hasFilter := opts["filter"]
+	params, hasParams := opts["query_params"]
+	switch {
+	case hasSelector && hasFilter:
+		return nil, badReplicationOption("doc_ids, filter and selector are mutually exclusive")
+	case hasParams && !hasFilter:
+		return nil, badReplicationOption("query_params requires filter")
+	case hasSelector:
+		if selector == nil {
+			return nil, badReplicationOption("selector must not be null")
+		}
+		return Options{"filter": "_selector", "selector": selector}, nil
+	case !hasFilter:
+		return nil, nil
+	}
+	name, ok := filter.(string)
+	if !ok || !strings.Contains(name, "/") || strings.HasPrefix(name, "_") {
+		return nil, badReplicationOption("filter must be in the form ddoc/filter")
+	}
+	result := Options{"filter": name}
+	if hasParams {
+		queryParams, ok := params.(map[string]interface{})
+		if !ok {
+			return nil, badReplicationOption("query_params must be a map[string]interface{}")
+		}
+		for k, v := range queryParams {
+			if changesParams[k] {
+				return nil, badReplicationOption(fmt.Sprintf("query param %q conflicts with a changes option", k))
+			}
+			result[k] = v
+		}
+	}
+	return result, nil
+}
+
+func boolOption(opts Options, key string) (bool, error) {
+	v, ok := opts[key]
+	if !ok {
+		return false, nil
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, &Error{HTTPStatus: http.StatusBadRequest, Message: "kivik: " + key + " must be a bool"}
+	}
+	return b, nil
+}
+
+// replicationID returns an identifier which is stable for a given source,
+// target and set of options, and is used to name the checkpoint documents.
+func (r *localReplication) replicationID(opts Options) string {
+	parts := []interface{}{
+		dbDSN(r.source), r.source.Name(),
+		dbDSN(r.target), r.target.Name(),
+		opts["doc_ids"],
+	}
+	if r.filter != nil && r.filter["filter"] != "_doc_ids" {
+		parts = append(parts, r.filter)
+	}
+	base, _ := json.Marshal(parts)
+	sum := md5.Sum(base) // nolint:gosec
+	return hex.EncodeToString(sum[:])
+}
+
+// dbDSN returns the DSN of db's client, for use in the replication ID. Any
+// password is removed, as CouchDB does, so that changing credentials does not
+// discard existing checkpoints.
+func dbDSN(db *DB) string {
+	if db.client == nil {
+		return ""
+	}
+	dsn, err := url.Parse(db.client.dsn)
+	if err != nil || dsn.User == nil {
+		return db.client.dsn
+	}
+	dsn.User = url.User(dsn.User.Username())
+	return dsn.String()
+}
+
+// prepare creates the target, if requested, and reads any existing
+// checkpoints, to determine the starting sequence.
+func (r *localReplication) prepare(ctx context.Context) error {
+	if r.createTarget {
+		client := r.target.Client()
+		exists, err := client.DBExists(ctx, r.target.Name())
+		if err != nil {
+			return err
+		}
+		if !exists {
+			if err := client.CreateDB(ctx, r.target.Name(), r.targetParams); err != nil {
+				return err
+			}
+		}
+	}
+	sessionID, err := newSessionID()
+	if err != nil {
+		return err
+	}
+	r.sessionID = sessionID
+	if !r.useCheckpoints {
+		return nil
+	}
+	if r.sourceCheckpoint, err = r.readCheckpoint(ctx, r.source); err != nil {
+		return err
+	}
+	if r.targetCheckpoint, err = r.readCheckpoint(ctx, r.target); err != nil {
+		return err
+	}
+	if r.since == "" {
+		r.since = compareCheckpoints(r.sourceCheckpoint, r.targetCheckpoint)
+	}
+	return nil
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (r *localReplication) run(ctx context.Context) {
+	defer close(r.done)
+	r.mu.Lock()
+	r.checkpointStart = r.startTime
+	r.startSeq = r.since
+	r.lastSeq = r.since
+	r.mu.Unlock()
+
+	err := r.replicate(ctx)
+
+	r.mu.Lock()
+	r.endTime = time.Now()
+	if err != nil {
+		r.state = ReplicationError
+		r.err = err
+	} else {
+		r.state = ReplicationComplete
+	}
+	r.mu.Unlock()
+}
+
+// change is the subset of a changes feed entry required for replication.
+type change struct {
+	id   string
+	seq  string
+	revs []string
+}
+
+func (r *localReplication) replicate(ctx context.Context) error {
+	since := r.since
+	feed := "normal"
+	for {
+		batch, lastSeq, pending, err := r.readChanges(ctx, since, feed)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 && lastSeq == since {
+			if !r.continuous {
+				return nil
+			}
+			// Caught up; wait for further changes.
+			feed = "longpoll"
+			continue
+		}
+		if err := r.replicateBatch(ctx, batch); err != nil {
+			return err
+		}
+		since = lastSeq
+		r.recordProgress(int64(len(batch)), pending, since)
+		if err := r.checkpoint(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// readChanges reads up to one batch of changes from the source, starting at
+// since. It returns the changes of interest, the last sequence read, and the
+// number of changes still pending.
+func (r *localReplication) readChanges(ctx context.Context, since, feed string) ([]change, string, int64, error) {
+	opts := Options{
+		"feed":  feed,
+		"style": "all_docs",
+		"limit": r.batchSize,
+	}
+	if since != "" {
+		opts["since"] = since
+	}
+	for k, v := range r.filter {
+		opts[k] = v
+	}
+	changes, err := r.source.Changes(ctx, opts)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	var batch []change
+	lastSeq := since
+	for changes.Next() {
+		lastSeq = changes.Seq()
+		batch = append(batch, change{
+			id:   changes.ID(),
+			seq:  changes.Seq(),
+			revs: changes.Changes(),
+		})
+	}
+	if err := changes.Err(); err != nil {
+		return nil, "", 0, err
+	}
+	if seq := changes.LastSeq(); seq != "" {
+		lastSeq = seq
+	}
+	return batch, lastSeq, changes.Pending(), nil
+}
+
+// replicateBatch replicates the missing revisions of a batch of changes to
+// the target.
+func (r *localReplication) replicateBatch(ctx context.Context, batch []change) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	missing, err := r.missingRevs(ctx, batch)
+	if err != nil {
+		return err
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	docs, readFailures, err := r.fetchDocs(ctx, missing)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.info.DocsRead += int64(len(docs))
+	r.info.DocWriteFailures += readFailures
+	r.mu.Unlock()
+	if len(docs) == 0 {
+		return nil
+	}
+	results, err := r.target.BulkDocs(ctx, docs, Options{"new_edits": false})
+	if err != nil {
+		return err
+	}
+	var failures int64
+	for results.Next() {
+		if results.UpdateErr() != nil {
+			failures++
+		}
+	}
+	if err := results.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.info.DocsWritten += int64(len(docs)) - failures
+	r.info.DocWriteFailures += failures
+	r.mu.Unlock()
+	return nil
+}
+
+// missingRevs calls RevsDiff against the target, and returns the list of
+// revisions which must be fetched from the source.
+func (r *localReplication) missingRevs(ctx context.Context, batch []change) ([]BulkGetReference, error) {
+	revMap := make(map[string][]string, len(batch))
+	var checked int64
+	for _, ch := range batch {
+		revMap[ch.id] = append(revMap[ch.id], ch.revs...)
+		checked += int64(len(ch.revs))
+	}
+	rows, err := r.target.RevsDiff(ctx, revMap)
+	if err != nil {
+		return nil, err
+	}
+	var missing []BulkGetReference
+	for rows.Next() {
+		var diff RevDiff
+		if err := rows.ScanValue(&diff); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		for _, rev := range diff.Missing {
+			missing = append(missing, BulkGetReference{ID: rows.ID(), Rev: rev})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	r.missingChecked += checked
+	r.missingFound += int64(len(missing))
+	r.mu.Unlock()
+	return missing, nil
+}
+
+// fetchDocs fetches the requested document revisions from the source, with
+// revision history and attachments, suitable for writing to the target with
+// new_edits=false. Revisions that cannot be read are counted, but otherwise
+// skipped.
+func (r *localReplication) fetchDocs(ctx context.Context, refs []BulkGetReference) ([]interface{}, int64, error) {
+	opts := Options{"revs": true, "attachments": true}
+	rows, err := r.source.BulkGet(ctx, refs, opts)
+	if StatusCode(err) == http.StatusNotImplemented {
+		return r.getDocs(ctx, refs, opts)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	docs := make([]interface{}, 0, len(refs))
+	var failures int64
+	for rows.Next() {
+		var doc json.RawMessage
+		if err := rows.ScanDoc(&doc); err != nil {
+			failures++
+			continue
+		}
+		docs = append(docs, doc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return docs, failures, nil
+}
+
+// getDocs emulates BulkGet by calling Get once per revision.
+func (r *localReplication) getDocs(ctx context.Context, refs []BulkGetReference, opts Options) ([]interface{}, int64, error) {
+	docs := make([]interface{}, 0, len(refs))
+	var failures int64
+	for _, ref := range refs {
+		var doc json.RawMessage
+		err := r.source.Get(ctx, ref.ID, opts, Options{"rev": ref.Rev}).ScanDoc(&doc)
+		switch {
+		case StatusCode(err) == http.StatusNotFound:
+			failures++
+			continue
+		case err != nil:
+			return nil, 0, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, failures, nil
+}
+
+func (r *localReplication) recordProgress(changesRead, pending int64, lastSeq string) {
+	r.mu.Lock()
+	r.changesRead += changesRead
+	r.lastSeq = lastSeq
+	if total := r.changesRead + pending; total > 0 {
+		r.info.Progress = float64(r.changesRead) / float64(total) * 100
+	}
+	info := r.info
+	r.mu.Unlock()
+	if r.onProgress != nil {
+		r.onProgress(info)
+	}
+}
+
+// checkpointDoc is the format of a replication checkpoint, as stored by
+// CouchDB in a _local document on both the source and target.
+type checkpointDoc struct {
+	ID                   string              `json:"_id"`
+	Rev                  string              `json:"_rev,omitempty"`
+	SessionID            string              `json:"session_id"`
+	SourceLastSeq        checkpointSeq       `json:"source_last_seq"`
+	ReplicationIDVersion int                 `json:"replication_id_version"`
+	History              []checkpointHistory `json:"history"`
+}
+
+type checkpointHistory struct {
+	SessionID        string        `json:"session_id"`
+	StartTime        string        `json:"start_time"`
+	EndTime          string        `json:"end_time"`
+	StartLastSeq     checkpointSeq `json:"start_last_seq"`
+	EndLastSeq       checkpointSeq `json:"end_last_seq"`
+	RecordedSeq      checkpointSeq `json:"recorded_seq"`
+	MissingChecked   int64         `json:"missing_checked"`
+	MissingFound     int64         `json:"missing_found"`
+	DocsRead         int64         `json:"docs_read"`
+	DocsWritten      int64         `json:"docs_written"`
+	DocWriteFailures int64         `json:"doc_write_failures"`
+}
+
+// checkpointSeq is an update sequence, which may be stored as either a JSON
+// string or a number, depending on the server version which wrote it.
+type checkpointSeq string
+
+func (s *checkpointSeq) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		*s = checkpointSeq(str)
+		return nil
+	}
+	var num json.Number
+	if err := json.Unmarshal(data, &num); err != nil {
+		return err
+	}
+	*s = checkpointSeq(num)
+	return nil
+}
+
+func (r *localReplication) checkpointID() string {
+	return localDocPrefix + r.baseID
+}
+
+func (r *localReplication) readCheckpoint(ctx context.Context, db *DB) (*checkpointDoc, error) {
+	doc := &checkpointDoc{}
+	err := db.Get(ctx, r.checkpointID()).ScanDoc(doc)
+	if StatusCode(err) == http.StatusNotFound {
+		return nil, nil
+	}
+	return doc, err
+}
+
+// compareCheckpoints returns the sequence from which to resume, based on the
+// source and target checkpoints, or an empty string to start from scratch.
+func compareCheckpoints(source, target *checkpointDoc) string {
+	if source == nil || target == nil {
+		return ""
+	}
+	if source.SessionID == target.SessionID {
+		return string(source.SourceLastSeq)
+	}
+	targetSessions := make(map[string]struct{}, len(target.History))
+	for _, h := range target.History {
+		targetSessions[h.SessionID] = struct{}{}
+	}
+	for _, h := range source.History {
+		if _, ok := targetSessions[h.SessionID]; ok {
+			return string(h.RecordedSeq)
+		}
+	}
+	return ""
+}
+
+// checkpoint records the current replication state in both the source and
+// target.
+func (r *localReplication) checkpoint(ctx context.Context) error {
+	if !r.useCheckpoints {
+		return nil
+	}
+	r.mu.RLock()
+	history := checkpointHistory{
+		SessionID:        r.sessionID,
+		StartTime:        r.checkpointStart.UTC().Format(http.TimeFormat),
+		EndTime:          time.Now().UTC().Format(http.TimeFormat),
+		StartLastSeq:     checkpointSeq(r.startSeq),
+		EndLastSeq:       checkpointSeq(r.lastSeq),
+		RecordedSeq:      checkpointSeq(r.lastSeq),
+		MissingChecked:   r.missingChecked,
+		MissingFound:     r.missingFound,
+		DocsRead:         r.info.DocsRead,
+		DocsWritten:      r.info.DocsWritten,
+		DocWriteFailures: r.info.DocWriteFailures,
+	}
+	r.mu.RUnlock()
+	var err error
+	if r.sourceCheckpoint, err = r.writeCheckpoint(ctx, r.source, r.sourceCheckpoint, history); err != nil {
+		return err
+	}
+	r.targetCheckpoint, err = r.writeCheckpoint(ctx, r.target, r.targetCheckpoint, history)
+	return err
+}
+
+func (r *localReplication) writeCheckpoint(ctx context.Context, db *DB, prev *checkpointDoc, history checkpointHistory) (*checkpointDoc, error) {
+	doc := &checkpointDoc{
+		ID:                   r.checkpointID(),
+		SessionID:            r.sessionID,
+		SourceLastSeq:        history.RecordedSeq,
+		ReplicationIDVersion: 4,
+		History:              []checkpointHistory{history},
+	}
+	if prev != nil {
+		doc.Rev = prev.Rev
+		for _, h := range prev.History {
+			if h.SessionID == r.sessionID {
+				// Replace the entry for the current session
+				continue
+			}
+			doc.History = append(doc.History, h)
+		}
+	}
+	if len(doc.History) > maxCheckpointHistory {
+		doc.History = doc.History[:maxCheckpointHistory]
+	}
+	rev, err := db.Put(ctx, doc.ID, doc)
+	if err != nil {
+		return nil, err
+	}
+	doc.Rev = rev
+	return doc, nil
+}
+
+func (r *localReplication) ReplicationID() string { return r.id }
+func (r *localReplication) Source() string        { return r.source.Name() }
+func (r *localReplication) Target() string        { return r.target.Name() }
+
+func (r *localReplication) StartTime() time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.startTime
+}
+
+func (r *localReplication) EndTime() time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.endTime
+}
+
+func (r *localReplication) State() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return string(r.state)
+}
+
+func (r *localReplication) Err() error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.err
+}
+
+// Delete aborts the replication, and waits for it to stop.
+func (r *localReplication) Delete(ctx context.Context) error {
+	r.cancel()
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *localReplication) Update(_ context.Context, info *driver.ReplicationInfo) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	*info = r.info
+	return nil
+}
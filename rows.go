@@ -197,6 +197,8 @@ func (r *Rows) QueryIndex() int {
 // set. This is intended for use with the Mango /_find interface, with CouchDB
 // 2.1.1 and later. Consult the official CouchDB documentation for detailed
 // usage instructions. http://docs.couchdb.org/en/2.1.1/api/database/find.html#pagination
+//
+// Bookmarks are also returned by full-text searches. See Search.
 func (r *Rows) Bookmark() string {
 	if b, ok := r.rowsi.(driver.Bookmarker); ok {
 		return b.Bookmark()
@@ -0,0 +1,229 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+var schedulerNotImplemented = &Error{HTTPStatus: http.StatusNotImplemented, Message: "kivik: driver does not support the replication scheduler"}
+
+// SchedulerJobs is a page of replication jobs, as returned by
+// Client.SchedulerJobs. Use the limit and skip options, together with
+// TotalRows and Offset, to page through all jobs.
+type SchedulerJobs struct {
+	TotalRows int64
+	Offset    int64
+	Jobs      []*SchedulerJob
+}
+
+// SchedulerJob is a replication job known to the replication scheduler.
+type SchedulerJob struct {
+	// Database is the replicator database containing the replication
+	// document, if any.
+	Database string
+	// JobID is the replication ID.
+	JobID string
+	// PID is the process ID of the job on the server.
+	PID string
+	// DocID is the ID of the replication document, if any.
+	DocID string
+	// Source and Target are the replication endpoints, with credentials
+	// redacted.
+	Source string
+	Target string
+	// User is the name of the user who started the replication.
+	User string
+	// Node is the cluster node running the job.
+	Node string
+	// StartTime is the time the job was first started.
+	StartTime time.Time
+	// History lists the events in the life of the job, most recent first.
+	History []SchedulerEvent
+	// Info contains the replication statistics, if known.
+	Info *SchedulerInfo
+}
+
+// SchedulerEvent is an entry in the history of a replication job.
+type SchedulerEvent struct {
+	Timestamp time.Time
+	// Type is the event type, such as "added", "started" or "crashed".
+	Type string
+	// Reason is the reason for a crash, if any.
+	Reason string
+}
+
+// SchedulerInfo contains the replication statistics, or the most recent
+// error, reported by the scheduler.
+type SchedulerInfo struct {
+	DocsRead              int64
+	DocsWritten           int64
+	DocWriteFailures      int64
+	MissingRevisionsFound int64
+	RevisionsChecked      int64
+	ChangesPending        int64
+	SourceSeq             string
+	CheckpointedSourceSeq string
+	ThroughSeq            string
+	Error                 string
+}
+
+// SchedulerDocs is a page of replication documents, as returned by
+// Client.SchedulerDocs.
+type SchedulerDocs struct {
+	TotalRows int64
+	Offset    int64
+	Docs      []*SchedulerDoc
+}
+
+// SchedulerDoc is the replication scheduler's view of a replication document.
+type SchedulerDoc struct {
+	// Database is the replicator database containing the document.
+	Database string
+	// DocID is the ID of the replication document.
+	DocID string
+	// JobID is the replication ID, once known.
+	JobID string
+	// Node is the cluster node running the replication.
+	Node string
+	// Source and Target are the replication endpoints, with credentials
+	// redacted.
+	Source string
+	Target string
+	// State is one of ReplicationInitializing, ReplicationError,
+	// ReplicationPending, ReplicationRunning, ReplicationCrashing,
+	// ReplicationComplete or ReplicationFailed.
+	State ReplicationState
+	// Info contains the replication statistics, or the most recent error.
+	Info *SchedulerInfo
+	// ErrorCount is the number of consecutive errors.
+	ErrorCount int
+	// LastUpdated is the time of the most recent state change.
+	LastUpdated time.Time
+	// StartTime is the time the replication was started.
+	StartTime time.Time
+}
+
+// SchedulerJobs returns the replication jobs known to the replication
+// scheduler. Pass the limit and skip options to paginate the results.
+//
+// See https://docs.couchdb.org/en/stable/api/server/common.html#scheduler-jobs
+func (c *Client) SchedulerJobs(ctx context.Context, options ...Options) (*SchedulerJobs, error) {
+	scheduler, ok := c.driverClient.(driver.Scheduler)
+	if !ok {
+		return nil, schedulerNotImplemented
+	}
+	jobs, err := scheduler.SchedulerJobs(ctx, mergeOptions(options...))
+	if err != nil {
+		return nil, err
+	}
+	result := &SchedulerJobs{
+		TotalRows: jobs.TotalRows,
+		Offset:    jobs.Offset,
+		Jobs:      make([]*SchedulerJob, len(jobs.Jobs)),
+	}
+	for i, job := range jobs.Jobs {
+		result.Jobs[i] = &SchedulerJob{
+			Database:  job.Database,
+			JobID:     job.JobID,
+			PID:       job.PID,
+			DocID:     job.DocID,
+			Source:    job.Source,
+			Target:    job.Target,
+			User:      job.User,
+			Node:      job.Node,
+			StartTime: job.StartTime,
+			History:   make([]SchedulerEvent, len(job.History)),
+			Info:      schedulerInfo(job.Info),
+		}
+		for j, event := range job.History {
+			result.Jobs[i].History[j] = SchedulerEvent(event)
+		}
+	}
+	return result, nil
+}
+
+// SchedulerDocs returns the replication scheduler's state for each document in
+// replicatorDB, or in all replicator databases if replicatorDB is empty. Pass
+// the limit and skip options to paginate the results.
+//
+// See https://docs.couchdb.org/en/stable/api/server/common.html#scheduler-docs
+func (c *Client) SchedulerDocs(ctx context.Context, replicatorDB string, options ...Options) (*SchedulerDocs, error) {
+	scheduler, ok := c.driverClient.(driver.Scheduler)
+	if !ok {
+		return nil, schedulerNotImplemented
+	}
+	docs, err := scheduler.SchedulerDocs(ctx, replicatorDB, mergeOptions(options...))
+	if err != nil {
+		return nil, err
+	}
+	result := &SchedulerDocs{
+		TotalRows: docs.TotalRows,
+		Offset:    docs.Offset,
+		Docs:      make([]*SchedulerDoc, len(docs.Docs)),
+	}
+	for i, doc := range docs.Docs {
+		result.Docs[i] = schedulerDoc(doc)
+	}
+	return result, nil
+}
+
+// SchedulerDoc returns the replication scheduler's state for a single
+// replication document.
+//
+// See https://docs.couchdb.org/en/stable/api/server/common.html#get--_scheduler-docs-replicator_db-docid
+func (c *Client) SchedulerDoc(ctx context.Context, replicatorDB, docID string) (*SchedulerDoc, error) {
+	if replicatorDB == "" {
+		return nil, missingArg("replicatorDB")
+	}
+	if docID == "" {
+		return nil, missingArg("docID")
+	}
+	scheduler, ok := c.driverClient.(driver.Scheduler)
+	if !ok {
+		return nil, schedulerNotImplemented
+	}
+	doc, err := scheduler.SchedulerDoc(ctx, replicatorDB, docID)
+	if err != nil {
+		return nil, err
+	}
+	return schedulerDoc(doc), nil
+}
+
+func schedulerDoc(doc *driver.SchedulerDoc) *SchedulerDoc {
+	return &SchedulerDoc{
+		Database:    doc.Database,
+		DocID:       doc.DocID,
+		JobID:       doc.JobID,
+		Node:        doc.Node,
+		Source:      doc.Source,
+		Target:      doc.Target,
+		State:       ReplicationState(doc.State),
+		Info:        schedulerInfo(doc.Info),
+		ErrorCount:  doc.ErrorCount,
+		LastUpdated: doc.LastUpdated,
+		StartTime:   doc.StartTime,
+	}
+}
+
+func schedulerInfo(info *driver.SchedulerInfo) *SchedulerInfo {
+	if info == nil {
+		return nil
+	}
+	i := SchedulerInfo(*info)
+	return &i
+}
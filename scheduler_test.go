@@ -0,0 +1,254 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestSchedulerJobs(t *testing.T) {
+	type tst struct {
+		client   *Client
+		options  Options
+		expected *SchedulerJobs
+		status   int
+		err      string
+	}
+	ts := time.Date(2017, 4, 29, 5, 1, 37, 0, time.UTC)
+	tests := testy.NewTable()
+	tests.Add("not supported", tst{
+		client: &Client{driverClient: &mock.Client{}},
+		status: http.StatusNotImplemented,
+		err:    "kivik: driver does not support the replication scheduler",
+	})
+	tests.Add("error", tst{
+		client: &Client{
+			driverClient: &mock.Scheduler{
+				SchedulerJobsFunc: func(_ context.Context, _ map[string]interface{}) (*driver.SchedulerJobs, error) {
+					return nil, errors.New("jobs failed")
+				},
+			},
+		},
+		status: http.StatusInternalServerError,
+		err:    "jobs failed",
+	})
+	tests.Add("success", tst{
+		client: &Client{
+			driverClient: &mock.Scheduler{
+				SchedulerJobsFunc: func(_ context.Context, options map[string]interface{}) (*driver.SchedulerJobs, error) {
+					if d := testy.DiffInterface(map[string]interface{}{"limit": 1, "skip": 1}, options); d != nil {
+						return nil, errors.New(d.String())
+					}
+					return &driver.SchedulerJobs{
+						TotalRows: 2,
+						Offset:    1,
+						Jobs: []*driver.SchedulerJob{
+							{
+								Database:  "_replicator",
+								JobID:     "a81a78e822837e66df423d54279c15fe+continuous",
+								PID:       "<0.1850.0>",
+								DocID:     "foo",
+								Source:    "http://localhost:5984/a/",
+								Target:    "http://localhost:5984/b/",
+								User:      "admin",
+								Node:      "node1@127.0.0.1",
+								StartTime: ts,
+								History: []driver.SchedulerEvent{
+									{Timestamp: ts, Type: "started"},
+									{Timestamp: ts, Type: "crashed", Reason: "db_not_found"},
+								},
+								Info: &driver.SchedulerInfo{DocsRead: 3},
+							},
+						},
+					}, nil
+				},
+			},
+		},
+		options: Options{"limit": 1, "skip": 1},
+		expected: &SchedulerJobs{
+			TotalRows: 2,
+			Offset:    1,
+			Jobs: []*SchedulerJob{
+				{
+					Database:  "_replicator",
+					JobID:     "a81a78e822837e66df423d54279c15fe+continuous",
+					PID:       "<0.1850.0>",
+					DocID:     "foo",
+					Source:    "http://localhost:5984/a/",
+					Target:    "http://localhost:5984/b/",
+					User:      "admin",
+					Node:      "node1@127.0.0.1",
+					StartTime: ts,
+					History: []SchedulerEvent{
+						{Timestamp: ts, Type: "started"},
+						{Timestamp: ts, Type: "crashed", Reason: "db_not_found"},
+					},
+					Info: &SchedulerInfo{DocsRead: 3},
+				},
+			},
+		},
+	})
+
+	tests.Run(t, func(t *testing.T, tt tst) {
+		jobs, err := tt.client.SchedulerJobs(context.Background(), tt.options)
+		testy.StatusError(t, tt.err, tt.status, err)
+		if d := testy.DiffInterface(tt.expected, jobs); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
+func TestSchedulerDocs(t *testing.T) {
+	type tst struct {
+		client       *Client
+		replicatorDB string
+		expected     *SchedulerDocs
+		status       int
+		err          string
+	}
+	ts := time.Date(2017, 4, 29, 5, 1, 37, 0, time.UTC)
+	tests := testy.NewTable()
+	tests.Add("not supported", tst{
+		client: &Client{driverClient: &mock.Client{}},
+		status: http.StatusNotImplemented,
+		err:    "kivik: driver does not support the replication scheduler",
+	})
+	tests.Add("error", tst{
+		client: &Client{
+			driverClient: &mock.Scheduler{
+				SchedulerDocsFunc: func(_ context.Context, _ string, _ map[string]interface{}) (*driver.SchedulerDocs, error) {
+					return nil, &Error{HTTPStatus: http.StatusNotFound, Message: "not found"}
+				},
+			},
+		},
+		replicatorDB: "missing",
+		status:       http.StatusNotFound,
+		err:          "not found",
+	})
+	tests.Add("success", tst{
+		client: &Client{
+			driverClient: &mock.Scheduler{
+				SchedulerDocsFunc: func(_ context.Context, replicatorDB string, _ map[string]interface{}) (*driver.SchedulerDocs, error) {
+					if replicatorDB != "other/_replicator" {
+						return nil, errors.New("unexpected replicator db: " + replicatorDB)
+					}
+					return &driver.SchedulerDocs{
+						TotalRows: 1,
+						Docs: []*driver.SchedulerDoc{
+							{
+								Database:    "other/_replicator",
+								DocID:       "foo",
+								State:       "crashing",
+								Info:        &driver.SchedulerInfo{Error: "db_not_found"},
+								ErrorCount:  3,
+								LastUpdated: ts,
+								StartTime:   ts,
+							},
+						},
+					}, nil
+				},
+			},
+		},
+		replicatorDB: "other/_replicator",
+		expected: &SchedulerDocs{
+			TotalRows: 1,
+			Docs: []*SchedulerDoc{
+				{
+					Database:    "other/_replicator",
+					DocID:       "foo",
+					State:       ReplicationCrashing,
+					Info:        &SchedulerInfo{Error: "db_not_found"},
+					ErrorCount:  3,
+					LastUpdated: ts,
+					StartTime:   ts,
+				},
+			},
+		},
+	})
+
+	tests.Run(t, func(t *testing.T, tt tst) {
+		docs, err := tt.client.SchedulerDocs(context.Background(), tt.replicatorDB)
+		testy.StatusError(t, tt.err, tt.status, err)
+		if d := testy.DiffInterface(tt.expected, docs); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
+func TestSchedulerDoc(t *testing.T) {
+	type tst struct {
+		client       *Client
+		replicatorDB string
+		docID        string
+		expected     *SchedulerDoc
+		status       int
+		err          string
+	}
+	tests := testy.NewTable()
+	tests.Add("missing replicator db", tst{
+		client: &Client{driverClient: &mock.Client{}},
+		docID:  "foo",
+		status: http.StatusBadRequest,
+		err:    "kivik: replicatorDB required",
+	})
+	tests.Add("missing doc id", tst{
+		client:       &Client{driverClient: &mock.Client{}},
+		replicatorDB: "_replicator",
+		status:       http.StatusBadRequest,
+		err:          "kivik: docID required",
+	})
+	tests.Add("not supported", tst{
+		client:       &Client{driverClient: &mock.Client{}},
+		replicatorDB: "_replicator",
+		docID:        "foo",
+		status:       http.StatusNotImplemented,
+		err:          "kivik: driver does not support the replication scheduler",
+	})
+	tests.Add("success", tst{
+		client: &Client{
+			driverClient: &mock.Scheduler{
+				SchedulerDocFunc: func(_ context.Context, replicatorDB, docID string) (*driver.SchedulerDoc, error) {
+					return &driver.SchedulerDoc{
+						Database: replicatorDB,
+						DocID:    docID,
+						State:    "completed",
+					}, nil
+				},
+			},
+		},
+		replicatorDB: "_replicator",
+		docID:        "foo",
+		expected: &SchedulerDoc{
+			Database: "_replicator",
+			DocID:    "foo",
+			State:    ReplicationComplete,
+		},
+	})
+
+	tests.Run(t, func(t *testing.T, tt tst) {
+		doc, err := tt.client.SchedulerDoc(context.Background(), tt.replicatorDB, tt.docID)
+		testy.StatusError(t, tt.err, tt.status, err)
+		if d := testy.DiffInterface(tt.expected, doc); d != nil {
+			t.Error(d)
+		}
+	})
+}
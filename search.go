@@ -0,0 +1,148 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+var searchNotImplemented = &Error{HTTPStatus: http.StatusNotImplemented, Message: "kivik: driver does not support search interface"}
+
+// SearchInfo is the result of a SearchInfo request.
+type SearchInfo struct {
+	// Name is the name of the search index.
+	Name string
+	// SearchIndex contains statistics about the search index.
+	SearchIndex SearchIndex
+	// RawResponse is the raw response body returned by the server, useful if
+	// you need additional backend-specific information.
+	RawResponse json.RawMessage
+}
+
+// SearchIndex contains textual search index information.
+type SearchIndex struct {
+	PendingSeq   int64
+	DocDelCount  int64
+	DocCount     int64
+	DiskSize     int64
+	CommittedSeq int64
+}
+
+// Facet is a single facet result of a full-text search, as returned when the
+// counts or ranges parameters are set.
+type Facet struct {
+	// Name is the name of the faceted field.
+	Name string
+	// Type is the facet type, either "counts" or "ranges".
+	Type string
+	// Values maps each facet value, or range name, to the number of matching
+	// documents.
+	Values map[string]int64
+}
+
+// Search performs a full-text search against the specified ddoc and index,
+// with the specified Lucene query. ddoc may or may not be prefixed with
+// '_design/'.
+//
+// Facets, groups and bookmarks are available from the returned Rows via the
+// Facets, SearchGroup and Bookmark methods, respectively.
+//
+// See https://docs.couchdb.org/en/stable/api/ddoc/search.html#get--db-_design-ddoc-_search-index
+func (db *DB) Search(ctx context.Context, ddoc, index, query string, options ...Options) (*Rows, error) {
+	if db.err != nil {
+		return nil, db.err
+	}
+	searcher, ok := db.driverDB.(driver.Searcher)
+	if !ok {
+		return nil, searchNotImplemented
+	}
+	ddoc = strings.TrimPrefix(ddoc, "_design/")
+	rowsi, err := searcher.Search(ctx, ddoc, index, query, mergeOptions(options...))
+	if err != nil {
+		return nil, err
+	}
+	return newRows(ctx, rowsi), nil
+}
+
+// SearchInfo returns statistics about the specified search index.
+//
+// See https://docs.couchdb.org/en/stable/api/ddoc/search.html#get--db-_design-ddoc-_search_info-index
+func (db *DB) SearchInfo(ctx context.Context, ddoc, index string) (*SearchInfo, error) {
+	if db.err != nil {
+		return nil, db.err
+	}
+	searcher, ok := db.driverDB.(driver.Searcher)
+	if !ok {
+		return nil, searchNotImplemented
+	}
+	ddoc = strings.TrimPrefix(ddoc, "_design/")
+	info, err := searcher.SearchInfo(ctx, ddoc, index)
+	if err != nil {
+		return nil, err
+	}
+	return &SearchInfo{
+		Name:        info.Name,
+		SearchIndex: SearchIndex(info.SearchIndex),
+		RawResponse: info.RawResponse,
+	}, nil
+}
+
+// SearchAnalyze tests the results of Lucene analyzer tokenization on sample
+// text.
+//
+// See https://docs.couchdb.org/en/stable/api/server/common.html#search-analyze
+func (db *DB) SearchAnalyze(ctx context.Context, text string) ([]string, error) {
+	if db.err != nil {
+		return nil, db.err
+	}
+	searcher, ok := db.driverDB.(driver.Searcher)
+	if !ok {
+		return nil, searchNotImplemented
+	}
+	return searcher.SearchAnalyze(ctx, text)
+}
+
+// Facets returns the facet counts and ranges of a search result, if any. This
+// value is only guaranteed to be set after all result rows have been
+// enumerated through by Next.
+func (r *Rows) Facets() []Facet {
+	f, ok := r.rowsi.(driver.Faceter)
+	if !ok {
+		return nil
+	}
+	dFacets := f.Facets()
+	if dFacets == nil {
+		return nil
+	}
+	facets := make([]Facet, len(dFacets))
+	for i, facet := range dFacets {
+		facets[i] = Facet(facet)
+	}
+	return facets
+}
+
+// SearchGroup returns the group_field value, and the total number of matching
+// rows, for the search result group currently being iterated. EOQ will be true
+// at the end of each group. For ungrouped results, SearchGroup returns an
+// empty string and 0.
+func (r *Rows) SearchGroup() (by string, totalRows int64) {
+	if g, ok := r.rowsi.(driver.SearchGrouper); ok {
+		return g.SearchGroup()
+	}
+	return "", 0
+}
@@ -0,0 +1,246 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestSearch(t *testing.T) {
+	type tt struct {
+		db       *DB
+		ddoc     string
+		index    string
+		query    string
+		options  Options
+		expected *Rows
+		status   int
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("db error", tt{
+		db:     &DB{err: errors.New("db error")},
+		status: http.StatusInternalServerError,
+		err:    "db error",
+	})
+	tests.Add("non-Searcher", tt{
+		db:     &DB{driverDB: &mock.DB{}},
+		status: http.StatusNotImplemented,
+		err:    "kivik: driver does not support search interface",
+	})
+	tests.Add("query error", tt{
+		db: &DB{driverDB: &mock.Searcher{
+			SearchFunc: func(_ context.Context, _, _, _ string, _ map[string]interface{}) (driver.Rows, error) {
+				return nil, &Error{HTTPStatus: http.StatusBadRequest, Message: "bad query"}
+			},
+		}},
+		status: http.StatusBadRequest,
+		err:    "bad query",
+	})
+	tests.Add("success", tt{
+		db: &DB{driverDB: &mock.Searcher{
+			SearchFunc: func(_ context.Context, ddoc, index, query string, opts map[string]interface{}) (driver.Rows, error) {
+				if ddoc != "foo" || index != "bar" || query != "baz:qux" {
+					return nil, fmt.Errorf("Unexpected args: %s, %s, %s", ddoc, index, query)
+				}
+				if d := testy.DiffInterface(testOptions, opts); d != nil {
+					return nil, fmt.Errorf("Unexpected options: %s", d)
+				}
+				return &mock.Rows{ID: "a"}, nil
+			},
+		}},
+		ddoc:    "_design/foo",
+		index:   "bar",
+		query:   "baz:qux",
+		options: testOptions,
+		expected: &Rows{
+			iter: &iter{
+				feed: &rowsIterator{
+					Rows: &mock.Rows{ID: "a"},
+				},
+				curVal: &driver.Row{},
+			},
+			rowsi: &mock.Rows{ID: "a"},
+		},
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		rows, err := tt.db.Search(context.Background(), tt.ddoc, tt.index, tt.query, tt.options)
+		testy.StatusError(t, tt.err, tt.status, err)
+		rows.ctx, rows.stop = nil, nil // Determinism
+		if d := testy.DiffInterface(tt.expected, rows); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
+func TestSearchInfo(t *testing.T) {
+	type tt struct {
+		db       *DB
+		ddoc     string
+		index    string
+		expected *SearchInfo
+		status   int
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("db error", tt{
+		db:     &DB{err: errors.New("db error")},
+		status: http.StatusInternalServerError,
+		err:    "db error",
+	})
+	tests.Add("non-Searcher", tt{
+		db:     &DB{driverDB: &mock.DB{}},
+		status: http.StatusNotImplemented,
+		err:    "kivik: driver does not support search interface",
+	})
+	tests.Add("error", tt{
+		db: &DB{driverDB: &mock.Searcher{
+			SearchInfoFunc: func(_ context.Context, _, _ string) (*driver.SearchInfo, error) {
+				return nil, &Error{HTTPStatus: http.StatusNotFound, Message: "index not found"}
+			},
+		}},
+		status: http.StatusNotFound,
+		err:    "index not found",
+	})
+	tests.Add("success", tt{
+		db: &DB{driverDB: &mock.Searcher{
+			SearchInfoFunc: func(_ context.Context, ddoc, index string) (*driver.SearchInfo, error) {
+				if ddoc != "foo" || index != "bar" {
+					return nil, fmt.Errorf("Unexpected args: %s, %s", ddoc, index)
+				}
+				return &driver.SearchInfo{
+					Name: "_design/foo/bar",
+					SearchIndex: driver.SearchIndex{
+						PendingSeq:   7,
+						DocCount:     3,
+						CommittedSeq: 5,
+					},
+				}, nil
+			},
+		}},
+		ddoc:  "_design/foo",
+		index: "bar",
+		expected: &SearchInfo{
+			Name: "_design/foo/bar",
+			SearchIndex: SearchIndex{
+				PendingSeq:   7,
+				DocCount:     3,
+				CommittedSeq: 5,
+			},
+		},
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		info, err := tt.db.SearchInfo(context.Background(), tt.ddoc, tt.index)
+		testy.StatusError(t, tt.err, tt.status, err)
+		if d := testy.DiffInterface(tt.expected, info); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
+func TestSearchAnalyze(t *testing.T) {
+	type tt struct {
+		db       *DB
+		text     string
+		expected []string
+		status   int
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("db error", tt{
+		db:     &DB{err: errors.New("db error")},
+		status: http.StatusInternalServerError,
+		err:    "db error",
+	})
+	tests.Add("non-Searcher", tt{
+		db:     &DB{driverDB: &mock.DB{}},
+		status: http.StatusNotImplemented,
+		err:    "kivik: driver does not support search interface",
+	})
+	tests.Add("success", tt{
+		db: &DB{driverDB: &mock.Searcher{
+			SearchAnalyzeFunc: func(_ context.Context, text string) ([]string, error) {
+				if text != "Foo Bar" {
+					return nil, fmt.Errorf("Unexpected text: %s", text)
+				}
+				return []string{"foo", "bar"}, nil
+			},
+		}},
+		text:     "Foo Bar",
+		expected: []string{"foo", "bar"},
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		tokens, err := tt.db.SearchAnalyze(context.Background(), tt.text)
+		testy.StatusError(t, tt.err, tt.status, err)
+		if d := testy.DiffInterface(tt.expected, tokens); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
+func TestFacets(t *testing.T) {
+	t.Run("Faceter", func(t *testing.T) {
+		r := newRows(context.Background(), &mock.Faceter{
+			FacetsFunc: func() []driver.Facet {
+				return []driver.Facet{
+					{Name: "type", Type: "counts", Values: map[string]int64{"fruit": 3}},
+					{Name: "price", Type: "ranges", Values: map[string]int64{"cheap": 1, "expensive": 2}},
+				}
+			},
+		})
+		expected := []Facet{
+			{Name: "type", Type: "counts", Values: map[string]int64{"fruit": 3}},
+			{Name: "price", Type: "ranges", Values: map[string]int64{"cheap": 1, "expensive": 2}},
+		}
+		if d := testy.DiffInterface(expected, r.Facets()); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("Non Faceter", func(t *testing.T) {
+		r := newRows(context.Background(), &mock.Rows{})
+		if f := r.Facets(); f != nil {
+			t.Errorf("Unexpected facets: %v", f)
+		}
+	})
+}
+
+func TestSearchGroup(t *testing.T) {
+	t.Run("SearchGrouper", func(t *testing.T) {
+		r := newRows(context.Background(), &mock.SearchGrouper{
+			SearchGroupFunc: func() (string, int64) { return "fruit", 12 },
+		})
+		by, total := r.SearchGroup()
+		if by != "fruit" || total != 12 {
+			t.Errorf("Unexpected result: %s, %d", by, total)
+		}
+	})
+	t.Run("Non SearchGrouper", func(t *testing.T) {
+		r := newRows(context.Background(), &mock.Rows{})
+		by, total := r.SearchGroup()
+		if by != "" || total != 0 {
+			t.Errorf("Unexpected result: %s, %d", by, total)
+		}
+	})
+}
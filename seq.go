@@ -0,0 +1,294 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-kivik/kivik/v4/internal/erlterm"
+)
+
+// Seq is a parsed database update sequence, as returned by Changes.Seq,
+// Changes.LastSeq, Rows.UpdateSeq and DBStats.UpdateSeq. Two forms are
+// understood:
+//
+//   - integer sequences, such as "42", as used by CouchDB 1.x and PouchDB
+//   - clustered sequences, such as "42-g1AAAA...", as used by CouchDB 2.0 and
+//     later, which consist of a numeric prefix, and an opaque suffix encoding
+//     the sequence of each shard
+//
+// The zero value represents the start of the database, as does the
+// sequence "0".
+type Seq struct {
+	raw    string
+	number int64
+	opaque string
+}
+
+// ParseSeq parses an update sequence.
+func ParseSeq(seq string) (Seq, error) {
+	s := Seq{raw: seq}
+	if seq == "" {
+		return s, nil
+	}
+	if strings.HasPrefix(seq, "[") {
+		// BigCouch and Cloudant return sequences as [number, "opaque"].
+		var parts []json.RawMessage
+		if err := json.Unmarshal([]byte(seq), &parts); err != nil || len(parts) != 2 {
+			return Seq{}, badSeq(seq)
+		}
+		if err := json.Unmarshal(parts[0], &s.number); err != nil {
+			return Seq{}, badSeq(seq)
+		}
+		if err := json.Unmarshal(parts[1], &s.opaque); err != nil {
+			return Seq{}, badSeq(seq)
+		}
+		return s, nil
+	}
+	prefix := seq
+	if i := strings.IndexByte(seq, '-'); i >= 0 {
+		prefix, s.opaque = seq[:i], seq[i+1:]
+		if s.opaque == "" {
+			return Seq{}, badSeq(seq)
+		}
+	}
+	n, err := strconv.ParseInt(prefix, 10, 64)
+	if err != nil || n < 0 {
+		return Seq{}, badSeq(seq)
+	}
+	s.number = n
+	return s, nil
+}
+
+func badSeq(seq string) error {
+	return &Error{HTTPStatus: http.StatusBadRequest, Message: fmt.Sprintf("kivik: invalid update sequence: %q", seq)}
+}
+
+// String returns the sequence exactly as it was parsed, suitable for passing
+// back to the server as the since option.
+func (s Seq) String() string {
+	return s.raw
+}
+
+// Number returns the numeric prefix of a clustered sequence, or the value of
+// an integer sequence. For a clustered sequence it is the sum of the shard
+// sequences, so the difference between the numbers of two sequences from the
+// same database estimates the number of changes between them.
+func (s Seq) Number() int64 {
+	return s.number
+}
+
+// Clustered returns true if s is a clustered sequence.
+func (s Seq) Clustered() bool {
+	return s.opaque != ""
+}
+
+// Compare returns -1 if s is older than other, 1 if s is newer, and 0 if they
+// represent the same point in the database's history, or if their order
+// cannot be determined. Sequences are only comparable when they come from
+// the same database.
+//
+// Clustered sequences are compared shard by shard where possible. When that
+// is inconclusive, such as after shards have been moved, or when the opaque
+// part cannot be decoded, they are ordered by their numeric prefix.
+func (s Seq) Compare(other Seq) int {
+	if s.raw == other.raw {
+		return 0
+	}
+	if s.Clustered() && other.Clustered() {
+		a, errA := s.Shards()
+		b, errB := other.Shards()
+		if errA == nil && errB == nil {
+			if cmp, ok := compareShards(a, b); ok {
+				return cmp
+			}
+		}
+	}
+	switch {
+	case s.number < other.number:
+		return -1
+	case s.number > other.number:
+		return 1
+	}
+	return 0
+}
+
+// MarshalJSON marshals the sequence as a JSON string.
+func (s Seq) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.raw)
+}
+
+// UnmarshalJSON parses a sequence from a JSON number, string or array.
+func (s *Seq) UnmarshalJSON(data []byte) error {
+	var str string
+	switch {
+	case len(data) > 0 && data[0] == '"':
+		if err := json.Unmarshal(data, &str); err != nil {
+			return err
+		}
+	case string(data) == "null":
+	default:
+		str = string(data)
+	}
+	seq, err := ParseSeq(str)
+	if err != nil {
+		return err
+	}
+	*s = seq
+	return nil
+}
+
+// SeqShard is the sequence of a single shard range, as encoded in a clustered
+// update sequence.
+type SeqShard struct {
+	// Node is the name of the node from which the shard's changes were read.
+	Node string
+	// Begin and End are the bounds of the shard's hash range.
+	Begin, End uint32
+	// Seq is the shard's update sequence.
+	Seq int64
+	// UUID is the prefix of the shard's UUID, if present.
+	UUID string
+}
+
+// Range returns the shard's range, formatted as CouchDB names it, for example
+// "00000000-7fffffff".
+func (s SeqShard) Range() string {
+	return fmt.Sprintf("%08x-%08x", s.Begin, s.End)
+}
+
+// Shards decodes the per-shard sequences of a clustered sequence. It returns
+// nil for an integer sequence. The encoding is internal to CouchDB, so
+// decoding is best-effort, and an error is returned for any value which is
+// not understood.
+func (s Seq) Shards() ([]SeqShard, error) {
+	if s.opaque == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s.opaque, "="))
+	if err != nil {
+		return nil, seqShardsError(err)
+	}
+	term, err := erlterm.Decode(data)
+	if err != nil {
+		return nil, seqShardsError(err)
+	}
+	list, ok := term.(erlterm.List)
+	if !ok {
+		return nil, seqShardsError(fmt.Errorf("unexpected %T", term))
+	}
+	shards := make([]SeqShard, 0, len(list))
+	for _, item := range list {
+		shard, err := parseSeqShard(item)
+		if err != nil {
+			return nil, seqShardsError(err)
+		}
+		shards = append(shards, shard)
+	}
+	return shards, nil
+}
+
+func seqShardsError(err error) error {
+	return &Error{HTTPStatus: http.StatusBadGateway, Message: "kivik: unrecognized clustered sequence", Err: err}
+}
+
+// parseSeqShard parses a term of the form {Node, [Begin, End], Seq}, where
+// Seq is either an integer, or a tuple {Seq, UUID, EpochNode}.
+func parseSeqShard(term interface{}) (SeqShard, error) {
+	var shard SeqShard
+	tuple, ok := term.(erlterm.Tuple)
+	if !ok || len(tuple) != 3 {
+		return shard, fmt.Errorf("unexpected shard %v", term)
+	}
+	node, ok := tuple[0].(erlterm.Atom)
+	if !ok {
+		return shard, fmt.Errorf("unexpected node %v", tuple[0])
+	}
+	shard.Node = string(node)
+	rng, ok := tuple[1].(erlterm.List)
+	if !ok || len(rng) != 2 {
+		return shard, fmt.Errorf("unexpected range %v", tuple[1])
+	}
+	begin, ok1 := rangeBound(rng[0])
+	end, ok2 := rangeBound(rng[1])
+	if !ok1 || !ok2 {
+		return shard, fmt.Errorf("unexpected range %v", tuple[1])
+	}
+	shard.Begin, shard.End = begin, end
+	seq := tuple[2]
+	if t, ok := seq.(erlterm.Tuple); ok && len(t) > 0 {
+		seq = t[0]
+		if len(t) > 1 {
+			if uuid, ok := t[1].([]byte); ok {
+				shard.UUID = string(uuid)
+			}
+		}
+	}
+	n, ok := seq.(int64)
+	if !ok {
+		return shard, fmt.Errorf("unexpected sequence %v", tuple[2])
+	}
+	shard.Seq = n
+	return shard, nil
+}
+
+func rangeBound(term interface{}) (uint32, bool) {
+	n, ok := term.(int64)
+	if !ok || n < 0 || n > 1<<32-1 {
+		return 0, false
+	}
+	return uint32(n), true
+}
+
+// compareShards compares two sets of shard sequences range by range. ok is
+// false if the sets cover different ranges, or if neither is ahead of the
+// other on every range.
+func compareShards(a, b []SeqShard) (cmp int, ok bool) {
+	if len(a) != len(b) {
+		return 0, false
+	}
+	seqs := make(map[[2]uint32]int64, len(a))
+	for _, shard := range a {
+		seqs[[2]uint32{shard.Begin, shard.End}] = shard.Seq
+	}
+	if len(seqs) != len(a) {
+		return 0, false
+	}
+	var older, newer bool
+	for _, shard := range b {
+		seq, found := seqs[[2]uint32{shard.Begin, shard.End}]
+		if !found {
+			return 0, false
+		}
+		switch {
+		case seq < shard.Seq:
+			older = true
+		case seq > shard.Seq:
+			newer = true
+		}
+	}
+	switch {
+	case older && newer:
+		return 0, false
+	case older:
+		return -1, true
+	case newer:
+		return 1, true
+	}
+	return 0, true
+}
@@ -0,0 +1,239 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/internal/erlterm"
+)
+
+const testNode = "couchdb@127.0.0.1"
+
+// clusteredSeq builds a clustered sequence the way CouchDB does, from the
+// per-shard sequences of two shard ranges.
+func clusteredSeq(seqs ...int64) string {
+	list := erlterm.List{}
+	var sum int64
+	for i, seq := range seqs {
+		begin := int64(i) << 31
+		list = append(list, erlterm.Tuple{
+			erlterm.Atom(testNode),
+			erlterm.List{begin, begin + 1<<31 - 1},
+			erlterm.Tuple{seq, "a1b2c3", erlterm.Atom(testNode)},
+		})
+		sum += seq
+	}
+	term, err := erlterm.Encode(list)
+	if err != nil {
+		panic(err)
+	}
+	buf := &bytes.Buffer{}
+	w := zlib.NewWriter(buf)
+	_, _ = w.Write(term[1:])
+	_ = w.Close()
+	size := make([]byte, 4)
+	binary.BigEndian.PutUint32(size, uint32(len(term)-1))
+	data := append([]byte{131, 80}, size...)
+	data = append(data, buf.Bytes()...)
+	return fmt.Sprintf("%d-%s", sum, base64.RawURLEncoding.EncodeToString(data))
+}
+
+// couchDB3Seq is a sequence in the form returned by CouchDB 2.x and 3.x for a
+// database with two shards on node couchdb@127.0.0.1: the compressed external
+// term format of [{Node, [Begin, End], {Seq, UUIDPrefix, Node}}], as encoded
+// by term_to_binary(Term, [compressed]) on OTP 25, using atoms of type
+// ATOM_EXT.
+const couchDB3Seq = "58-g1AAAACbeJzLYWBgYMpgTmEQTM4vTc5ISXIwNDLXMwBCwxyQVCJDUv3___-zMpgTpXOBAuypFkkmiQZp2DTgMSaPBUgyNACp_1DT5MGmJRmlWSYbm2LTlwUAS10o6A"
+
+func TestParseSeq(t *testing.T) {
+	type tst struct {
+		seq       string
+		number    int64
+		clustered bool
+		status    int
+		err       string
+	}
+	tests := testy.NewTable()
+	tests.Add("empty", tst{})
+	tests.Add("integer", tst{
+		seq:    "42",
+		number: 42,
+	})
+	tests.Add("clustered", tst{
+		seq:       couchDB3Seq,
+		number:    58,
+		clustered: true,
+	})
+	tests.Add("bigcouch", tst{
+		seq:       `[123,"g1AAAAFTeJzLYWBg"]`,
+		number:    123,
+		clustered: true,
+	})
+	tests.Add("not a number", tst{
+		seq:    "now",
+		status: http.StatusBadRequest,
+		err:    `kivik: invalid update sequence: "now"`,
+	})
+	tests.Add("negative", tst{
+		seq:    "-1",
+		status: http.StatusBadRequest,
+		err:    `kivik: invalid update sequence: "-1"`,
+	})
+	tests.Add("missing suffix", tst{
+		seq:    "1-",
+		status: http.StatusBadRequest,
+		err:    `kivik: invalid update sequence: "1-"`,
+	})
+	tests.Add("bad array", tst{
+		seq:    `["1","abc"]`,
+		status: http.StatusBadRequest,
+		err:    `kivik: invalid update sequence: "[\"1\",\"abc\"]"`,
+	})
+
+	tests.Run(t, func(t *testing.T, tt tst) {
+		seq, err := ParseSeq(tt.seq)
+		testy.StatusError(t, tt.err, tt.status, err)
+		if seq.String() != tt.seq {
+			t.Errorf("Unexpected string: %s", seq)
+		}
+		if seq.Number() != tt.number {
+			t.Errorf("Unexpected number: %d", seq.Number())
+		}
+		if seq.Clustered() != tt.clustered {
+			t.Errorf("Unexpected clustered: %t", seq.Clustered())
+		}
+	})
+}
+
+func TestSeqShards(t *testing.T) {
+	t.Run("CouchDB 3.x", func(t *testing.T) {
+		seq, err := ParseSeq(couchDB3Seq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		shards, err := seq.Shards()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []SeqShard{
+			{Node: testNode, Begin: 0, End: 0x7fffffff, Seq: 27, UUID: "e8b4a0f"},
+			{Node: testNode, Begin: 0x80000000, End: 0xffffffff, Seq: 31, UUID: "b2f9c35"},
+		}
+		if d := testy.DiffInterface(expected, shards); d != nil {
+			t.Error(d)
+		}
+		if r := shards[1].Range(); r != "80000000-ffffffff" {
+			t.Errorf("Unexpected range: %s", r)
+		}
+	})
+	t.Run("clustered", func(t *testing.T) {
+		seq, err := ParseSeq(clusteredSeq(3, 4))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seq.Number() != 7 {
+			t.Errorf("Unexpected number: %d", seq.Number())
+		}
+		shards, err := seq.Shards()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []SeqShard{
+			{Node: testNode, Begin: 0, End: 0x7fffffff, Seq: 3, UUID: "a1b2c3"},
+			{Node: testNode, Begin: 0x80000000, End: 0xffffffff, Seq: 4, UUID: "a1b2c3"},
+		}
+		if d := testy.DiffInterface(expected, shards); d != nil {
+			t.Error(d)
+		}
+		if r := shards[1].Range(); r != "80000000-ffffffff" {
+			t.Errorf("Unexpected range: %s", r)
+		}
+	})
+	t.Run("integer", func(t *testing.T) {
+		seq, _ := ParseSeq("42")
+		shards, err := seq.Shards()
+		if err != nil || shards != nil {
+			t.Errorf("Unexpected result: %v, %v", shards, err)
+		}
+	})
+	t.Run("unrecognized", func(t *testing.T) {
+		seq, _ := ParseSeq("42-notbase64!")
+		_, err := seq.Shards()
+		testy.StatusError(t, "kivik: unrecognized clustered sequence: illegal base64 data at input byte 9", http.StatusBadGateway, err)
+	})
+}
+
+func TestSeqCompare(t *testing.T) {
+	type tst struct {
+		a, b     string
+		expected int
+	}
+	tests := testy.NewTable()
+	tests.Add("equal", tst{a: "5", b: "5", expected: 0})
+	tests.Add("integer older", tst{a: "5", b: "10", expected: -1})
+	tests.Add("integer newer", tst{a: "10", b: "5", expected: 1})
+	tests.Add("empty", tst{a: "", b: "1", expected: -1})
+	tests.Add("clustered older", tst{a: clusteredSeq(3, 4), b: clusteredSeq(3, 5), expected: -1})
+	tests.Add("clustered newer", tst{a: clusteredSeq(6, 4), b: clusteredSeq(3, 4), expected: 1})
+	tests.Add("clustered same", tst{a: clusteredSeq(3, 4), b: clusteredSeq(3, 4), expected: 0})
+	tests.Add("clustered diverged", tst{a: clusteredSeq(2, 9), b: clusteredSeq(5, 4), expected: 1})
+	tests.Add("clustered undecodable", tst{a: "3-foo", b: "4-bar", expected: -1})
+	tests.Add("mixed", tst{a: "3", b: clusteredSeq(3, 4), expected: -1})
+
+	tests.Run(t, func(t *testing.T, tt tst) {
+		a, err := ParseSeq(tt.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ParseSeq(tt.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cmp := a.Compare(b); cmp != tt.expected {
+			t.Errorf("Expected %d, got %d", tt.expected, cmp)
+		}
+	})
+}
+
+func TestSeqJSON(t *testing.T) {
+	var result struct {
+		Number Seq `json:"number"`
+		String Seq `json:"string"`
+		Array  Seq `json:"array"`
+		Null   Seq `json:"null"`
+	}
+	input := `{"number":12,"string":"13-abc","array":[14,"def"],"null":null}`
+	if err := json.Unmarshal([]byte(input), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Number.Number() != 12 || result.String.Number() != 13 || result.Array.Number() != 14 || result.Null.Number() != 0 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if d := testy.DiffAsJSON([]byte(`{"number":"12","string":"13-abc","array":"[14,\"def\"]","null":""}`), result); d != nil {
+		t.Error(d)
+	}
+	var seq Seq
+	if err := json.Unmarshal([]byte(`true`), &seq); err == nil {
+		t.Error("Expected an error")
+	}
+}
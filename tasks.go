@@ -0,0 +1,291 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// Active task types, as found in TaskInfo.Type.
+const (
+	TaskDatabaseCompaction = "database_compaction"
+	TaskViewCompaction     = "view_compaction"
+	TaskIndexer            = "indexer"
+	TaskReplication        = "replication"
+	TaskSearchIndexer      = "search_indexer"
+)
+
+// ActiveTask is a task running on the server. The concrete type is one of
+// *DatabaseCompactionTask, *ViewCompactionTask, *IndexerTask,
+// *ReplicationTask, *SearchIndexerTask or, for any other task type,
+// *UnknownTask.
+type ActiveTask interface {
+	// Info returns the fields common to all tasks.
+	Info() *TaskInfo
+}
+
+// TaskInfo contains the fields common to all active tasks.
+type TaskInfo struct {
+	// Type is the task type, such as TaskIndexer.
+	Type string
+	// PID is the process ID of the task on the server.
+	PID string
+	// Node is the cluster node running the task.
+	Node string
+	// Progress is the percentage of the task completed, from 0 to 100. It is
+	// always 0 for replication tasks.
+	Progress int
+	// StartedOn is the time the task was started.
+	StartedOn time.Time
+	// UpdatedOn is the time the task was last updated.
+	UpdatedOn time.Time
+	// RawTask is the raw JSON object describing the task, useful for fields
+	// not otherwise exposed.
+	RawTask json.RawMessage
+}
+
+// Info returns t.
+func (t *TaskInfo) Info() *TaskInfo {
+	return t
+}
+
+// DatabaseCompactionTask is a running database compaction.
+type DatabaseCompactionTask struct {
+	TaskInfo
+	Database     string
+	ChangesDone  int64
+	TotalChanges int64
+}
+
+// ViewCompactionTask is a running view compaction.
+type ViewCompactionTask struct {
+	TaskInfo
+	Database     string
+	DesignDoc    string
+	Phase        string
+	ChangesDone  int64
+	TotalChanges int64
+}
+
+// IndexerTask is a running view index build.
+type IndexerTask struct {
+	TaskInfo
+	Database     string
+	DesignDoc    string
+	ChangesDone  int64
+	TotalChanges int64
+}
+
+// SearchIndexerTask is a running search index build.
+type SearchIndexerTask struct {
+	TaskInfo
+	Database     string
+	DesignDoc    string
+	Index        string
+	ChangesDone  int64
+	TotalChanges int64
+}
+
+// ReplicationTask is a running replication.
+type ReplicationTask struct {
+	TaskInfo
+	ReplicationID         string
+	DocID                 string
+	Source                string
+	Target                string
+	Continuous            bool
+	DocsRead              int64
+	DocsWritten           int64
+	DocWriteFailures      int64
+	MissingRevisionsFound int64
+	RevisionsChecked      int64
+	ChangesPending        int64
+	SourceSeq             string
+	CheckpointedSourceSeq string
+	ThroughSeq            string
+}
+
+// UnknownTask is a task of a type not otherwise known to Kivik. Its details
+// may be read from RawTask.
+type UnknownTask struct {
+	TaskInfo
+}
+
+var (
+	_ ActiveTask = &DatabaseCompactionTask{}
+	_ ActiveTask = &ViewCompactionTask{}
+	_ ActiveTask = &IndexerTask{}
+	_ ActiveTask = &SearchIndexerTask{}
+	_ ActiveTask = &ReplicationTask{}
+	_ ActiveTask = &UnknownTask{}
+)
+
+// ActiveTasks returns the tasks currently running on the server, such as
+// compactions, index builds and replications.
+//
+// See https://docs.couchdb.org/en/stable/api/server/common.html#active-tasks
+func (c *Client) ActiveTasks(ctx context.Context) ([]ActiveTask, error) {
+	tasker, ok := c.driverClient.(driver.ActiveTasker)
+	if !ok {
+		return nil, &Error{HTTPStatus: http.StatusNotImplemented, Message: "kivik: driver does not support active tasks"}
+	}
+	raw, err := tasker.ActiveTasks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tasks := make([]ActiveTask, len(raw))
+	for i, r := range raw {
+		if tasks[i], err = parseActiveTask(r); err != nil {
+			return nil, err
+		}
+	}
+	return tasks, nil
+}
+
+// rawTask is the union of the fields of all known task types.
+type rawTask struct {
+	Type         string `json:"type"`
+	PID          string `json:"pid"`
+	Node         string `json:"node"`
+	Progress     *int   `json:"progress"`
+	StartedOn    int64  `json:"started_on"`
+	UpdatedOn    int64  `json:"updated_on"`
+	Database     string `json:"database"`
+	DesignDoc    string `json:"design_document"`
+	Index        string `json:"index"`
+	Phase        string `json:"phase"`
+	ChangesDone  int64  `json:"changes_done"`
+	TotalChanges int64  `json:"total_changes"`
+
+	ReplicationID         string          `json:"replication_id"`
+	DocID                 string          `json:"doc_id"`
+	Source                string          `json:"source"`
+	Target                string          `json:"target"`
+	Continuous            bool            `json:"continuous"`
+	DocsRead              int64           `json:"docs_read"`
+	DocsWritten           int64           `json:"docs_written"`
+	DocWriteFailures      int64           `json:"doc_write_failures"`
+	MissingRevisionsFound int64           `json:"missing_revisions_found"`
+	RevisionsChecked      int64           `json:"revisions_checked"`
+	ChangesPending        int64           `json:"changes_pending"`
+	SourceSeq             json.RawMessage `json:"source_seq"`
+	CheckpointedSourceSeq json.RawMessage `json:"checkpointed_source_seq"`
+	ThroughSeq            json.RawMessage `json:"through_seq"`
+}
+
+func parseActiveTask(raw json.RawMessage) (ActiveTask, error) {
+	var t rawTask
+	if err := json.Unmarshal(raw, &t); err != nil {
+		return nil, &Error{HTTPStatus: http.StatusBadGateway, Err: err}
+	}
+	info := TaskInfo{
+		Type:      t.Type,
+		PID:       t.PID,
+		Node:      t.Node,
+		Progress:  progress(t.Progress, t.ChangesDone, t.TotalChanges),
+		StartedOn: unixTime(t.StartedOn),
+		UpdatedOn: unixTime(t.UpdatedOn),
+		RawTask:   raw,
+	}
+	switch t.Type {
+	case TaskDatabaseCompaction:
+		return &DatabaseCompactionTask{
+			TaskInfo:     info,
+			Database:     t.Database,
+			ChangesDone:  t.ChangesDone,
+			TotalChanges: t.TotalChanges,
+		}, nil
+	case TaskViewCompaction:
+		return &ViewCompactionTask{
+			TaskInfo:     info,
+			Database:     t.Database,
+			DesignDoc:    t.DesignDoc,
+			Phase:        t.Phase,
+			ChangesDone:  t.ChangesDone,
+			TotalChanges: t.TotalChanges,
+		}, nil
+	case TaskIndexer:
+		return &IndexerTask{
+			TaskInfo:     info,
+			Database:     t.Database,
+			DesignDoc:    t.DesignDoc,
+			ChangesDone:  t.ChangesDone,
+			TotalChanges: t.TotalChanges,
+		}, nil
+	case TaskSearchIndexer:
+		return &SearchIndexerTask{
+			TaskInfo:     info,
+			Database:     t.Database,
+			DesignDoc:    t.DesignDoc,
+			Index:        t.Index,
+			ChangesDone:  t.ChangesDone,
+			TotalChanges: t.TotalChanges,
+		}, nil
+	case TaskReplication:
+		return &ReplicationTask{
+			TaskInfo:              info,
+			ReplicationID:         t.ReplicationID,
+			DocID:                 t.DocID,
+			Source:                t.Source,
+			Target:                t.Target,
+			Continuous:            t.Continuous,
+			DocsRead:              t.DocsRead,
+			DocsWritten:           t.DocsWritten,
+			DocWriteFailures:      t.DocWriteFailures,
+			MissingRevisionsFound: t.MissingRevisionsFound,
+			RevisionsChecked:      t.RevisionsChecked,
+			ChangesPending:        t.ChangesPending,
+			SourceSeq:             seqString(t.SourceSeq),
+			CheckpointedSourceSeq: seqString(t.CheckpointedSourceSeq),
+			ThroughSeq:            seqString(t.ThroughSeq),
+		}, nil
+	}
+	return &UnknownTask{TaskInfo: info}, nil
+}
+
+// progress returns the reported progress, or if none was reported, an
+// estimate based on the number of changes processed.
+func progress(reported *int, done, total int64) int {
+	if reported != nil {
+		return *reported
+	}
+	if total <= 0 {
+		return 0
+	}
+	return int(done * 100 / total)
+}
+
+func unixTime(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
+
+// seqString returns a sequence, which may be a JSON string or number, as a
+// string.
+func seqString(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	if string(raw) == "null" {
+		return ""
+	}
+	return string(raw)
+}
@@ -0,0 +1,211 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestActiveTasks(t *testing.T) {
+	type tst struct {
+		client   *Client
+		expected []ActiveTask
+		status   int
+		err      string
+	}
+	tasker := func(tasks ...string) *Client {
+		return &Client{
+			driverClient: &mock.ActiveTasker{
+				ActiveTasksFunc: func(_ context.Context) ([]json.RawMessage, error) {
+					raw := make([]json.RawMessage, len(tasks))
+					for i, task := range tasks {
+						raw[i] = json.RawMessage(task)
+					}
+					return raw, nil
+				},
+			},
+		}
+	}
+	tests := testy.NewTable()
+	tests.Add("not supported", tst{
+		client: &Client{driverClient: &mock.Client{}},
+		status: http.StatusNotImplemented,
+		err:    "kivik: driver does not support active tasks",
+	})
+	tests.Add("driver error", tst{
+		client: &Client{
+			driverClient: &mock.ActiveTasker{
+				ActiveTasksFunc: func(_ context.Context) ([]json.RawMessage, error) {
+					return nil, errors.New("tasks failed")
+				},
+			},
+		},
+		status: http.StatusInternalServerError,
+		err:    "tasks failed",
+	})
+	tests.Add("invalid JSON", tst{
+		client: tasker(`{"type":`),
+		status: http.StatusBadGateway,
+		err:    "unexpected end of JSON input",
+	})
+	tests.Add("no tasks", tst{
+		client:   tasker(),
+		expected: []ActiveTask{},
+	})
+	tests.Add("database compaction", func() interface{} {
+		raw := `{"type":"database_compaction","pid":"<0.1.0>","node":"node1@127.0.0.1","database":"foo","changes_done":44,"total_changes":100,"progress":44,"started_on":1376116576,"updated_on":1376116619}`
+		return tst{
+			client: tasker(raw),
+			expected: []ActiveTask{&DatabaseCompactionTask{
+				TaskInfo: TaskInfo{
+					Type:      TaskDatabaseCompaction,
+					PID:       "<0.1.0>",
+					Node:      "node1@127.0.0.1",
+					Progress:  44,
+					StartedOn: time.Unix(1376116576, 0),
+					UpdatedOn: time.Unix(1376116619, 0),
+					RawTask:   json.RawMessage(raw),
+				},
+				Database:     "foo",
+				ChangesDone:  44,
+				TotalChanges: 100,
+			}},
+		}
+	})
+	tests.Add("view compaction", func() interface{} {
+		raw := `{"type":"view_compaction","pid":"<0.2.0>","database":"foo","design_document":"_design/bar","phase":"view","changes_done":5,"total_changes":10,"progress":50}`
+		return tst{
+			client: tasker(raw),
+			expected: []ActiveTask{&ViewCompactionTask{
+				TaskInfo: TaskInfo{
+					Type:     TaskViewCompaction,
+					PID:      "<0.2.0>",
+					Progress: 50,
+					RawTask:  json.RawMessage(raw),
+				},
+				Database:     "foo",
+				DesignDoc:    "_design/bar",
+				Phase:        "view",
+				ChangesDone:  5,
+				TotalChanges: 10,
+			}},
+		}
+	})
+	tests.Add("indexer without progress", func() interface{} {
+		raw := `{"type":"indexer","pid":"<0.3.0>","database":"foo","design_document":"_design/bar","changes_done":25,"total_changes":200}`
+		return tst{
+			client: tasker(raw),
+			expected: []ActiveTask{&IndexerTask{
+				TaskInfo: TaskInfo{
+					Type:     TaskIndexer,
+					PID:      "<0.3.0>",
+					Progress: 12,
+					RawTask:  json.RawMessage(raw),
+				},
+				Database:     "foo",
+				DesignDoc:    "_design/bar",
+				ChangesDone:  25,
+				TotalChanges: 200,
+			}},
+		}
+	})
+	tests.Add("search indexer", func() interface{} {
+		raw := `{"type":"search_indexer","pid":"<0.4.0>","database":"foo","design_document":"_design/bar","index":"idx","changes_done":1,"total_changes":1,"progress":100}`
+		return tst{
+			client: tasker(raw),
+			expected: []ActiveTask{&SearchIndexerTask{
+				TaskInfo: TaskInfo{
+					Type:     TaskSearchIndexer,
+					PID:      "<0.4.0>",
+					Progress: 100,
+					RawTask:  json.RawMessage(raw),
+				},
+				Database:     "foo",
+				DesignDoc:    "_design/bar",
+				Index:        "idx",
+				ChangesDone:  1,
+				TotalChanges: 1,
+			}},
+		}
+	})
+	tests.Add("replication", func() interface{} {
+		raw := `{"type":"replication","pid":"<0.5.0>","replication_id":"abc+continuous","doc_id":null,"source":"http://a/foo/","target":"http://b/foo/","continuous":true,"docs_read":10,"docs_written":9,"doc_write_failures":1,"missing_revisions_found":10,"revisions_checked":12,"changes_pending":0,"source_seq":"15-g1AAAA","checkpointed_source_seq":15,"through_seq":null}`
+		return tst{
+			client: tasker(raw),
+			expected: []ActiveTask{&ReplicationTask{
+				TaskInfo: TaskInfo{
+					Type:    TaskReplication,
+					PID:     "<0.5.0>",
+					RawTask: json.RawMessage(raw),
+				},
+				ReplicationID:         "abc+continuous",
+				Source:                "http://a/foo/",
+				Target:                "http://b/foo/",
+				Continuous:            true,
+				DocsRead:              10,
+				DocsWritten:           9,
+				DocWriteFailures:      1,
+				MissingRevisionsFound: 10,
+				RevisionsChecked:      12,
+				SourceSeq:             "15-g1AAAA",
+				CheckpointedSourceSeq: "15",
+			}},
+		}
+	})
+	tests.Add("unknown type", func() interface{} {
+		raw := `{"type":"reshard","pid":"<0.6.0>"}`
+		return tst{
+			client: tasker(raw),
+			expected: []ActiveTask{&UnknownTask{
+				TaskInfo: TaskInfo{
+					Type:    "reshard",
+					PID:     "<0.6.0>",
+					RawTask: json.RawMessage(raw),
+				},
+			}},
+		}
+	})
+
+	tests.Run(t, func(t *testing.T, tt tst) {
+		tasks, err := tt.client.ActiveTasks(context.Background())
+		testy.StatusError(t, tt.err, tt.status, err)
+		if d := testy.DiffInterface(tt.expected, tasks); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
+func TestWrappedActiveTasks(t *testing.T) {
+	d := driver.Wrap("mock", &mock.Driver{
+		NewClientFunc: func(_ string, _ map[string]interface{}) (driver.Client, error) {
+			return &mock.Client{}, nil
+		},
+	})
+	c, err := d.NewClient("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &Client{driverClient: c}
+	_, err = client.ActiveTasks(context.Background())
+	testy.StatusError(t, "kivik: not supported by driver", http.StatusNotImplemented, err)
+}
@@ -102,7 +102,7 @@ func TestDBUpdatesIteratorNew(t *testing.T) {
 		},
 		updatesi: &mock.DBUpdates{},
 	}
-	u.cancel = nil // determinism
+	u.ctx, u.stop = nil, nil // determinism
 	if d := testy.DiffInterface(expected, u); d != nil {
 		t.Error(d)
 	}
@@ -222,7 +222,7 @@ func TestDBUpdates(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			result, err := test.client.DBUpdates(context.TODO())
 			testy.StatusError(t, test.err, test.status, err)
-			result.cancel = nil // Determinism
+			result.ctx, result.stop = nil, nil // Determinism
 			if d := testy.DiffInterface(test.expected, result); d != nil {
 				t.Error(d)
 			}